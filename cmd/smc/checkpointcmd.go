@@ -0,0 +1,86 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of Spectrum.
+//
+// Spectrum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Spectrum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Spectrum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/MeshBoxFoundation/meshbox/cmd/utils"
+	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/light"
+	"github.com/MeshBoxFoundation/meshbox/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var checkpointCommand = cli.Command{
+	Action:    utils.MigrateFlags(checkpoint),
+	Name:      "checkpoint",
+	Usage:     "Print the light sync trusted checkpoint of a synced full node",
+	ArgsUsage: "",
+	Category:  "DATABASE COMMANDS",
+	Flags: []cli.Flag{
+		utils.DataDirFlag,
+		utils.AncientFlag,
+	},
+	Description: `
+Computes the section index, CHT root and BloomTrie root of the latest section
+indexed by a full node serving light clients (--lightserv). The printed value
+can be given to light clients with --les.checkpoint, or hardcoded by pasting
+the printed entry into params.TrustedCheckpoints. The node must be stopped.`,
+}
+
+func checkpoint(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	chtIndexer := light.NewChtIndexer(db, false)
+	defer chtIndexer.Close()
+	bloomTrieIndexer := light.NewBloomTrieIndexer(db, false)
+	defer bloomTrieIndexer.Close()
+
+	cp, err := light.ServerCheckpoint(db, chtIndexer, bloomTrieIndexer)
+	if err != nil {
+		utils.Fatalf("Failed to compute checkpoint: %v", err)
+	}
+	fmt.Printf("Section index:  %d\n", cp.SectionIndex)
+	fmt.Printf("Section head:   %x\n", cp.SectionHead)
+	fmt.Printf("CHT root:       %x\n", cp.CHTRoot)
+	fmt.Printf("BloomTrie root: %x\n", cp.BloomTrieRoot)
+	fmt.Printf("Checkpoint:     %s\n", cp)
+
+	// print the entry to add to params.TrustedCheckpoints for the network
+	genesis := core.GetCanonicalHash(db, 0)
+	key, name := fmt.Sprintf("common.HexToHash(\"%x\")", genesis), "custom"
+	switch genesis {
+	case params.MainnetGenesisHash:
+		key, name = "MainnetGenesisHash", "mainnet"
+	case params.TestnetGenesisHash:
+		key, name = "TestnetGenesisHash", "testnet"
+	case params.DevnetGenesisHash:
+		key, name = "DevnetGenesisHash", "devnet"
+	}
+	fmt.Printf("\nparams.TrustedCheckpoints entry:\n\n")
+	fmt.Printf("\t%s: {\n", key)
+	fmt.Printf("\t\tName:          %q,\n", name)
+	fmt.Printf("\t\tSectionIndex:  %d,\n", cp.SectionIndex)
+	fmt.Printf("\t\tSectionHead:   common.HexToHash(\"%x\"),\n", cp.SectionHead)
+	fmt.Printf("\t\tCHTRoot:       common.HexToHash(\"%x\"),\n", cp.CHTRoot)
+	fmt.Printf("\t\tBloomTrieRoot: common.HexToHash(\"%x\"),\n", cp.BloomTrieRoot)
+	fmt.Printf("\t},\n")
+	return nil
+}
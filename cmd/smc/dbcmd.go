@@ -0,0 +1,208 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of Spectrum.
+//
+// Spectrum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Spectrum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Spectrum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/cmd/utils"
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/common/hexutil"
+	"github.com/MeshBoxFoundation/meshbox/console"
+	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level chain database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Inspect the storage size of each type of data in the database",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectDB),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+				},
+				Description: `
+Walks the whole chain database and reports the number of entries and the bytes
+taken by headers, bodies, receipts, tx lookup entries, trie nodes, bloombits and
+preimages, along with the size of the local transaction journal.`,
+			},
+			{
+				Name:      "compact",
+				Usage:     "Compact the entire database",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(compactDB),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+				},
+				Description: `
+Compacts the entire chain database, printing the LevelDB compaction stats before
+and after.`,
+			},
+			{
+				Name:      "get",
+				Usage:     "Show the value of a database key",
+				ArgsUsage: "<hex-encoded key>",
+				Action:    utils.MigrateFlags(dbGet),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.LightModeFlag,
+				},
+			},
+			{
+				Name:      "delete-prefix",
+				Usage:     "Delete all the keys with the given prefix",
+				ArgsUsage: "<hex-encoded prefix>",
+				Action:    utils.MigrateFlags(dbDeletePrefix),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.LightModeFlag,
+				},
+				Description: `
+Deletes every key starting with the given prefix. This is a destructive action,
+deleting the wrong prefix corrupts the chain database. It asks for confirmation
+before deleting anything.`,
+			},
+		},
+	}
+)
+
+func inspectDB(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	var (
+		start = time.Now()
+		total common.StorageSize
+		table = tablewriter.NewWriter(os.Stdout)
+	)
+	table.SetHeader([]string{"Category", "Entries", "Size"})
+	for _, stat := range core.InspectDatabase(db) {
+		table.Append([]string{stat.Name, fmt.Sprintf("%d", stat.Count), stat.Size.String()})
+		total += stat.Size
+	}
+	journal := "-"
+	if cfg.Eth.TxPool.Journal != "" {
+		if info, err := os.Stat(stack.ResolvePath(cfg.Eth.TxPool.Journal)); err == nil {
+			journal = common.StorageSize(info.Size()).String()
+		}
+	}
+	table.Append([]string{"Tx journal (file)", "-", journal})
+	table.SetFooter([]string{"", "Total (database)", total.String()})
+	table.Render()
+
+	log.Info("Database inspection done", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+func compactDB(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	stats, err := db.Stat("leveldb.stats")
+	if err != nil {
+		utils.Fatalf("Failed to read database stats: %v", err)
+	}
+	fmt.Println(stats)
+
+	start := time.Now()
+	fmt.Println("Compacting entire database...")
+	if err = db.Compact(nil, nil); err != nil {
+		utils.Fatalf("Compaction failed: %v", err)
+	}
+	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
+
+	stats, err = db.Stat("leveldb.stats")
+	if err != nil {
+		utils.Fatalf("Failed to read database stats: %v", err)
+	}
+	fmt.Println(stats)
+	return nil
+}
+
+func dbGet(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a key argument.")
+	}
+	key, err := hexutil.Decode(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Invalid key: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	value, err := db.Get(key)
+	if err != nil {
+		utils.Fatalf("Failed to read key %#x: %v", key, err)
+	}
+	fmt.Printf("%#x\n", value)
+	return nil
+}
+
+func dbDeletePrefix(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a prefix argument.")
+	}
+	prefix, err := hexutil.Decode(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Invalid prefix: %v", err)
+	}
+	if len(prefix) == 0 {
+		utils.Fatalf("Refusing to delete the entire database, use removedb instead.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	// Confirm removal and execute
+	confirm, err := console.Stdin.PromptConfirm(fmt.Sprintf("Delete all keys with prefix %#x?", prefix))
+	switch {
+	case err != nil:
+		utils.Fatalf("%v", err)
+	case !confirm:
+		log.Warn("Database prefix deletion aborted")
+		return nil
+	}
+	start := time.Now()
+	if err := db.DeleteRange(prefix, util.BytesPrefix(prefix).Limit); err != nil {
+		utils.Fatalf("Failed to delete prefix %#x: %v", prefix, err)
+	}
+	log.Info("Deleted database prefix", "prefix", hexutil.Encode(prefix), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -57,7 +57,9 @@ var (
 		//utils.BootnodesV4Flag,
 		//utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
 		utils.KeyStoreDirFlag,
+		utils.FastSyncCheckpointFlag,
 		/*
 			utils.EthashCacheDirFlag,
 			utils.EthashCachesInMemoryFlag,
@@ -83,6 +85,10 @@ var (
 
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.LightPriorityFlag,
+		utils.LightCheckpointFlag,
+		utils.ULCServersFlag,
+		utils.ULCFractionFlag,
 		utils.LightKDFFlag,
 
 
@@ -108,6 +114,11 @@ var (
 		*/
 		utils.CacheFlag,
 		utils.TrieCacheGenFlag,
+		utils.HistoryKeepFlag,
+		utils.TxLookupLimitFlag,
+		utils.TxLookupSkipChiefFlag,
+		utils.SnapshotFlag,
+		utils.LogIndexFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
@@ -167,6 +178,12 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See dbcmd.go:
+		dbCommand,
+		// See snapshotcmd.go:
+		snapshotCommand,
+		// See checkpointcmd.go:
+		checkpointCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -182,6 +199,8 @@ func init() {
 		versionCommand,
 		bugCommand,
 		licenseCommand,
+		// See simulatecmd.go:
+		simulateCommand,
 		// See config.go
 		dumpConfigCommand,
 		// add by liangc
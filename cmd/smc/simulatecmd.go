@@ -0,0 +1,234 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of Spectrum.
+//
+// Spectrum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Spectrum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Spectrum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/MeshBoxFoundation/meshbox/cmd/utils"
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/common/math"
+	"github.com/MeshBoxFoundation/meshbox/contracts/chief"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/params"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	simBlocksFlag = cli.Uint64Flag{
+		Name:  "blocks",
+		Usage: "Number of blocks to simulate",
+		Value: 6171 * 30,
+	}
+	simStartFlag = cli.Uint64Flag{
+		Name:  "start",
+		Usage: "First block to simulate (default = chief 1.0.0 fork block)",
+	}
+	simSeedFlag = cli.StringFlag{
+		Name:  "seed",
+		Usage: "Seed of the simulated vrf numbers and node uptimes",
+		Value: "meshbox",
+	}
+	simulateCommand = cli.Command{
+		Action:    utils.MigrateFlags(simulateRewards),
+		Name:      "simulate-rewards",
+		Usage:     "Simulate the signer selection and block rewards of a POC miner set",
+		ArgsUsage: "<spec.json>",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			simBlocksFlag,
+			simStartFlag,
+			simSeedFlag,
+			utils.TestnetFlag,
+			utils.DevnetFlag,
+		},
+		Description: `
+Replays the chief 1.0.0 contract for the leaders and POC miners described in the
+spec file, and reports for each address how often it was selected as a signer,
+the blocks it sealed, took over or missed, and the block rewards it received.
+The spec file looks like:
+
+    {
+        "leaders":      ["0x..."],
+        "leaderUptime": 10000,
+        "minDeposit":   "100000000000000000000000",
+        "signerLimit":  17,
+        "epoch":        6171,
+        "miners": [
+            {"address": "0x...", "owner": "0x...", "deposit": "200000000000000000000000", "uptime": 9900}
+        ]
+    }
+
+Deposits are in wei, uptimes in basis points (10000 when omitted), the owner is
+the account bound to the miner in anmap. Signers are picked uniformly from the
+POC normal list: a deposit above the minimum doesn't change the chance to be
+selected. Transaction fees aren't accounted for.`,
+	}
+)
+
+// simSpec is the JSON spec file of simulate-rewards.
+type simSpec struct {
+	Leaders      []common.Address      `json:"leaders"`
+	LeaderUptime *uint64               `json:"leaderUptime"`
+	MinDeposit   *math.HexOrDecimal256 `json:"minDeposit"`
+	SignerLimit  uint64                `json:"signerLimit"`
+	Epoch        uint64                `json:"epoch"`
+	Miners       []simSpecMiner        `json:"miners"`
+}
+
+type simSpecMiner struct {
+	Address common.Address        `json:"address"`
+	Owner   common.Address        `json:"owner"`
+	Deposit *math.HexOrDecimal256 `json:"deposit"`
+	Uptime  *uint64               `json:"uptime"`
+}
+
+func simulateRewards(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires the spec file as argument.")
+	}
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read spec file: %v", err)
+	}
+	defer file.Close()
+
+	var spec simSpec
+	if err := json.NewDecoder(file).Decode(&spec); err != nil {
+		utils.Fatalf("Invalid spec file: %v", err)
+	}
+	if len(spec.Leaders) == 0 {
+		utils.Fatalf("The spec needs at least one leader")
+	}
+	if spec.SignerLimit == 0 {
+		spec.SignerLimit = 17
+	}
+	if spec.Epoch == 0 {
+		spec.Epoch = 6171
+	}
+	config := params.MainnetChainConfig
+	switch {
+	case ctx.GlobalBool(utils.TestnetFlag.Name):
+		config = params.TestnetChainConfig
+	case ctx.GlobalBool(utils.DevnetFlag.Name):
+		config = params.DevnetChainConfig
+	}
+	cfg := &chief.SimConfig{
+		Chain:        config,
+		Leaders:      spec.Leaders,
+		LeaderUptime: simUptime(spec.LeaderUptime),
+		MinDeposit:   new(big.Int),
+		SignerLimit:  spec.SignerLimit,
+		Epoch:        spec.Epoch,
+		Start:        config.Chief100Block.Uint64(),
+		Blocks:       ctx.Uint64(simBlocksFlag.Name),
+		Seed:         crypto.Keccak256Hash([]byte(ctx.String(simSeedFlag.Name))),
+	}
+	if ctx.IsSet(simStartFlag.Name) {
+		cfg.Start = ctx.Uint64(simStartFlag.Name)
+	}
+	if spec.MinDeposit != nil {
+		cfg.MinDeposit = (*big.Int)(spec.MinDeposit)
+	}
+	deposits := make(map[common.Address]*big.Int)
+	for _, miner := range spec.Miners {
+		deposit := new(big.Int)
+		if miner.Deposit != nil {
+			deposit = (*big.Int)(miner.Deposit)
+		}
+		deposits[miner.Address] = deposit
+		cfg.Miners = append(cfg.Miners, &chief.SimMiner{
+			Address: miner.Address,
+			Owner:   miner.Owner,
+			Deposit: deposit,
+			Uptime:  simUptime(miner.Uptime),
+		})
+	}
+	stats := chief.Simulate(cfg)
+
+	// Report the addresses by income, then by selections
+	var (
+		addrs    []common.Address
+		selected uint64
+		income   = new(big.Int)
+	)
+	for addr, stat := range stats {
+		addrs = append(addrs, addr)
+		selected += stat.Selected
+		income.Add(income, stat.Income)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		a, b := stats[addrs[i]], stats[addrs[j]]
+		if c := a.Income.Cmp(b.Income); c != 0 {
+			return c > 0
+		}
+		if a.Selected != b.Selected {
+			return a.Selected > b.Selected
+		}
+		return addrs[i].Hex() < addrs[j].Hex()
+	})
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Address", "Deposit (SMT)", "Selected", "Share", "Sealed", "Taken over", "Missed", "Income (SMT)"})
+	for _, addr := range addrs {
+		stat, deposit := stats[addr], "-"
+		if amount, ok := deposits[addr]; ok {
+			deposit = simEther(amount)
+		}
+		table.Append([]string{
+			addr.Hex(),
+			deposit,
+			fmt.Sprintf("%d", stat.Selected),
+			simShare(stat.Selected, selected),
+			fmt.Sprintf("%d", stat.Sealed),
+			fmt.Sprintf("%d", stat.TakeOver),
+			fmt.Sprintf("%d", stat.Missed),
+			simEther(stat.Income),
+		})
+	}
+	table.SetFooter([]string{"", "", fmt.Sprintf("%d", selected), "", "", "", fmt.Sprintf("blocks %d-%d", cfg.Start, cfg.Start+cfg.Blocks-1), simEther(income)})
+	table.Render()
+	return nil
+}
+
+// simUptime returns the uptime given in a spec, full uptime if omitted.
+func simUptime(uptime *uint64) uint64 {
+	if uptime == nil {
+		return chief.FullUptime
+	}
+	return *uptime
+}
+
+// simEther formats a wei amount in ether with four decimals, truncated.
+func simEther(wei *big.Int) string {
+	units := new(big.Int).Div(wei, big.NewInt(params.Ether/10000))
+	whole, frac := new(big.Int).DivMod(units, big.NewInt(10000), new(big.Int))
+	return fmt.Sprintf("%v.%04d", whole, frac.Uint64())
+}
+
+// simShare formats part as a percentage of total with two decimals.
+func simShare(part, total uint64) string {
+	if total == 0 {
+		return "-"
+	}
+	basis := part * 10000 / total
+	return fmt.Sprintf("%d.%02d%%", basis/100, basis%100)
+}
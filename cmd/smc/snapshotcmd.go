@@ -0,0 +1,80 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of Spectrum.
+//
+// Spectrum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Spectrum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Spectrum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/MeshBoxFoundation/meshbox/cmd/utils"
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/common/hexutil"
+	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	snapshotCommand = cli.Command{
+		Name:      "snapshot",
+		Usage:     "Flat state snapshot operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:      "verify",
+				Usage:     "Check the snapshot against the state trie",
+				ArgsUsage: "[<hex-encoded state root>]",
+				Action:    utils.MigrateFlags(verifySnapshot),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.LightModeFlag,
+				},
+				Description: `
+Walks the state trie at the given root, the head state by default, and checks
+that the snapshot holds exactly the same accounts and storage slots. The node
+flattens the snapshot to the head state when it shuts down cleanly.`,
+			},
+		},
+	}
+)
+
+func verifySnapshot(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	var root common.Hash
+	if ctx.NArg() > 0 {
+		blob, err := hexutil.Decode(ctx.Args().First())
+		if err != nil || len(blob) != common.HashLength {
+			utils.Fatalf("Invalid state root: %s", ctx.Args().First())
+		}
+		root = common.BytesToHash(blob)
+	} else {
+		hash := core.GetHeadBlockHash(db)
+		header := core.GetHeader(db, hash, core.GetBlockNumber(db, hash))
+		if header == nil {
+			utils.Fatalf("Head block %x not found", hash)
+		}
+		root = header.Root
+	}
+	if err := snapshot.Verify(db, root); err != nil {
+		utils.Fatalf("Snapshot verification failed: %v", err)
+	}
+	log.Info("Snapshot matches the state trie", "root", root)
+	return nil
+}
@@ -67,12 +67,14 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "SPECTRUM",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.KeyStoreDirFlag,
 			utils.TestnetFlag,
 			utils.DevnetFlag,
 			utils.DevnetResetFlag,
 			utils.DevnetMasterFlag,
 			utils.EthStatsURLFlag,
+			utils.FastSyncCheckpointFlag,
 			/*
 				utils.DeveloperFlag,
 				utils.NetworkIdFlag,
@@ -82,6 +84,10 @@ var AppHelpFlagGroups = []flagGroup{
 				utils.SyncModeFlag,
 				utils.LightServFlag,
 				utils.LightPeersFlag,
+				utils.LightPriorityFlag,
+				utils.LightCheckpointFlag,
+				utils.ULCServersFlag,
+				utils.ULCFractionFlag,
 				utils.LightKDFFlag,
 			*/
 		},
@@ -134,6 +140,11 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.CacheFlag,
 			utils.TrieCacheGenFlag,
+			utils.HistoryKeepFlag,
+			utils.TxLookupLimitFlag,
+			utils.TxLookupSkipChiefFlag,
+			utils.SnapshotFlag,
+			utils.LogIndexFlag,
 		},
 	},
 	{
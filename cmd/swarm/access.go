@@ -0,0 +1,99 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of Spectrum.
+//
+// Spectrum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Spectrum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Spectrum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command access new pass/pk
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/MeshBoxFoundation/meshbox/cmd/utils"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	swarm "github.com/MeshBoxFoundation/meshbox/swarm/api/client"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func accessNewPass(ctx *cli.Context) {
+	hash := accessHashArg(ctx)
+	password := getPassPhrase("Please enter the passphrase protecting the content.", 0, utils.MakePasswordList(ctx))
+	client := swarm.NewClient(strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/"))
+	access, err := client.CreatePassAccess(hash, password)
+	if err != nil {
+		utils.Fatalf("Failed to protect %s: %s", hash, err)
+	}
+	fmt.Println(access)
+}
+
+func accessNewPK(ctx *cli.Context) {
+	hash := accessHashArg(ctx)
+	keysFile := ctx.String(SwarmAccessGrantKeysFlag.Name)
+	if keysFile == "" {
+		utils.Fatalf("Please supply the public keys to grant access to with --%s", SwarmAccessGrantKeysFlag.Name)
+	}
+	grantees, err := readGrantKeys(keysFile)
+	if err != nil {
+		utils.Fatalf("Failed to read the grantee keys: %s", err)
+	}
+	client := swarm.NewClient(strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/"))
+	access, err := client.CreatePKAccess(hash, grantees)
+	if err != nil {
+		utils.Fatalf("Failed to protect %s: %s", hash, err)
+	}
+	fmt.Println(access)
+}
+
+// readGrantKeys reads the hex encoded public keys, compressed or not, listed
+// one per line in the given file.
+func readGrantKeys(file string) ([]*ecdsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*ecdsa.PublicKey
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "0x")
+		if line == "" {
+			continue
+		}
+		b, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %s", line, err)
+		}
+		var key *ecdsa.PublicKey
+		if len(b) == 33 {
+			key, err = crypto.DecompressPubkey(b)
+		} else if key = crypto.ToECDSAPub(b); key.X == nil {
+			err = fmt.Errorf("invalid length %d", len(b))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %s", line, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func accessHashArg(ctx *cli.Context) string {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Please supply the hash of the manifest to protect as the only argument")
+	}
+	return args[0]
+}
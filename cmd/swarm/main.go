@@ -141,6 +141,18 @@ var (
 		Name:  "stdin",
 		Usage: "reads data to be uploaded from stdin",
 	}
+	SwarmUpEncryptFlag = cli.BoolFlag{
+		Name:  "encrypt",
+		Usage: "encrypts the uploaded data, the returned hash includes the decryption key",
+	}
+	SwarmPinRawFlag = cli.BoolFlag{
+		Name:  "raw",
+		Usage: "pin only the given content, not a manifest and the content it references",
+	}
+	SwarmAccessGrantKeysFlag = cli.StringFlag{
+		Name:  "grant-keys",
+		Usage: "file listing the hex encoded public keys to grant access to, one per line",
+	}
 	SwarmUploadMimeType = cli.StringFlag{
 		Name:  "mime",
 		Usage: "force mime type",
@@ -257,6 +269,92 @@ Removes a path from the manifest
 				},
 			},
 		},
+		{
+			Name:      "pin",
+			Usage:     "manage the content pinned in the local chunk database",
+			ArgsUsage: "pin COMMAND",
+			Description: `
+Pins content so that its chunks are never garbage collected from the local
+chunk database of the node. Content is pinned as a manifest along with all
+the content it references unless --raw is given.
+`,
+			Subcommands: []cli.Command{
+				{
+					Action:    pinAdd,
+					Name:      "add",
+					Usage:     "pin content in the local chunk database",
+					ArgsUsage: "<hash>",
+					Flags:     []cli.Flag{SwarmPinRawFlag},
+					Description: `
+Pins the content with the given hash, retrieving it if needed. Content pinned
+several times has to be unpinned as many times.
+`,
+				},
+				{
+					Action:    pinRemove,
+					Name:      "rm",
+					Usage:     "release a pin of content",
+					ArgsUsage: "<hash>",
+					Description: `
+Releases a pin of the content with the given hash, along with the content
+referenced by it if it was pinned as a manifest.
+`,
+				},
+				{
+					Action:    pinList,
+					Name:      "ls",
+					Usage:     "list the pinned content",
+					ArgsUsage: " ",
+					Description: `
+Lists the pinned content and reports the pinned size against the capacity of
+the local chunk database.
+`,
+				},
+			},
+		},
+		{
+			Name:      "access",
+			Usage:     "protect manifests with access control",
+			ArgsUsage: "access COMMAND",
+			Description: `
+Protects manifests so that only the parties they are granted to can open them.
+The manifest reference is wrapped in an access manifest, the hash of which is
+published instead. Only manifests uploaded with --encrypt can be protected, the
+chunks of others can be read by any node storing them.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:      "new",
+					Usage:     "create an access manifest",
+					ArgsUsage: "new COMMAND",
+					Subcommands: []cli.Command{
+						{
+							Action:    accessNewPass,
+							Name:      "pass",
+							Usage:     "protect a manifest with a passphrase",
+							ArgsUsage: "<hash>",
+							Description: `
+Protects the manifest with the given hash with a passphrase, read from the
+--password file or prompted for. The passphrase is then given as the password
+of HTTP basic authentication to open the manifest.
+`,
+						},
+						{
+							Action:    accessNewPK,
+							Name:      "pk",
+							Usage:     "grant a manifest to public keys",
+							ArgsUsage: "<hash>",
+							Flags:     []cli.Flag{SwarmAccessGrantKeysFlag},
+							Description: `
+Grants the manifest with the given hash to the public keys listed in the
+--grant-keys file. The nodes with the swarm account of one of the keys open
+the manifest.
+`,
+						},
+					},
+				},
+			},
+		},
 		{
 			Name:      "db",
 			Usage:     "manage the local chunk database",
@@ -360,6 +458,7 @@ DEPRECATED: use 'swarm db clean'.
 		SwarmWantManifestFlag,
 		SwarmUploadDefaultPath,
 		SwarmUpFromStdinFlag,
+		SwarmUpEncryptFlag,
 		SwarmUploadMimeType,
 		//deprecated flags
 		DeprecatedEthAPIFlag,
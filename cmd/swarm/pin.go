@@ -0,0 +1,75 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of Spectrum.
+//
+// Spectrum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Spectrum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Spectrum. If not, see <http://www.gnu.org/licenses/>.
+
+// Command pin add/rm/ls
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MeshBoxFoundation/meshbox/cmd/utils"
+	swarm "github.com/MeshBoxFoundation/meshbox/swarm/api/client"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func pinAdd(ctx *cli.Context) {
+	hash := pinHashArg(ctx)
+	client := swarm.NewClient(strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/"))
+	if err := client.Pin(hash, ctx.Bool(SwarmPinRawFlag.Name)); err != nil {
+		utils.Fatalf("Failed to pin %s: %s", hash, err)
+	}
+	fmt.Println(hash)
+}
+
+func pinRemove(ctx *cli.Context) {
+	hash := pinHashArg(ctx)
+	client := swarm.NewClient(strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/"))
+	if err := client.Unpin(hash); err != nil {
+		utils.Fatalf("Failed to unpin %s: %s", hash, err)
+	}
+	fmt.Println(hash)
+}
+
+func pinList(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		utils.Fatalf("Too many arguments - usage 'swarm pin ls'")
+	}
+	client := swarm.NewClient(strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/"))
+	list, err := client.ListPins()
+	if err != nil {
+		utils.Fatalf("Failed to list the pinned content: %s", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	fmt.Fprintln(w, "HASH\tPINS\tCHUNKS\tSIZE")
+	for _, pin := range list.Pins {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", pin.Key, pin.Count, pin.Chunks, pin.Size)
+	}
+	w.Flush()
+	fmt.Printf("\n%d bytes in %d pinned chunks, %d of %d chunks stored\n",
+		list.Stats.PinnedSize, list.Stats.PinnedChunks, list.Stats.Entries, list.Stats.Capacity)
+}
+
+func pinHashArg(ctx *cli.Context) string {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Please supply the hash of the content as the only argument")
+	}
+	return args[0]
+}
@@ -44,6 +44,7 @@ func upload(ctx *cli.Context) {
 		wantManifest = ctx.GlobalBoolT(SwarmWantManifestFlag.Name)
 		defaultPath  = ctx.GlobalString(SwarmUploadDefaultPath.Name)
 		fromStdin    = ctx.GlobalBool(SwarmUpFromStdinFlag.Name)
+		toEncrypt    = ctx.GlobalBool(SwarmUpEncryptFlag.Name)
 		mimeType     = ctx.GlobalString(SwarmUploadMimeType.Name)
 		client       = swarm.NewClient(bzzapi)
 		file         string
@@ -76,7 +77,7 @@ func upload(ctx *cli.Context) {
 			utils.Fatalf("Error opening file: %s", err)
 		}
 		defer f.Close()
-		hash, err := client.UploadRaw(f, f.Size)
+		hash, err := client.UploadRaw(f, f.Size, toEncrypt)
 		if err != nil {
 			utils.Fatalf("Upload failed: %s", err)
 		}
@@ -89,6 +90,12 @@ func upload(ctx *cli.Context) {
 		utils.Fatalf("Error opening file: %s", err)
 	}
 
+	// encrypted content is added to a new encrypted manifest
+	manifest := ""
+	if toEncrypt {
+		manifest = "encrypt"
+	}
+
 	// define a function which either uploads a directory or single file
 	// based on the type of the file being uploaded
 	var doUpload func() (hash string, err error)
@@ -97,7 +104,7 @@ func upload(ctx *cli.Context) {
 			if !recursive {
 				return "", errors.New("Argument is a directory and recursive upload is disabled")
 			}
-			return client.UploadDirectory(file, defaultPath, "")
+			return client.UploadDirectory(file, defaultPath, manifest)
 		}
 	} else {
 		doUpload = func() (string, error) {
@@ -110,7 +117,7 @@ func upload(ctx *cli.Context) {
 				mimeType = detectMimeType(file)
 			}
 			f.ContentType = mimeType
-			return client.Upload(f, "")
+			return client.Upload(f, manifest)
 		}
 	}
 	hash, err := doUpload()
@@ -115,6 +115,10 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for the frozen chain segments (default = inside chaindata)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -171,7 +175,11 @@ var (
 	}
 	FastSyncFlag = cli.BoolFlag{
 		Name:  "fast",
-		Usage: "Enable fast syncing through state downloads",
+		Usage: "Enable fast syncing through state downloads (tribe chains without --fast.checkpoint sync in full)",
+	}
+	FastSyncCheckpointFlag = cli.StringFlag{
+		Name:  "fast.checkpoint",
+		Usage: "Trusted block fast sync must pass through, required to fast sync tribe chains, with the only sealers accepted up to it. The pivot never passes it, so the blocks after it are imported in full and an old checkpoint makes fast sync a full sync (<number>:<hash>:<signer>,...)",
 	}
 	LightModeFlag = cli.BoolFlag{
 		Name:  "light",
@@ -180,7 +188,7 @@ var (
 	defaultSyncMode = eth.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("full" or "light(TODO:not yet)")`,
+		Usage: `Blockchain sync mode ("full", "fast" or "light(TODO:not yet)"), tribe chains without --fast.checkpoint sync in full`,
 		Value: &defaultSyncMode,
 	}
 
@@ -194,6 +202,23 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: 20,
 	}
+	LightPriorityFlag = cli.StringFlag{
+		Name:  "lightprio",
+		Usage: "JSON file of the LES clients served with priority (node IDs and capacities)",
+	}
+	LightCheckpointFlag = cli.StringFlag{
+		Name:  "les.checkpoint",
+		Usage: "Trusted checkpoint light sync starts from, as printed by the checkpoint command (<index>:<head>:<cht>:<bloomtrie>)",
+	}
+	ULCServersFlag = cli.StringFlag{
+		Name:  "ulc.servers",
+		Usage: "Comma separated enode URLs of the trusted LES servers, enables the ultra light client mode",
+	}
+	ULCFractionFlag = cli.IntFlag{
+		Name:  "ulc.fraction",
+		Usage: "Percentage of the trusted LES servers that must announce a head before it's accepted",
+		Value: 75,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -309,6 +334,26 @@ var (
 		Usage: "Megabytes of memory allocated to internal caching (min 16MB / database forced)",
 		Value: 128,
 	}
+	HistoryKeepFlag = cli.Uint64Flag{
+		Name:  "history.keep",
+		Usage: "Number of recent blocks to keep the bodies and receipts of, older ones are deleted (0 = keep all, min 1024)",
+	}
+	TxLookupLimitFlag = cli.Uint64Flag{
+		Name:  "txlookuplimit",
+		Usage: "Number of recent blocks to index the transactions of, older ones can't be looked up by hash (0 = index all)",
+	}
+	TxLookupSkipChiefFlag = cli.BoolFlag{
+		Name:  "txlookupskipchief",
+		Usage: "Don't index the chief-update system transactions (entries already in the index are kept)",
+	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Keep a flat snapshot of the state for faster state reads (generated in the background on first use)",
+	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Index the logs by contract address and first topic, and the chief, POC and anmap calls by method (enables tribe_getBindHistory)",
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
@@ -998,6 +1043,59 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// parseCheckpoint parses a trusted fast sync checkpoint given as
+// <number>:<hash>:<signer>,...
+func parseCheckpoint(value string) *downloader.Checkpoint {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) < 3 {
+		Fatalf("Option %q: want <number>:<hash>:<signers>, got %q", FastSyncCheckpointFlag.Name, value)
+	}
+	number, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		Fatalf("Option %q: invalid block number: %v", FastSyncCheckpointFlag.Name, err)
+	}
+	hash := common.FromHex(parts[1])
+	if len(hash) != common.HashLength {
+		Fatalf("Option %q: invalid block hash %q", FastSyncCheckpointFlag.Name, parts[1])
+	}
+	var signers []common.Address
+	for _, signer := range strings.Split(parts[2], ",") {
+		if !common.IsHexAddress(signer) {
+			Fatalf("Option %q: invalid signer address %q", FastSyncCheckpointFlag.Name, signer)
+		}
+		signers = append(signers, common.HexToAddress(signer))
+	}
+	return &downloader.Checkpoint{Number: number, Hash: common.BytesToHash(hash), Signers: signers}
+}
+
+// parseLightCheckpoint parses a trusted light sync checkpoint given as
+// <index>:<head>:<cht>:<bloomtrie>.
+func parseLightCheckpoint(value string) *params.TrustedCheckpoint {
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		Fatalf("Option %q: want <index>:<head>:<cht>:<bloomtrie>, got %q", LightCheckpointFlag.Name, value)
+	}
+	index, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		Fatalf("Option %q: invalid section index: %v", LightCheckpointFlag.Name, err)
+	}
+	var hashes [3]common.Hash
+	for i, part := range parts[1:] {
+		hash := common.FromHex(part)
+		if len(hash) != common.HashLength {
+			Fatalf("Option %q: invalid hash %q", LightCheckpointFlag.Name, part)
+		}
+		hashes[i] = common.BytesToHash(hash)
+	}
+	return &params.TrustedCheckpoint{
+		Name:          "user supplied",
+		SectionIndex:  index,
+		SectionHead:   hashes[0],
+		CHTRoot:       hashes[1],
+		BloomTrieRoot: hashes[2],
+	}
+}
+
 // SetEthConfig applies eth-related command line flags to the config.
 func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	// Avoid conflicting network flags
@@ -1019,12 +1117,27 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	case ctx.GlobalBool(LightModeFlag.Name):
 		cfg.SyncMode = downloader.LightSync
 	}
+	if ctx.GlobalIsSet(FastSyncCheckpointFlag.Name) {
+		cfg.FastSyncCheckpoint = parseCheckpoint(ctx.GlobalString(FastSyncCheckpointFlag.Name))
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightPriorityFlag.Name) {
+		cfg.LightPriorityFile = ctx.GlobalString(LightPriorityFlag.Name)
+	}
+	if ctx.GlobalIsSet(LightCheckpointFlag.Name) {
+		cfg.LightCheckpoint = parseLightCheckpoint(ctx.GlobalString(LightCheckpointFlag.Name))
+	}
+	if ctx.GlobalIsSet(ULCServersFlag.Name) {
+		cfg.ULC = &eth.ULCConfig{
+			TrustedServers:     strings.Split(ctx.GlobalString(ULCServersFlag.Name), ","),
+			MinTrustedFraction: ctx.GlobalInt(ULCFractionFlag.Name),
+		}
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1033,6 +1146,24 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name)
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+	if ctx.GlobalIsSet(HistoryKeepFlag.Name) {
+		cfg.HistoryKeep = ctx.GlobalUint64(HistoryKeepFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
+		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxLookupSkipChiefFlag.Name) {
+		cfg.TxLookupSkipChief = ctx.GlobalBool(TxLookupSkipChiefFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapshotFlag.Name) {
+		cfg.Snapshot = ctx.GlobalBool(SnapshotFlag.Name)
+	}
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
@@ -1089,7 +1220,10 @@ func RegisterEthService(stack *node.Node, cfg *eth.Config) {
 		err = stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 			fullNode, err := eth.New(ctx, cfg)
 			if fullNode != nil && cfg.LightServ > 0 {
-				ls, _ := les.NewLesServer(fullNode, cfg)
+				ls, err := les.NewLesServer(fullNode, cfg)
+				if err != nil {
+					return nil, err
+				}
 				fullNode.AddLesServer(ls)
 			}
 			return fullNode, err
@@ -1103,7 +1237,11 @@ func RegisterEthService(stack *node.Node, cfg *eth.Config) {
 // RegisterDashboardService adds a dashboard to the stack.
 func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config) {
 	stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
-		return dashboard.New(cfg)
+		// Retrieve the full node, the dashboard only shows the host data without it
+		var ethServ *eth.Ethereum
+		ctx.Service(&ethServ)
+
+		return dashboard.New(cfg, ethServ)
 	})
 }
 
@@ -1153,6 +1291,16 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	// The full chain keeps its old blocks in the ancient store
+	if root := stack.ResolvePath(name); root != "" && !ctx.GlobalBool(LightModeFlag.Name) {
+		freezer := filepath.Join(root, "ancient")
+		if ctx.GlobalIsSet(AncientFlag.Name) {
+			freezer = stack.ResolvePath(ctx.GlobalString(AncientFlag.Name))
+		}
+		if chainDb, err = core.NewDatabaseWithFreezer(chainDb, freezer, eth.DefaultConfig.DatabaseFreezerDistance); err != nil {
+			Fatalf("Could not open ancient database: %v", err)
+		}
+	}
 	return chainDb
 }
 
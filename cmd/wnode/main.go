@@ -21,9 +21,9 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/sha512"
-	"encoding/binary"
 	"encoding/hex"
 	"flag"
 	"fmt"
@@ -43,12 +43,15 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
 	"github.com/MeshBoxFoundation/meshbox/p2p/nat"
 	"github.com/MeshBoxFoundation/meshbox/whisper/mailserver"
-	whisper "github.com/MeshBoxFoundation/meshbox/whisper/whisperv5"
+	whisper "github.com/MeshBoxFoundation/meshbox/whisper/whisperv6"
 	"golang.org/x/crypto/pbkdf2"
 )
 
 const quitCommand = "~Q"
 
+// mailRequestTopic is the topic of the mail requests and their responses
+var mailRequestTopic = whisper.BytesToTopic([]byte("mail"))
+
 // singletons
 var (
 	server     *p2p.Server
@@ -213,7 +216,6 @@ func initialize() {
 
 		shh = whisper.New(cfg)
 		shh.RegisterServer(&mailServer)
-		mailServer.Init(shh, *argDBPath, msPassword, *argServerPoW)
 	} else {
 		shh = whisper.New(cfg)
 	}
@@ -254,6 +256,11 @@ func initialize() {
 		}
 	}
 
+	if *mailServerMode {
+		// the responses are signed with the node key
+		mailServer.Init(shh, *argDBPath, msPassword, *argServerPoW, nodeid)
+	}
+
 	maxPeers := 80
 	if *bootstrapMode {
 		maxPeers = 800
@@ -263,7 +270,7 @@ func initialize() {
 		Config: p2p.Config{
 			PrivateKey:     nodeid,
 			MaxPeers:       maxPeers,
-			Name:           common.MakeName("wnode", "5.0"),
+			Name:           common.MakeName("wnode", "6.0"),
 			Protocols:      shh.Protocols(),
 			ListenAddr:     *argIP,
 			NAT:            nat.Any(),
@@ -589,9 +596,7 @@ func writeMessageToFile(dir string, msg *whisper.ReceivedMessage) {
 
 func requestExpiredMessagesLoop() {
 	var key, peerID []byte
-	var timeLow, timeUpp uint32
-	var t string
-	var xt, empty whisper.TopicType
+	var req *mailserver.MailRequest
 
 	keyID, err := shh.AddSymKeyFromPassword(msPassword)
 	if err != nil {
@@ -604,34 +609,48 @@ func requestExpiredMessagesLoop() {
 	peerID = extractIdFromEnode(*argEnode)
 	shh.AllowP2PMessagesFromPeer(peerID)
 
+	// the mail server responds under the topic of the request, encrypted with
+	// the mail server key
+	responses := &whisper.Filter{
+		KeySym:   key,
+		Topics:   [][]byte{mailRequestTopic[:]},
+		AllowP2P: true,
+	}
+	if _, err := shh.Subscribe(responses); err != nil {
+		utils.Fatalf("Failed to install the mail server response filter: %s", err)
+	}
+
 	for {
-		timeLow = scanUint("Please enter the lower limit of the time range (unix timestamp): ")
-		timeUpp = scanUint("Please enter the upper limit of the time range (unix timestamp): ")
-		t = scanLine("Please enter the topic (hexadecimal): ")
-		if len(t) >= whisper.TopicLength*2 {
-			x, err := hex.DecodeString(t)
-			if err != nil {
-				utils.Fatalf("Failed to parse the topic: %s", err)
+		if req == nil {
+			req = &mailserver.MailRequest{
+				Lower: scanUint("Please enter the lower limit of the time range (unix timestamp): "),
+				Upper: scanUint("Please enter the upper limit of the time range (unix timestamp): "),
 			}
-			xt = whisper.BytesToTopic(x)
-		}
-		if timeUpp == 0 {
-			timeUpp = 0xFFFFFFFF
-		}
-
-		data := make([]byte, 8+whisper.TopicLength)
-		binary.BigEndian.PutUint32(data, timeLow)
-		binary.BigEndian.PutUint32(data[4:], timeUpp)
-		copy(data[8:], xt[:])
-		if xt == empty {
-			data = data[:8]
+			if req.Upper == 0 {
+				req.Upper = 0xFFFFFFFF
+			}
+			t := scanLine("Please enter the topics (hexadecimal, separated by spaces, empty for all): ")
+			for _, field := range strings.Fields(t) {
+				x, err := hex.DecodeString(field)
+				if err != nil || len(x) != whisper.TopicLength {
+					utils.Fatalf("Failed to parse the topic %q", field)
+				}
+				if req.Bloom == nil {
+					req.Bloom = make([]byte, whisper.BloomFilterSize)
+				}
+				for i, b := range whisper.TopicToBloom(whisper.BytesToTopic(x)) {
+					req.Bloom[i] |= b
+				}
+			}
+			req.Limit = scanUint("Please enter the maximum number of messages per page (0 for no limit): ")
 		}
 
 		var params whisper.MessageParams
 		params.PoW = *argServerPoW
-		params.Payload = data
+		params.Payload = req.Payload()
 		params.KeySym = key
 		params.Src = nodeid
+		params.Topic = mailRequestTopic
 		params.WorkTime = 5
 
 		msg, err := whisper.NewSentMessage(&params)
@@ -648,7 +667,35 @@ func requestExpiredMessagesLoop() {
 			utils.Fatalf("Failed to send P2P message: %s", err)
 		}
 
-		time.Sleep(time.Second * 5)
+		req.Cursor = waitMailResponse(responses, env.Hash())
+		if len(req.Cursor) == 0 || scanLine("More messages are available, request the next page? (y/n) ") != "y" {
+			req = nil
+		}
+	}
+}
+
+// waitMailResponse waits for the response of the mail server to the request
+// with the given hash and returns the cursor of the next page.
+func waitMailResponse(f *whisper.Filter, request common.Hash) []byte {
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case <-timeout:
+			fmt.Println("No response from the mail server")
+			return nil
+		case <-time.After(time.Millisecond * 50):
+			for _, msg := range f.Retrieve() {
+				res, err := mailserver.ParseMailResponse(msg.Payload)
+				if err != nil || res.RequestHash != request {
+					continue
+				}
+				if msg.Src == nil || !bytes.Equal(crypto.FromECDSAPub(msg.Src)[1:], extractIdFromEnode(*argEnode)) {
+					fmt.Println("Ignoring a mail server response not signed by the server")
+					continue
+				}
+				return res.Cursor
+			}
+		}
 	}
 }
 
@@ -220,11 +220,58 @@ func (api *API) GetMiner(number *rpc.BlockNumber) (*TribeMiner, error) {
 	return &TribeMiner{add, b, api.tribe.Status.SignerLevel}, nil
 }
 
+// GetMyStatus reports the level of this node in the current round, its
+// position in the signer list and the next block it should seal in turn.
+func (api *API) GetMyStatus() (*TribeMyStatus, error) {
+	header := api.chain.CurrentHeader()
+	status, err := api.loadHistoryChiefStatus(header.Hash(), header.Number)
+	if err != nil {
+		return nil, err
+	}
+	miner := api.tribe.Status.GetMinerAddress()
+	my := &TribeMyStatus{
+		Address:     miner,
+		Level:       status.SignerLevel,
+		SignerIndex: -1,
+		Leader:      status.IsLeader(miner),
+		Number:      header.Number.Int64(),
+		Period:      api.tribe.config.Period,
+	}
+	if params.IsSIP002Block(new(big.Int).Add(header.Number, big.NewInt(1))) {
+		// in turn signers wait Period-1 seconds, see GetPeriod
+		my.Period = api.tribe.config.Period - 1
+	}
+	for i, s := range status.Signers {
+		if s.Address == miner {
+			my.SignerIndex = i
+			break
+		}
+	}
+	my.NextSlot = nextInTurn(my.Number, my.SignerIndex, len(status.Signers))
+	return my, nil
+}
+
+// nextInTurn returns the first block after number sealed in turn by the signer
+// at index idx of a signer list of the given size, 0 if there is none.
+func nextInTurn(number int64, idx, size int) int64 {
+	if idx < 0 || idx >= size {
+		return 0
+	}
+	next, sl := number+1, int64(size)
+	return next + (int64(idx)-next%sl+sl)%sl
+}
+
 // chief-0.0.3 show blacklist
 func (api *API) GetSinners(hash *common.Hash) ([]common.Address, error) {
 	return api.tribe.Status.blackList, nil
 }
 
+// GetEquivocations returns the double-sign and out-of-turn evidences observed
+// by this node, optionally filtered by signer.
+func (api *API) GetEquivocations(signer *common.Address) []*Equivocation {
+	return api.tribe.Equivocations(signer)
+}
+
 func (api *API) GetSigners(hash *common.Hash) ([]*Signer, error) {
 	header := api.chain.CurrentHeader()
 
@@ -0,0 +1,162 @@
+package tribe
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/common/hexutil"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/params"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// EquivocationDoubleSign : 同一个signer在同一高度签了两个不同的块
+	EquivocationDoubleSign = "double-sign"
+	// EquivocationOutOfTurn : 轮值signer已经出块,常委会节点仍然在同一高度替补出块
+	EquivocationOutOfTurn = "out-of-turn"
+
+	evidenceLimit = 256 // Number of equivocation evidences kept in memory
+)
+
+// Equivocation is a self-contained evidence bundle proving that Signer sealed
+// a header which conflicts with another header at the same height. Headers[0]
+// is the header seen first, Headers[1] the conflicting one; RLP carries their
+// consensus encodings so the bundle can be verified without a local chain.
+type Equivocation struct {
+	Kind     string           `json:"kind"`
+	Signer   common.Address   `json:"signer"`
+	Number   uint64           `json:"number"`
+	Headers  [2]*types.Header `json:"headers"`
+	RLP      [2]hexutil.Bytes `json:"rlp"`
+	Detected int64            `json:"detected"`
+}
+
+// EquivocationEvent is posted when a conflicting seal has been detected.
+type EquivocationEvent struct{ Evidence *Equivocation }
+
+func newEquivocation(kind string, signer common.Address, first, second *types.Header) *Equivocation {
+	e := &Equivocation{
+		Kind:     kind,
+		Signer:   signer,
+		Number:   second.Number.Uint64(),
+		Headers:  [2]*types.Header{first, second},
+		Detected: time.Now().Unix(),
+	}
+	for i, h := range e.Headers {
+		enc, err := rlp.EncodeToBytes(h)
+		if err != nil {
+			panic(err)
+		}
+		e.RLP[i] = enc
+	}
+	return e
+}
+
+type evidenceKey struct {
+	kind   string
+	signer common.Address
+	number uint64
+}
+
+// equivocationIndex keeps a bounded (number -> signer -> header) index of all
+// verified headers, canonical or not, and the evidences found on it.
+type equivocationIndex struct {
+	seen      *lru.ARCCache // number -> map[common.Address]*types.Header
+	evidences *lru.ARCCache // evidenceKey -> *Equivocation
+	lock      sync.Mutex
+}
+
+func newEquivocationIndex() *equivocationIndex {
+	seen, err := lru.NewARC(historyLimit)
+	if err != nil {
+		panic(err)
+	}
+	evidences, err := lru.NewARC(evidenceLimit)
+	if err != nil {
+		panic(err)
+	}
+	return &equivocationIndex{seen: seen, evidences: evidences}
+}
+
+// isMainTurn : chief1.0.0 以后难度为6的块是轮值signer出的块
+func isMainTurn(header *types.Header) bool {
+	return header.Difficulty != nil && header.Difficulty.Cmp(big.NewInt(diff)) == 0
+}
+
+// add records that signer sealed header and returns the evidences newly found.
+// isLeader reports whether an address belongs to the leader set at the height
+// of header.
+func (self *equivocationIndex) add(signer common.Address, header *types.Header, isLeader func(common.Address) bool) []*Equivocation {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var (
+		number  = header.Number.Uint64()
+		hash    = header.Hash()
+		sealers map[common.Address]*types.Header
+		found   []*Equivocation
+	)
+	if v, ok := self.seen.Get(number); ok {
+		sealers = v.(map[common.Address]*types.Header)
+	} else {
+		sealers = make(map[common.Address]*types.Header)
+		self.seen.Add(number, sealers)
+	}
+	if prev, ok := sealers[signer]; ok {
+		if prev.Hash() != hash {
+			found = self.report(found, newEquivocation(EquivocationDoubleSign, signer, prev, header))
+		}
+		return found
+	}
+	if params.IsSIP100Block(header.Number) {
+		for other, h := range sealers {
+			switch {
+			case isMainTurn(h) && !isMainTurn(header) && isLeader(signer):
+				found = self.report(found, newEquivocation(EquivocationOutOfTurn, signer, h, header))
+			case isMainTurn(header) && !isMainTurn(h) && isLeader(other):
+				found = self.report(found, newEquivocation(EquivocationOutOfTurn, other, header, h))
+			}
+		}
+	}
+	sealers[signer] = header
+	return found
+}
+
+func (self *equivocationIndex) report(found []*Equivocation, e *Equivocation) []*Equivocation {
+	key := evidenceKey{e.Kind, e.Signer, e.Number}
+	if self.evidences.Contains(key) {
+		return found
+	}
+	self.evidences.Add(key, e)
+	return append(found, e)
+}
+
+// list returns the known evidences ordered by block number, optionally
+// filtered by signer.
+func (self *equivocationIndex) list(signer *common.Address) []*Equivocation {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	rtn := make([]*Equivocation, 0)
+	for _, k := range self.evidences.Keys() {
+		v, ok := self.evidences.Peek(k)
+		if !ok {
+			continue
+		}
+		if e := v.(*Equivocation); signer == nil || e.Signer == *signer {
+			rtn = append(rtn, e)
+		}
+	}
+	sort.Slice(rtn, func(i, j int) bool {
+		if rtn[i].Number == rtn[j].Number {
+			return rtn[i].Signer.Hex() < rtn[j].Signer.Hex()
+		}
+		return rtn[i].Number < rtn[j].Number
+	})
+	return rtn
+}
@@ -0,0 +1,141 @@
+package tribe
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/params"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+)
+
+func sealedHeader(t *testing.T, key *ecdsa.PrivateKey, number int64, difficulty int64, time int64) *types.Header {
+	header := &types.Header{
+		Number:     big.NewInt(number),
+		Difficulty: big.NewInt(difficulty),
+		Time:       big.NewInt(time),
+		GasLimit:   big.NewInt(0),
+		GasUsed:    big.NewInt(0),
+		Extra:      make([]byte, extraVanityFn(big.NewInt(number))+extraSeal),
+	}
+	sig, err := crypto.Sign(sigHash(header).Bytes(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+	return header
+}
+
+func newTestTribe(leaders ...common.Address) *Tribe {
+	t := &Tribe{Status: NewTribeStatus(), equivocations: newEquivocationIndex()}
+	t.Status.Leaders = leaders
+	t.chiefStatus = func(number *big.Int, hash common.Hash) (params.ChiefStatus, error) {
+		return params.ChiefStatus{LeaderList: leaders}, nil
+	}
+	t.Status.SetTribe(t)
+	return t
+}
+
+func TestEquivocationDoubleSign(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	tribe := newTestTribe()
+
+	events := make(chan EquivocationEvent, 4)
+	sub := tribe.SubscribeEquivocationEvent(events)
+	defer sub.Unsubscribe()
+
+	first := sealedHeader(t, key, 100, 2, 1000)
+	tribe.recordSealer(first)
+	tribe.recordSealer(first)
+	if n := len(tribe.Equivocations(nil)); n != 0 {
+		t.Fatalf("evidence count mismatch: have %d, want 0", n)
+	}
+	second := sealedHeader(t, key, 100, 2, 1001)
+	tribe.recordSealer(second)
+	tribe.recordSealer(second)
+
+	evidences := tribe.Equivocations(nil)
+	if len(evidences) != 1 {
+		t.Fatalf("evidence count mismatch: have %d, want 1", len(evidences))
+	}
+	e := evidences[0]
+	if e.Kind != EquivocationDoubleSign || e.Signer != signer || e.Number != 100 {
+		t.Fatalf("evidence mismatch: %v %x %d", e.Kind, e.Signer, e.Number)
+	}
+	if e.Headers[0].Hash() != first.Hash() || e.Headers[1].Hash() != second.Hash() {
+		t.Fatal("evidence headers mismatch")
+	}
+	for i, enc := range e.RLP {
+		h := new(types.Header)
+		if err := rlp.DecodeBytes(enc, h); err != nil {
+			t.Fatal(err)
+		}
+		if signed, _ := ecrecover(h, newTestTribe()); signed != signer {
+			t.Fatalf("header %d not signed by offender: %x", i, signed)
+		}
+	}
+	select {
+	case ev := <-events:
+		if ev.Evidence != e {
+			t.Fatal("event evidence mismatch")
+		}
+	default:
+		t.Fatal("no equivocation event")
+	}
+	other := common.HexToAddress("0x1")
+	if n := len(tribe.Equivocations(&other)); n != 0 {
+		t.Fatalf("filtered evidence count mismatch: have %d, want 0", n)
+	}
+}
+
+func TestEquivocationOutOfTurn(t *testing.T) {
+	mainKey, _ := crypto.GenerateKey()
+	leaderKey, _ := crypto.GenerateKey()
+	volunteerKey, _ := crypto.GenerateKey()
+	leader := crypto.PubkeyToAddress(leaderKey.PublicKey)
+	tribe := newTestTribe(leader)
+
+	number := int64(10000000)
+	tribe.recordSealer(sealedHeader(t, mainKey, number, diff, 1000))
+	tribe.recordSealer(sealedHeader(t, volunteerKey, number, diff-1, 1004))
+	if n := len(tribe.Equivocations(nil)); n != 0 {
+		t.Fatalf("evidence count mismatch: have %d, want 0", n)
+	}
+	tribe.recordSealer(sealedHeader(t, leaderKey, number, diff-2, 1008))
+	evidences := tribe.Equivocations(nil)
+	if len(evidences) != 1 {
+		t.Fatalf("evidence count mismatch: have %d, want 1", len(evidences))
+	}
+	if e := evidences[0]; e.Kind != EquivocationOutOfTurn || e.Signer != leader || e.Headers[0].Difficulty.Int64() != diff {
+		t.Fatalf("evidence mismatch: %v %x", e.Kind, e.Signer)
+	}
+}
+
+func TestEquivocationOutOfTurnLeadersAtHeight(t *testing.T) {
+	mainKey, _ := crypto.GenerateKey()
+	leaderKey, _ := crypto.GenerateKey()
+	formerKey, _ := crypto.GenerateKey()
+	leader := crypto.PubkeyToAddress(leaderKey.PublicKey)
+	former := crypto.PubkeyToAddress(formerKey.PublicKey)
+
+	// The current leader set differs from the one at the sealed heights.
+	tribe := newTestTribe(leader)
+	tribe.chiefStatus = func(number *big.Int, hash common.Hash) (params.ChiefStatus, error) {
+		return params.ChiefStatus{LeaderList: []common.Address{former}}, nil
+	}
+	number := int64(10000000)
+	tribe.recordSealer(sealedHeader(t, mainKey, number, diff, 1000))
+	tribe.recordSealer(sealedHeader(t, leaderKey, number, diff-1, 1004))
+	if n := len(tribe.Equivocations(nil)); n != 0 {
+		t.Fatalf("evidence count mismatch: have %d, want 0", n)
+	}
+	tribe.recordSealer(sealedHeader(t, formerKey, number, diff-2, 1008))
+	evidences := tribe.Equivocations(nil)
+	if len(evidences) != 1 || evidences[0].Signer != former {
+		t.Fatalf("evidence mismatch: have %d evidences", len(evidences))
+	}
+}
@@ -239,6 +239,34 @@ func leaderSort(first common.Address, list []common.Address) ([]common.Address,
 	return list, nil
 }
 
+// TakeoverOrder returns the addresses allowed to seal the block with the given
+// number under chief 1.0.0, the preferred one first: the signer in turn, the
+// leader of the round, then the other leaders as sorted by leaderSort. It is the
+// order of the periods assigned by GetPeriodChief100. Empty slots are skipped.
+func TakeoverOrder(number int64, signers, leaders []common.Address) []common.Address {
+	if len(signers) == 0 {
+		return nil
+	}
+	var (
+		order []common.Address
+		seen  = make(map[common.Address]bool)
+	)
+	add := func(addr common.Address) {
+		if addr != (common.Address{}) && !seen[addr] {
+			seen[addr] = true
+			order = append(order, addr)
+		}
+	}
+	add(signers[number%int64(len(signers))])
+	add(signers[0])
+
+	others, _ := leaderSort(signers[0], leaders)
+	for _, leader := range others {
+		add(leader)
+	}
+	return order
+}
+
 //InTurnForCalcDifficulty 在0.6版本yiqian 计算难度
 func (self *TribeStatus) InTurnForCalcDifficulty(signer common.Address, parent *types.Header) *big.Int {
 	number := parent.Number.Int64() + 1
@@ -1,8 +1,13 @@
 package tribe
 
 import (
-	"github.com/MeshBoxFoundation/meshbox/common"
+	"math/big"
+	"reflect"
 	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
 )
 
 var (
@@ -56,3 +61,99 @@ func TestLeaderSort(t *testing.T) {
 	t.Log(list)
 	t.Log(r)
 }
+
+// Tests that the sealers of a block are ordered like the chief 1.0.0 periods:
+// signer in turn, round leader, then the leaders following it.
+func TestTakeoverOrder(t *testing.T) {
+	var (
+		l1, l2, l3 = common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+		s1, s2     = common.HexToAddress("0x11"), common.HexToAddress("0x12")
+		leaders    = []common.Address{l1, l2, l3}
+		signers    = []common.Address{l2, s1, {}, s2}
+	)
+	tests := []struct {
+		number int64
+		want   []common.Address
+	}{
+		{8, []common.Address{l2, l3, l1}},
+		{9, []common.Address{s1, l2, l3, l1}},
+		{10, []common.Address{l2, l3, l1}},
+		{11, []common.Address{s2, l2, l3, l1}},
+	}
+	for i, tt := range tests {
+		if have := TakeoverOrder(tt.number, signers, leaders); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: order mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+	if have := TakeoverOrder(1, nil, leaders); have != nil {
+		t.Errorf("order without signers: have %x, want none", have)
+	}
+}
+
+func TestNextInTurn(t *testing.T) {
+	tests := []struct {
+		number    int64
+		idx, size int
+		want      int64
+	}{
+		{10, 11, 17, 11},
+		{10, 3, 17, 20},
+		{10, 10, 17, 27},
+		{16, 0, 17, 17},
+		{10, -1, 17, 0},
+		{10, 0, 0, 0},
+	}
+	for i, tt := range tests {
+		if have := nextInTurn(tt.number, tt.idx, tt.size); have != tt.want {
+			t.Errorf("test %d: next slot mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
+
+func TestSyncPivot(t *testing.T) {
+	tribe := new(Tribe)
+	header := &types.Header{Number: big.NewInt(100)}
+	if tribe.belowSyncPivot(header) {
+		t.Fatalf("header below pivot without fast sync")
+	}
+	tribe.SetSyncPivot(100)
+	if tribe.belowSyncPivot(header) {
+		t.Fatalf("pivot header treated as below pivot")
+	}
+	tribe.SetSyncPivot(101)
+	if !tribe.belowSyncPivot(header) {
+		t.Fatalf("header not below pivot")
+	}
+	tribe.SetSyncPivot(0)
+	if tribe.belowSyncPivot(header) {
+		t.Fatalf("header below pivot after sync")
+	}
+}
+
+func TestCheckpointSealer(t *testing.T) {
+	signerKey, _ := crypto.GenerateKey()
+	forgerKey, _ := crypto.GenerateKey()
+	tribe := newTestTribe()
+
+	// without checkpoint signers no header below the pivot is accepted
+	header := sealedHeader(t, signerKey, 100, 2, 1000)
+	if err := tribe.verifyCheckpointSealer(header); err != errUnauthorized {
+		t.Fatalf("error mismatch without checkpoint signers: have %v, want %v", err, errUnauthorized)
+	}
+	forged := sealedHeader(t, forgerKey, 100, 2, 1000)
+	tribe.SetCheckpointSigners([]common.Address{crypto.PubkeyToAddress(signerKey.PublicKey)})
+
+	unsealed := sealedHeader(t, signerKey, 100, 2, 1000)
+	for i := len(unsealed.Extra) - extraSeal; i < len(unsealed.Extra); i++ {
+		unsealed.Extra[i] = 0xff
+	}
+	if err := tribe.verifyCheckpointSealer(unsealed); err == nil {
+		t.Fatalf("header with an invalid seal accepted")
+	}
+	if err := tribe.verifyCheckpointSealer(header); err != nil {
+		t.Fatalf("checkpoint signer rejected: %v", err)
+	}
+	if err := tribe.verifyCheckpointSealer(forged); err != errUnauthorized {
+		t.Fatalf("error mismatch for forged sealer: have %v, want %v", err, errUnauthorized)
+	}
+}
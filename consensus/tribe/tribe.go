@@ -7,6 +7,8 @@ import (
 	"errors"
 	"math/big"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"crypto/ecdsa"
@@ -23,6 +25,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/crypto/sha3"
 	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/event"
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/params"
 	"github.com/MeshBoxFoundation/meshbox/rlp"
@@ -120,10 +123,12 @@ func New(accman *accounts.Manager, config *params.TribeConfig, _ ethdb.Database)
 		conf.Period = blockPeriod
 	}
 	tribe := &Tribe{
-		accman:   accman,
-		config:   &conf,
-		Status:   status,
-		sigcache: sigcache,
+		accman:        accman,
+		config:        &conf,
+		Status:        status,
+		sigcache:      sigcache,
+		equivocations: newEquivocationIndex(),
+		chiefStatus:   params.TribeGetStatus,
 	}
 	status.SetTribe(tribe)
 	return tribe
@@ -176,6 +181,7 @@ func (t *Tribe) VerifyHeader(chain consensus.ChainReader, header *types.Header,
 	if err == nil {
 		//p := chain.GetHeaderByHash(header.ParentHash)
 		//t.Status.LoadSignersFromChief(p.Hash(), p.Number)
+		t.recordSealer(header)
 	}
 	return err
 }
@@ -190,6 +196,9 @@ func (t *Tribe) VerifyHeaders(chain consensus.ChainReader, headers []*types.Head
 	go func() {
 		for i, header := range headers {
 			err := t.verifyHeader(chain, header, headers[:i])
+			if err == nil {
+				t.recordSealer(header)
+			}
 			select {
 			case <-abort:
 				return
@@ -200,6 +209,87 @@ func (t *Tribe) VerifyHeaders(chain consensus.ChainReader, headers []*types.Head
 	return abort, results
 }
 
+// recordSealer indexes the signer of a verified header and reports every
+// equivocation it reveals, so that the evidence can later be exported through
+// tribe_getEquivocations and used to blacklist the offender.
+func (t *Tribe) recordSealer(header *types.Header) {
+	if t.equivocations == nil || header.Number.Sign() == 0 {
+		return
+	}
+	// the leaders of old rounds are unknown, avoid false out-of-turn reports
+	if t.belowSyncPivot(header) {
+		return
+	}
+	signer, err := ecrecover(header, t)
+	if err != nil {
+		return
+	}
+	for _, e := range t.equivocations.add(signer, header, t.leadersAt(header)) {
+		log.Warn("Tribe equivocation detected", "kind", e.Kind, "signer", e.Signer.Hex(), "number", e.Number,
+			"first", e.Headers[0].Hash().Hex(), "second", e.Headers[1].Hash().Hex())
+		t.equivocationFeed.Send(EquivocationEvent{Evidence: e})
+	}
+}
+
+// leadersAt returns a function reporting whether an address belongs to the
+// leader set at the height of the header, which is loaded from the chief
+// status on top of its parent on first use. If the status is unavailable no
+// address is reported as a leader.
+func (t *Tribe) leadersAt(header *types.Header) func(common.Address) bool {
+	var (
+		once    sync.Once
+		leaders = make(map[common.Address]bool)
+	)
+	return func(addr common.Address) bool {
+		once.Do(func() {
+			cs, err := t.chiefStatus(header.Number, header.ParentHash)
+			if err != nil {
+				log.Debug("Leaders unavailable for equivocation check", "number", header.Number, "err", err)
+				return
+			}
+			for _, leader := range cs.LeaderList {
+				leaders[leader] = true
+			}
+		})
+		return leaders[addr]
+	}
+}
+
+// SetSyncPivot is called by the downloader with the pivot of every fast sync
+// cycle and with 0 once the cycle is over. The signers of the headers below it
+// are validated against the trusted checkpoint only.
+func (t *Tribe) SetSyncPivot(pivot uint64) {
+	atomic.StoreUint64(&t.syncPivot, pivot)
+}
+
+// SetCheckpointSigners restricts the sealers of the headers below the fast sync
+// pivot to the given signers of the trusted checkpoint range. Without them no
+// header below the pivot is accepted.
+func (t *Tribe) SetCheckpointSigners(signers []common.Address) {
+	t.checkpointSigners = make(map[common.Address]bool, len(signers))
+	for _, signer := range signers {
+		t.checkpointSigners[signer] = true
+	}
+}
+
+func (t *Tribe) belowSyncPivot(header *types.Header) bool {
+	return header.Number.Uint64() < atomic.LoadUint64(&t.syncPivot)
+}
+
+// SubscribeEquivocationEvent registers a subscription of EquivocationEvent.
+func (t *Tribe) SubscribeEquivocationEvent(ch chan<- EquivocationEvent) event.Subscription {
+	return t.scope.Track(t.equivocationFeed.Subscribe(ch))
+}
+
+// Equivocations returns the evidences collected so far, optionally filtered
+// by signer.
+func (t *Tribe) Equivocations(signer *common.Address) []*Equivocation {
+	if t.equivocations == nil {
+		return []*Equivocation{}
+	}
+	return t.equivocations.list(signer)
+}
+
 // verifyHeader checks whether a header conforms to the consensus rules.The
 // caller may optionally pass in a batch of parents (ascending order) to avoid
 // looking those up from the database. This is useful for concurrently verifying
@@ -262,6 +352,13 @@ func (t *Tribe) verifyHeader(chain consensus.ChainReader, header *types.Header,
 	if err := misc.VerifyForkHashes(chain.Config(), header, false); err != nil {
 		return err
 	}
+	// Below the fast sync pivot the chief state needed to validate the signer is
+	// missing, rely on the trusted checkpoint of the downloader.
+	if t.belowSyncPivot(header) {
+		if err := t.verifyCheckpointSealer(header); err != nil {
+			return err
+		}
+	}
 	// All basic checks passed, verify cascading fields
 	err = t.verifyCascadingFields(chain, header, parents)
 	if err != nil {
@@ -270,6 +367,21 @@ func (t *Tribe) verifyHeader(chain consensus.ChainReader, header *types.Header,
 	return err
 }
 
+// verifyCheckpointSealer checks that a header below the fast sync pivot is
+// sealed by one of the checkpoint signers. The pivot never passes the trusted
+// checkpoint and the downloader pins the hash chain of these headers to it, so
+// the sealer is all that is left to check.
+func (t *Tribe) verifyCheckpointSealer(header *types.Header) error {
+	signer, err := ecrecover(header, t)
+	if err != nil {
+		return err
+	}
+	if !t.checkpointSigners[signer] {
+		return errUnauthorized
+	}
+	return nil
+}
+
 // verifyCascadingFields verifies all the header fields that are not standalone,
 // rather depend on a batch of previous headers. The caller may optionally pass
 // in a batch of parents (ascending order) to avoid looking those up from the
@@ -758,18 +870,24 @@ func (t *Tribe) GetPeriod(header *types.Header, signers []*Signer) (p uint64) {
 // included uncles. The coinbase of each uncle block is also rewarded.
 // add by liangc : no reward
 func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header) {
-	if config.Chief100Block == nil || header.Number.Cmp(config.Chief100Block) < 0 {
-		return
+	if blockReward := BlockReward(config, header.Number); blockReward.Sign() > 0 {
+		state.AddBalance(header.Coinbase, blockReward)
+	}
+}
+
+// BlockReward returns the reward of the block with the given number, halved
+// every BlockRewardReducedInterval blocks since the chief 1.0.0 fork. Blocks
+// before the fork have no reward.
+func BlockReward(config *params.ChainConfig, number *big.Int) *big.Int {
+	if config.Chief100Block == nil || number.Cmp(config.Chief100Block) < 0 {
+		return new(big.Int)
 	}
 	// Select the correct block reward based on chain progression
 	blockReward := new(big.Int).Set(Chief100BlockReward)
 
-	// Accumulate the rewards for the miner and any included uncles
-	number := new(big.Int).Set(header.Number)
-	number = number.Sub(number, config.Chief100Block)
-	number = number.Div(number, big.NewInt(int64(BlockRewardReducedInterval)))
-	blockReward = blockReward.Rsh(blockReward, uint(number.Int64()))
-	state.AddBalance(header.Coinbase, blockReward)
+	halvings := new(big.Int).Sub(number, config.Chief100Block)
+	halvings = halvings.Div(halvings, big.NewInt(int64(BlockRewardReducedInterval)))
+	return blockReward.Rsh(blockReward, uint(halvings.Int64()))
 }
 
 //
@@ -13,6 +13,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/common/hexutil"
 	"github.com/MeshBoxFoundation/meshbox/consensus"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/event"
 	"github.com/MeshBoxFoundation/meshbox/params"
 	lru "github.com/hashicorp/golang-lru"
 )
@@ -124,6 +125,17 @@ type Tribe struct {
 	config   *params.TribeConfig // Consensus engine configuration parameters
 	sigcache *lru.ARCCache       // mapping block.hash -> signer
 	Status   *TribeStatus
+
+	equivocations    *equivocationIndex // (signer, number) -> header, both canonical and side chain
+	equivocationFeed event.Feed
+	scope            event.SubscriptionScope
+
+	syncPivot         uint64                  // fast sync pivot, below it the chief state is missing, 0 when not syncing
+	checkpointSigners map[common.Address]bool // sealers of the blocks up to the checkpoint, none if empty
+
+	// chiefStatus reads the chief contract status on top of the given block,
+	// params.TribeGetStatus unless replaced by tests
+	chiefStatus func(number *big.Int, hash common.Hash) (params.ChiefStatus, error)
 	//SealErrorCounter uint32     // less then 3 , retry commit new work
 	isInit bool
 	lock   sync.Mutex
@@ -188,3 +200,14 @@ type TribeMiner struct {
 	Balance *big.Int       `json:"balance"`
 	Level   string         `json:"level"` // None 、 Volunteer 、 Signer
 }
+
+// TribeMyStatus describes the role of the local node in the current round.
+type TribeMyStatus struct {
+	Address     common.Address `json:"address"`
+	Level       string         `json:"level"`       // None 、 Volunteer 、 Signer 、 Sinner
+	SignerIndex int            `json:"signerIndex"` // -1 if not in the signer list
+	Leader      bool           `json:"leader"`
+	Number      int64          `json:"number"`   // current block number
+	NextSlot    int64          `json:"nextSlot"` // next block sealed in turn by this node, 0 if none
+	Period      uint64         `json:"period"`   // seconds between in-turn blocks
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -27,8 +28,10 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/MeshBoxFoundation/meshbox/common/hexutil"
 	"github.com/MeshBoxFoundation/meshbox/internal/jsre"
 	"github.com/MeshBoxFoundation/meshbox/internal/web3ext"
+	"github.com/MeshBoxFoundation/meshbox/params"
 	"github.com/MeshBoxFoundation/meshbox/rpc"
 	"github.com/mattn/go-colorable"
 	"github.com/peterh/liner"
@@ -47,6 +50,24 @@ const HistoryFile = "history"
 // DefaultPrompt is the default prompt line prefix to use for user input querying.
 const DefaultPrompt = "> "
 
+// minGasPrice is the lowest gas price tribe nodes accept into their pools.
+var minGasPrice = new(big.Int).Mul(big.NewInt(18), big.NewInt(params.Shannon))
+
+// gasPriceGuard wraps eth.sendTransaction to warn about gas prices below the
+// pool minimum, such transactions would never be mined.
+const gasPriceGuard = `
+(function() {
+	var sendTransaction = eth.sendTransaction;
+	eth.sendTransaction = function(tx) {
+		if (tx && tx.gasPrice !== undefined && web3.toBigNumber(tx.gasPrice).lessThan('%[1]d')) {
+			console.log('WARNING: gas price ' + web3.fromWei(tx.gasPrice, 'gwei') + ' Gwei is below the ' + web3.fromWei('%[1]d', 'gwei') + ' Gwei minimum, the transaction will not be mined');
+		}
+		return sendTransaction.apply(eth, arguments);
+	};
+	eth.sendTransaction.request = sendTransaction.request;
+})();
+`
+
 // Config is the collection of configurations to fine tune the behavior of the
 // JavaScript console.
 type Config struct {
@@ -145,6 +166,10 @@ func (c *Console) init(preload []string) error {
 				return fmt.Errorf("%s.js: %v", api, err)
 			}
 			flatten += fmt.Sprintf("var %s = web3.%s; ", api, api)
+			if api == "tribe" {
+				// poc helpers are installed by the tribe extension
+				flatten += "var poc = web3.poc; "
+			}
 		} else if obj, err := c.jsre.Run("web3." + api); err == nil && obj.IsObject() {
 			// Enable web3.js built-in extension if available.
 			flatten += fmt.Sprintf("var %s = web3.%s; ", api, api)
@@ -153,6 +178,12 @@ func (c *Console) init(preload []string) error {
 	if _, err = c.jsre.Run(flatten); err != nil {
 		return fmt.Errorf("namespace flattening: %v", err)
 	}
+	// Warn about transactions the tribe nodes won't accept into their pools
+	if _, ok := apis["tribe"]; ok {
+		if _, err = c.jsre.Run(fmt.Sprintf(gasPriceGuard, minGasPrice)); err != nil {
+			return fmt.Errorf("gas price guard: %v", err)
+		}
+	}
 	// Initialize the global name register (disabled for now)
 	//c.jsre.Run(`var GlobalRegistrar = eth.contract(` + registrar.GlobalRegistrarAbi + `);   registrar = GlobalRegistrar.at("` + registrar.GlobalRegistrarAddr + `");`)
 
@@ -279,6 +310,10 @@ func (c *Console) Welcome() {
 		console.log("at block: " + eth.blockNumber + " (" + new Date(1000 * eth.getBlock(eth.blockNumber).timestamp) + ")");
 		console.log(" datadir: " + admin.datadir);
 	`)
+	var chainId hexutil.Uint64
+	if err := c.client.Call(&chainId, "eth_chainId"); err == nil {
+		fmt.Fprintf(c.printer, " chainId: %d (%s)\n", uint64(chainId), chainName(uint64(chainId)))
+	}
 	// List all the supported modules for the user to call
 	if apis, err := c.client.SupportedModules(); err == nil {
 		modules := make([]string, 0, len(apis))
@@ -291,6 +326,19 @@ func (c *Console) Welcome() {
 	fmt.Fprintln(c.printer)
 }
 
+// chainName returns the name of the well known network with the given chain id.
+func chainName(id uint64) string {
+	switch id {
+	case params.MainnetChainConfig.ChainId.Uint64():
+		return "mainnet"
+	case params.TestnetChainConfig.ChainId.Uint64():
+		return "testnet"
+	case params.DevnetChainConfig.ChainId.Uint64():
+		return "devnet"
+	}
+	return "private network"
+}
+
 // Evaluate executes code and pretty prints the result to the specified output
 // stream.
 func (c *Console) Evaluate(statement string) error {
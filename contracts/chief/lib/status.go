@@ -0,0 +1,247 @@
+package chieflib
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/accounts/abi"
+	"github.com/MeshBoxFoundation/meshbox/accounts/abi/bind"
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/params"
+)
+
+var errUnknownChief = errors.New("status_not_found")
+
+var (
+	statusABIs     = make(map[string]abi.ABI) // ABI definition -> parsed ABI, the status is read for every block
+	statusABIsLock sync.Mutex
+)
+
+// boundStatusContract binds the contract at address to caller, parsing its ABI
+// only the first time it is used.
+func boundStatusContract(definition string, address common.Address, caller bind.ContractCaller) (*bind.BoundContract, error) {
+	statusABIsLock.Lock()
+	defer statusABIsLock.Unlock()
+
+	parsed, ok := statusABIs[definition]
+	if !ok {
+		var err error
+		if parsed, err = abi.JSON(strings.NewReader(definition)); err != nil {
+			return nil, err
+		}
+		statusABIs[definition] = parsed
+	}
+	return bind.NewBoundContract(address, parsed, caller, nil), nil
+}
+
+// ReadStatus calls the getters of the given chief contract through caller and
+// assembles their results into a chief status. The caller decides what state
+// the calls run against, the chain of a full or light node, or a proven
+// slice of it.
+func ReadStatus(opts *bind.CallOptsWithNumber, chief *params.ChiefInfo, caller bind.ContractCaller) (params.ChiefStatus, error) {
+	if chief == nil {
+		return params.ChiefStatus{}, errUnknownChief
+	}
+	switch chief.Version {
+	case "0.0.2":
+		bound, err := boundStatusContract(TribeChiefABI, chief.Addr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		contract := &TribeChiefCaller{contract: bound}
+		chiefStatus, err := contract.GetStatus(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		return params.ChiefStatus{
+			VolunteerList: chiefStatus.VolunteerList,
+			SignerList:    chiefStatus.SignerList,
+			ScoreList:     chiefStatus.ScoreList,
+			NumberList:    chiefStatus.NumberList,
+			Number:        chiefStatus.Number,
+		}, nil
+	case "0.0.3":
+		bound, err := boundStatusContract(TribeChief_0_0_3ABI, chief.Addr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		contract := &TribeChief_0_0_3Caller{contract: bound}
+		chiefStatus, err := contract.GetStatus(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		return params.ChiefStatus{
+			VolunteerList: chiefStatus.VolunteerList,
+			SignerList:    chiefStatus.SignerList,
+			ScoreList:     chiefStatus.ScoreList,
+			NumberList:    chiefStatus.NumberList,
+			Number:        chiefStatus.Number,
+		}, nil
+	case "0.0.4":
+		bound, err := boundStatusContract(TribeChief_0_0_4ABI, chief.Addr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		contract := &TribeChief_0_0_4Caller{contract: bound}
+		chiefStatus, err := contract.GetStatus(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		return params.ChiefStatus{
+			VolunteerList: chiefStatus.VolunteerList,
+			SignerList:    chiefStatus.SignerList,
+			ScoreList:     chiefStatus.ScoreList,
+			NumberList:    chiefStatus.NumberList,
+			Number:        chiefStatus.Number,
+		}, nil
+	case "0.0.5":
+		bound, err := boundStatusContract(TribeChief_0_0_5ABI, chief.Addr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		contract := &TribeChief_0_0_5Caller{contract: bound}
+		chiefStatus, err := contract.GetStatus(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		epoch, err := contract.GetEpoch(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		signerLimit, err := contract.GetSignerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		volunteerLimit, err := contract.GetVolunteerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		return params.ChiefStatus{
+			VolunteerList:  chiefStatus.VolunteerList,
+			SignerList:     chiefStatus.SignerList,
+			ScoreList:      chiefStatus.ScoreList,
+			NumberList:     chiefStatus.NumberList,
+			BlackList:      chiefStatus.BlackList,
+			Number:         chiefStatus.Number,
+			Epoch:          epoch,
+			SignerLimit:    signerLimit,
+			VolunteerLimit: volunteerLimit,
+		}, nil
+	case "0.0.6":
+		bound, err := boundStatusContract(TribeChief_0_0_6ABI, chief.Addr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		contract := &TribeChief_0_0_6Caller{contract: bound}
+		chiefStatus, err := contract.GetStatus(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		epoch, err := contract.GetEpoch(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		signerLimit, err := contract.GetSignerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		volunteerLimit, err := contract.GetVolunteerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		return params.ChiefStatus{
+			SignerList:     chiefStatus.SignerList,
+			ScoreList:      chiefStatus.ScoreList,
+			NumberList:     chiefStatus.NumberList,
+			BlackList:      chiefStatus.BlackList,
+			Number:         chiefStatus.Number,
+			Epoch:          epoch,
+			SignerLimit:    signerLimit,
+			VolunteerLimit: volunteerLimit,
+			TotalVolunteer: chiefStatus.TotalVolunteer,
+		}, nil
+	case "0.0.7":
+		bound, err := boundStatusContract(TribeChief_0_0_7ABI, chief.Addr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		contract := &TribeChief_0_0_7Caller{contract: bound}
+		chiefStatus, err := contract.GetStatus(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		epoch, err := contract.GetEpoch(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		signerLimit, err := contract.GetSignerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		volunteerLimit, err := contract.GetVolunteerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		return params.ChiefStatus{
+			SignerList:     chiefStatus.SignerList,
+			ScoreList:      chiefStatus.ScoreList,
+			NumberList:     chiefStatus.NumberList,
+			BlackList:      chiefStatus.BlackList,
+			Number:         chiefStatus.Number,
+			Epoch:          epoch,
+			SignerLimit:    signerLimit,
+			VolunteerLimit: volunteerLimit,
+			TotalVolunteer: chiefStatus.TotalVolunteer,
+		}, nil
+	case "1.0.0":
+		bound, err := boundStatusContract(TribeChief_1_0_0ABI, chief.Addr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		contract := &TribeChief_1_0_0Caller{contract: bound}
+		baseBound, err := boundStatusContract(ChiefBase_1_0_0ABI, chief.BaseAddr, caller)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		base := &ChiefBase_1_0_0Caller{contract: baseBound}
+		chiefStatus, err := contract.GetStatus(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		epoch, err := contract.GetEpoch(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		signerLimit, err := contract.GetSignerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		volunteerLimit, err := contract.GetVolunteerLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		leaderList, err := base.TakeLeaderList(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		leaderLimit, err := base.TakeLeaderLimit(opts)
+		if err != nil {
+			return params.ChiefStatus{}, err
+		}
+		return params.ChiefStatus{
+			LeaderLimit:    leaderLimit,
+			LeaderList:     leaderList,
+			SignerList:     chiefStatus.SignerList,
+			ScoreList:      chiefStatus.ScoreList,
+			NumberList:     chiefStatus.NumberList,
+			BlackList:      chiefStatus.BlackList,
+			Number:         chiefStatus.Number,
+			Epoch:          epoch,
+			SignerLimit:    signerLimit,
+			VolunteerLimit: volunteerLimit,
+			TotalVolunteer: chiefStatus.TotalVolunteer,
+		}, nil
+	}
+	return params.ChiefStatus{}, errUnknownChief
+}
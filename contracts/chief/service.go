@@ -48,6 +48,7 @@ type TribeService struct {
 	tribeChief_1_0_0 *chieflib.TribeChief_1_0_0
 	poc              *chieflib.POC_1_0_0
 	base             *chieflib.ChiefBase_1_0_0
+	backend          bind.ContractCaller
 	quit             chan int
 	server           *p2p.Server // peers and nodekey ...
 	ethereum         *eth.Ethereum
@@ -71,6 +72,7 @@ func NewTribeService(ctx *node.ServiceContext) (node.Service, error) {
 	}
 
 	ts := &TribeService{
+		backend:  eth.NewContractBackend(apiBackend),
 		quit:     make(chan int),
 		ethereum: ethereum,
 		ctx:      ctx,
@@ -389,171 +391,7 @@ func (self *TribeService) getChiefStatus(blockNumber *big.Int, blockHash *common
 	opts := new(bind.CallOptsWithNumber)
 	opts.Context = ctx
 	opts.Hash = blockHash
-	if chiefInfo := params.GetChiefInfo(blockNumber); chiefInfo != nil {
-		switch chiefInfo.Version {
-		case "0.0.2":
-			chiefStatus, err := self.tribeChief_0_0_2.GetStatus(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			return params.ChiefStatus{
-				VolunteerList: chiefStatus.VolunteerList,
-				SignerList:    chiefStatus.SignerList,
-				ScoreList:     chiefStatus.ScoreList,
-				NumberList:    chiefStatus.NumberList,
-				BlackList:     nil,
-				Number:        chiefStatus.Number,
-			}, nil
-		case "0.0.3":
-			chiefStatus, err := self.tribeChief_0_0_3.GetStatus(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			return params.ChiefStatus{
-				VolunteerList: chiefStatus.VolunteerList,
-				SignerList:    chiefStatus.SignerList,
-				ScoreList:     chiefStatus.ScoreList,
-				NumberList:    chiefStatus.NumberList,
-				BlackList:     nil,
-				Number:        chiefStatus.Number,
-			}, nil
-		case "0.0.4":
-			chiefStatus, err := self.tribeChief_0_0_4.GetStatus(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			return params.ChiefStatus{
-				VolunteerList: chiefStatus.VolunteerList,
-				SignerList:    chiefStatus.SignerList,
-				ScoreList:     chiefStatus.ScoreList,
-				NumberList:    chiefStatus.NumberList,
-				BlackList:     nil,
-				Number:        chiefStatus.Number,
-			}, nil
-		case "0.0.5":
-			chiefStatus, err := self.tribeChief_0_0_5.GetStatus(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			epoch, err := self.tribeChief_0_0_5.GetEpoch(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			signerLimit, err := self.tribeChief_0_0_5.GetSignerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			volunteerLimit, err := self.tribeChief_0_0_5.GetVolunteerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			return params.ChiefStatus{
-				VolunteerList:  chiefStatus.VolunteerList,
-				SignerList:     chiefStatus.SignerList,
-				ScoreList:      chiefStatus.ScoreList,
-				NumberList:     chiefStatus.NumberList,
-				BlackList:      chiefStatus.BlackList,
-				Number:         chiefStatus.Number,
-				Epoch:          epoch,
-				SignerLimit:    signerLimit,
-				VolunteerLimit: volunteerLimit,
-			}, nil
-		case "0.0.6":
-			chiefStatus, err := self.tribeChief_0_0_6.GetStatus(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			epoch, err := self.tribeChief_0_0_6.GetEpoch(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			signerLimit, err := self.tribeChief_0_0_6.GetSignerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			volunteerLimit, err := self.tribeChief_0_0_6.GetVolunteerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			return params.ChiefStatus{
-				VolunteerList:  nil,
-				SignerList:     chiefStatus.SignerList,
-				ScoreList:      chiefStatus.ScoreList,
-				NumberList:     chiefStatus.NumberList,
-				BlackList:      chiefStatus.BlackList,
-				Number:         chiefStatus.Number,
-				Epoch:          epoch,
-				SignerLimit:    signerLimit,
-				VolunteerLimit: volunteerLimit,
-				TotalVolunteer: chiefStatus.TotalVolunteer,
-			}, nil
-		case "0.0.7":
-			chiefStatus, err := self.tribeChief_0_0_7.GetStatus(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			epoch, err := self.tribeChief_0_0_7.GetEpoch(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			signerLimit, err := self.tribeChief_0_0_7.GetSignerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			volunteerLimit, err := self.tribeChief_0_0_7.GetVolunteerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			return params.ChiefStatus{
-				VolunteerList:  nil,
-				SignerList:     chiefStatus.SignerList,
-				ScoreList:      chiefStatus.ScoreList,
-				NumberList:     chiefStatus.NumberList,
-				BlackList:      chiefStatus.BlackList,
-				Number:         chiefStatus.Number,
-				Epoch:          epoch,
-				SignerLimit:    signerLimit,
-				VolunteerLimit: volunteerLimit,
-				TotalVolunteer: chiefStatus.TotalVolunteer,
-			}, nil
-		case "1.0.0":
-			chiefStatus, err := self.tribeChief_1_0_0.GetStatus(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			epoch, err := self.tribeChief_1_0_0.GetEpoch(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			signerLimit, err := self.tribeChief_1_0_0.GetSignerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			volunteerLimit, err := self.tribeChief_1_0_0.GetVolunteerLimit(opts)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			leaderList, leaderLimit, err := self.GetLeaders(blockNumber, blockHash)
-			if err != nil {
-				return params.ChiefStatus{}, err
-			}
-			return params.ChiefStatus{
-				LeaderLimit:    leaderLimit,
-				LeaderList:     leaderList,
-				VolunteerList:  nil,
-				SignerList:     chiefStatus.SignerList,
-				ScoreList:      chiefStatus.ScoreList,
-				NumberList:     chiefStatus.NumberList,
-				BlackList:      chiefStatus.BlackList,
-				Number:         chiefStatus.Number,
-				Epoch:          epoch,
-				SignerLimit:    signerLimit,
-				VolunteerLimit: volunteerLimit,
-				TotalVolunteer: chiefStatus.TotalVolunteer,
-			}, nil
-		}
-	}
-	return params.ChiefStatus{}, errors.New("status_not_found")
+	return chieflib.ReadStatus(opts, params.GetChiefInfo(blockNumber), self.backend)
 }
 
 func (self *TribeService) isVolunteer(dict map[common.Address]interface{}, add common.Address) bool {
@@ -655,50 +493,61 @@ func (self *TribeService) getNextRoundSignerExcludeList(blockNumber *big.Int, bl
 //从nl也就是可能出块节列表中根据vrf选择一个,如果选中的人已经在下一轮出块列表中就尝试选择下一个,takerMiner只会在1.0.0版本后使用
 func (self *TribeService) takeMiner(nl []common.Address, hash common.Hash, _vrfn []byte) common.Address {
 	if nl != nil && len(nl) > 0 {
-		var (
-			block = self.ethereum.BlockChain().GetBlockByHash(hash)
-			vrfn  = new(big.Int).SetBytes(_vrfn[:])
-			fn    func(_vrfn *big.Int) common.Address
-		)
+		block := self.ethereum.BlockChain().GetHeaderByHash(hash)
 		if block == nil {
 			panic(errors.New("get block by hash fail"))
 		}
 		//排除当前signerList的原因是有可能被选中作为下一轮出块节点,但是同时又
-		excludes := self.getNextRoundSignerExcludeList(block.Number(), block.Hash())
-		fn = func(_vrfn *big.Int) common.Address {
-			m := big.NewInt(int64(len(nl)))
-			x := new(big.Int).Sub(_vrfn, vrfn)
-			if x.Cmp(m) >= 0 {
-				return common.Address{}
-			}
-			idx := new(big.Int).Mod(_vrfn, m)
-			addrLog := make([]string, 0)
-			for _, n := range nl {
-				addrLog = append(addrLog[:], n.Hex())
-			}
-			log.Debug("fetchVolunteer-1.0.0-volunteers", "num", block.Number(), "addrList", addrLog)
-			// skip if `n` in volunteer list
-			v := nl[idx.Int64()]
-
-			for _, vol := range excludes {
-				if vol == v {
-					return fn(new(big.Int).Add(_vrfn, big.NewInt(1)))
-				}
-			}
-			log.Debug("fetchVolunteer-1.0.0-final", "num", block.Number(), "idx", idx.String(), "addr", v.Hex(), "vrfn", _vrfn)
-			return v
+		excludes := self.getNextRoundSignerExcludeList(block.Number, block.Hash())
+		addrLog := make([]string, 0)
+		for _, n := range nl {
+			addrLog = append(addrLog[:], n.Hex())
 		}
-		return fn(vrfn)
+		log.Debug("fetchVolunteer-1.0.0-volunteers", "num", block.Number, "addrList", addrLog)
+		v := pickMiner(nl, excludes, new(big.Int).SetBytes(_vrfn[:]))
+		log.Debug("fetchVolunteer-1.0.0-final", "num", block.Number, "addr", v.Hex(), "vrfn", new(big.Int).SetBytes(_vrfn[:]))
+		return v
 	}
 	return common.Address{}
 }
 
+// pickMiner selects the next round signer out of the POC normal list nl by the
+// vrf number of the block: the vrf number modulo the list length, moving to the
+// following entries while they are excluded. The zero address is returned if
+// all of them are.
+func pickMiner(nl, excludes []common.Address, vrfn *big.Int) common.Address {
+	var (
+		m  = big.NewInt(int64(len(nl)))
+		fn func(_vrfn *big.Int) common.Address
+	)
+	if len(nl) == 0 {
+		return common.Address{}
+	}
+	fn = func(_vrfn *big.Int) common.Address {
+		x := new(big.Int).Sub(_vrfn, vrfn)
+		if x.Cmp(m) >= 0 {
+			return common.Address{}
+		}
+		idx := new(big.Int).Mod(_vrfn, m)
+		// skip if `n` in volunteer list
+		v := nl[idx.Int64()]
+
+		for _, vol := range excludes {
+			if vol == v {
+				return fn(new(big.Int).Add(_vrfn, big.NewInt(1)))
+			}
+		}
+		return v
+	}
+	return fn(vrfn)
+}
+
 func (self *TribeService) verifyMiner(vol common.Address, hash common.Hash, vrfn []byte) bool {
-	block := self.ethereum.BlockChain().GetBlockByHash(hash)
-	ci := params.GetChiefInfo(block.Number())
+	block := self.ethereum.BlockChain().GetHeaderByHash(hash)
+	ci := params.GetChiefInfo(block.Number)
 	switch ci.Version {
 	case "1.0.0":
-		m := self.takeMiner(self.minerList(block.Number(), block.Hash()), hash, vrfn)
+		m := self.takeMiner(self.minerList(block.Number, block.Hash()), hash, vrfn)
 		log.Debug("<<TribeService.verifyMiner>>", "result", vol == m, "c", vol.Hex(), "t", m.Hex())
 		if vol == m {
 			return true
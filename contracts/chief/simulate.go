@@ -0,0 +1,191 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package chief
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/params"
+)
+
+// FullUptime is the uptime of a node sealing every block it's asked to, in
+// basis points.
+const FullUptime = 10000
+
+// SimMiner is a POC miner taking part in a reward simulation.
+type SimMiner struct {
+	Address common.Address
+	Owner   common.Address // Account bound in anmap, receiving the rewards (miner itself if zero)
+	Deposit *big.Int       // POC deposit, only checked against the minimum
+	Uptime  uint64         // Chance to seal a block it's asked to, in basis points
+}
+
+// SimConfig is the chain set up replayed by Simulate.
+type SimConfig struct {
+	Chain        *params.ChainConfig // Chain config holding the chief 1.0.0 fork block
+	Leaders      []common.Address    // Leaders of the chief base contract
+	LeaderUptime uint64              // Chance of a leader to seal a block, in basis points
+	Miners       []*SimMiner         // Miners which deposited in the POC contract
+	MinDeposit   *big.Int            // Minimum POC deposit to enter the normal list
+	SignerLimit  uint64              // Signers per round, the leader included
+	Epoch        uint64              // Blocks between clearing the POC black list
+	Start        uint64              // First block to simulate
+	Blocks       uint64              // Number of blocks to simulate
+	Seed         common.Hash         // Seed of the vrf numbers and of the uptime draws
+}
+
+// SimStat is the outcome of a simulation for a single address.
+type SimStat struct {
+	Selected uint64   // Times picked as a next round signer
+	Sealed   uint64   // Blocks sealed in turn
+	TakeOver uint64   // Blocks sealed in place of another signer
+	Missed   uint64   // Blocks missed while in turn, each one stopping the miner
+	Income   *big.Int // Block rewards received as coinbase
+}
+
+// Simulate replays the chief 1.0.0 contract over the configured blocks: each
+// block is sealed by the first online node of tribe.TakeoverOrder and rewarded
+// as in tribe.BlockReward, or left empty without reward if none is online, the
+// next round signers are picked from the POC normal
+// list as in TribeService.takeMiner, and the signers missing their turn are
+// stopped. Stopped miners are assumed to restart as soon as the black list is
+// cleared. The signer list starts empty, as after deploying the contract.
+func Simulate(cfg *SimConfig) map[common.Address]*SimStat {
+	var (
+		stats   = make(map[common.Address]*SimStat)
+		uptimes = make(map[common.Address]uint64)
+		owners  = make(map[common.Address]common.Address)
+		normal  []common.Address
+		stopped []common.Address
+		signers = make([]common.Address, cfg.SignerLimit)
+		nextRnd []common.Address
+	)
+	stat := func(addr common.Address) *SimStat {
+		if stats[addr] == nil {
+			stats[addr] = &SimStat{Income: new(big.Int)}
+		}
+		return stats[addr]
+	}
+	for _, leader := range cfg.Leaders {
+		uptimes[leader] = cfg.LeaderUptime
+		stat(leader)
+	}
+	for _, miner := range cfg.Miners {
+		uptimes[miner.Address] = miner.Uptime
+		if miner.Owner != (common.Address{}) {
+			owners[miner.Address] = miner.Owner
+		}
+		if miner.Deposit != nil && miner.Deposit.Cmp(cfg.MinDeposit) >= 0 {
+			normal = append(normal, miner.Address)
+		}
+		stat(miner.Address)
+	}
+	if len(cfg.Leaders) == 0 || cfg.SignerLimit == 0 {
+		return stats
+	}
+	signers[0] = cfg.Leaders[0]
+
+	for number := cfg.Start; number < cfg.Start+cfg.Blocks; number++ {
+		var (
+			slot   = number % cfg.SignerLimit
+			si     = signers[slot]
+			sealer common.Address
+		)
+		for _, addr := range tribe.TakeoverOrder(int64(number), signers, cfg.Leaders) {
+			if simOnline(cfg.Seed, number, addr, uptimes[addr]) {
+				sealer = addr
+				break
+			}
+		}
+		switch {
+		case sealer == (common.Address{}):
+			// Nobody online, the slot stays empty and pays no reward
+		case sealer == si:
+			stat(sealer).Sealed++
+		default:
+			stat(sealer).TakeOver++
+		}
+		if sealer != (common.Address{}) {
+			coinbase := sealer
+			if owner, ok := owners[sealer]; ok {
+				coinbase = owner
+			}
+			stat(coinbase).Income.Add(stat(coinbase).Income, tribe.BlockReward(cfg.Chain, new(big.Int).SetUint64(number)))
+		}
+		// Replay the chief update of the sealer
+		if slot > 0 {
+			if sealer != (common.Address{}) {
+				excludes := append(append([]common.Address{}, nextRnd...), signers...)
+				volunteer := pickMiner(normal, excludes, simVrf(cfg.Seed, number))
+				if volunteer != (common.Address{}) && uint64(len(nextRnd)) < cfg.SignerLimit-1 {
+					nextRnd = append(nextRnd, volunteer)
+					stat(volunteer).Selected++
+				}
+			}
+			if si != (common.Address{}) && sealer != si {
+				stat(si).Missed++
+				for i, addr := range normal {
+					if addr == si {
+						normal[i] = normal[len(normal)-1]
+						normal = normal[:len(normal)-1]
+						stopped = append(stopped, si)
+						break
+					}
+				}
+				signers[slot] = common.Address{}
+			}
+		}
+		if slot == cfg.SignerLimit-1 {
+			leader := cfg.Leaders[0]
+			for i, l := range cfg.Leaders {
+				if l == signers[0] && i+1 < len(cfg.Leaders) {
+					leader = cfg.Leaders[i+1]
+				}
+			}
+			signers = make([]common.Address, cfg.SignerLimit)
+			signers[0] = leader
+			copy(signers[1:], nextRnd)
+			nextRnd = nil
+		}
+		if cfg.Epoch > 0 && number%cfg.Epoch == 0 {
+			normal = append(normal, stopped...)
+			stopped = nil
+		}
+	}
+	return stats
+}
+
+// simVrf returns the vrf number standing in for the one of the given block.
+func simVrf(seed common.Hash, number uint64) *big.Int {
+	return new(big.Int).SetBytes(crypto.Keccak256(seed.Bytes(), simNumber(number)))
+}
+
+// simOnline draws whether the node is online to seal the given block.
+func simOnline(seed common.Hash, number uint64, addr common.Address, uptime uint64) bool {
+	draw := new(big.Int).SetBytes(crypto.Keccak256(seed.Bytes(), simNumber(number), addr.Bytes()))
+	return draw.Mod(draw, big.NewInt(FullUptime)).Uint64() < uptime
+}
+
+func simNumber(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return enc
+}
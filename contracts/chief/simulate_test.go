@@ -0,0 +1,122 @@
+package chief
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
+	"github.com/MeshBoxFoundation/meshbox/params"
+)
+
+// Tests that the vrf pick skips the excluded miners and gives up once it went
+// through the whole list.
+func TestPickMiner(t *testing.T) {
+	var (
+		a, b, c = common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+		nl      = []common.Address{a, b, c}
+	)
+	tests := []struct {
+		excludes []common.Address
+		vrfn     int64
+		want     common.Address
+	}{
+		{nil, 7, b},
+		{[]common.Address{b}, 7, c},
+		{[]common.Address{b, c}, 7, a},
+		{[]common.Address{a, b, c}, 7, common.Address{}},
+	}
+	for i, tt := range tests {
+		if have := pickMiner(nl, tt.excludes, big.NewInt(tt.vrfn)); have != tt.want {
+			t.Errorf("test %d: miner mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+	if have := pickMiner(nil, nil, big.NewInt(7)); have != (common.Address{}) {
+		t.Errorf("empty list: have %x, want none", have)
+	}
+}
+
+// Tests that the simulation hands out exactly the block rewards, that the
+// deposit above the minimum doesn't change the chance to be selected and that
+// offline miners lose their slots to the leaders.
+func TestSimulate(t *testing.T) {
+	var (
+		leader  = common.HexToAddress("0x100")
+		owner   = common.HexToAddress("0x200")
+		small   = &SimMiner{Address: common.HexToAddress("0x1"), Deposit: big.NewInt(10), Uptime: FullUptime}
+		large   = &SimMiner{Address: common.HexToAddress("0x2"), Deposit: big.NewInt(1000), Uptime: FullUptime, Owner: owner}
+		poor    = &SimMiner{Address: common.HexToAddress("0x3"), Deposit: big.NewInt(9), Uptime: FullUptime}
+		offline = &SimMiner{Address: common.HexToAddress("0x4"), Deposit: big.NewInt(10)}
+		miners  = []*SimMiner{small, large, poor, offline}
+	)
+	for i := 5; i < 25; i++ {
+		miners = append(miners, &SimMiner{Address: common.BigToAddress(big.NewInt(int64(i))), Deposit: big.NewInt(10), Uptime: FullUptime})
+	}
+	cfg := &SimConfig{
+		Chain:        &params.ChainConfig{Chief100Block: big.NewInt(100)},
+		Leaders:      []common.Address{leader},
+		LeaderUptime: FullUptime,
+		Miners:       miners,
+		MinDeposit:   big.NewInt(10),
+		SignerLimit:  5,
+		Epoch:        50,
+		Start:        100,
+		Blocks:       20000,
+	}
+	stats := Simulate(cfg)
+
+	want := new(big.Int)
+	for number := cfg.Start; number < cfg.Start+cfg.Blocks; number++ {
+		want.Add(want, tribe.BlockReward(cfg.Chain, new(big.Int).SetUint64(number)))
+	}
+	have := new(big.Int)
+	for _, stat := range stats {
+		have.Add(have, stat.Income)
+	}
+	if have.Cmp(want) != 0 {
+		t.Errorf("total income mismatch: have %v, want %v", have, want)
+	}
+	if stats[poor.Address].Selected != 0 {
+		t.Errorf("miner below the minimum deposit selected %d times", stats[poor.Address].Selected)
+	}
+	if s, l := stats[small.Address].Selected, stats[large.Address].Selected; s == 0 || l == 0 || s > 2*l || l > 2*s {
+		t.Errorf("selection skewed by deposit: small %d, large %d", s, l)
+	}
+	if stats[large.Address].Income.Sign() != 0 || stats[owner].Income.Sign() == 0 {
+		t.Errorf("rewards not paid to the bound owner: miner %v, owner %v", stats[large.Address].Income, stats[owner].Income)
+	}
+	if stat := stats[offline.Address]; stat.Sealed != 0 || stat.Missed > stat.Selected || stat.Missed == 0 {
+		t.Errorf("offline miner stats mismatch: sealed %d, missed %d, selected %d", stat.Sealed, stat.Missed, stat.Selected)
+	}
+	if stats[leader].TakeOver < stats[offline.Address].Missed {
+		t.Errorf("leader took over %d blocks, less than the %d missed", stats[leader].TakeOver, stats[offline.Address].Missed)
+	}
+}
+
+// Tests that slots nobody is online to seal stay empty instead of crediting
+// and rewarding an offline node.
+func TestSimulateAllOffline(t *testing.T) {
+	var (
+		leader = common.HexToAddress("0x100")
+		miners []*SimMiner
+	)
+	for i := 1; i < 10; i++ {
+		miners = append(miners, &SimMiner{Address: common.BigToAddress(big.NewInt(int64(i))), Deposit: big.NewInt(10)})
+	}
+	stats := Simulate(&SimConfig{
+		Chain:       &params.ChainConfig{Chief100Block: big.NewInt(100)},
+		Leaders:     []common.Address{leader},
+		Miners:      miners,
+		MinDeposit:  big.NewInt(10),
+		SignerLimit: 5,
+		Epoch:       50,
+		Start:       100,
+		Blocks:      1000,
+	})
+	for addr, stat := range stats {
+		if stat.Sealed != 0 || stat.TakeOver != 0 || stat.Selected != 0 || stat.Income.Sign() != 0 {
+			t.Errorf("offline node %x credited: sealed %d, takeover %d, selected %d, income %v",
+				addr, stat.Sealed, stat.TakeOver, stat.Selected, stat.Income)
+		}
+	}
+}
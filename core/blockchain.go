@@ -33,6 +33,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/common/mclock"
 	"github.com/MeshBoxFoundation/meshbox/consensus"
 	"github.com/MeshBoxFoundation/meshbox/core/state"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/core/vm"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
@@ -59,6 +60,12 @@ const (
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
 
+	historyPruneInterval = time.Minute // Frequency of deleting the bodies and receipts falling out of the history window
+	historyPruneBatch    = 10000       // Number of blocks to expire before committing the deletions
+
+	txIndexInterval = time.Minute // Frequency of moving the transaction index window along with the head
+	txIndexBatch    = 10000       // Number of blocks to (un)index before committing the changes
+
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
 )
@@ -117,6 +124,12 @@ type BlockChain struct {
 
 	badBlocks *lru.Cache // Bad block cache
 
+	historyKeep       uint64         // Number of recent blocks to keep the bodies and receipts of, 0 keeps all
+	txLookupLimit     uint64         // Number of recent blocks to index the transactions of, 0 indexes all
+	txLookupSkipChief bool           // Whether to leave the chief-update transactions unindexed
+	txIndexLock       sync.Mutex     // Serializes the history expiry and the transaction (un)indexing
+	snaps             *snapshot.Tree // Flat state snapshot for faster state reads, nil if disabled
+
 	nodeKey *ecdsa.PrivateKey
 }
 
@@ -259,6 +272,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Drop the frozen blocks past the new head, they'd be served as canonical otherwise
+	if ancients, ok := bc.chainDb.(ethdb.AncientWriter); ok {
+		if err := ancients.TruncateAncients(currentHeader.Number.Uint64() + 1); err != nil {
+			log.Crit("Failed to truncate ancient store", "err", err)
+		}
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -292,6 +312,10 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
 		log.Crit("Failed to reset head fast block", "err", err)
 	}
+	// The snapshot can't be rewound, regenerate it for the new head
+	if bc.snaps != nil && bc.snaps.Snapshot(bc.currentBlock.Root()) == nil {
+		bc.snaps.Rebuild(bc.currentBlock.Root())
+	}
 	return bc.loadLastState()
 }
 
@@ -311,6 +335,9 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	bc.currentBlock = block
 	bc.mu.Unlock()
 
+	if bc.snaps != nil {
+		bc.snaps.Rebuild(block.Root())
+	}
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
 	return nil
 }
@@ -530,8 +557,7 @@ func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
 		return true
 	}
-	ok, _ := bc.chainDb.Has(blockBodyKey(hash, number))
-	return ok
+	return HasBody(bc.chainDb, hash, number)
 }
 
 // HasBlockAndState checks if a block and associated state trie is fully present
@@ -617,6 +643,13 @@ func (bc *BlockChain) Stop() {
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
 	bc.wg.Wait()
+
+	// Flatten the snapshot to the head, so it's reused after a restart
+	if bc.snaps != nil {
+		if err := bc.snaps.Close(bc.CurrentBlock().Root()); err != nil {
+			log.Error("Failed to persist snapshot", "err", err)
+		}
+	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -750,7 +783,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
 			return i, fmt.Errorf("failed to write block receipts: %v", err)
 		}
-		if err := WriteTxLookupEntries(batch, block); err != nil {
+		if err := writeTxLookupEntries(batch, block, bc.txLookupSkipChief); err != nil {
 			return i, fmt.Errorf("failed to write lookup metadata: %v", err)
 		}
 		stats.processed++
@@ -844,7 +877,7 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 			}
 		}
 		// Write the positional metadata for transaction and receipt lookups
-		if err := WriteTxLookupEntries(batch, block); err != nil {
+		if err := writeTxLookupEntries(batch, block, bc.txLookupSkipChief); err != nil {
 			return NonStatTy, err
 		}
 		// Write hash preimages
@@ -1183,7 +1216,7 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		// insert the block in the canonical way, re-writing history
 		bc.insert(block)
 		// write lookup entries for hash based transaction/receipt searches
-		if err := WriteTxLookupEntries(bc.chainDb, block); err != nil {
+		if err := writeTxLookupEntries(bc.chainDb, block, bc.txLookupSkipChief); err != nil {
 			return err
 		}
 		addedTxs = append(addedTxs, block.Transactions()...)
@@ -1244,6 +1277,222 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// SetHistoryKeep enables history expiry: the bodies and receipts of the blocks
+// more than keep blocks behind the head are deleted in the background. Headers,
+// total difficulties and state are kept.
+func (bc *BlockChain) SetHistoryKeep(keep uint64) {
+	if keep == 0 {
+		return
+	}
+	bc.historyKeep = keep
+
+	bc.wg.Add(1)
+	go bc.historyLoop()
+}
+
+// historyLoop periodically expires the history falling out of the window.
+func (bc *BlockChain) historyLoop() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		bc.pruneHistory()
+
+		select {
+		case <-ticker.C:
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// pruneHistory deletes the bodies and receipts of the canonical blocks, and of
+// the side chains next to them, from the history tail up to the window behind
+// the head. The genesis block is always kept. The frozen blocks have theirs
+// dropped from the freezer too. The lookup entries of the expired transactions
+// are removed along with the bodies, as they can't be found afterwards.
+func (bc *BlockChain) pruneHistory() {
+	bc.txIndexLock.Lock()
+	defer bc.txIndexLock.Unlock()
+
+	head := bc.CurrentBlock().NumberU64()
+	if head <= bc.historyKeep {
+		return
+	}
+	var (
+		limit = head - bc.historyKeep
+		tail  = GetHistoryTail(bc.chainDb)
+		start = time.Now()
+	)
+	if tail == 0 {
+		tail = 1
+	}
+	if tail >= limit {
+		return
+	}
+	defer bc.truncateFrozenHistory()
+	first, txTail := tail, GetTxIndexTail(bc.chainDb)
+	for tail < limit {
+		batch := bc.chainDb.NewBatch()
+		for end := tail + historyPruneBatch; tail < limit && tail < end; tail++ {
+			if tail >= txTail {
+				if body := GetBody(bc.chainDb, GetCanonicalHash(bc.chainDb, tail), tail); body != nil {
+					for _, tx := range body.Transactions {
+						DeleteTxLookupEntry(batch, tx.Hash())
+					}
+				}
+			}
+			for _, hash := range getHeaderHashes(bc.chainDb, tail) {
+				DeleteBody(batch, hash, tail)
+				DeleteBlockReceipts(batch, hash, tail)
+			}
+		}
+		WriteHistoryTail(batch, tail)
+		if txTail < tail {
+			WriteTxIndexTail(batch, tail)
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to expire chain history", "err", err)
+		}
+		select {
+		case <-bc.quit:
+			return
+		default:
+		}
+	}
+	bc.bodyCache.Purge()
+	bc.bodyRLPCache.Purge()
+	bc.blockCache.Purge()
+
+	log.Info("Expired chain history", "blocks", limit-first, "tail", limit, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// truncateFrozenHistory drops the expired bodies and receipts held by the
+// freezer, if the chain database has one.
+func (bc *BlockChain) truncateFrozenHistory() {
+	if f, ok := bc.chainDb.(historyTruncater); ok {
+		if err := f.truncateHistory(GetHistoryTail(bc.chainDb)); err != nil {
+			log.Error("Failed to expire frozen history", "err", err)
+		}
+	}
+}
+
+// EnableSnapshots attaches a flat state snapshot to the chain, which the state
+// reads go through. If the snapshot on disk doesn't belong to the head state,
+// it's regenerated in the background; reads use the trie meanwhile.
+func (bc *BlockChain) EnableSnapshots() {
+	bc.snaps = snapshot.New(bc.chainDb, bc.CurrentBlock().Root())
+	state.SetSnapshots(bc.stateCache, bc.snaps)
+}
+
+// SetTxLookupLimit configures the transaction index: only the transactions of
+// the last limit blocks are indexed, and the entries falling out of the window
+// are removed in the background. A zero limit indexes the whole chain again,
+// as far as the bodies weren't expired. With skipChief the chief-update system
+// transactions of the blocks indexed from now on are left out, the entries
+// already written for them are kept.
+func (bc *BlockChain) SetTxLookupLimit(limit uint64, skipChief bool) {
+	bc.txLookupLimit = limit
+	bc.txLookupSkipChief = skipChief
+
+	if limit == 0 && GetTxIndexTail(bc.chainDb) == 0 {
+		return
+	}
+	bc.wg.Add(1)
+	go bc.txIndexLoop()
+}
+
+// txIndexLoop periodically moves the transaction index window along with the
+// head.
+func (bc *BlockChain) txIndexLoop() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(txIndexInterval)
+	defer ticker.Stop()
+
+	for {
+		bc.indexTransactions(bc.CurrentBlock().NumberU64())
+
+		select {
+		case <-ticker.C:
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// indexTransactions removes the lookup entries of the canonical blocks falling
+// out of the transaction index window, or adds them back for the blocks that
+// entered it after the limit was raised or the head was rewound. The blocks
+// whose bodies were expired were unindexed by pruneHistory and stay so.
+func (bc *BlockChain) indexTransactions(head uint64) {
+	bc.txIndexLock.Lock()
+	defer bc.txIndexLock.Unlock()
+
+	var (
+		tail  = GetTxIndexTail(bc.chainDb)
+		want  uint64
+		start = time.Now()
+	)
+	if bc.txLookupLimit > 0 && head >= bc.txLookupLimit {
+		want = head - bc.txLookupLimit + 1
+	}
+	if expired := GetHistoryTail(bc.chainDb); want < expired {
+		want = expired
+	}
+	switch {
+	case tail < want:
+		first := tail
+		for tail < want {
+			batch := bc.chainDb.NewBatch()
+			for i := 0; i < txIndexBatch && tail < want; i++ {
+				if body := GetBody(bc.chainDb, GetCanonicalHash(bc.chainDb, tail), tail); body != nil {
+					for _, tx := range body.Transactions {
+						DeleteTxLookupEntry(batch, tx.Hash())
+					}
+				}
+				tail++
+			}
+			WriteTxIndexTail(batch, tail)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to unindex transactions", "err", err)
+			}
+			select {
+			case <-bc.quit:
+				return
+			default:
+			}
+		}
+		log.Info("Unindexed transactions", "blocks", want-first, "tail", want, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	case tail > want:
+		first := tail
+		for tail > want {
+			batch := bc.chainDb.NewBatch()
+			for i := 0; i < txIndexBatch && tail > want; i++ {
+				tail--
+				if block := GetBlock(bc.chainDb, GetCanonicalHash(bc.chainDb, tail), tail); block != nil {
+					if err := writeTxLookupEntries(batch, block, bc.txLookupSkipChief); err != nil {
+						log.Crit("Failed to index transactions", "err", err)
+					}
+				}
+			}
+			WriteTxIndexTail(batch, tail)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to index transactions", "err", err)
+			}
+			select {
+			case <-bc.quit:
+				return
+			default:
+			}
+		}
+		log.Info("Indexed transactions", "blocks", first-want, "tail", want, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
+
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
 	Hash   common.Hash   `json:"hash"`
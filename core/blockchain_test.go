@@ -27,6 +27,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/consensus/ethash"
 	"github.com/MeshBoxFoundation/meshbox/core/state"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/core/vm"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
@@ -1197,3 +1198,181 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		t.Error("account should not exist")
 	}
 }
+
+// Tests that history expiry deletes the bodies and receipts of the blocks past
+// the window, but keeps their headers and the genesis.
+func TestHistoryExpiry(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 64, nil)
+	chain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.historyKeep = 16
+	chain.pruneHistory()
+
+	if tail := GetHistoryTail(db); tail != 48 {
+		t.Fatalf("history tail mismatch: have %d, want %d", tail, 48)
+	}
+	if !HasBody(db, genesis.Hash(), 0) {
+		t.Errorf("genesis body expired")
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		if !HasHeader(db, hash, number) {
+			t.Errorf("block %d: header expired", number)
+		}
+		pruned := number < 48
+		if IsHistoryPruned(db, number) != pruned {
+			t.Errorf("block %d: pruned mismatch: have %v, want %v", number, !pruned, pruned)
+		}
+		if HasBody(db, hash, number) == pruned || (len(GetBodyRLP(db, hash, number)) == 0) != pruned {
+			t.Errorf("block %d: body presence mismatch, want pruned %v", number, pruned)
+		}
+		if (chain.GetBlockByHash(hash) == nil) != pruned {
+			t.Errorf("block %d: block retrieval mismatch, want pruned %v", number, pruned)
+		}
+	}
+	// Expiring again is a noop until the chain progresses
+	chain.pruneHistory()
+	if tail := GetHistoryTail(db); tail != 48 {
+		t.Fatalf("history tail moved: have %d, want %d", tail, 48)
+	}
+}
+
+// Tests that the transaction index follows the configured window, dropping the
+// entries falling out of it and restoring them when the window grows.
+func TestTxLookupLimit(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	chain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	// Write a canonical chain with a transaction in every block
+	blocks := []*types.Block{genesis}
+	for i := uint64(1); i <= 32; i++ {
+		tx := types.NewTransaction(i, common.Address{0x01}, big.NewInt(1000), bigTxGas, nil, nil)
+		block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(i)}, []*types.Transaction{tx}, nil, nil)
+		if err := WriteBlock(db, block); err != nil {
+			t.Fatalf("block %d: failed to write: %v", i, err)
+		}
+		WriteCanonicalHash(db, block.Hash(), i)
+		WriteTxLookupEntries(db, block)
+		blocks = append(blocks, block)
+	}
+	check := func(limit uint64, tail uint64) {
+		chain.txLookupLimit = limit
+		chain.indexTransactions(32)
+
+		if have := GetTxIndexTail(db); have != tail {
+			t.Fatalf("limit %d: index tail mismatch: have %d, want %d", limit, have, tail)
+		}
+		for _, block := range blocks[1:] {
+			tx := block.Transactions()[0]
+			if indexed, _, _, _ := GetTransaction(db, tx.Hash()); (indexed != nil) != (block.NumberU64() >= tail) {
+				t.Errorf("limit %d: block %d: indexed mismatch: have %v, want %v", limit, block.NumberU64(), indexed != nil, block.NumberU64() >= tail)
+			}
+		}
+	}
+	check(8, 25)
+	check(16, 17)
+	check(0, 0)
+}
+
+// Tests that history expiry removes the lookup entries of the transactions it
+// expires, and that the transaction index doesn't reach below the history tail.
+func TestTxLookupLimitWithHistoryExpiry(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	chain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	// Write a canonical chain with a transaction in every block
+	blocks := []*types.Block{}
+	parent := genesis
+	for i := uint64(1); i <= 64; i++ {
+		tx := types.NewTransaction(i, common.Address{0x01}, big.NewInt(1000), bigTxGas, nil, nil)
+		block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(i), ParentHash: parent.Hash()}, []*types.Transaction{tx}, nil, nil)
+		if err := WriteBlock(db, block); err != nil {
+			t.Fatalf("block %d: failed to write: %v", i, err)
+		}
+		WriteCanonicalHash(db, block.Hash(), i)
+		WriteTxLookupEntries(db, block)
+		blocks, parent = append(blocks, block), block
+	}
+	chain.currentBlock = parent
+
+	check := func(tail uint64) {
+		if have := GetTxIndexTail(db); have != tail {
+			t.Fatalf("index tail mismatch: have %d, want %d", have, tail)
+		}
+		for _, block := range blocks {
+			tx := block.Transactions()[0]
+			if indexed, _, _, _ := GetTransaction(db, tx.Hash()); (indexed != nil) != (block.NumberU64() >= tail) {
+				t.Errorf("block %d: indexed mismatch: have %v, want %v", block.NumberU64(), indexed != nil, block.NumberU64() >= tail)
+			}
+		}
+	}
+	// Expire part of the indexed blocks, their entries go with the bodies
+	chain.txLookupLimit = 32
+	chain.indexTransactions(64)
+	check(33)
+
+	chain.historyKeep = 16
+	chain.pruneHistory()
+	check(48)
+
+	// Lifting the limit can't index the expired blocks again
+	chain.txLookupLimit = 0
+	chain.indexTransactions(64)
+	check(48)
+}
+
+// Tests that the snapshot follows the imported blocks, and is flattened to the
+// head state when the chain stops.
+func TestSnapshotChain(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 16, func(i int, block *BlockGen) {
+		block.SetCoinbase(common.Address{byte(i)})
+	})
+	chain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	chain.EnableSnapshots()
+
+	for i := 0; ; i++ {
+		if _, err := chain.snaps.Snapshot(genesis.Root()).AccountRLP(common.Hash{}); err != snapshot.ErrNotCoveredYet {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("snapshot not generated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	head := blocks[len(blocks)-1].Root()
+	if chain.snaps.Snapshot(head) == nil {
+		t.Fatalf("head state missing from the snapshot tree")
+	}
+	chain.Stop()
+
+	if err := snapshot.Verify(db, head); err != nil {
+		t.Fatalf("snapshot mismatch: %v", err)
+	}
+}
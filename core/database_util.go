@@ -23,7 +23,11 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"time"
+
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/ethdb"
 	"github.com/MeshBoxFoundation/meshbox/log"
@@ -47,6 +51,9 @@ var (
 	headBlockKey  = []byte("LastBlock")
 	headFastKey   = []byte("LastFast")
 
+	historyTailKey = []byte("HistoryTail") // first block whose body and receipts weren't expired
+	txIndexTailKey = []byte("TxIndexTail") // first block whose transactions are indexed
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	tdSuffix            = []byte("t") // headerPrefix + num (uint64 big endian) + hash + tdSuffix -> td
@@ -56,12 +63,15 @@ var (
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 	lookupPrefix        = []byte("l") // lookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix     = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	logIndexPrefix      = []byte("x") // logIndexPrefix + address + first topic + num (uint64 big endian) -> empty
+	callIndexPrefix     = []byte("X") // callIndexPrefix + address + method selector + num (uint64 big endian) -> empty
 
 	preimagePrefix = "secure-key-"              // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexPrefix       = []byte("iL") // LogIndexPrefix is the data table of the log indexer to track its progress
 
 	// used by old db, now only used for conversion
 	oldReceiptsPrefix = []byte("receipts-")
@@ -90,7 +100,10 @@ func encodeBlockNumber(number uint64) []byte {
 
 // GetCanonicalHash retrieves a hash assigned to a canonical block number.
 func GetCanonicalHash(db DatabaseReader, number uint64) common.Hash {
-	data, _ := db.Get(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
+	data, _ := db.Get(headerHashKey(number))
+	if len(data) == 0 {
+		data = getAncient(db, freezerHashTable, number)
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
@@ -145,10 +158,39 @@ func GetHeadFastBlockHash(db DatabaseReader) common.Hash {
 	return common.BytesToHash(data)
 }
 
+// GetHistoryTail retrieves the number of the oldest block whose body and
+// receipts weren't deleted by history expiry.
+func GetHistoryTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(historyTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// GetTxIndexTail retrieves the number of the oldest block whose transactions
+// are indexed. Zero is returned if the whole chain is.
+func GetTxIndexTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(txIndexTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// IsHistoryPruned returns whether the body and receipts of the block with the
+// given number were deleted by history expiry. The genesis block never is.
+func IsHistoryPruned(db DatabaseReader, number uint64) bool {
+	return number > 0 && number < GetHistoryTail(db)
+}
+
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(hash, number))
+	if len(data) == 0 {
+		data = getAncientByHash(db, freezerHeaderTable, hash, number)
+	}
 	return data
 }
 
@@ -167,9 +209,34 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 	return header
 }
 
+// HasHeader checks if a block header is present in the database or among the
+// frozen chain segments.
+func HasHeader(db ethdb.Database, hash common.Hash, number uint64) bool {
+	if has, _ := db.Has(headerKey(hash, number)); has {
+		return true
+	}
+	return bytes.Equal(getAncient(db, freezerHashTable, number), hash[:])
+}
+
+// HasBody checks if a block body is present in the database or among the frozen
+// chain segments.
+func HasBody(db ethdb.Database, hash common.Hash, number uint64) bool {
+	if has, _ := db.Has(blockBodyKey(hash, number)); has {
+		return true
+	}
+	if IsHistoryPruned(db, number) {
+		return false
+	}
+	return bytes.Equal(getAncient(db, freezerHashTable, number), hash[:])
+}
+
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
+// Expired bodies aren't served, even if the freezer still holds them.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(hash, number))
+	if len(data) == 0 && !IsHistoryPruned(db, number) {
+		data = getAncientByHash(db, freezerBodiesTable, hash, number)
+	}
 	return data
 }
 
@@ -177,10 +244,42 @@ func headerKey(hash common.Hash, number uint64) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+func headerHashKey(number uint64) []byte {
+	return append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...)
+}
+
+func headerTDKey(hash common.Hash, number uint64) []byte {
+	return append(headerKey(hash, number), tdSuffix...)
+}
+
 func blockBodyKey(hash common.Hash, number uint64) []byte {
 	return append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+func blockReceiptsKey(hash common.Hash, number uint64) []byte {
+	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// getAncient retrieves an item of the given kind from the frozen chain segments,
+// if the database has any.
+func getAncient(db DatabaseReader, kind string, number uint64) []byte {
+	ancients, ok := db.(ethdb.AncientReader)
+	if !ok {
+		return nil
+	}
+	data, _ := ancients.Ancient(kind, number)
+	return data
+}
+
+// getAncientByHash retrieves an item of the given kind from the frozen chain
+// segments, if the block frozen at number is the one with the given hash.
+func getAncientByHash(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if frozen := getAncient(db, freezerHashTable, number); !bytes.Equal(frozen, hash[:]) {
+		return nil
+	}
+	return getAncient(db, kind, number)
+}
+
 // GetBody retrieves the block body (transactons, uncles) corresponding to the
 // hash, nil if none found.
 func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
@@ -199,7 +298,10 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data, _ := db.Get(headerTDKey(hash, number))
+	if len(data) == 0 {
+		data = getAncientByHash(db, freezerDifficultyTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -234,7 +336,10 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data, _ := db.Get(blockReceiptsKey(hash, number))
+	if len(data) == 0 && !IsHistoryPruned(db, number) {
+		data = getAncientByHash(db, freezerReceiptTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -340,6 +445,64 @@ func GetBloomBits(db DatabaseReader, bit uint, section uint64, head common.Hash)
 	return db.Get(key)
 }
 
+// GetLogIndexBlocks retrieves the numbers of the blocks within [from, to] that
+// emitted logs from address with the given first topic, in ascending order. A
+// nil topic matches any, the zero hash matches logs without topics. Entries of
+// blocks reorged out since indexing may be returned too.
+func GetLogIndexBlocks(db ethdb.Database, address common.Address, topic *common.Hash, from, to uint64) []uint64 {
+	prefix := append(append([]byte{}, logIndexPrefix...), address.Bytes()...)
+	if topic != nil {
+		return readIndexBlocks(db, append(prefix, topic.Bytes()...), 0, from, to)
+	}
+	return readIndexBlocks(db, prefix, common.HashLength, from, to)
+}
+
+// GetCallIndexBlocks retrieves the numbers of the blocks within [from, to] that
+// include a successful call of the given method into address, in ascending
+// order. Only calls into the chief, POC and anmap contracts are indexed.
+func GetCallIndexBlocks(db ethdb.Database, address common.Address, selector []byte, from, to uint64) []uint64 {
+	prefix := append(append(append([]byte{}, callIndexPrefix...), address.Bytes()...), selector...)
+	return readIndexBlocks(db, prefix, 0, from, to)
+}
+
+// readIndexBlocks collects the block numbers ending the index keys under prefix,
+// skipping the gap bytes in between. Without a gap the keys are ordered by
+// number, otherwise they are spread over several ranges and need sorting.
+func readIndexBlocks(db ethdb.Database, prefix []byte, gap int, from, to uint64) []uint64 {
+	var start []byte
+	if gap == 0 {
+		start = encodeBlockNumber(from)
+	}
+	it := db.NewIterator(prefix, start)
+	defer it.Release()
+
+	var numbers []uint64
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+gap+8 {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(key)-8:])
+		if number > to && gap == 0 {
+			break
+		}
+		if number >= from && number <= to {
+			numbers = append(numbers, number)
+		}
+	}
+	if gap > 0 {
+		sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+		unique := numbers[:0]
+		for i, number := range numbers {
+			if i == 0 || number != unique[len(unique)-1] {
+				unique = append(unique, number)
+			}
+		}
+		numbers = unique
+	}
+	return numbers
+}
+
 // WriteCanonicalHash stores the canonical hash for the given block number.
 func WriteCanonicalHash(db ethdb.Putter, hash common.Hash, number uint64) error {
 	key := append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...)
@@ -373,6 +536,42 @@ func WriteHeadFastBlockHash(db ethdb.Putter, hash common.Hash) error {
 	return nil
 }
 
+// WriteHistoryTail stores the number of the oldest block whose body and receipts
+// weren't deleted by history expiry.
+func WriteHistoryTail(db ethdb.Putter, number uint64) error {
+	if err := db.Put(historyTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store history tail", "err", err)
+	}
+	return nil
+}
+
+// WriteTxIndexTail stores the number of the oldest block whose transactions are
+// indexed.
+func WriteTxIndexTail(db ethdb.Putter, number uint64) error {
+	if err := db.Put(txIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store transaction index tail", "err", err)
+	}
+	return nil
+}
+
+// WriteLogIndexEntry marks the block with the given number as having emitted a
+// log from address with the given first topic.
+func WriteLogIndexEntry(db ethdb.Putter, address common.Address, topic common.Hash, number uint64) {
+	key := append(append(append(append([]byte{}, logIndexPrefix...), address.Bytes()...), topic.Bytes()...), encodeBlockNumber(number)...)
+	if err := db.Put(key, nil); err != nil {
+		log.Crit("Failed to store log index entry", "err", err)
+	}
+}
+
+// WriteCallIndexEntry marks the block with the given number as including a
+// successful call of the given method into address.
+func WriteCallIndexEntry(db ethdb.Putter, address common.Address, selector []byte, number uint64) {
+	key := append(append(append(append([]byte{}, callIndexPrefix...), address.Bytes()...), selector...), encodeBlockNumber(number)...)
+	if err := db.Put(key, nil); err != nil {
+		log.Crit("Failed to store call index entry", "err", err)
+	}
+}
+
 // WriteHeader serializes a block header into the database.
 func WriteHeader(db ethdb.Putter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
@@ -461,8 +660,17 @@ func WriteBlockReceipts(db ethdb.Putter, hash common.Hash, number uint64, receip
 // WriteTxLookupEntries stores a positional metadata for every transaction from
 // a block, enabling hash based transaction and receipt lookups.
 func WriteTxLookupEntries(db ethdb.Putter, block *types.Block) error {
+	return writeTxLookupEntries(db, block, false)
+}
+
+// writeTxLookupEntries stores the positional metadata of the transactions of a
+// block, leaving out the chief-update system transactions if skipChief is set.
+func writeTxLookupEntries(db ethdb.Putter, block *types.Block, skipChief bool) error {
 	// Iterate over each transaction and encode its metadata
 	for i, tx := range block.Transactions() {
+		if skipChief && isChiefUpdateTx(tx) {
+			continue
+		}
 		entry := TxLookupEntry{
 			BlockHash:  block.Hash(),
 			BlockIndex: block.NumberU64(),
@@ -479,6 +687,12 @@ func WriteTxLookupEntries(db ethdb.Putter, block *types.Block) error {
 	return nil
 }
 
+// isChiefUpdateTx returns whether the transaction is a chief-update system
+// transaction sent by a signer.
+func isChiefUpdateTx(tx *types.Transaction) bool {
+	return tx.To() != nil && params.IsChiefAddress(*tx.To()) && params.IsChiefUpdate(tx.Data())
+}
+
 // WriteBloomBits writes the compressed bloom bits vector belonging to the given
 // section and bit index.
 func WriteBloomBits(db ethdb.Putter, bit uint, section uint64, head common.Hash, bits []byte) {
@@ -630,3 +844,112 @@ func FindCommonAncestor(db DatabaseReader, a, b *types.Header) *types.Header {
 	}
 	return a
 }
+
+// DatabaseStat is the number of entries and the bytes taken by the keys and
+// values of a key space category of the chain database.
+type DatabaseStat struct {
+	Name  string
+	Count uint64
+	Size  common.StorageSize
+}
+
+func (s *DatabaseStat) add(key, value []byte) {
+	s.Count++
+	s.Size += common.StorageSize(len(key) + len(value))
+}
+
+// InspectDatabase walks the whole chain database and sums up its entries per
+// key space category. Entries not matching any known layout are reported as
+// unaccounted.
+func InspectDatabase(db ethdb.Database) []*DatabaseStat {
+	var (
+		headers     = &DatabaseStat{Name: "Headers"}
+		tds         = &DatabaseStat{Name: "Total difficulties"}
+		numHashes   = &DatabaseStat{Name: "Canonical hashes"}
+		hashNums    = &DatabaseStat{Name: "Block number lookups"}
+		bodies      = &DatabaseStat{Name: "Bodies"}
+		receipts    = &DatabaseStat{Name: "Receipts"}
+		lookups     = &DatabaseStat{Name: "Tx lookup entries"}
+		bloomBits   = &DatabaseStat{Name: "Bloombits"}
+		logIndex    = &DatabaseStat{Name: "Log index"}
+		tries       = &DatabaseStat{Name: "Trie nodes"}
+		preimages   = &DatabaseStat{Name: "Preimages"}
+		snapAccts   = &DatabaseStat{Name: "Snapshot accounts"}
+		snapSlots   = &DatabaseStat{Name: "Snapshot storage"}
+		metadata    = &DatabaseStat{Name: "Chain metadata"}
+		unaccounted = &DatabaseStat{Name: "Unaccounted"}
+
+		numLen  = len(headerPrefix) + 8
+		hashLen = numLen + common.HashLength
+
+		count  uint64
+		start  = time.Now()
+		logged = time.Now()
+	)
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		switch {
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == hashLen:
+			headers.add(key, value)
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == hashLen+len(tdSuffix) && bytes.HasSuffix(key, tdSuffix):
+			tds.add(key, value)
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == numLen+len(numSuffix) && bytes.HasSuffix(key, numSuffix):
+			numHashes.add(key, value)
+		case bytes.HasPrefix(key, blockHashPrefix) && len(key) == len(blockHashPrefix)+common.HashLength:
+			hashNums.add(key, value)
+		case bytes.HasPrefix(key, bodyPrefix) && len(key) == hashLen:
+			bodies.add(key, value)
+		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == hashLen:
+			receipts.add(key, value)
+		case bytes.HasPrefix(key, lookupPrefix) && len(key) == len(lookupPrefix)+common.HashLength:
+			lookups.add(key, value)
+		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == len(bloomBitsPrefix)+10+common.HashLength: // bit + section + hash
+			bloomBits.add(key, value)
+		case bytes.HasPrefix(key, logIndexPrefix) && len(key) == len(logIndexPrefix)+common.AddressLength+common.HashLength+8:
+			logIndex.add(key, value)
+		case bytes.HasPrefix(key, callIndexPrefix) && len(key) == len(callIndexPrefix)+common.AddressLength+4+8:
+			logIndex.add(key, value)
+		case bytes.HasPrefix(key, []byte(preimagePrefix)) && len(key) == len(preimagePrefix)+common.HashLength:
+			preimages.add(key, value)
+		case bytes.HasPrefix(key, snapshot.AccountPrefix) && len(key) == len(snapshot.AccountPrefix)+common.HashLength:
+			snapAccts.add(key, value)
+		case bytes.HasPrefix(key, snapshot.StoragePrefix) && len(key) == len(snapshot.StoragePrefix)+2*common.HashLength:
+			snapSlots.add(key, value)
+		case len(key) == common.HashLength:
+			tries.add(key, value)
+		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
+			bloomBits.add(key, value)
+		case bytes.HasPrefix(key, LogIndexPrefix):
+			logIndex.add(key, value)
+		case bytes.HasPrefix(key, configPrefix) || bytes.Equal(key, headHeaderKey) || bytes.Equal(key, headBlockKey) || bytes.Equal(key, headFastKey) || bytes.Equal(key, historyTailKey) || bytes.Equal(key, txIndexTailKey) || bytes.Equal(key, snapshot.RootKey) || bytes.Equal(key, []byte("BlockchainVersion")):
+			metadata.add(key, value)
+		default:
+			unaccounted.add(key, value)
+		}
+		count++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	stats := []*DatabaseStat{headers, tds, numHashes, hashNums, bodies, receipts, lookups, bloomBits, logIndex, tries, snapAccts, snapSlots, preimages, metadata, unaccounted}
+
+	// Report the frozen chain segments too, if the database has any
+	if ancients, ok := db.(ethdb.AncientReader); ok {
+		frozen, _ := ancients.Ancients()
+		for _, table := range []struct{ kind, name string }{
+			{freezerHeaderTable, "Ancient headers"},
+			{freezerBodiesTable, "Ancient bodies"},
+			{freezerReceiptTable, "Ancient receipts"},
+			{freezerDifficultyTable, "Ancient total difficulties"},
+			{freezerHashTable, "Ancient canonical hashes"},
+		} {
+			size, _ := ancients.AncientSize(table.kind)
+			stats = append(stats, &DatabaseStat{Name: table.name, Count: frozen, Size: common.StorageSize(size)})
+		}
+	}
+	return stats
+}
@@ -18,10 +18,13 @@ package core
 
 import (
 	"bytes"
+	"math"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/crypto/sha3"
 	"github.com/MeshBoxFoundation/meshbox/ethdb"
@@ -397,3 +400,100 @@ func TestCanonicalMappingStorageDevnet(t *testing.T) {
 	}
 
 }
+
+// Tests that the database inspection sorts the entries into the right categories.
+func TestInspectDatabase(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	tx := types.NewTransaction(1, common.BytesToAddress([]byte{0x11}), big.NewInt(111), big.NewInt(1111), big.NewInt(11111), []byte{0x11, 0x11, 0x11})
+	block := types.NewBlock(&types.Header{Number: big.NewInt(314)}, []*types.Transaction{tx}, nil, nil)
+
+	WriteBlock(db, block)
+	WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(1))
+	WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	WriteBlockReceipts(db, block.Hash(), block.NumberU64(), nil)
+	WriteTxLookupEntries(db, block)
+	WriteBloomBits(db, 1, 2, block.Hash(), []byte{0x01})
+	WriteLogIndexEntry(db, common.BytesToAddress([]byte{0x11}), common.HexToHash("0x06"), block.NumberU64())
+	WriteCallIndexEntry(db, common.BytesToAddress([]byte{0x11}), []byte{0x11, 0x11, 0x11, 0x11}, block.NumberU64())
+	WritePreimages(db, block.NumberU64(), map[common.Hash][]byte{common.HexToHash("0x01"): {0x01}})
+	WriteHeadBlockHash(db, block.Hash())
+	WriteBlockChainVersion(db, 3)
+	db.Put(common.HexToHash("0x02").Bytes(), []byte{0x02})
+	db.Put(append(snapshot.AccountPrefix, common.HexToHash("0x03").Bytes()...), []byte{0x03})
+	db.Put(append(append(snapshot.StoragePrefix, common.HexToHash("0x03").Bytes()...), common.HexToHash("0x04").Bytes()...), []byte{0x04})
+	db.Put(snapshot.RootKey, common.HexToHash("0x05").Bytes())
+	db.Put([]byte("unknown"), []byte{0x03})
+
+	want := map[string]uint64{
+		"Headers":              1,
+		"Total difficulties":   1,
+		"Canonical hashes":     1,
+		"Block number lookups": 1,
+		"Bodies":               1,
+		"Receipts":             1,
+		"Tx lookup entries":    1,
+		"Bloombits":            1,
+		"Log index":            2,
+		"Trie nodes":           1,
+		"Snapshot accounts":    1,
+		"Snapshot storage":     1,
+		"Preimages":            1,
+		"Chain metadata":       3,
+		"Unaccounted":          1,
+	}
+	stats := InspectDatabase(db)
+	if len(stats) != len(want) {
+		t.Fatalf("category count mismatch: have %d, want %d", len(stats), len(want))
+	}
+	for _, stat := range stats {
+		if stat.Count != want[stat.Name] {
+			t.Errorf("%s: entry count mismatch: have %d, want %d", stat.Name, stat.Count, want[stat.Name])
+		}
+		if stat.Count > 0 && stat.Size == 0 {
+			t.Errorf("%s: missing size", stat.Name)
+		}
+	}
+}
+
+// Tests that the log and call index entries are found by address, topic and
+// method, and only within the requested range.
+func TestLogIndexStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	var (
+		addr1  = common.BytesToAddress([]byte{0x01})
+		addr2  = common.BytesToAddress([]byte{0x02})
+		topic1 = common.HexToHash("0x11")
+		topic2 = common.HexToHash("0x12")
+		bind   = []byte{0xf6, 0x5f, 0x2e, 0xec}
+		unbind = []byte{0x99, 0xab, 0x9f, 0xca}
+	)
+	for _, number := range []uint64{1, 5, 300, 70000} {
+		WriteLogIndexEntry(db, addr1, topic1, number)
+		WriteCallIndexEntry(db, addr1, bind, number)
+	}
+	WriteLogIndexEntry(db, addr1, topic2, 5)
+	WriteLogIndexEntry(db, addr1, topic2, 7)
+	WriteLogIndexEntry(db, addr2, topic1, 6)
+	WriteCallIndexEntry(db, addr1, unbind, 6)
+
+	tests := []struct {
+		have []uint64
+		want []uint64
+	}{
+		{GetLogIndexBlocks(db, addr1, &topic1, 0, math.MaxUint64), []uint64{1, 5, 300, 70000}},
+		{GetLogIndexBlocks(db, addr1, &topic1, 2, 300), []uint64{5, 300}},
+		{GetLogIndexBlocks(db, addr1, &topic2, 0, 100), []uint64{5, 7}},
+		{GetLogIndexBlocks(db, addr1, nil, 0, 1000), []uint64{1, 5, 7, 300}},
+		{GetLogIndexBlocks(db, addr2, nil, 0, 5), nil},
+		{GetCallIndexBlocks(db, addr1, bind, 5, 70000), []uint64{5, 300, 70000}},
+		{GetCallIndexBlocks(db, addr1, unbind, 0, 10), []uint64{6}},
+		{GetCallIndexBlocks(db, addr2, bind, 0, 10), nil},
+	}
+	for i, tt := range tests {
+		if !reflect.DeepEqual(tt.have, tt.want) && (len(tt.have) > 0 || len(tt.want) > 0) {
+			t.Errorf("test %d: block numbers mismatch: have %v, want %v", i, tt.have, tt.want)
+		}
+	}
+}
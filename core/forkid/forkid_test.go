@@ -0,0 +1,133 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/params"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+)
+
+// Tests that the fork ID of the mainnet is calculated correctly at every
+// tribe fork transition.
+func TestCreation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		want ID
+	}{
+		{0, ID{Hash: checksumToBytes(0x2c48938c), Next: 2}},
+		{1, ID{Hash: checksumToBytes(0x2c48938c), Next: 2}},             // Last genesis block
+		{2, ID{Hash: checksumToBytes(0xb6a20875), Next: 588888}},        // First Chief005 block
+		{588887, ID{Hash: checksumToBytes(0xb6a20875), Next: 588888}},   // Last Chief005 block
+		{588888, ID{Hash: checksumToBytes(0xaa846564), Next: 595888}},   // First SIP002 block
+		{595888, ID{Hash: checksumToBytes(0x9688cf06), Next: 808888}},   // First Chief006 block
+		{808888, ID{Hash: checksumToBytes(0x0ce774c0), Next: 2823366}},  // First SIP003 block
+		{2823366, ID{Hash: checksumToBytes(0xa1a3b38c), Next: 8360000}}, // First Chief100 (SIP100) block
+		{8360000, ID{Hash: checksumToBytes(0xfad42b40), Next: 0}},       // First SIP004 block
+		{10000000, ID{Hash: checksumToBytes(0xfad42b40), Next: 0}},      // Future block
+	}
+	for i, tt := range tests {
+		if have := NewID(params.MainnetChainConfig, params.MainnetGenesisHash, tt.head); have != tt.want {
+			t.Errorf("test %d: fork ID mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the testnet and devnet don't share fork IDs with the mainnet.
+func TestNetworkSeparation(t *testing.T) {
+	main := NewID(params.MainnetChainConfig, params.MainnetGenesisHash, 0)
+	if test := NewID(params.TestnetChainConfig, params.TestnetGenesisHash, 0); test == main {
+		t.Errorf("testnet fork ID equals mainnet: %v", test)
+	}
+	if dev := NewID(params.DevnetChainConfig, params.DevnetGenesisHash, 0); dev == main {
+		t.Errorf("devnet fork ID equals mainnet: %v", dev)
+	}
+}
+
+// Tests that IDs are properly RLP encoded (specifically important because we
+// use uint32 to store the hash, but we need to encode it as [4]byte).
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, common.Hex2Bytes("c6840000000080")},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, common.Hex2Bytes("ca84deadbeef84baddcafe")},
+		{ID{Hash: checksumToBytes(math.MaxUint32), Next: math.MaxUint64}, common.Hex2Bytes("ce84ffffffff88ffffffffffffffff")},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode forkid: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the fork ID filter accepts and rejects remote peers according to
+// the EIP-2124 ruleset.
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is mainnet SIP002, remote announces the same. No future fork is announced.
+		{588888, ID{Hash: checksumToBytes(0xaa846564), Next: 0}, nil},
+
+		// Local is mainnet SIP002, remote announces the same. Remote also announces
+		// the next fork at block 595888, which is known locally.
+		{588888, ID{Hash: checksumToBytes(0xaa846564), Next: 595888}, nil},
+
+		// Local is mainnet SIP002, remote announces the same. Remote also announces
+		// a fork unknown to us that we haven't passed yet. Accept, upgrade may follow.
+		{588888, ID{Hash: checksumToBytes(0xaa846564), Next: math.MaxUint64}, nil},
+
+		// Local is mainnet Chief005, remote announces SIP002 which is the next fork.
+		// We are not yet aware of it, so accept, we are simply syncing.
+		{588887, ID{Hash: checksumToBytes(0xaa846564), Next: 595888}, nil},
+
+		// Local is mainnet Chief100, remote is still on Chief005 and announces
+		// SIP002 as next. Remote is syncing, accept.
+		{2823366, ID{Hash: checksumToBytes(0xb6a20875), Next: 588888}, nil},
+
+		// Local is mainnet Chief100, remote is on SIP003 but doesn't know about
+		// Chief100 at all. Remote missed the SIP100 activation, reject.
+		{2823366, ID{Hash: checksumToBytes(0x0ce774c0), Next: 0}, ErrRemoteStale},
+
+		// Local is mainnet Chief100, remote announces the same with a next fork
+		// we already passed locally without forking. Incompatible, reject.
+		{2900000, ID{Hash: checksumToBytes(0xa1a3b38c), Next: 2850000}, ErrLocalIncompatibleOrStale},
+
+		// Remote is on a completely different chain.
+		{588888, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		head := tt.head
+		filter := NewFilter(params.MainnetChainConfig, params.MainnetGenesisHash, func() uint64 { return head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/prometheus/prometheus/util/flock"
+)
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before syncing the tables and deleting the blocks from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// The kinds of data kept in the freezer, one table each.
+const (
+	freezerHashTable       = "hashes"
+	freezerHeaderTable     = "headers"
+	freezerBodiesTable     = "bodies"
+	freezerReceiptTable    = "receipts"
+	freezerDifficultyTable = "diffs"
+)
+
+// freezerNoSnappy configures whether compression is disabled for the tables.
+var freezerNoSnappy = map[string]bool{
+	freezerHashTable:       true,
+	freezerHeaderTable:     false,
+	freezerBodiesTable:     false,
+	freezerReceiptTable:    false,
+	freezerDifficultyTable: true,
+}
+
+var (
+	// errUnknownTable is returned if the user attempts to read from a table that
+	// isn't part of the freezer.
+	errUnknownTable = errors.New("unknown table")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// blocks into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+)
+
+// freezer is a set of append-only flat file tables keeping the canonical chain
+// segments that are old enough to never change anymore. Moving them out of the
+// key-value store keeps its size, and thus its compaction cost, bounded.
+type freezer struct {
+	frozen    uint64 // Number of blocks already frozen (atomic, keep first for alignment)
+	threshold uint64 // Number of recent blocks kept in the key-value store
+
+	tables       map[string]*freezerTable // Data tables for the stored chain data
+	instanceLock flock.Releaser           // File-system lock to prevent double opens
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer opens the freezer tables in the given directory, repairing them if
+// they got out of sync.
+func newFreezer(datadir string, threshold uint64) (*freezer, error) {
+	if err := os.MkdirAll(datadir, 0755); err != nil {
+		return nil, err
+	}
+	lock, _, err := flock.New(filepath.Join(datadir, "FLOCK"))
+	if err != nil {
+		return nil, err
+	}
+	freezer := &freezer{
+		threshold:    threshold,
+		tables:       make(map[string]*freezerTable),
+		instanceLock: lock,
+		quit:         make(chan struct{}),
+	}
+	for name, noCompression := range freezerNoSnappy {
+		table, err := newTable(datadir, name, noCompression)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			lock.Release()
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		lock.Release()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", freezer.frozen)
+	return freezer, nil
+}
+
+// Close terminates the freezing thread and closes all the tables.
+func (f *freezer) Close() error {
+	close(f.quit)
+	f.wg.Wait()
+
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := f.instanceLock.Release(); err != nil {
+		errs = append(errs, err)
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns whether the freezer holds an item of the given kind at
+// the given number.
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an item of the given kind at the given number.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the number of blocks frozen so far.
+func (f *freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AncientSize returns the data size of the given kind.
+func (f *freezer) AncientSize(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.size()
+	}
+	return 0, errUnknownTable
+}
+
+// AppendAncient injects all the data of a block at the head of the freezer. If
+// any of the tables fails, all of them are rolled back to keep them in sync.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	if frozen := atomic.LoadUint64(&f.frozen); frozen != number {
+		return errOutOrderInsertion
+	}
+	defer func() {
+		if err != nil {
+			if rerr := f.repair(); rerr != nil {
+				log.Crit("Failed to repair freezer", "err", rerr)
+			}
+		}
+	}()
+	items := []struct {
+		table string
+		blob  []byte
+	}{
+		{freezerHashTable, hash},
+		{freezerHeaderTable, header},
+		{freezerBodiesTable, body},
+		{freezerReceiptTable, receipts},
+		{freezerDifficultyTable, td},
+	}
+	for _, item := range items {
+		if err = f.tables[item.table].Append(number, item.blob); err != nil {
+			log.Error("Failed to append ancient item", "table", item.table, "number", number, "hash", common.BytesToHash(hash), "err", err)
+			return err
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// TruncateAncients discards all but the first items blocks of the freezer.
+func (f *freezer) TruncateAncients(items uint64) error {
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// truncateHistory drops the bodies and receipts of the frozen blocks below the
+// history tail, as far as whole data files allow.
+func (f *freezer) truncateHistory(tail uint64) error {
+	for _, kind := range []string{freezerBodiesTable, freezerReceiptTable} {
+		if err := f.tables[kind].truncateTail(tail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync flushes all the tables to disk.
+func (f *freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// repair truncates all the tables to the length of the shortest one.
+func (f *freezer) repair() error {
+	min := uint64(1<<64 - 1)
+	for _, table := range f.tables {
+		if items := atomic.LoadUint64(&table.items); items < min {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// freeze is a background thread that periodically checks the chain progress in
+// the key-value store and moves the canonical blocks older than the threshold
+// into the freezer, deleting them and their side chains from the store.
+//
+// This is deliberately kept apart from block import so it doesn't delay block
+// propagation.
+func (f *freezer) freeze(db ethdb.Database) {
+	defer f.wg.Done()
+
+	backoff := false
+	for {
+		select {
+		case <-f.quit:
+			log.Info("Freezer shutting down")
+			return
+		default:
+		}
+		if backoff {
+			select {
+			case <-time.After(freezerRecheckInterval):
+				backoff = false
+			case <-f.quit:
+				return
+			}
+		}
+		// Retrieve the freezing threshold from the current full block
+		hash := GetHeadBlockHash(db)
+		if hash == (common.Hash{}) {
+			log.Debug("Current full block hash unavailable")
+			backoff = true
+			continue
+		}
+		number := GetBlockNumber(db, hash)
+		frozen := atomic.LoadUint64(&f.frozen)
+		switch {
+		case number == missingNumber:
+			log.Error("Current full block number unavailable", "hash", hash)
+			backoff = true
+			continue
+
+		case number < f.threshold:
+			log.Debug("Current full block not old enough", "number", number, "hash", hash, "delay", f.threshold)
+			backoff = true
+			continue
+
+		case number-f.threshold <= frozen:
+			log.Debug("Ancient blocks frozen already", "number", number, "hash", hash, "frozen", frozen)
+			backoff = true
+			continue
+		}
+		limit := number - f.threshold
+		if limit-frozen > freezerBatchLimit {
+			limit = frozen + freezerBatchLimit
+		}
+		var (
+			start    = time.Now()
+			first    = frozen
+			ancients = make([]common.Hash, 0, limit-frozen)
+		)
+		for n := first; n < limit; n++ {
+			hash := GetCanonicalHash(db, n)
+			if hash == (common.Hash{}) {
+				log.Error("Canonical hash missing, can't freeze", "number", n)
+				break
+			}
+			header := GetHeaderRLP(db, hash, n)
+			if len(header) == 0 {
+				log.Error("Block header missing, can't freeze", "number", n, "hash", hash)
+				break
+			}
+			// Expired bodies and receipts are frozen as empty items
+			body := GetBodyRLP(db, hash, n)
+			receipts, _ := db.Get(blockReceiptsKey(hash, n))
+			if !IsHistoryPruned(db, n) {
+				if len(body) == 0 {
+					log.Error("Block body missing, can't freeze", "number", n, "hash", hash)
+					break
+				}
+				if len(receipts) == 0 {
+					log.Error("Block receipts missing, can't freeze", "number", n, "hash", hash)
+					break
+				}
+			}
+			td, _ := db.Get(headerTDKey(hash, n))
+			if len(td) == 0 {
+				log.Error("Total difficulty missing, can't freeze", "number", n, "hash", hash)
+				break
+			}
+			if err := f.AppendAncient(n, hash[:], header, body, receipts, td); err != nil {
+				break
+			}
+			ancients = append(ancients, hash)
+		}
+		if len(ancients) == 0 {
+			backoff = true
+			continue
+		}
+		if err := f.Sync(); err != nil {
+			log.Crit("Failed to flush frozen tables", "err", err)
+		}
+		// Wipe the frozen blocks and their side chains from the key-value store,
+		// always keeping the genesis block there
+		batch := db.NewBatch()
+		for i, hash := range ancients {
+			n := first + uint64(i)
+			if n == 0 {
+				continue
+			}
+			DeleteCanonicalHash(batch, n)
+			batch.Delete(headerKey(hash, n))
+			DeleteBody(batch, hash, n)
+			DeleteBlockReceipts(batch, hash, n)
+			DeleteTd(batch, hash, n)
+
+			for _, side := range getHeaderHashes(db, n) {
+				if side != hash {
+					DeleteBlock(batch, side, n)
+				}
+			}
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Crit("Failed to delete frozen blocks", "err", err)
+				}
+				batch = db.NewBatch()
+			}
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to delete frozen blocks", "err", err)
+		}
+		log.Info("Deep froze chain segment", "blocks", len(ancients), "elapsed", common.PrettyDuration(time.Since(start)),
+			"number", first+uint64(len(ancients))-1, "hash", ancients[len(ancients)-1])
+
+		// Avoid database thrashing with tiny writes
+		if len(ancients) < freezerBatchLimit {
+			backoff = true
+		}
+	}
+}
+
+// getHeaderHashes retrieves the hashes of all the headers stored in the
+// key-value store at the given number, canonical or not.
+func getHeaderHashes(db ethdb.Database, number uint64) []common.Hash {
+	var hashes []common.Hash
+
+	it := db.NewIterator(append(headerPrefix, encodeBlockNumber(number)...), nil)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(headerPrefix)+8+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(key)-common.HashLength:]))
+		}
+	}
+	return hashes
+}
+
+// historyTruncater is implemented by the chain databases able to drop expired
+// history from their frozen chain segments.
+type historyTruncater interface {
+	truncateHistory(tail uint64) error
+}
+
+// freezerdb is a database wrapper serving the frozen chain segments next to the
+// key-value store.
+type freezerdb struct {
+	ethdb.Database
+	*freezer
+}
+
+// Close stops the freezer and closes both the freezer tables and the key-value
+// store.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// NewDatabaseWithFreezer wraps a key-value store with a freezer in the given
+// directory, moving the canonical blocks older than threshold out of the store
+// in the background. The chain accessors of this package read the frozen blocks
+// from the wrapper transparently.
+func NewDatabaseWithFreezer(db ethdb.Database, freezer string, threshold uint64) (ethdb.Database, error) {
+	frdb, err := newFreezer(freezer, threshold)
+	if err != nil {
+		return nil, err
+	}
+	// The freezer can be stored apart from the key-value store, make sure the
+	// two belong together before serving any data from them
+	frozen, _ := frdb.Ancients()
+	if kvgenesis, _ := db.Get(headerHashKey(0)); len(kvgenesis) > 0 {
+		if frozen > 0 {
+			if frgenesis, _ := frdb.Ancient(freezerHashTable, 0); !bytes.Equal(kvgenesis, frgenesis) {
+				frdb.Close()
+				return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+			}
+			// The key-value store must continue where the freezer ends
+			if kvhash, _ := db.Get(headerHashKey(frozen)); len(kvhash) == 0 {
+				if head := GetBlockNumber(db, GetHeadHeaderHash(db)); head != missingNumber && head >= frozen {
+					frdb.Close()
+					return nil, fmt.Errorf("gap (#%d) in the chain between ancients and leveldb", frozen)
+				}
+			}
+		}
+	} else if frozen > 0 {
+		frdb.Close()
+		return nil, errors.New("ancient chain segments already extracted, please set --datadir.ancient to the correct path")
+	}
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{Database: db, freezer: frdb}, nil
+}
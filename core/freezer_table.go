@@ -0,0 +1,481 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/golang/snappy"
+)
+
+var (
+	// errClosed is returned if an operation attempts to read from or write to the
+	// freezer table after it has already been closed.
+	errClosed = errors.New("closed")
+
+	// errOutOfBounds is returned if the item requested is not contained within the
+	// freezer table.
+	errOutOfBounds = errors.New("out of bounds")
+)
+
+// indexEntrySize is the size of a serialized index entry: a uint16 file number
+// followed by a uint32 offset.
+const indexEntrySize = 6
+
+// indexEntry contains the number of the data file an item resides in, as well
+// as the offset within that file to the end of the item.
+type indexEntry struct {
+	filenum uint32 // stored as uint16 (2 bytes)
+	offset  uint32 // stored as uint32 (4 bytes)
+}
+
+// unmarshal loads an index entry from its serialized form.
+func (i *indexEntry) unmarshal(b []byte) {
+	i.filenum = uint32(binary.BigEndian.Uint16(b[:2]))
+	i.offset = binary.BigEndian.Uint32(b[2:6])
+}
+
+// marshal serializes the index entry.
+func (i *indexEntry) marshal() []byte {
+	b := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint16(b[:2], uint16(i.filenum))
+	binary.BigEndian.PutUint32(b[2:6], i.offset)
+	return b
+}
+
+// freezerTable is an append-only store of numbered items in flat files. The
+// items are split across data files of at most maxFileSize bytes, and an index
+// file holds the end position of every item. The first index entry only marks
+// the data file the table starts in.
+type freezerTable struct {
+	items uint64 // Number of items stored in the table (atomic, keep first for alignment)
+
+	noCompression bool   // Whether to store the items as is, or snappy compressed
+	maxFileSize   uint32 // Maximum size of a data file before rolling over to the next
+	name          string // Name of the table, used as the file name prefix
+	path          string // Directory holding the table files
+
+	head      *os.File            // Data file currently appended to
+	index     *os.File            // Index file with the end offset of every item
+	files     map[uint32]*os.File // Open data files, keyed by their number
+	tailID    uint32              // Number of the oldest data file kept, see truncateTail
+	headID    uint32              // Number of the head data file
+	headBytes uint32              // Number of bytes written to the head data file
+
+	lock sync.RWMutex // Protects the files from concurrent reads and writes
+}
+
+// newTable opens a freezer table with the default data file size limit.
+func newTable(path string, name string, noCompression bool) (*freezerTable, error) {
+	return newCustomTable(path, name, 2*1000*1000*1000, noCompression)
+}
+
+// newCustomTable opens a freezer table, creating the files if they don't exist
+// and repairing any inconsistency left behind by an unclean shutdown.
+func newCustomTable(path string, name string, maxFileSize uint32, noCompression bool) (*freezerTable, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	ext := "cidx"
+	if noCompression {
+		ext = "ridx"
+	}
+	index, err := os.OpenFile(filepath.Join(path, fmt.Sprintf("%s.%s", name, ext)), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	tab := &freezerTable{
+		noCompression: noCompression,
+		maxFileSize:   maxFileSize,
+		name:          name,
+		path:          path,
+		index:         index,
+		files:         make(map[uint32]*os.File),
+	}
+	if err := tab.repair(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	return tab, nil
+}
+
+// repair cross checks the index against the head data file and truncates both
+// to the last item that was fully written.
+func (t *freezerTable) repair() error {
+	buffer := make([]byte, indexEntrySize)
+
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	// A fresh index starts with the marker of the first data file
+	if stat.Size() == 0 {
+		if _, err := t.index.Write(buffer); err != nil {
+			return err
+		}
+	}
+	// Drop any partially written trailing index entry
+	if overflow := stat.Size() % indexEntrySize; overflow != 0 {
+		if err := t.index.Truncate(stat.Size() - overflow); err != nil {
+			return err
+		}
+	}
+	if stat, err = t.index.Stat(); err != nil {
+		return err
+	}
+	offsetsSize := stat.Size()
+
+	var lastIndex indexEntry
+	if _, err := t.index.ReadAt(buffer, offsetsSize-indexEntrySize); err != nil {
+		return err
+	}
+	lastIndex.unmarshal(buffer)
+
+	if t.head, err = t.openFile(lastIndex.filenum, os.O_RDWR|os.O_CREATE|os.O_APPEND); err != nil {
+		return err
+	}
+	if stat, err = t.head.Stat(); err != nil {
+		return err
+	}
+	contentSize := stat.Size()
+
+	// Keep truncating until the index and the head data file agree
+	for contentExp := int64(lastIndex.offset); contentExp != contentSize; contentExp = int64(lastIndex.offset) {
+		if contentExp < contentSize {
+			// The data file holds an item missing from the index, drop it
+			log.Warn("Truncating dangling freezer data", "table", t.name, "indexed", contentExp, "stored", contentSize)
+			if err := t.head.Truncate(contentExp); err != nil {
+				return err
+			}
+			contentSize = contentExp
+			continue
+		}
+		// The index references data that never made it to disk, drop the entry
+		log.Warn("Truncating dangling freezer index", "table", t.name, "indexed", contentExp, "stored", contentSize)
+		offsetsSize -= indexEntrySize
+		if err := t.index.Truncate(offsetsSize); err != nil {
+			return err
+		}
+		if _, err := t.index.ReadAt(buffer, offsetsSize-indexEntrySize); err != nil {
+			return err
+		}
+		var newLastIndex indexEntry
+		newLastIndex.unmarshal(buffer)
+
+		// The dropped item might have been the first one of a new data file
+		if newLastIndex.filenum != lastIndex.filenum {
+			t.releaseFile(lastIndex.filenum)
+			if t.head, err = t.openFile(newLastIndex.filenum, os.O_RDWR|os.O_APPEND); err != nil {
+				return err
+			}
+			if stat, err = t.head.Stat(); err != nil {
+				return err
+			}
+			contentSize = stat.Size()
+		}
+		lastIndex = newLastIndex
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	if err := t.head.Sync(); err != nil {
+		return err
+	}
+	t.items = uint64(offsetsSize/indexEntrySize - 1)
+	t.headID = lastIndex.filenum
+	t.headBytes = uint32(contentSize)
+
+	// Open all the older data files for reading, the ones before the tail were
+	// removed by truncateTail
+	t.tailID = t.headID
+	for num := t.headID; num > 0; num-- {
+		if _, err := t.openFile(num-1, os.O_RDONLY); err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return err
+		}
+		t.tailID = num - 1
+	}
+	return nil
+}
+
+// fileName returns the name of the data file with the given number.
+func (t *freezerTable) fileName(num uint32) string {
+	ext := "cdat"
+	if t.noCompression {
+		ext = "rdat"
+	}
+	return filepath.Join(t.path, fmt.Sprintf("%s.%04d.%s", t.name, num, ext))
+}
+
+// openFile opens the data file with the given number, or returns it if already
+// open.
+func (t *freezerTable) openFile(num uint32, flag int) (*os.File, error) {
+	if f, ok := t.files[num]; ok {
+		return f, nil
+	}
+	f, err := os.OpenFile(t.fileName(num), flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	t.files[num] = f
+	return f, nil
+}
+
+// releaseFile closes the data file with the given number.
+func (t *freezerTable) releaseFile(num uint32) {
+	if f, ok := t.files[num]; ok {
+		delete(t.files, num)
+		f.Close()
+	}
+}
+
+// Append injects a binary blob at the end of the table. The item number must be
+// the next one in line, so concurrent appends aren't supported.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if items := atomic.LoadUint64(&t.items); items != item {
+		return fmt.Errorf("appending unexpected item: want %d, have %d", items, item)
+	}
+	if !t.noCompression {
+		blob = snappy.Encode(nil, blob)
+	}
+	size := uint32(len(blob))
+
+	// Roll over to a new data file if the current one would grow too large
+	if t.headBytes > 0 && (t.headBytes+size < size || t.headBytes+size > t.maxFileSize) {
+		next := t.headID + 1
+		head, err := t.openFile(next, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND)
+		if err != nil {
+			return err
+		}
+		// Reopen the previous head for reading only
+		t.releaseFile(t.headID)
+		if _, err := t.openFile(t.headID, os.O_RDONLY); err != nil {
+			return err
+		}
+		t.head, t.headID, t.headBytes = head, next, 0
+	}
+	if _, err := t.head.Write(blob); err != nil {
+		return err
+	}
+	t.headBytes += size
+
+	entry := indexEntry{filenum: t.headID, offset: t.headBytes}
+	if _, err := t.index.Write(entry.marshal()); err != nil {
+		return err
+	}
+	atomic.AddUint64(&t.items, 1)
+	return nil
+}
+
+// Retrieve looks up the item at the given number and returns it.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return nil, errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= item {
+		return nil, errOutOfBounds
+	}
+	// Both the start and the end of the item are in the index
+	buffer := make([]byte, 2*indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, int64(item*indexEntrySize)); err != nil {
+		return nil, err
+	}
+	var start, end indexEntry
+	start.unmarshal(buffer[:indexEntrySize])
+	end.unmarshal(buffer[indexEntrySize:])
+
+	// An item that starts a new data file begins at its start
+	if start.filenum != end.filenum {
+		start.offset = 0
+	}
+	f, ok := t.files[end.filenum]
+	if !ok {
+		return nil, fmt.Errorf("missing data file %d", end.filenum)
+	}
+	blob := make([]byte, end.offset-start.offset)
+	if _, err := f.ReadAt(blob, int64(start.offset)); err != nil {
+		return nil, err
+	}
+	if t.noCompression {
+		return blob, nil
+	}
+	return snappy.Decode(nil, blob)
+}
+
+// has returns whether the table contains the item at the given number.
+func (t *freezerTable) has(item uint64) bool {
+	return atomic.LoadUint64(&t.items) > item
+}
+
+// truncate discards all the items from the given number on.
+func (t *freezerTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= items {
+		return nil
+	}
+	log.Warn("Truncating freezer table", "table", t.name, "items", atomic.LoadUint64(&t.items), "limit", items)
+	if err := t.index.Truncate(int64(items+1) * indexEntrySize); err != nil {
+		return err
+	}
+	buffer := make([]byte, indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+		return err
+	}
+	var expected indexEntry
+	expected.unmarshal(buffer)
+
+	// Drop the data files past the new head altogether
+	if expected.filenum != t.headID {
+		for num := range t.files {
+			if num >= expected.filenum {
+				t.releaseFile(num)
+			}
+		}
+		for num := expected.filenum + 1; num <= t.headID; num++ {
+			if err := os.Remove(t.fileName(num)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		head, err := t.openFile(expected.filenum, os.O_RDWR|os.O_APPEND)
+		if err != nil {
+			return err
+		}
+		t.head, t.headID = head, expected.filenum
+	}
+	if err := t.head.Truncate(int64(expected.offset)); err != nil {
+		return err
+	}
+	t.headBytes = expected.offset
+	atomic.StoreUint64(&t.items, items)
+	return nil
+}
+
+// truncateTail removes the data files holding only items below the given
+// number. The index is kept so the items don't get renumbered, but the removed
+// ones can't be retrieved anymore. Data is only freed by whole files, and the
+// head data file is never removed.
+func (t *freezerTable) truncateTail(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	// The first item kept ends in the data file of the index entry after it
+	keep := t.headID
+	if items < atomic.LoadUint64(&t.items) {
+		buffer := make([]byte, indexEntrySize)
+		if _, err := t.index.ReadAt(buffer, int64((items+1)*indexEntrySize)); err != nil {
+			return err
+		}
+		var entry indexEntry
+		entry.unmarshal(buffer)
+		keep = entry.filenum
+	}
+	for ; t.tailID < keep; t.tailID++ {
+		t.releaseFile(t.tailID)
+		if err := os.Remove(t.fileName(t.tailID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// size returns the total data size of the table.
+func (t *freezerTable) size() (uint64, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return 0, errClosed
+	}
+	size := uint64(t.headBytes)
+	for num, f := range t.files {
+		if num == t.headID {
+			continue
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		size += uint64(stat.Size())
+	}
+	return size, nil
+}
+
+// Sync pushes any pending data from memory out to disk.
+func (t *freezerTable) Sync() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	return t.head.Sync()
+}
+
+// Close closes all the files of the table.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	if t.index != nil {
+		if err := t.index.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.index = nil
+	}
+	for num, f := range t.files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(t.files, num)
+	}
+	t.head = nil
+
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// getChunk returns a chunk of data of the given size, filled with b.
+func getChunk(size int, b int) []byte {
+	return bytes.Repeat([]byte{byte(b)}, size)
+}
+
+// newTestTable opens a table with tiny data files, so that the items spread
+// across many of them.
+func newTestTable(t *testing.T, dir string, compress bool) *freezerTable {
+	table, err := newCustomTable(dir, "test", 50, !compress)
+	if err != nil {
+		t.Fatalf("failed to open table: %v", err)
+	}
+	return table
+}
+
+// checkItems verifies that the table holds exactly the given number of items of
+// 15 bytes each, filled with their own number.
+func checkItems(t *testing.T, table *freezerTable, items uint64) {
+	if table.items != items {
+		t.Fatalf("item count mismatch: have %d, want %d", table.items, items)
+	}
+	for i := uint64(0); i < items; i++ {
+		blob, err := table.Retrieve(i)
+		if err != nil {
+			t.Fatalf("item %d: failed to retrieve: %v", i, err)
+		}
+		if want := getChunk(15, int(i)); !bytes.Equal(blob, want) {
+			t.Fatalf("item %d: data mismatch: have %x, want %x", i, blob, want)
+		}
+	}
+	if _, err := table.Retrieve(items); err != errOutOfBounds {
+		t.Fatalf("item %d: error mismatch: have %v, want %v", items, err, errOutOfBounds)
+	}
+}
+
+// Tests that items are stored across data files and survive a restart.
+func TestFreezerBasics(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "freezer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		table := newTestTable(t, dir, compress)
+		for i := 0; i < 255; i++ {
+			if err := table.Append(uint64(i), getChunk(15, i)); err != nil {
+				t.Fatalf("item %d: failed to append: %v", i, err)
+			}
+		}
+		if err := table.Append(300, getChunk(15, 300)); err == nil {
+			t.Fatalf("out of order append succeeded")
+		}
+		checkItems(t, table, 255)
+		if !compress && table.headID == 0 {
+			t.Fatalf("data files not rolled over")
+		}
+		table.Close()
+
+		table = newTestTable(t, dir, compress)
+		checkItems(t, table, 255)
+		table.Close()
+	}
+}
+
+// Tests that a crash in the middle of an append is repaired on startup.
+func TestFreezerRepairDangling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := newTestTable(t, dir, false)
+	for i := 0; i < 9; i++ {
+		table.Append(uint64(i), getChunk(15, i))
+	}
+	head := table.fileName(table.headID)
+	table.Close()
+
+	// Cut off a part of the last index entry
+	index := filepath.Join(dir, "test.ridx")
+	stat, err := os.Stat(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(index, stat.Size()-4); err != nil {
+		t.Fatal(err)
+	}
+	table = newTestTable(t, dir, false)
+	checkItems(t, table, 8)
+	table.Close()
+
+	// Append garbage to the head data file, as if the index write got lost
+	f, err := os.OpenFile(head, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(getChunk(7, 0xff))
+	f.Close()
+
+	table = newTestTable(t, dir, false)
+	checkItems(t, table, 8)
+	if err := table.Append(8, getChunk(15, 8)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	checkItems(t, table, 9)
+	table.Close()
+}
+
+// Tests that truncating drops the items and the data files past the limit.
+func TestFreezerTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := newTestTable(t, dir, false)
+	for i := 0; i < 30; i++ {
+		table.Append(uint64(i), getChunk(15, i))
+	}
+	last := table.fileName(table.headID)
+
+	if err := table.truncate(10); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	checkItems(t, table, 10)
+	if _, err := os.Stat(last); !os.IsNotExist(err) {
+		t.Fatalf("truncated data file still present: %v", err)
+	}
+	for i := 10; i < 20; i++ {
+		if err := table.Append(uint64(i), getChunk(15, i)); err != nil {
+			t.Fatalf("item %d: failed to append: %v", i, err)
+		}
+	}
+	table.Close()
+
+	table = newTestTable(t, dir, false)
+	checkItems(t, table, 20)
+	table.Close()
+}
+
+// Tests that truncating the tail drops the data files holding only items below
+// the limit, keeping the numbering of the remaining ones.
+func TestFreezerTruncateTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := newTestTable(t, dir, false)
+	for i := 0; i < 30; i++ {
+		table.Append(uint64(i), getChunk(15, i))
+	}
+	// Three items fit in a data file, item 10 is the second one of the fourth
+	first := table.fileName(0)
+	if err := table.truncateTail(10); err != nil {
+		t.Fatalf("failed to truncate tail: %v", err)
+	}
+	check := func() {
+		if _, err := os.Stat(first); !os.IsNotExist(err) {
+			t.Fatalf("truncated data file still present: %v", err)
+		}
+		if _, err := table.Retrieve(8); err == nil {
+			t.Fatalf("item in a removed data file retrieved")
+		}
+		for i := 9; i < 30; i++ {
+			blob, err := table.Retrieve(uint64(i))
+			if err != nil {
+				t.Fatalf("item %d: failed to retrieve: %v", i, err)
+			}
+			if want := getChunk(15, i); !bytes.Equal(blob, want) {
+				t.Fatalf("item %d: data mismatch: have %x, want %x", i, blob, want)
+			}
+		}
+	}
+	check()
+	table.Close()
+
+	table = newTestTable(t, dir, false)
+	defer table.Close()
+	if table.items != 30 || table.tailID != 3 {
+		t.Fatalf("reopened table mismatch: have %d items from file %d, want 30 from 3", table.items, table.tailID)
+	}
+	check()
+	if err := table.Append(30, getChunk(15, 30)); err != nil {
+		t.Fatalf("failed to append after tail truncation: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus/ethash"
+	"github.com/MeshBoxFoundation/meshbox/core/vm"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/params"
+)
+
+// Tests that the old canonical blocks are moved into the freezer and are still
+// served from there.
+func TestFreezerMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 64, nil)
+	chain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	frdb, err := NewDatabaseWithFreezer(db, dir, 16)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	defer frdb.Close()
+
+	// Wait until all but the last 16 blocks are frozen and wiped from the store
+	ancients := frdb.(ethdb.AncientStore)
+	for i := 0; ; i++ {
+		frozen, _ := ancients.Ancients()
+		if has, _ := db.Has(headerKey(blocks[46].Hash(), 47)); frozen == 48 && !has {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("blocks not frozen: have %d, want %d", frozen, 48)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if has, _ := db.Has(headerKey(genesis.Hash(), 0)); !has {
+		t.Errorf("genesis wiped from the store")
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		if have := GetCanonicalHash(frdb, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if stored := GetBlock(frdb, hash, number); stored == nil || stored.Hash() != hash {
+			t.Errorf("block %d: block not found", number)
+		}
+		if GetTd(frdb, hash, number) == nil {
+			t.Errorf("block %d: total difficulty not found", number)
+		}
+		if GetBlockReceipts(frdb, hash, number) == nil {
+			t.Errorf("block %d: receipts not found", number)
+		}
+		if !HasHeader(frdb, hash, number) || !HasBody(frdb, hash, number) {
+			t.Errorf("block %d: block not reported present", number)
+		}
+		if GetHeader(frdb, common.Hash{0x01}, number) != nil {
+			t.Errorf("block %d: header served for unknown hash", number)
+		}
+	}
+	// Rewinding drops the frozen blocks past the new head
+	if err := ancients.TruncateAncients(32); err != nil {
+		t.Fatalf("failed to truncate freezer: %v", err)
+	}
+	if hash := GetCanonicalHash(frdb, 40); hash != (common.Hash{}) {
+		t.Errorf("truncated block still served: %x", hash)
+	}
+}
+
+// Tests that history expiry covers the frozen blocks too, which stop being
+// served and reported present.
+func TestFreezerHistoryExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 64, nil)
+	chain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	frdb, err := NewDatabaseWithFreezer(db, dir, 32)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	defer frdb.Close()
+	for i := 0; ; i++ {
+		if frozen, _ := frdb.(ethdb.AncientStore).Ancients(); frozen == 32 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("blocks not frozen")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	chain, _ = NewBlockChain(frdb, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	chain.historyKeep = 16
+	chain.pruneHistory()
+
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		pruned := number < 48
+		if HasBody(frdb, hash, number) == pruned || (len(GetBodyRLP(frdb, hash, number)) == 0) != pruned {
+			t.Errorf("block %d: body presence mismatch, want pruned %v", number, pruned)
+		}
+		if (GetBlockReceipts(frdb, hash, number) == nil) != pruned {
+			t.Errorf("block %d: receipts presence mismatch, want pruned %v", number, pruned)
+		}
+		if !HasHeader(frdb, hash, number) {
+			t.Errorf("block %d: header expired", number)
+		}
+	}
+}
+
+// Tests that a freezer isn't attached to a key-value store it doesn't belong to.
+func TestFreezerMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, _ := ethdb.NewMemDatabase()
+	gspec := &Genesis{Config: params.TestChainConfig}
+	genesis := gspec.MustCommit(db)
+
+	frdb, err := NewDatabaseWithFreezer(db, dir, 16)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	ancients := frdb.(ethdb.AncientStore)
+	if err := ancients.AppendAncient(0, genesis.Hash().Bytes(), GetHeaderRLP(db, genesis.Hash(), 0), GetBodyRLP(db, genesis.Hash(), 0), []byte{0xc0}, []byte{0x80}); err != nil {
+		t.Fatalf("failed to freeze genesis: %v", err)
+	}
+	frdb.Close()
+
+	// An empty store can't continue after the frozen genesis
+	empty, _ := ethdb.NewMemDatabase()
+	if _, err := NewDatabaseWithFreezer(empty, dir, 16); err == nil {
+		t.Errorf("freezer attached to an empty store")
+	}
+	// Neither can a store of another network
+	other, _ := ethdb.NewMemDatabase()
+	(&Genesis{Config: params.TestChainConfig, ExtraData: []byte("other")}).MustCommit(other)
+	if _, err := NewDatabaseWithFreezer(other, dir, 16); err == nil {
+		t.Errorf("freezer attached to a store of another network")
+	}
+}
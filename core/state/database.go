@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
 	"github.com/MeshBoxFoundation/meshbox/ethdb"
 	"github.com/MeshBoxFoundation/meshbox/trie"
 	lru "github.com/hashicorp/golang-lru"
@@ -70,11 +71,21 @@ func NewDatabase(db ethdb.Database) Database {
 	return &cachingDB{db: db, codeSizeCache: csc}
 }
 
+// SetSnapshots attaches a snapshot tree to a database created by NewDatabase.
+// The states opened on it afterwards read through the snapshot of their root,
+// and feed their changes into the tree on commit.
+func SetSnapshots(db Database, snaps *snapshot.Tree) {
+	if db, ok := db.(*cachingDB); ok {
+		db.snaps = snaps
+	}
+}
+
 type cachingDB struct {
 	db            ethdb.Database
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
+	snaps         *snapshot.Tree
 }
 
 func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
@@ -0,0 +1,163 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+)
+
+// diffLayer holds the state changes of a block on top of its parent layer. The
+// destructs are applied first: the accounts in there are wiped together with
+// all their storage. The accounts and slots written come after, a nil slot is
+// a deletion.
+type diffLayer struct {
+	parent snapshot
+	root   common.Hash
+	stale  bool
+
+	destructs map[common.Hash]struct{}
+	accounts  map[common.Hash][]byte
+	storage   map[common.Hash]map[common.Hash][]byte
+
+	lock sync.RWMutex
+}
+
+// newDiffLayer creates a diff layer on top of parent. Nil maps are replaced by
+// empty ones.
+func newDiffLayer(parent snapshot, root common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer {
+	if destructs == nil {
+		destructs = make(map[common.Hash]struct{})
+	}
+	if accounts == nil {
+		accounts = make(map[common.Hash][]byte)
+	}
+	if storage == nil {
+		storage = make(map[common.Hash]map[common.Hash][]byte)
+	}
+	return &diffLayer{
+		parent:    parent,
+		root:      root,
+		destructs: destructs,
+		accounts:  accounts,
+		storage:   storage,
+	}
+}
+
+// Root returns the state root the layer belongs to.
+func (dl *diffLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Stale returns whether the layer was flattened or dropped from the tree.
+func (dl *diffLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+func (dl *diffLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+func (dl *diffLayer) getParent() snapshot {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.parent
+}
+
+func (dl *diffLayer) setParent(parent snapshot) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.parent = parent
+}
+
+// AccountRLP retrieves the RLP encoded account with the given address hash,
+// looking through the parent layers if it wasn't changed in this one.
+func (dl *diffLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if data, ok := dl.accounts[hash]; ok {
+		dl.lock.RUnlock()
+		return data, nil
+	}
+	if _, ok := dl.destructs[hash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.AccountRLP(hash)
+}
+
+// Storage retrieves the RLP encoded storage slot of an account, looking through
+// the parent layers if it wasn't changed in this one.
+func (dl *diffLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if data, ok := dl.storage[accountHash][storageHash]; ok {
+		dl.lock.RUnlock()
+		return data, nil
+	}
+	if _, ok := dl.destructs[accountHash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.Storage(accountHash, storageHash)
+}
+
+// absorb merges the changes of a child layer into this one.
+func (dl *diffLayer) absorb(child *diffLayer) {
+	child.lock.RLock()
+	defer child.lock.RUnlock()
+
+	for hash := range child.destructs {
+		dl.destructs[hash] = struct{}{}
+		delete(dl.accounts, hash)
+		delete(dl.storage, hash)
+	}
+	for hash, data := range child.accounts {
+		dl.accounts[hash] = data
+	}
+	for hash, slots := range child.storage {
+		merged, ok := dl.storage[hash]
+		if !ok {
+			merged = make(map[common.Hash][]byte, len(slots))
+			dl.storage[hash] = merged
+		}
+		for slot, data := range slots {
+			merged[slot] = data
+		}
+	}
+}
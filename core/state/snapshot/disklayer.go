@@ -0,0 +1,173 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+)
+
+// diskLayer is the bottom layer of the tree, the flat state of a root stored in
+// the key-value database.
+type diskLayer struct {
+	diskdb ethdb.Database
+	root   common.Hash
+	stale  bool
+
+	genDone  chan struct{}      // Closed once the flat state of root is complete
+	genAbort chan chan struct{} // Channel to abort the generation on, nil if not running
+
+	lock sync.RWMutex
+}
+
+// Root returns the state root the layer belongs to.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Stale returns whether the layer was replaced by a newer disk layer.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// generated returns whether the flat state of the layer is complete.
+func (dl *diskLayer) generated() bool {
+	select {
+	case <-dl.genDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// abort stops the background generation if it's still running, and waits for
+// it to exit.
+func (dl *diskLayer) abort() {
+	dl.lock.Lock()
+	abort := dl.genAbort
+	dl.genAbort = nil
+	dl.lock.Unlock()
+
+	if abort != nil {
+		done := make(chan struct{})
+		abort <- done
+		<-done
+	}
+}
+
+// AccountRLP retrieves the RLP encoded account with the given address hash.
+func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.generated() {
+		return nil, ErrNotCoveredYet
+	}
+	data, _ := dl.diskdb.Get(accountKey(hash))
+	return data, nil
+}
+
+// Storage retrieves the RLP encoded storage slot of an account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.generated() {
+		return nil, ErrNotCoveredYet
+	}
+	data, _ := dl.diskdb.Get(storageKey(accountHash, storageHash))
+	return data, nil
+}
+
+// commit writes the changes of a diff layer sitting right on this disk layer
+// into the database, and returns the disk layer of the new root. The root
+// marker is dropped while writing, so a crash half way through regenerates the
+// snapshot on the next start.
+func (dl *diskLayer) commit(diff *diffLayer) (*diskLayer, error) {
+	if err := dl.diskdb.Delete(RootKey); err != nil {
+		return nil, err
+	}
+	batch := dl.diskdb.NewBatch()
+	flush := func(force bool) error {
+		if !force && batch.ValueSize() < ethdb.IdealBatchSize {
+			return nil
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch = dl.diskdb.NewBatch()
+		return nil
+	}
+	for hash := range diff.destructs {
+		batch.Delete(accountKey(hash))
+
+		it := dl.diskdb.NewIterator(append(append([]byte{}, StoragePrefix...), hash.Bytes()...), nil)
+		for it.Next() {
+			if isStorageKey(it.Key()) {
+				batch.Delete(common.CopyBytes(it.Key()))
+			}
+		}
+		it.Release()
+
+		if err := flush(false); err != nil {
+			return nil, err
+		}
+	}
+	for hash, data := range diff.accounts {
+		batch.Put(accountKey(hash), data)
+		if err := flush(false); err != nil {
+			return nil, err
+		}
+	}
+	for hash, slots := range diff.storage {
+		for slot, data := range slots {
+			if len(data) == 0 {
+				batch.Delete(storageKey(hash, slot))
+			} else {
+				batch.Put(storageKey(hash, slot), data)
+			}
+		}
+		if err := flush(false); err != nil {
+			return nil, err
+		}
+	}
+	batch.Put(RootKey, diff.root.Bytes())
+	if err := flush(true); err != nil {
+		return nil, err
+	}
+	disk := &diskLayer{diskdb: dl.diskdb, root: diff.root, genDone: make(chan struct{})}
+	close(disk.genDone)
+	return disk, nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+	"github.com/MeshBoxFoundation/meshbox/trie"
+)
+
+// account is the consensus representation of an account, as stored in the
+// state trie and in the snapshot.
+type account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// generateSnapshot wipes the flat state in diskdb and starts regenerating it
+// from the state trie at root in the background.
+func generateSnapshot(diskdb ethdb.Database, root common.Hash) *diskLayer {
+	disk := &diskLayer{
+		diskdb:   diskdb,
+		root:     root,
+		genDone:  make(chan struct{}),
+		genAbort: make(chan chan struct{}),
+	}
+	go disk.generate()
+	return disk
+}
+
+// generate fills the flat state of the disk layer from the state trie. It
+// stops early if aborted or if the trie is missing nodes.
+func (dl *diskLayer) generate() {
+	var (
+		abort   = dl.genAbort
+		batch   = dl.diskdb.NewBatch()
+		start   = time.Now()
+		logged  = time.Now()
+		aborted chan struct{}
+
+		accounts, slots uint64
+	)
+	// Flush the batch if it grew big enough, and check for abortion meanwhile
+	flush := func(force bool) bool {
+		if !force && batch.ValueSize() < ethdb.IdealBatchSize {
+			return true
+		}
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to write snapshot", "err", err)
+			return false
+		}
+		batch = dl.diskdb.NewBatch()
+
+		select {
+		case aborted = <-abort:
+			return false
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return true
+	}
+	// Wait for the abort request if the generation stopped on its own
+	defer func() {
+		if aborted == nil {
+			aborted = <-abort
+		}
+		close(aborted)
+	}()
+
+	dl.diskdb.Delete(RootKey)
+	if !wipeSnapshot(dl.diskdb) {
+		return
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.diskdb, 0)
+	if err != nil {
+		log.Error("Failed to open state trie for snapshot", "root", dl.root, "err", err)
+		return
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		hash := common.BytesToHash(accIt.Key)
+		batch.Put(accountKey(hash), common.CopyBytes(accIt.Value))
+		accounts++
+
+		var acc account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			log.Error("Invalid account in state trie", "hash", hash, "err", err)
+			return
+		}
+		if acc.Root != types.EmptyRootHash {
+			storageTrie, err := trie.NewSecure(acc.Root, dl.diskdb, 0)
+			if err != nil {
+				log.Error("Failed to open storage trie for snapshot", "hash", hash, "root", acc.Root, "err", err)
+				return
+			}
+			storageIt := trie.NewIterator(storageTrie.NodeIterator(nil))
+			for storageIt.Next() {
+				batch.Put(storageKey(hash, common.BytesToHash(storageIt.Key)), common.CopyBytes(storageIt.Value))
+				slots++
+
+				if !flush(false) {
+					return
+				}
+			}
+			if storageIt.Err != nil {
+				log.Error("Failed to iterate storage trie for snapshot", "hash", hash, "err", storageIt.Err)
+				return
+			}
+		}
+		if !flush(false) {
+			return
+		}
+	}
+	if accIt.Err != nil {
+		log.Error("Failed to iterate state trie for snapshot", "root", dl.root, "err", accIt.Err)
+		return
+	}
+	// Mark the snapshot complete, unless an abort request is already waiting
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	if dl.genAbort == nil {
+		return
+	}
+	batch.Put(RootKey, dl.root.Bytes())
+	if !flush(true) {
+		return
+	}
+	dl.genAbort = nil
+	aborted = make(chan struct{}) // Nobody will abort anymore
+	close(dl.genDone)
+
+	log.Info("Generated snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// wipeSnapshot deletes all the flat accounts and storage slots from db.
+func wipeSnapshot(db ethdb.Database) bool {
+	for _, prefix := range [][]byte{AccountPrefix, StoragePrefix} {
+		batch := db.NewBatch()
+		it := db.NewIterator(prefix, nil)
+		for it.Next() {
+			if key := it.Key(); isAccountKey(key) || isStorageKey(key) {
+				batch.Delete(common.CopyBytes(key))
+			}
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Error("Failed to wipe snapshot", "err", err)
+					it.Release()
+					return false
+				}
+				batch = db.NewBatch()
+			}
+		}
+		it.Release()
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to wipe snapshot", "err", err)
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks that the flat state stored in db is exactly the state of the
+// trie at root: every account and slot matches, and there are no extra ones.
+func Verify(db ethdb.Database, root common.Hash) error {
+	if have := ReadRoot(db); have != root {
+		return fmt.Errorf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+
+		accounts, slots uint64
+	)
+	accTrie, err := trie.NewSecure(root, db, 0)
+	if err != nil {
+		return err
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		hash := common.BytesToHash(accIt.Key)
+		if data, _ := db.Get(accountKey(hash)); !bytes.Equal(data, accIt.Value) {
+			return fmt.Errorf("account %x mismatch: have %x, want %x", hash, data, accIt.Value)
+		}
+		accounts++
+
+		var acc account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			return fmt.Errorf("invalid account %x: %v", hash, err)
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		storageTrie, err := trie.NewSecure(acc.Root, db, 0)
+		if err != nil {
+			return err
+		}
+		storageIt := trie.NewIterator(storageTrie.NodeIterator(nil))
+		for storageIt.Next() {
+			slot := common.BytesToHash(storageIt.Key)
+			if data, _ := db.Get(storageKey(hash, slot)); !bytes.Equal(data, storageIt.Value) {
+				return fmt.Errorf("account %x slot %x mismatch: have %x, want %x", hash, slot, data, storageIt.Value)
+			}
+			slots++
+		}
+		if storageIt.Err != nil {
+			return storageIt.Err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if accIt.Err != nil {
+		return accIt.Err
+	}
+	// All the trie entries are present, make sure there are no leftovers
+	if have := countKeys(db, AccountPrefix, isAccountKey); have != accounts {
+		return fmt.Errorf("account count mismatch: have %d, want %d", have, accounts)
+	}
+	if have := countKeys(db, StoragePrefix, isStorageKey); have != slots {
+		return fmt.Errorf("slot count mismatch: have %d, want %d", have, slots)
+	}
+	log.Info("Verified snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// countKeys counts the keys with the given prefix accepted by the filter.
+func countKeys(db ethdb.Database, prefix []byte, filter func([]byte) bool) uint64 {
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var count uint64
+	for it.Next() {
+		if filter(it.Key()) {
+			count++
+		}
+	}
+	return count
+}
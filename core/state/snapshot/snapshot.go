@@ -0,0 +1,283 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat key-value snapshot of the accounts and
+// storage slots of the state, so reads don't have to walk the state trie.
+//
+// The snapshot is a tree of layers: a disk layer holding the flat state of some
+// older root, and in-memory diff layers on top of it with the changes of each
+// recent block. Old diff layers are flattened into the disk layer as the chain
+// progresses.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/log"
+)
+
+var (
+	// AccountPrefix + account hash -> account RLP, as stored in the state trie
+	AccountPrefix = []byte("a")
+
+	// StoragePrefix + account hash + slot hash -> slot RLP, as stored in the
+	// storage trie
+	StoragePrefix = []byte("o")
+
+	// RootKey tracks the state root whose flat state the disk layer holds
+	RootKey = []byte("SnapshotRoot")
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the layer was
+	// flattened or dropped from the tree while being read.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors while the disk layer is
+	// still being generated.
+	ErrNotCoveredYet = errors.New("not covered yet")
+)
+
+// Snapshot represents the flat state at a given state root.
+type Snapshot interface {
+	// Root returns the state root the snapshot belongs to.
+	Root() common.Hash
+
+	// AccountRLP retrieves the RLP encoded account with the given address hash,
+	// or nil if the account doesn't exist.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage retrieves the RLP encoded storage slot with the given slot hash
+	// of the account with the given address hash, or nil if the slot is empty.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is a layer of the tree, either the disk layer or a diff layer.
+type snapshot interface {
+	Snapshot
+
+	// Stale returns whether the layer was flattened or dropped from the tree.
+	Stale() bool
+}
+
+// accountKey = AccountPrefix + hash
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, AccountPrefix...), hash.Bytes()...)
+}
+
+// storageKey = StoragePrefix + account hash + slot hash
+func storageKey(accountHash, storageHash common.Hash) []byte {
+	return append(append(append([]byte{}, StoragePrefix...), accountHash.Bytes()...), storageHash.Bytes()...)
+}
+
+// isAccountKey returns whether the key is a snapshot account. Trie nodes are
+// keyed by their bare hash and may share the prefix, but not the length.
+func isAccountKey(key []byte) bool {
+	return len(key) == len(AccountPrefix)+common.HashLength && key[0] == AccountPrefix[0]
+}
+
+// isStorageKey returns whether the key is a snapshot storage slot.
+func isStorageKey(key []byte) bool {
+	return len(key) == len(StoragePrefix)+2*common.HashLength && key[0] == StoragePrefix[0]
+}
+
+// Tree is the set of snapshot layers, keyed by the state root they belong to.
+type Tree struct {
+	diskdb ethdb.Database
+	layers map[common.Hash]snapshot
+	disk   *diskLayer // Current disk layer, possibly still generating
+	lock   sync.RWMutex
+}
+
+// New opens the snapshot stored in diskdb. If it doesn't belong to the given
+// state root, it's wiped and regenerated from the state trie in the background.
+func New(diskdb ethdb.Database, root common.Hash) *Tree {
+	tree := &Tree{diskdb: diskdb}
+	if have := ReadRoot(diskdb); have == root {
+		disk := &diskLayer{diskdb: diskdb, root: root, genDone: make(chan struct{})}
+		close(disk.genDone)
+		tree.disk, tree.layers = disk, map[common.Hash]snapshot{root: disk}
+	} else {
+		log.Info("Snapshot out of date, regenerating", "have", have, "want", root)
+		tree.Rebuild(root)
+	}
+	return tree
+}
+
+// ReadRoot retrieves the state root the snapshot on disk belongs to.
+func ReadRoot(db ethdb.Database) common.Hash {
+	data, _ := db.Get(RootKey)
+	return common.BytesToHash(data)
+}
+
+// Snapshot retrieves the snapshot of the given state root, or nil if the tree
+// has no layer for it.
+func (t *Tree) Snapshot(root common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[root]; ok {
+		return layer
+	}
+	return nil
+}
+
+// Update adds a diff layer on top of the layer of parentRoot, with the changes
+// leading to root: the accounts destructed (their storage wiped), then the
+// accounts and storage slots written. Nil slot values are deletions.
+func (t *Tree) Update(root, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.layers[root]; ok {
+		return nil
+	}
+	parent, ok := t.layers[parentRoot]
+	if !ok {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	t.layers[root] = newDiffLayer(parent, root, destructs, accounts, storage)
+	return nil
+}
+
+// Cap keeps the given number of diff layers below and including root, and
+// flattens the ones under them into the disk layer. Layers on forks that don't
+// reach the new bottom are dropped. While the disk layer is still generating,
+// the flattened layers are merged into a single diff layer instead.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	layer, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := layer.(*diffLayer)
+	if !ok {
+		return nil // Already on disk
+	}
+	// Find the top of the layers to flatten, and the lowest layer kept on it
+	var keep *diffLayer
+	if layers > 0 {
+		for i := 1; i < layers; i++ {
+			if diff, ok = diff.getParent().(*diffLayer); !ok {
+				return nil
+			}
+		}
+		keep = diff
+		if diff, ok = keep.getParent().(*diffLayer); !ok {
+			return nil
+		}
+	}
+	base, err := t.flatten(diff)
+	if err != nil {
+		return err
+	}
+	if keep != nil {
+		keep.setParent(base)
+	}
+	// Drop all the layers not built on the new bottom
+	remaining := map[common.Hash]snapshot{base.Root(): base}
+	for root, layer := range t.layers {
+		for parent := layer; ; {
+			if parent == base {
+				remaining[root] = layer
+				break
+			}
+			diff, ok := parent.(*diffLayer)
+			if !ok || diff.Stale() {
+				if diff, ok := layer.(*diffLayer); ok {
+					diff.markStale()
+				}
+				break
+			}
+			parent = diff.getParent()
+		}
+	}
+	t.layers = remaining
+	return nil
+}
+
+// flatten merges the given diff layer and all the ones under it, marking them
+// stale. The result is written into a new disk layer, or returned as a single
+// diff layer over the old one while that is still generating.
+func (t *Tree) flatten(top *diffLayer) (snapshot, error) {
+	var chain []*diffLayer
+	for layer := top; ; {
+		chain = append(chain, layer)
+		parent, ok := layer.getParent().(*diffLayer)
+		if !ok {
+			break
+		}
+		layer = parent
+	}
+	merged := newDiffLayer(t.disk, top.root, nil, nil, nil)
+	for i := len(chain) - 1; i >= 0; i-- {
+		merged.absorb(chain[i])
+		chain[i].markStale()
+	}
+	if !t.disk.generated() {
+		return merged, nil
+	}
+	disk, err := t.disk.commit(merged)
+	if err != nil {
+		return nil, err
+	}
+	t.disk.markStale()
+	t.disk = disk
+	return disk, nil
+}
+
+// Rebuild drops all the layers and regenerates the disk layer from the state
+// trie at root in the background.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.disk != nil {
+		t.disk.abort()
+		t.disk.markStale()
+	}
+	for _, layer := range t.layers {
+		if diff, ok := layer.(*diffLayer); ok {
+			diff.markStale()
+		}
+	}
+	t.disk = generateSnapshot(t.diskdb, root)
+	t.layers = map[common.Hash]snapshot{root: t.disk}
+}
+
+// Close stops the background generation and flattens all the layers up to root
+// into the disk layer, so the snapshot is reused on the next start. If the disk
+// layer wasn't fully generated, it's regenerated from scratch then.
+func (t *Tree) Close(root common.Hash) error {
+	t.lock.RLock()
+	disk, layer := t.disk, t.layers[root]
+	t.lock.RUnlock()
+
+	disk.abort()
+	if !disk.generated() {
+		return nil
+	}
+	if layer == nil {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	return t.Cap(root, 0)
+}
@@ -0,0 +1,193 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+	"github.com/MeshBoxFoundation/meshbox/trie"
+)
+
+// waitGenerated waits until the snapshot of root can serve reads.
+func waitGenerated(t *testing.T, tree *Tree, root common.Hash) {
+	for i := 0; ; i++ {
+		snap := tree.Snapshot(root)
+		if snap == nil {
+			t.Fatalf("snapshot %x missing", root)
+		}
+		if _, err := snap.AccountRLP(common.Hash{}); err != ErrNotCoveredYet {
+			return
+		}
+		if i == 100 {
+			t.Fatalf("snapshot %x not generated", root)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// checkAccount checks the account served by a snapshot.
+func checkAccount(t *testing.T, snap Snapshot, hash common.Hash, want []byte) {
+	have, err := snap.AccountRLP(hash)
+	if err != nil {
+		t.Fatalf("root %x: account %x: failed to read: %v", snap.Root(), hash, err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("root %x: account %x mismatch: have %x, want %x", snap.Root(), hash, have, want)
+	}
+}
+
+// checkStorage checks the storage slot served by a snapshot.
+func checkStorage(t *testing.T, snap Snapshot, hash, slot common.Hash, want []byte) {
+	have, err := snap.Storage(hash, slot)
+	if err != nil {
+		t.Fatalf("root %x: slot %x/%x: failed to read: %v", snap.Root(), hash, slot, err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("root %x: slot %x/%x mismatch: have %x, want %x", snap.Root(), hash, slot, have, want)
+	}
+}
+
+// Tests that the diff layers shadow their parents, and that capping the tree
+// flattens the old ones into the disk layer.
+func TestDiffLayers(t *testing.T) {
+	var (
+		db, _ = ethdb.NewMemDatabase()
+		base  = common.Hash{0x00}
+		acc1  = common.Hash{0x01}
+		acc2  = common.Hash{0x02}
+		slot1 = common.Hash{0x11}
+		slot2 = common.Hash{0x12}
+	)
+	// Lay down a generated disk layer with two accounts
+	db.Put(accountKey(acc1), []byte{0x01})
+	db.Put(accountKey(acc2), []byte{0x02})
+	db.Put(storageKey(acc1, slot1), []byte{0x01})
+	db.Put(storageKey(acc2, slot1), []byte{0x02})
+	db.Put(RootKey, base.Bytes())
+
+	tree := New(db, base)
+
+	// Change a slot of the first account, then recreate the second one
+	tree.Update(common.Hash{0xa1}, base, nil, map[common.Hash][]byte{acc1: {0x11}}, map[common.Hash]map[common.Hash][]byte{
+		acc1: {slot1: nil, slot2: {0x12}},
+	})
+	tree.Update(common.Hash{0xa2}, common.Hash{0xa1}, map[common.Hash]struct{}{acc2: {}}, map[common.Hash][]byte{acc2: {0x22}}, map[common.Hash]map[common.Hash][]byte{
+		acc2: {slot2: {0x22}},
+	})
+	// A fork off the base, dropped when the base is flattened
+	tree.Update(common.Hash{0xb1}, base, map[common.Hash]struct{}{acc1: {}}, nil, nil)
+
+	snap := tree.Snapshot(common.Hash{0xa2})
+	checkAccount(t, snap, acc1, []byte{0x11})
+	checkAccount(t, snap, acc2, []byte{0x22})
+	checkStorage(t, snap, acc1, slot1, nil)
+	checkStorage(t, snap, acc1, slot2, []byte{0x12})
+	checkStorage(t, snap, acc2, slot1, nil)
+	checkStorage(t, snap, acc2, slot2, []byte{0x22})
+
+	fork := tree.Snapshot(common.Hash{0xb1})
+	checkAccount(t, fork, acc1, nil)
+	checkStorage(t, fork, acc1, slot1, nil)
+	checkAccount(t, fork, acc2, []byte{0x02})
+
+	// Flatten the first layer, the second remains in memory and the fork is dropped
+	if err := tree.Cap(common.Hash{0xa2}, 1); err != nil {
+		t.Fatalf("failed to cap tree: %v", err)
+	}
+	if root := ReadRoot(db); root != (common.Hash{0xa1}) {
+		t.Fatalf("disk root mismatch: have %x, want %x", root, common.Hash{0xa1})
+	}
+	if _, err := fork.AccountRLP(acc2); err != ErrSnapshotStale {
+		t.Errorf("fork read error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	if tree.Snapshot(common.Hash{0xb1}) != nil || tree.Snapshot(base) != nil {
+		t.Errorf("flattened layers still in the tree")
+	}
+	checkStorage(t, snap, acc1, slot2, []byte{0x12})
+	checkStorage(t, snap, acc2, slot1, nil)
+
+	// Flatten everything, the disk holds the state of the top layer
+	if err := tree.Close(common.Hash{0xa2}); err != nil {
+		t.Fatalf("failed to close tree: %v", err)
+	}
+	disk := tree.Snapshot(common.Hash{0xa2})
+	if _, ok := disk.(*diskLayer); !ok {
+		t.Fatalf("top layer not flattened: %T", disk)
+	}
+	checkAccount(t, disk, acc1, []byte{0x11})
+	checkAccount(t, disk, acc2, []byte{0x22})
+	checkStorage(t, disk, acc1, slot1, nil)
+	checkStorage(t, disk, acc1, slot2, []byte{0x12})
+	checkStorage(t, disk, acc2, slot1, nil)
+	checkStorage(t, disk, acc2, slot2, []byte{0x22})
+}
+
+// Tests that the snapshot is generated from the state trie, and that the
+// verification catches missing and extra entries.
+func TestGenerate(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	// Create a state with a few accounts, one of them with storage
+	storage, _ := trie.NewSecure(common.Hash{}, db, 0)
+	for i := byte(1); i <= 3; i++ {
+		storage.Update([]byte{i}, []byte{i})
+	}
+	storageRoot, _ := storage.CommitTo(db)
+
+	state, _ := trie.NewSecure(common.Hash{}, db, 0)
+	for i := byte(1); i <= 4; i++ {
+		acc := account{Nonce: uint64(i), Balance: big.NewInt(int64(i)), Root: types.EmptyRootHash, CodeHash: crypto.Keccak256(nil)}
+		if i == 1 {
+			acc.Root = storageRoot
+		}
+		data, _ := rlp.EncodeToBytes(acc)
+		state.Update([]byte{i}, data)
+	}
+	root, _ := state.CommitTo(db)
+
+	// Leave some junk behind, it must be wiped
+	db.Put(accountKey(common.Hash{0xff}), []byte{0xff})
+
+	tree := New(db, root)
+	waitGenerated(t, tree, root)
+
+	if err := Verify(db, root); err != nil {
+		t.Fatalf("failed to verify generated snapshot: %v", err)
+	}
+	snap := tree.Snapshot(root)
+	checkAccount(t, snap, crypto.Keccak256Hash([]byte{4}), state.Get([]byte{4}))
+	checkStorage(t, snap, crypto.Keccak256Hash([]byte{1}), crypto.Keccak256Hash([]byte{2}), []byte{2})
+
+	// Drop a slot and add an account, both must be caught
+	db.Delete(storageKey(crypto.Keccak256Hash([]byte{1}), crypto.Keccak256Hash([]byte{2})))
+	if err := Verify(db, root); err == nil {
+		t.Errorf("missing slot not detected")
+	}
+	db.Put(storageKey(crypto.Keccak256Hash([]byte{1}), crypto.Keccak256Hash([]byte{2})), []byte{2})
+	db.Put(accountKey(common.Hash{0xff}), []byte{0xff})
+	if err := Verify(db, root); err == nil {
+		t.Errorf("extra account not detected")
+	}
+}
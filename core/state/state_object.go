@@ -94,6 +94,7 @@ type stateObject struct {
 	suicided  bool
 	touched   bool
 	deleted   bool
+	recreated bool                      // true if the object replaced an existing account not yet wiped from the snapshot
 	onDirty   func(addr common.Address) // Callback method to mark a state object newly dirty
 }
 
@@ -182,8 +183,22 @@ func (self *stateObject) GetState(db Database, key common.Hash) common.Hash {
 	if exists {
 		return value
 	}
-	// Load from DB in case it is missing.
-	enc, err := self.getTrie(db).TryGet(key[:])
+	// Load from the snapshot in case it is missing, unless the storage was reset
+	// in this state, or from the trie if the snapshot can't serve it.
+	var (
+		snap = self.db.snap
+		enc  []byte
+		err  error
+	)
+	if _, destructed := self.db.snapDestructs[self.addrHash]; destructed || self.recreated {
+		snap = nil
+	}
+	if snap != nil {
+		enc, err = snap.Storage(self.addrHash, crypto.Keccak256Hash(key[:]))
+	}
+	if snap == nil || err != nil {
+		enc, err = self.getTrie(db).TryGet(key[:])
+	}
 	if err != nil {
 		self.setError(err)
 		return common.Hash{}
@@ -224,15 +239,30 @@ func (self *stateObject) setState(key, value common.Hash) {
 // updateTrie writes cached storage modifications into the object's storage trie.
 func (self *stateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
+
+	// Collect the storage changes for the snapshot too
+	var storage map[common.Hash][]byte
+	if self.db.snap != nil && len(self.dirtyStorage) > 0 {
+		if storage = self.db.snapStorage[self.addrHash]; storage == nil {
+			storage = make(map[common.Hash][]byte)
+			self.db.snapStorage[self.addrHash] = storage
+		}
+	}
 	for key, value := range self.dirtyStorage {
 		delete(self.dirtyStorage, key)
 		if (value == common.Hash{}) {
 			self.setError(tr.TryDelete(key[:]))
+			if storage != nil {
+				storage[crypto.Keccak256Hash(key[:])] = nil
+			}
 			continue
 		}
 		// Encoding []byte cannot fail, ok to ignore the error.
 		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
 		self.setError(tr.TryUpdate(key[:], v))
+		if storage != nil {
+			storage[crypto.Keccak256Hash(key[:])] = v
+		}
 	}
 	return tr
 }
@@ -311,6 +341,7 @@ func (self *stateObject) deepCopy(db *StateDB, onDirty func(addr common.Address)
 	stateObject.suicided = self.suicided
 	stateObject.dirtyCode = self.dirtyCode
 	stateObject.deleted = self.deleted
+	stateObject.recreated = self.recreated
 	return stateObject
 }
 
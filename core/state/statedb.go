@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/log"
@@ -31,6 +32,10 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/trie"
 )
 
+// snapshotLayers is the number of diff layers kept in memory on top of the disk
+// snapshot, the older ones are flattened into it.
+const snapshotLayers = 128
+
 type revision struct {
 	id           int
 	journalIndex int
@@ -40,6 +45,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// Flat snapshot of the state root, with the changes to feed into the
+	// snapshot tree on commit. All nil if the database has no snapshots.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -76,14 +89,31 @@ func New(root common.Hash, db Database) (*StateDB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	sdb := &StateDB{
 		db:                db,
 		trie:              tr,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
-	}, nil
+	}
+	sdb.openSnapshot(root)
+	return sdb, nil
+}
+
+// openSnapshot looks up the snapshot of the given root, if the database has a
+// snapshot tree attached.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+
+	if db, ok := self.db.(*cachingDB); ok && db.snaps != nil {
+		self.snaps = db.snaps
+		if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+			self.snapDestructs = make(map[common.Hash]struct{})
+			self.snapAccounts = make(map[common.Hash][]byte)
+			self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+		}
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -113,6 +143,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.logs = make(map[common.Hash][]*types.Log)
 	self.logSize = 0
 	self.preimages = make(map[common.Hash][]byte)
+	self.openSnapshot(root)
 	self.clearJournalAndRefund()
 	return nil
 }
@@ -341,6 +372,10 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -348,6 +383,27 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snap != nil {
+		self.snapDestruct(stateObject.addrHash)
+	}
+}
+
+// snapDestruct records an account wiped together with its storage for the
+// snapshot, dropping its earlier changes.
+func (self *StateDB) snapDestruct(addrHash common.Hash) {
+	self.snapDestructs[addrHash] = struct{}{}
+	delete(self.snapAccounts, addrHash)
+	delete(self.snapStorage, addrHash)
+}
+
+// snapRecreate wipes the account replaced by the given object from the snapshot,
+// before the changes of the object itself are recorded.
+func (self *StateDB) snapRecreate(stateObject *stateObject) {
+	if stateObject.recreated && self.snap != nil {
+		self.snapDestruct(stateObject.addrHash)
+	}
+	stateObject.recreated = false
 }
 
 // Retrieve a state object given my the address. Returns nil if not found.
@@ -360,8 +416,17 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot, or from the trie if it can't serve it.
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snap.AccountRLP(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -406,6 +471,7 @@ func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObjec
 		self.journal = append(self.journal, createObjectChange{account: &addr})
 	} else {
 		self.journal = append(self.journal, resetObjectChange{prev: prev})
+		newobj.recreated = true // The old storage is wiped from the snapshot if the object survives
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -465,6 +531,25 @@ func (self *StateDB) Copy() *StateDB {
 		logs:              make(map[common.Hash][]*types.Log, len(self.logs)),
 		logSize:           self.logSize,
 		preimages:         make(map[common.Hash][]byte),
+		snaps:             self.snaps,
+		snap:              self.snap,
+	}
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, slots := range self.snapStorage {
+			state.snapStorage[hash] = make(map[common.Hash][]byte, len(slots))
+			for slot, data := range slots {
+				state.snapStorage[hash][slot] = data
+			}
+		}
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.stateObjectsDirty {
@@ -526,6 +611,7 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 		if stateObject.suicided || (deleteEmptyObjects && stateObject.empty()) {
 			s.deleteStateObject(stateObject)
 		} else {
+			s.snapRecreate(stateObject)
 			stateObject.updateRoot(s.db)
 			s.updateStateObject(stateObject)
 		}
@@ -590,6 +676,8 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 			// and just mark it for deletion in the trie.
 			s.deleteStateObject(stateObject)
 		case isDirty:
+			s.snapRecreate(stateObject)
+
 			// Write any contract code associated with the state object
 			if stateObject.code != nil && stateObject.dirtyCode {
 				if err := dbw.Put(stateObject.CodeHash(), stateObject.code); err != nil {
@@ -609,5 +697,18 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 	// Write trie changes.
 	root, err = s.trie.CommitTo(dbw)
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
+
+	// Feed the changes into the snapshot tree as a new layer
+	if err == nil && s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+			}
+			if err := s.snaps.Cap(root, snapshotLayers); err != nil {
+				log.Warn("Failed to cap snapshot tree", "root", root, "layers", snapshotLayers, "err", err)
+			}
+		}
+		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+	}
 	return root, err
 }
@@ -27,10 +27,12 @@ import (
 	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	check "gopkg.in/check.v1"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/state/snapshot"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/ethdb"
 )
@@ -432,3 +434,130 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that states read through an attached snapshot, and that committing them
+// feeds the changes into it, leaving a flattened snapshot matching the trie.
+func TestFlatSnapshot(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+
+	state, _ := New(common.Hash{}, sdb)
+	for i := byte(0); i < 4; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.AddBalance(addr, big.NewInt(int64(i)+1))
+		state.SetState(addr, common.Hash{i}, common.Hash{i + 1})
+		state.SetState(addr, common.Hash{i + 1}, common.Hash{i + 2})
+	}
+	root, _ := state.CommitTo(db, false)
+
+	snaps := snapshot.New(db, root)
+	for i := 0; ; i++ {
+		if _, err := snaps.Snapshot(root).AccountRLP(common.Hash{}); err != snapshot.ErrNotCoveredYet {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("snapshot not generated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	SetSnapshots(sdb, snaps)
+
+	var (
+		addr0 = common.BytesToAddress([]byte{0})
+		addr1 = common.BytesToAddress([]byte{1})
+		addr2 = common.BytesToAddress([]byte{2})
+		addr9 = common.BytesToAddress([]byte{9})
+	)
+	state, _ = New(root, sdb)
+	if state.snap == nil {
+		t.Fatalf("snapshot not used")
+	}
+	if balance := state.GetBalance(addr1); balance.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, 2)
+	}
+	if value := state.GetState(addr1, common.Hash{2}); value != (common.Hash{3}) {
+		t.Errorf("storage mismatch: have %x, want %x", value, common.Hash{3})
+	}
+	// Change and clear slots, delete an account and recreate another
+	state.SetState(addr0, common.Hash{0}, common.Hash{0xff})
+	state.SetState(addr0, common.Hash{1}, common.Hash{})
+	state.Suicide(addr1)
+	state.CreateAccount(addr2)
+	state.SetState(addr2, common.Hash{0x42}, common.Hash{0x42})
+	state.AddBalance(addr9, big.NewInt(9))
+
+	root, _ = state.CommitTo(db, false)
+	if snaps.Snapshot(root) == nil {
+		t.Fatalf("committed state missing from the snapshot tree")
+	}
+	state, _ = New(root, sdb)
+	if state.Exist(addr1) {
+		t.Errorf("deleted account still exists")
+	}
+	if value := state.GetState(addr0, common.Hash{1}); value != (common.Hash{}) {
+		t.Errorf("cleared slot mismatch: have %x, want empty", value)
+	}
+	if value := state.GetState(addr2, common.Hash{2}); value != (common.Hash{}) {
+		t.Errorf("slot of recreated account mismatch: have %x, want empty", value)
+	}
+	if value := state.GetState(addr2, common.Hash{0x42}); value != (common.Hash{0x42}) {
+		t.Errorf("slot mismatch: have %x, want %x", value, common.Hash{0x42})
+	}
+	if balance := state.GetBalance(addr9); balance.Cmp(big.NewInt(9)) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, 9)
+	}
+	// Flatten the snapshot and check it against the trie
+	if err := snaps.Close(root); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	if err := snapshot.Verify(db, root); err != nil {
+		t.Fatalf("snapshot mismatch after commit: %v", err)
+	}
+}
+
+// Tests that reverting the creation of a contract over an existing account
+// leaves the storage of the account in the snapshot.
+func TestFlatSnapshotRevertedCreate(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+
+	addr := common.BytesToAddress([]byte{1})
+	state, _ := New(common.Hash{}, sdb)
+	state.AddBalance(addr, big.NewInt(1))
+	state.SetState(addr, common.Hash{1}, common.Hash{2})
+	root, _ := state.CommitTo(db, false)
+
+	snaps := snapshot.New(db, root)
+	for i := 0; ; i++ {
+		if _, err := snaps.Snapshot(root).AccountRLP(common.Hash{}); err != snapshot.ErrNotCoveredYet {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("snapshot not generated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	SetSnapshots(sdb, snaps)
+
+	state, _ = New(root, sdb)
+	revision := state.Snapshot()
+	state.CreateAccount(addr)
+	if value := state.GetState(addr, common.Hash{1}); value != (common.Hash{}) {
+		t.Errorf("slot of recreated account mismatch: have %x, want empty", value)
+	}
+	state.SetState(addr, common.Hash{3}, common.Hash{4})
+	state.RevertToSnapshot(revision)
+
+	if value := state.GetState(addr, common.Hash{1}); value != (common.Hash{2}) {
+		t.Errorf("slot mismatch after revert: have %x, want %x", value, common.Hash{2})
+	}
+	state.AddBalance(addr, big.NewInt(1))
+	root, _ = state.CommitTo(db, false)
+
+	if err := snaps.Close(root); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	if err := snapshot.Verify(db, root); err != nil {
+		t.Fatalf("snapshot mismatch after commit: %v", err)
+	}
+}
@@ -27,13 +27,20 @@ import (
 	"net"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/eth"
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/p2p"
+	"github.com/MeshBoxFoundation/meshbox/params"
 	"github.com/MeshBoxFoundation/meshbox/rpc"
+	"github.com/hashicorp/golang-lru"
 	"github.com/rcrowley/go-metrics"
 	"golang.org/x/net/websocket"
 )
@@ -41,6 +48,9 @@ import (
 const (
 	memorySampleLimit  = 200 // Maximum number of memory data samples
 	trafficSampleLimit = 200 // Maximum number of traffic data samples
+	txPoolAccountLimit = 16  // Maximum number of senders listed in the txpool message
+	tribeBlockLimit    = 128 // Number of recent blocks scanned for sealed and missed slots
+	logChanSize        = 256 // Size of the channel buffering the streamed log records
 )
 
 var nextID uint32 // Next connection id
@@ -49,9 +59,16 @@ var nextID uint32 // Next connection id
 type Dashboard struct {
 	config *Config
 
+	ethereum *eth.Ethereum // Full node the chain, txpool and tribe data are collected from, nil if not running
+	tribe    *tribe.API    // Tribe API of the full node, nil if the chain is not sealed by tribe
+	server   *p2p.Server   // Server the network data is collected from
+
 	listener net.Listener
 	conns    map[uint32]*client // Currently live websocket connections
 	charts   *HomeMessage
+	node     *Message     // Latest chain, txpool, network and tribe messages, sent to the new connections
+	head     common.Hash  // Chain head the tribe message was assembled at
+	slots    *lru.Cache   // Block hash -> slot of the local signer in the signer list the block was sealed from
 	lock     sync.RWMutex // Lock protecting the dashboard's internals
 
 	quit chan chan error // Channel used for graceful exit
@@ -65,17 +82,32 @@ type client struct {
 	logger log.Logger      // Logger for the particular live websocket connection
 }
 
-// New creates a new dashboard instance with the given configuration.
-func New(config *Config) (*Dashboard, error) {
-	return &Dashboard{
-		conns:  make(map[uint32]*client),
-		config: config,
-		quit:   make(chan chan error),
+// New creates a new dashboard instance with the given configuration. The node
+// panels are only filled if the full Ethereum service is given.
+func New(config *Config, ethereum *eth.Ethereum) (*Dashboard, error) {
+	slots, _ := lru.New(tribeBlockLimit)
+	db := &Dashboard{
+		conns:    make(map[uint32]*client),
+		slots:    slots,
+		config:   config,
+		ethereum: ethereum,
+		quit:     make(chan chan error),
 		charts: &HomeMessage{
 			Memory:  &Chart{},
 			Traffic: &Chart{},
 		},
-	}, nil
+		node: &Message{},
+	}
+	if ethereum != nil {
+		if t, ok := ethereum.Engine().(*tribe.Tribe); ok {
+			for _, api := range t.APIs(ethereum.BlockChain()) {
+				if api, ok := api.Service.(*tribe.API); ok {
+					db.tribe = api
+				}
+			}
+		}
+	}
+	return db, nil
 }
 
 // Protocols is a meaningless implementation of node.Service.
@@ -86,6 +118,8 @@ func (db *Dashboard) APIs() []rpc.API { return nil }
 
 // Start implements node.Service, starting the data collection thread and the listening server of the dashboard.
 func (db *Dashboard) Start(server *p2p.Server) error {
+	db.server = server
+
 	db.wg.Add(2)
 	go db.collectData()
 	go db.collectLogs() // In case of removing this line change 2 back to 1 in wg.Add.
@@ -209,6 +243,7 @@ func (db *Dashboard) apiHandler(conn *websocket.Conn) {
 	}
 	// Start tracking the connection and drop at connection loss.
 	db.lock.Lock()
+	client.msg <- *db.node
 	db.conns[id] = client
 	db.lock.Unlock()
 	defer func() {
@@ -268,28 +303,212 @@ func (db *Dashboard) collectData() {
 					},
 				},
 			})
+			db.collectNode()
+		}
+	}
+}
+
+// collectNode assembles the chain, txpool, network and tribe messages and sends
+// them to the active dashboards.
+func (db *Dashboard) collectNode() {
+	msg := &Message{
+		Network: db.networkMessage(),
+	}
+	if db.ethereum != nil {
+		msg.Chain = db.chainMessage()
+		msg.TxPool = db.txPoolMessage()
+	}
+	if db.tribe != nil {
+		head := db.ethereum.BlockChain().CurrentHeader().Hash()
+		db.lock.RLock()
+		changed := head != db.head
+		db.lock.RUnlock()
+		if changed {
+			msg.Tribe = db.tribeMessage()
+		}
+	}
+	db.lock.Lock()
+	if msg.Chain != nil {
+		db.node.Chain = msg.Chain
+	}
+	if msg.TxPool != nil {
+		db.node.TxPool = msg.TxPool
+	}
+	if msg.Network != nil {
+		db.node.Network = msg.Network
+	}
+	if msg.Tribe != nil {
+		db.node.Tribe = msg.Tribe
+		db.head = db.ethereum.BlockChain().CurrentHeader().Hash()
+	}
+	db.lock.Unlock()
+
+	db.sendToAll(msg)
+}
+
+// chainMessage reports the current head block.
+func (db *Dashboard) chainMessage() *ChainMessage {
+	block := db.ethereum.BlockChain().CurrentBlock()
+	header := block.Header()
+	signer, err := db.ethereum.Engine().Author(header)
+	if err != nil {
+		signer = header.Coinbase
+	}
+	return &ChainMessage{
+		Number:   header.Number.Uint64(),
+		Hash:     block.Hash(),
+		Signer:   signer,
+		Time:     header.Time.Uint64(),
+		Txs:      len(block.Transactions()),
+		GasUsed:  header.GasUsed.Uint64(),
+		GasLimit: header.GasLimit.Uint64(),
+	}
+}
+
+// txPoolMessage reports the transaction pool counters and its busiest senders.
+func (db *Dashboard) txPoolMessage() *TxPoolMessage {
+	pool := db.ethereum.TxPool()
+	pending, queued := pool.Stats()
+
+	accounts := make(map[common.Address]*TxPoolAccount)
+	account := func(addr common.Address) *TxPoolAccount {
+		if accounts[addr] == nil {
+			accounts[addr] = &TxPoolAccount{Address: addr}
+		}
+		return accounts[addr]
+	}
+	pendingTxs, queuedTxs := pool.Content()
+	for addr, txs := range pendingTxs {
+		account(addr).Pending = len(txs)
+	}
+	for addr, txs := range queuedTxs {
+		account(addr).Queued = len(txs)
+	}
+	msg := &TxPoolMessage{
+		Pending:  pending,
+		Queued:   queued,
+		Accounts: make([]*TxPoolAccount, 0, len(accounts)),
+	}
+	for _, acc := range accounts {
+		msg.Accounts = append(msg.Accounts, acc)
+	}
+	sort.Slice(msg.Accounts, func(i, j int) bool {
+		a, b := msg.Accounts[i], msg.Accounts[j]
+		if a.Pending+a.Queued != b.Pending+b.Queued {
+			return a.Pending+a.Queued > b.Pending+b.Queued
+		}
+		return a.Address.Hex() < b.Address.Hex()
+	})
+	if len(msg.Accounts) > txPoolAccountLimit {
+		msg.Accounts = msg.Accounts[:txPoolAccountLimit]
+	}
+	return msg
+}
+
+// networkMessage reports the connected peers.
+func (db *Dashboard) networkMessage() *NetworkMessage {
+	if db.server == nil {
+		return nil
+	}
+	return &NetworkMessage{
+		MaxPeers: db.server.MaxPeers,
+		Peers:    db.server.PeersInfo(),
+	}
+}
+
+// tribeMessage reports the tribe status of this node along with the blocks it
+// recently sealed and the in-turn slots it missed. The slots are derived from
+// the current signer rotation. Nil is returned until the node key is loaded.
+func (db *Dashboard) tribeMessage() *TribeMessage {
+	t := db.ethereum.Engine().(*tribe.Tribe)
+	if t.Status.GetNodeKey() == nil {
+		return nil
+	}
+	status, err := db.tribe.GetMyStatus()
+	if err != nil {
+		log.Debug("Failed to retrieve tribe status", "err", err)
+		return nil
+	}
+	signers, err := db.tribe.GetSigners(nil)
+	if err != nil {
+		log.Debug("Failed to retrieve tribe signers", "err", err)
+		return nil
+	}
+	msg := &TribeMessage{
+		Status:  status,
+		Signers: signers,
+	}
+	chain := db.ethereum.BlockChain()
+	for n := status.Number; n > 0 && n > status.Number-tribeBlockLimit; n-- {
+		header := chain.GetHeaderByNumber(uint64(n))
+		if header == nil {
+			break
+		}
+		if signer, err := t.Author(header); err == nil && signer == status.Address {
+			msg.Sealed = append(msg.Sealed, uint64(n))
+			continue
+		}
+		// The slots of past blocks are assigned by the signer list of their own round
+		slot, err := db.signerSlot(header, status.Address)
+		if err != nil {
+			log.Debug("Failed to retrieve tribe signers", "number", n, "err", err)
+			break
+		}
+		if slot >= 0 {
+			msg.Missed = append(msg.Missed, uint64(n))
+		}
+	}
+	return msg
+}
+
+// signerSlot returns the slot of the header's block if it belonged to the given
+// signer in the signer list the block was sealed from, -1 otherwise.
+func (db *Dashboard) signerSlot(header *types.Header, signer common.Address) (int, error) {
+	if slot, ok := db.slots.Get(header.Hash()); ok {
+		return slot.(int), nil
+	}
+	status, err := params.TribeGetStatus(header.Number, header.ParentHash)
+	if err != nil {
+		return 0, err
+	}
+	slot := -1
+	if size := len(status.SignerList); size > 0 {
+		if i := int(header.Number.Uint64() % uint64(size)); status.SignerList[i] == signer {
+			slot = i
 		}
 	}
+	db.slots.Add(header.Hash(), slot)
+	return slot, nil
 }
 
-// collectLogs collects and sends the logs to the active dashboards.
+// collectLogs tees the records of the root logger and sends them to the active
+// dashboards. The original handler is restored on exit.
 func (db *Dashboard) collectLogs() {
 	defer db.wg.Done()
 
-	id := 1
-	// TODO (kurkomisi): log collection comes here.
+	records := make(chan *log.Record, logChanSize)
+	root := log.Root().GetHandler()
+	log.Root().SetHandler(log.MultiHandler(root, log.LvlFilterHandler(log.LvlInfo, log.FuncHandler(func(r *log.Record) error {
+		// Never block the logger, records are dropped if the dashboard lags behind.
+		select {
+		case records <- r:
+		default:
+		}
+		return nil
+	}))))
+	format := log.TerminalFormat(false)
 	for {
 		select {
 		case errc := <-db.quit:
+			log.Root().SetHandler(root)
 			errc <- nil
 			return
-		case <-time.After(db.config.Refresh / 2):
+		case r := <-records:
 			db.sendToAll(&Message{
 				Logs: &LogsMessage{
-					Log: fmt.Sprintf("%-4d: This is a fake log.", id),
+					Log: string(format.Format(r)),
 				},
 			})
-			id++
 		}
 	}
 }
@@ -16,7 +16,13 @@
 
 package dashboard
 
-import "time"
+import (
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
+	"github.com/MeshBoxFoundation/meshbox/p2p"
+)
 
 type Message struct {
 	Home    *HomeMessage    `json:"home,omitempty"`
@@ -25,6 +31,7 @@ type Message struct {
 	Network *NetworkMessage `json:"network,omitempty"`
 	System  *SystemMessage  `json:"system,omitempty"`
 	Logs    *LogsMessage    `json:"logs,omitempty"`
+	Tribe   *TribeMessage   `json:"tribe,omitempty"`
 }
 
 type HomeMessage struct {
@@ -43,15 +50,30 @@ type ChartEntry struct {
 }
 
 type ChainMessage struct {
-	/* TODO (kurkomisi) */
+	Number   uint64         `json:"number"`
+	Hash     common.Hash    `json:"hash"`
+	Signer   common.Address `json:"signer"`
+	Time     uint64         `json:"time"`
+	Txs      int            `json:"txs"`
+	GasUsed  uint64         `json:"gasUsed"`
+	GasLimit uint64         `json:"gasLimit"`
 }
 
 type TxPoolMessage struct {
-	/* TODO (kurkomisi) */
+	Pending  int              `json:"pending"`
+	Queued   int              `json:"queued"`
+	Accounts []*TxPoolAccount `json:"accounts,omitempty"` // Senders with the most pending transactions
+}
+
+type TxPoolAccount struct {
+	Address common.Address `json:"address"`
+	Pending int            `json:"pending"`
+	Queued  int            `json:"queued"`
 }
 
 type NetworkMessage struct {
-	/* TODO (kurkomisi) */
+	MaxPeers int             `json:"maxPeers"`
+	Peers    []*p2p.PeerInfo `json:"peers"`
 }
 
 type SystemMessage struct {
@@ -61,3 +83,10 @@ type SystemMessage struct {
 type LogsMessage struct {
 	Log string `json:"log,omitempty"`
 }
+
+type TribeMessage struct {
+	Status  *tribe.TribeMyStatus `json:"status,omitempty"`
+	Signers []*tribe.Signer      `json:"signers,omitempty"` // Current signer rotation
+	Sealed  []uint64             `json:"sealed,omitempty"`  // Recent blocks sealed by this node
+	Missed  []uint64             `json:"missed,omitempty"`  // Recent in-turn slots of this node sealed by others
+}
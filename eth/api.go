@@ -17,12 +17,15 @@
 package eth
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
@@ -30,6 +33,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/core"
 	"github.com/MeshBoxFoundation/meshbox/core/state"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/miner"
 	"github.com/MeshBoxFoundation/meshbox/params"
 	"github.com/MeshBoxFoundation/meshbox/rlp"
@@ -496,3 +500,113 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 	}
 	return dirty, nil
 }
+
+var (
+	errLogIndexDisabled = errors.New("log index disabled, restart with --logindex")
+	errLogIndexSyncing  = errors.New("log index still being generated")
+
+	anmapBindSelector   = crypto.Keccak256([]byte("bind(address,uint8,bytes32,bytes32)"))[:4]
+	anmapUnbindSelector = crypto.Keccak256([]byte("unbindBySig(address,uint8,bytes32,bytes32)"))[:4]
+)
+
+// BindRecord is a successful call into the anmap contract binding a node to, or
+// unbinding it from, an account.
+type BindRecord struct {
+	Action      string         `json:"action"`
+	From        common.Address `json:"from"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	TxHash      common.Hash    `json:"transactionHash"`
+}
+
+// PublicTribeHistoryAPI provides the tribe queries served from the log index.
+type PublicTribeHistoryAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicTribeHistoryAPI creates a new API for the tribe history queries.
+func NewPublicTribeHistoryAPI(eth *Ethereum) *PublicTribeHistoryAPI {
+	return &PublicTribeHistoryAPI{eth: eth}
+}
+
+// GetBindHistory returns the bind and unbind calls of the given node address
+// into the anmap contract, oldest first. The anmap contract emits no events,
+// the blocks calling it are looked up in the log index.
+func (api *PublicTribeHistoryAPI) GetBindHistory(nodeAddr common.Address) ([]*BindRecord, error) {
+	if api.eth.logIndexer == nil {
+		return nil, errLogIndexDisabled
+	}
+	head := api.eth.blockchain.CurrentBlock().NumberU64()
+	start, anmap := params.AnmapInfo(new(big.Int).SetUint64(head), "0.0.1")
+	if anmap == (common.Address{}) {
+		return nil, errors.New("anmap_not_ready")
+	}
+	// Collect the indexed blocks calling anmap, then add the unindexed ones
+	sections, _, _ := api.eth.logIndexer.Sections()
+	indexed := sections * params.BloomBitsBlocks
+	if head+1 > indexed+2*params.BloomBitsBlocks {
+		return nil, errLogIndexSyncing
+	}
+	candidates := make(map[uint64]struct{})
+	if indexed > start.Uint64() {
+		for _, selector := range [][]byte{anmapBindSelector, anmapUnbindSelector} {
+			for _, number := range core.GetCallIndexBlocks(api.eth.chainDb, anmap, selector, start.Uint64(), indexed-1) {
+				candidates[number] = struct{}{}
+			}
+		}
+	} else {
+		indexed = start.Uint64()
+	}
+	for number := indexed; number <= head; number++ {
+		candidates[number] = struct{}{}
+	}
+	numbers := make([]uint64, 0, len(candidates))
+	for number := range candidates {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	// Pick the successful calls concerning the node out of the candidate blocks
+	records := make([]*BindRecord, 0)
+	for _, number := range numbers {
+		block := api.eth.blockchain.GetBlockByNumber(number)
+		if block == nil {
+			continue
+		}
+		receipts := core.GetBlockReceipts(api.eth.chainDb, block.Hash(), number)
+		if len(receipts) != len(block.Transactions()) {
+			continue
+		}
+		signer := types.MakeSigner(api.eth.chainConfig, block.Number())
+		for i, tx := range block.Transactions() {
+			data := tx.Data()
+			if tx.To() == nil || *tx.To() != anmap || len(data) < 4+common.HashLength || receipts[i].Status != types.ReceiptStatusSuccessful {
+				continue
+			}
+			var action string
+			switch {
+			case bytes.Equal(data[:4], anmapBindSelector):
+				action = "bind"
+			case bytes.Equal(data[:4], anmapUnbindSelector):
+				action = "unbind"
+			default:
+				continue
+			}
+			if common.BytesToAddress(data[4:4+common.HashLength]) != nodeAddr {
+				continue
+			}
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, &BindRecord{
+				Action:      action,
+				From:        from,
+				BlockNumber: hexutil.Uint64(number),
+				BlockHash:   block.Hash(),
+				TxHash:      tx.Hash(),
+			})
+		}
+	}
+	return records, nil
+}
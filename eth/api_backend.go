@@ -88,15 +88,12 @@ func (b *EthApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 func (b *EthApiBackend) StateAndHeaderByHash(ctx context.Context, hash *common.Hash) (*state.StateDB, *types.Header, error) {
 	// Pending state is only known by the miner
 	// Otherwise resolve the block number and return its state
-	blk, err := b.GetBlock(ctx, *hash)
-	if err != nil {
-		return nil, nil, err
-	}
-	if blk == nil {
+	// Only the header is needed, so it works for the blocks with expired bodies too
+	header := b.eth.blockchain.GetHeaderByHash(*hash)
+	if header == nil {
 		log.Debug("EthApiBackend.StateAndHeaderByHash", "err", "block_not_found", "hash", hash.Hex())
 		return nil, nil, errors.New("block_not_found")
 	}
-	header := blk.Header()
 	log.Debug("EthApiBackend.StateAndHeaderByHash #>", "hex", hash.Hex())
 	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
 	return stateDb, header, err
@@ -223,6 +220,14 @@ func (b *EthApiBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, sections
 }
 
+func (b *EthApiBackend) LogIndexStatus() (uint64, uint64) {
+	if b.eth.logIndexer == nil {
+		return 0, 0
+	}
+	sections, _, _ := b.eth.logIndexer.Sections()
+	return params.BloomBitsBlocks, sections
+}
+
 func (b *EthApiBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
@@ -105,6 +105,23 @@ func (db *ephemeralDatabase) Close()                             { db.memdb.Clos
 func (db *ephemeralDatabase) NewBatch() ethdb.Batch {
 	return db.memdb.NewBatch()
 }
+func (db *ephemeralDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	return &ephemeralIterator{
+		mem:         db.memdb.NewIterator(prefix, start),
+		disk:        db.diskdb.NewIterator(prefix, start),
+		advanceMem:  true,
+		advanceDisk: true,
+	}
+}
+func (db *ephemeralDatabase) Stat(property string) (string, error) {
+	return "", errors.New("stat not supported")
+}
+func (db *ephemeralDatabase) Compact(start []byte, limit []byte) error {
+	return errors.New("compact not supported")
+}
+func (db *ephemeralDatabase) DeleteRange(start []byte, limit []byte) error {
+	return errors.New("delete not supported")
+}
 func (db *ephemeralDatabase) Has(key []byte) (bool, error) {
 	if has, _ := db.memdb.Has(key); has {
 		return has, nil
@@ -118,6 +135,67 @@ func (db *ephemeralDatabase) Get(key []byte) ([]byte, error) {
 	return db.diskdb.Get(key)
 }
 
+// ephemeralIterator iterates over the memory layer merged on top of the disk
+// database, the same view Get and Has read from. Keys present in both are
+// returned once, with the value of the memory layer.
+type ephemeralIterator struct {
+	mem, disk     ethdb.Iterator
+	memOk, diskOk bool // Whether the iterators are positioned on an entry
+
+	advanceMem, advanceDisk bool // Whether the iterators were consumed by the last Next
+	key, value              []byte
+}
+
+func (it *ephemeralIterator) Next() bool {
+	if it.advanceMem {
+		it.memOk = it.mem.Next()
+	}
+	if it.advanceDisk {
+		it.diskOk = it.disk.Next()
+	}
+	it.advanceMem, it.advanceDisk = false, false
+
+	switch {
+	case !it.memOk && !it.diskOk:
+		it.key, it.value = nil, nil
+		return false
+	case !it.diskOk:
+		it.advanceMem = true
+	case !it.memOk:
+		it.advanceDisk = true
+	default:
+		switch bytes.Compare(it.mem.Key(), it.disk.Key()) {
+		case -1:
+			it.advanceMem = true
+		case 1:
+			it.advanceDisk = true
+		default:
+			it.advanceMem, it.advanceDisk = true, true
+		}
+	}
+	if it.advanceMem {
+		it.key, it.value = it.mem.Key(), it.mem.Value()
+	} else {
+		it.key, it.value = it.disk.Key(), it.disk.Value()
+	}
+	return true
+}
+
+func (it *ephemeralIterator) Error() error {
+	if err := it.mem.Error(); err != nil {
+		return err
+	}
+	return it.disk.Error()
+}
+
+func (it *ephemeralIterator) Key() []byte   { return it.key }
+func (it *ephemeralIterator) Value() []byte { return it.value }
+
+func (it *ephemeralIterator) Release() {
+	it.mem.Release()
+	it.disk.Release()
+}
+
 // Prune does a state sync into a new memory write layer and replaces the old one.
 // This allows us to discard entries that are no longer referenced from the current
 // state.
@@ -0,0 +1,62 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+)
+
+// Tests that iterating an ephemeral database walks the memory layer merged on
+// top of the disk database, matching what Get returns.
+func TestEphemeralDatabaseIterator(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	memdb, _ := ethdb.NewMemDatabase()
+	db := &ephemeralDatabase{diskdb: diskdb, memdb: memdb}
+
+	diskdb.Put([]byte("a1"), []byte("disk"))
+	diskdb.Put([]byte("a3"), []byte("disk"))
+	diskdb.Put([]byte("a4"), []byte("disk"))
+	diskdb.Put([]byte("b1"), []byte("disk"))
+	db.Put([]byte("a2"), []byte("mem"))
+	db.Put([]byte("a3"), []byte("mem"))
+	db.Put([]byte("a5"), []byte("mem"))
+
+	var keys, values []string
+	it := db.NewIterator([]byte("a"), []byte("2"))
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+		values = append(values, string(it.Value()))
+
+		if blob, _ := db.Get(it.Key()); string(blob) != string(it.Value()) {
+			t.Errorf("value mismatch for %s: iterated %q, got %q", it.Key(), it.Value(), blob)
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	it.Release()
+
+	if want := []string{"a2", "a3", "a4", "a5"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys mismatch: have %v, want %v", keys, want)
+	}
+	if want := []string{"mem", "mem", "disk", "mem"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values mismatch: have %v, want %v", values, want)
+	}
+}
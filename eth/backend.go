@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -53,11 +54,20 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/rpc"
 )
 
+// errCheckpointSigners is returned when fast syncing a tribe chain with a
+// trusted checkpoint that doesn't name the sealers of the blocks up to it.
+var errCheckpointSigners = errors.New("tribe fast sync checkpoint without signers")
+
+// minHistoryKeep is the smallest history window allowed with history expiry,
+// reorgs need the bodies of the blocks they drop.
+const minHistoryKeep = 1024
+
 type LesServer interface {
 	Start(srvr *p2p.Server)
 	Stop()
 	Protocols() []p2p.Protocol
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
+	APIs() []rpc.API
 }
 
 // Ethereum implements the Ethereum full node service.
@@ -84,6 +94,7 @@ type Ethereum struct {
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	logIndexer    *core.ChainIndexer             // Log and system call indexer, nil if disabled
 
 	ApiBackend *EthApiBackend
 
@@ -111,7 +122,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	if config.HistoryKeep > 0 && config.HistoryKeep < minHistoryKeep {
+		log.Warn("Sanitizing history window", "provided", config.HistoryKeep, "updated", minHistoryKeep)
+		config.HistoryKeep = minHistoryKeep
+	}
+	chainDb, err := CreateChainDB(ctx, config)
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +173,16 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		eth.blockchain.SetHead(compat.RewindTo)
 		core.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
+	if config.Snapshot {
+		eth.blockchain.EnableSnapshots()
+	}
+	eth.blockchain.SetHistoryKeep(config.HistoryKeep)
+	eth.blockchain.SetTxLookupLimit(config.TxLookupLimit, config.TxLookupSkipChief)
 	eth.bloomIndexer.Start(eth.blockchain)
+	if config.LogIndex {
+		eth.logIndexer = NewLogIndexer(chainDb, params.BloomBitsBlocks)
+		eth.logIndexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
@@ -167,9 +191,24 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	//设置默认的GasPrice 18Gwei
 	eth.txPool.SetGasPrice(DefaultConfig.GasPrice)
 
+	// Tribe signers below the fast sync pivot can only be validated against a checkpoint
+	if t, ok := eth.engine.(*tribe.Tribe); ok && config.SyncMode == downloader.FastSync {
+		switch cp := config.FastSyncCheckpoint; {
+		case cp == nil:
+			log.Warn("No trusted checkpoint to fast sync tribe chain, falling back to full sync")
+			config.SyncMode = downloader.FullSync
+		case len(cp.Signers) == 0:
+			return nil, errCheckpointSigners
+		default:
+			t.SetCheckpointSigners(cp.Signers)
+		}
+	}
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	if config.FastSyncCheckpoint != nil {
+		eth.protocolManager.downloader.SetCheckpoint(config.FastSyncCheckpoint)
+	}
 
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	//eth.miner.SetExtra(makeExtraData(config.ExtraData))
@@ -217,6 +256,41 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	return db, nil
 }
 
+// CreateChainDB creates the full chain database, moving the canonical blocks
+// older than the configured distance into the ancient store. Ephemeral nodes
+// keep all the chain in memory.
+func CreateChainDB(ctx *node.ServiceContext, config *Config) (ethdb.Database, error) {
+	db, err := CreateDB(ctx, config, "chaindata")
+	if err != nil {
+		return nil, err
+	}
+	root := ctx.ResolvePath("chaindata")
+	if root == "" {
+		return db, nil
+	}
+	freezer := config.DatabaseFreezer
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(root, "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = ctx.ResolvePath(freezer)
+	}
+	distance := config.DatabaseFreezerDistance
+	if distance == 0 {
+		distance = DefaultConfig.DatabaseFreezerDistance
+	}
+	// Frozen blocks can't be expired anymore, freeze them once they are
+	if distance < config.HistoryKeep {
+		distance = config.HistoryKeep
+	}
+	frdb, err := core.NewDatabaseWithFreezer(db, freezer, distance)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chainConfig *params.ChainConfig, db ethdb.Database) consensus.Engine {
 	// add by liangc : start tribe engine : POS
@@ -260,6 +334,11 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the management APIs of the light server, if serving
+	if s.lesServer != nil {
+		apis = append(apis, s.lesServer.APIs()...)
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -305,6 +384,11 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   s.netRPCService,
 			Public:    true,
+		}, {
+			Namespace: "tribe",
+			Version:   "1.0",
+			Service:   NewPublicTribeHistoryAPI(s),
+			Public:    true,
 		},
 	}...)
 }
@@ -417,6 +501,7 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	}
 	// Start the networking layer and the light server if requested
 	s.protocolManager.Start(maxPeers)
+	s.protocolManager.StartPeering(srvr)
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
@@ -431,6 +516,9 @@ func (s *Ethereum) Stop() error {
 		s.stopDbUpgrade()
 	}
 	s.bloomIndexer.Close()
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
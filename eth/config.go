@@ -42,10 +42,11 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     1,
-	LightPeers:    20,
-	DatabaseCache: 128,
-	GasPrice:      big.NewInt(18 * params.Shannon),
+	NetworkId:               1,
+	LightPeers:              20,
+	DatabaseCache:           128,
+	DatabaseFreezerDistance: 90000,
+	GasPrice:                big.NewInt(18 * params.Shannon),
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -68,6 +69,14 @@ func init() {
 	}
 }
 
+// ULCConfig configures the ultra light client mode, in which a new head is
+// accepted without downloading or verifying any header, once enough trusted
+// servers have announced it.
+type ULCConfig struct {
+	TrustedServers     []string `toml:",omitempty"` // Node URLs of the trusted LES servers
+	MinTrustedFraction int      `toml:",omitempty"` // Percentage of the trusted servers needed to accept a head
+}
+
 //go:generate gencodec -type Config -field-override configMarshaling -formats toml -out gen_config.go
 
 type Config struct {
@@ -79,14 +88,45 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// Trusted block the headers below the fast sync pivot must lead to
+	FastSyncCheckpoint *downloader.Checkpoint `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	// File of the LES clients served with priority, see les.clientPool
+	LightPriorityFile string `toml:",omitempty"`
+
+	// Trusted checkpoint light sync starts from, the hardcoded one if nil
+	LightCheckpoint *params.TrustedCheckpoint `toml:",omitempty"`
+
+	// Ultra light client options, see les.ulc
+	ULC *ULCConfig `toml:",omitempty"`
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string // Directory of the ancient store, chaindata/ancient if empty
+
+	// Number of recent blocks kept in the key-value store, older canonical
+	// blocks are moved into the ancient store
+	DatabaseFreezerDistance uint64
+
+	// Number of recent blocks to keep the bodies and receipts of, 0 keeps all
+	HistoryKeep uint64 `toml:",omitempty"`
+
+	// Number of recent blocks to index the transactions of, 0 indexes all
+	TxLookupLimit     uint64 `toml:",omitempty"`
+	TxLookupSkipChief bool   `toml:",omitempty"` // Leave the chief-update transactions unindexed
+
+	// Whether to keep a flat snapshot of the state for faster state reads
+	Snapshot bool `toml:",omitempty"`
+
+	// Whether to index the logs by contract address and first topic, and the
+	// system contract calls by method
+	LogIndex bool `toml:",omitempty"`
 
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
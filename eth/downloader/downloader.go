@@ -92,8 +92,24 @@ var (
 	errCancelContentProcessing = errors.New("content processing canceled (requested)")
 	errNoSyncActive            = errors.New("no sync active")
 	errTooOld                  = errors.New("peer doesn't speak recent enough protocol version (need version >= 62)")
+	errCheckpointUnreached     = errors.New("remote chain below trusted checkpoint")
 )
 
+// Checkpoint is a block known to be canonical. Fast sync can't validate the
+// tribe signers of the headers below the pivot against the signer list of their
+// round, as the chief state needed to do so isn't available, so it requires them
+// to be sealed by the checkpoint signers and to lead to the checkpoint instead.
+// Peers which don't have the checkpoint are dropped before any of their headers
+// are imported. The pivot is never moved
+// past the checkpoint, the blocks after it are imported in full and their
+// signers validated against the chief state, so fast sync only pays off with a
+// recent checkpoint.
+type Checkpoint struct {
+	Number  uint64           `json:"number"`
+	Hash    common.Hash      `json:"hash"`
+	Signers []common.Address `json:"signers"` // Only sealers accepted up to the checkpoint, none if empty
+}
+
 type Downloader struct {
 	mode SyncMode       // Synchronisation mode defining the strategy used (per sync cycle)
 	mux  *event.TypeMux // Event multiplexer to announce sync operation events
@@ -105,12 +121,16 @@ type Downloader struct {
 	fsPivotLock  *types.Header // Pivot header on critical section entry (cannot change between retries)
 	fsPivotFails uint32        // Number of subsequent fast sync failures in the critical section
 
+	checkpoint *Checkpoint  // Trusted block the headers below the fast sync pivot must lead to
+	pivotHook  func(uint64) // Method to call when a fast sync pivot is chosen (signers are validated from there on)
+
 	rttEstimate   uint64 // Round trip time to target for download requests
 	rttConfidence uint64 // Confidence in the estimated RTT (unit: millionths to allow atomic ops)
 
 	// Statistics
 	syncStatsChainOrigin uint64 // Origin block number where syncing started at
 	syncStatsChainHeight uint64 // Highest block number known when syncing started
+	syncStatsPivot       uint64 // Pivot block of the current fast sync, 0 in other modes
 	syncStatsState       stateSyncStats
 	syncStatsLock        sync.RWMutex // Lock protecting the sync stats fields
 
@@ -233,6 +253,18 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+// SetCheckpoint configures the trusted block fast sync anchors the headers below
+// the pivot to.
+func (d *Downloader) SetCheckpoint(checkpoint *Checkpoint) {
+	d.checkpoint = checkpoint
+}
+
+// SetPivotHook sets the method called with the fast sync pivot every time a sync
+// cycle chooses one. Consensus engines use it to only validate seals below it.
+func (d *Downloader) SetPivotHook(hook func(uint64)) {
+	d.pivotHook = hook
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -260,6 +292,8 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 		HighestBlock:  d.syncStatsChainHeight,
 		PulledStates:  d.syncStatsState.processed,
 		KnownStates:   d.syncStatsState.processed + d.syncStatsState.pending,
+		Mode:          d.mode.String(),
+		PivotBlock:    d.syncStatsPivot,
 	}
 }
 
@@ -425,6 +459,15 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	height := latest.Number.Uint64()
 
+	// Make sure the peer is on the checkpointed chain before taking its headers
+	if d.mode == FastSync && d.checkpoint != nil {
+		if height < d.checkpoint.Number {
+			return errCheckpointUnreached
+		}
+		if err := d.fetchCheckpoint(p); err != nil {
+			return err
+		}
+	}
 	origin, err := d.findAncestor(p, height)
 	if err != nil {
 		return err
@@ -459,6 +502,10 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 			// Pivot point locked in, use this and do not pick a new one!
 			pivot = d.fsPivotLock.Number.Uint64()
 		}
+		// The checkpoint signers only vouch for the blocks up to the checkpoint
+		if d.checkpoint != nil && pivot > d.checkpoint.Number {
+			pivot = d.checkpoint.Number
+		}
 		// If the point is below the origin, move origin back to ensure state download
 		if pivot < origin {
 			if pivot > 0 {
@@ -468,7 +515,19 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 			}
 		}
 		log.Debug("Fast syncing until pivot block", "pivot", pivot)
+		if d.pivotHook != nil {
+			d.pivotHook(pivot)
+			defer d.pivotHook(0)
+		}
 	}
+	d.syncStatsLock.Lock()
+	if d.mode == FastSync {
+		d.syncStatsPivot = pivot
+	} else {
+		d.syncStatsPivot = 0
+	}
+	d.syncStatsLock.Unlock()
+
 	d.queue.Prepare(origin+1, d.mode, pivot, latest)
 	if d.syncInitHook != nil {
 		d.syncInitHook(origin, height)
@@ -615,6 +674,49 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 	}
 }
 
+// fetchCheckpoint retrieves the header of the trusted checkpoint from the peer
+// and checks that it is the checkpointed block.
+func (d *Downloader) fetchCheckpoint(p *peerConnection) error {
+	p.log.Debug("Retrieving remote checkpoint header", "number", d.checkpoint.Number)
+
+	go p.peer.RequestHeadersByNumber(d.checkpoint.Number, 1, 0, false)
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return errCancelBlockFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			// Make sure the peer gave the checkpointed block
+			headers := packet.(*headerPack).headers
+			if len(headers) != 1 {
+				p.log.Debug("Multiple headers for single request", "headers", len(headers))
+				return errBadPeer
+			}
+			if header := headers[0]; header.Number.Uint64() != d.checkpoint.Number || header.Hash() != d.checkpoint.Hash {
+				p.log.Warn("Remote chain doesn't match trusted checkpoint", "number", header.Number, "hash", header.Hash(), "checkpointHash", d.checkpoint.Hash)
+				return errInvalidChain
+			}
+			return nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for checkpoint header timed out", "elapsed", ttl)
+			return errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
 // findAncestor tries to locate the common ancestor link of the local chain and
 // a remote peers blockchain. In the general case when our node was in sync and
 // on the correct chain, checking the top N links should already get us a match.
@@ -1283,6 +1385,12 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				}
 				chunk := headers[:limit]
 
+				// Signers below the pivot aren't fully validated, make sure the headers lead to the checkpoint
+				if d.mode == FastSync {
+					if err := d.checkCheckpoint(chunk); err != nil {
+						return err
+					}
+				}
 				// In case of header only syncing, validate the chunk immediately
 				if d.mode == FastSync || d.mode == LightSync {
 					// Collect the yet unknown headers to mark them as uncertain
@@ -1349,6 +1457,23 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 	}
 }
 
+// checkCheckpoint verifies that a contiguous chunk of headers matches the
+// trusted checkpoint, if it contains the checkpointed block.
+func (d *Downloader) checkCheckpoint(chunk []*types.Header) error {
+	if d.checkpoint == nil || len(chunk) == 0 {
+		return nil
+	}
+	n, first := d.checkpoint.Number, chunk[0].Number.Uint64()
+	if n < first || n > chunk[len(chunk)-1].Number.Uint64() {
+		return nil
+	}
+	if header := chunk[n-first]; header.Hash() != d.checkpoint.Hash {
+		log.Warn("Header doesn't match trusted checkpoint", "number", n, "remoteHash", header.Hash(), "checkpointHash", d.checkpoint.Hash)
+		return errInvalidChain
+	}
+	return nil
+}
+
 // processFullSyncContent takes fetch results from the queue and imports them into the chain.
 func (d *Downloader) processFullSyncContent() error {
 	for {
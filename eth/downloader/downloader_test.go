@@ -922,6 +922,76 @@ func testBoundedHeavyForkedSync(t *testing.T, protocol int, mode SyncMode) {
 	}
 }
 
+// Tests that header chunks containing the trusted checkpoint block are only
+// accepted if they match its hash.
+func TestCheckpointMismatch(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chunk := make([]*types.Header, 4)
+	for i := range chunk {
+		chunk[i] = &types.Header{Number: big.NewInt(int64(10 + i)), Extra: []byte{1}}
+	}
+	if err := tester.downloader.checkCheckpoint(chunk); err != nil {
+		t.Fatalf("chunk rejected without checkpoint: %v", err)
+	}
+	tester.downloader.SetCheckpoint(&Checkpoint{Number: 12, Hash: chunk[2].Hash()})
+	if err := tester.downloader.checkCheckpoint(chunk); err != nil {
+		t.Fatalf("matching chunk rejected: %v", err)
+	}
+	if err := tester.downloader.checkCheckpoint(chunk[3:]); err != nil {
+		t.Fatalf("chunk past the checkpoint rejected: %v", err)
+	}
+	chunk[2] = &types.Header{Number: big.NewInt(12), Extra: []byte{2}}
+	if err := tester.downloader.checkCheckpoint(chunk); err != errInvalidChain {
+		t.Fatalf("error mismatch: have %v, want %v", err, errInvalidChain)
+	}
+}
+
+// Tests that fast syncing with a trusted checkpoint drops a peer which doesn't
+// have the checkpointed block before any of its headers are imported.
+func TestCheckpointPeerRejected(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Generate a short chain, without transactions the chain maker doesn't
+	// need a blockchain to run them on
+	targetBlocks := 32
+	chain, chainReceipts := core.GenerateChain(params.TestChainConfig, tester.genesis, ethash.NewFaker(), tester.peerDb, targetBlocks, nil)
+
+	hashes := []common.Hash{tester.genesis.Hash()}
+	headers := map[common.Hash]*types.Header{tester.genesis.Hash(): tester.genesis.Header()}
+	blocks := map[common.Hash]*types.Block{tester.genesis.Hash(): tester.genesis}
+	receipts := map[common.Hash]types.Receipts{tester.genesis.Hash(): nil}
+	for i, block := range chain {
+		hashes = append([]common.Hash{block.Hash()}, hashes...)
+		headers[block.Hash()] = block.Header()
+		blocks[block.Hash()] = block
+		receipts[block.Hash()] = chainReceipts[i]
+	}
+	tester.newPeer("forked", 63, hashes, headers, blocks, receipts)
+	tester.newPeer("canonical", 63, hashes, headers, blocks, receipts)
+
+	tester.downloader.SetCheckpoint(&Checkpoint{Number: 10, Hash: common.Hash{0x01}})
+	if err := tester.downloader.Synchronise("forked", hashes[0], tester.peerChainTds["forked"][hashes[0]], FastSync); err != errInvalidChain {
+		t.Fatalf("sync failure mismatch: have %v, want %v", err, errInvalidChain)
+	}
+	if _, ok := tester.peerHashes["forked"]; ok {
+		t.Errorf("peer without the checkpoint not dropped")
+	}
+	assertOwnChain(t, tester, 1)
+
+	tester.downloader.SetCheckpoint(&Checkpoint{Number: 10, Hash: hashes[len(hashes)-11]})
+	if err := tester.sync("canonical", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+}
+
 // Tests that an inactive downloader will not accept incoming block headers and
 // bodies.
 func TestInactiveDownloader62(t *testing.T) {
@@ -0,0 +1,43 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/MeshBoxFoundation/meshbox/core/forkid"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+)
+
+// ethEntry is the "eth" ENR entry which advertises eth protocol
+// on the discovery network.
+type ethEntry struct {
+	ForkID forkid.ID // Fork identifier per EIP-2124
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ethEntry) ENRKey() string {
+	return "eth"
+}
+
+// currentENREntry constructs an `eth` ENR entry based on the current state of the chain.
+func (pm *ProtocolManager) currentENREntry() *ethEntry {
+	return &ethEntry{
+		ForkID: forkid.NewID(pm.chainconfig, pm.blockchain.Genesis().Hash(), pm.blockchain.CurrentHeader().Number.Uint64()),
+	}
+}
@@ -19,6 +19,7 @@ package filters
 import (
 	"context"
 	"math/big"
+	"sort"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/core"
@@ -42,6 +43,10 @@ type Backend interface {
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	// LogIndexStatus returns the section size and the number of sections of the
+	// address and topic log index, zero sections if it's disabled.
+	LogIndexStatus() (uint64, uint64)
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -113,13 +118,28 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		logs []*types.Log
 		err  error
 	)
+	if len(f.addresses) > 0 {
+		size, sections := f.backend.LogIndexStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				logs, err = f.logIndexedLogs(ctx, end)
+			} else {
+				logs, err = f.logIndexedLogs(ctx, indexed-1)
+			}
+			if err != nil || f.begin > int64(end) {
+				return logs, err
+			}
+		}
+	}
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
@@ -176,6 +196,56 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
+// logIndexedLogs returns the logs matching the filter criteria based on the
+// address and first topic log index. The candidate blocks of every address and
+// topic pair are merged, then checked against the receipts.
+func (f *Filter) logIndexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+	var topics []common.Hash
+	if len(f.topics) > 0 {
+		topics = f.topics[0]
+	}
+	candidates := make(map[uint64]struct{})
+	for _, address := range f.addresses {
+		if len(topics) == 0 {
+			for _, number := range core.GetLogIndexBlocks(f.db, address, nil, uint64(f.begin), end) {
+				candidates[number] = struct{}{}
+			}
+			continue
+		}
+		for i := range topics {
+			for _, number := range core.GetLogIndexBlocks(f.db, address, &topics[i], uint64(f.begin), end) {
+				candidates[number] = struct{}{}
+			}
+		}
+	}
+	numbers := make([]uint64, 0, len(candidates))
+	for number := range candidates {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	var logs []*types.Log
+	for _, number := range numbers {
+		select {
+		case <-ctx.Done():
+			return logs, ctx.Err()
+		default:
+		}
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if header == nil || err != nil {
+			return logs, err
+		}
+		found, err := f.checkMatches(ctx, header)
+		if err != nil {
+			return logs, err
+		}
+		logs = append(logs, found...)
+		f.begin = int64(number) + 1
+	}
+	f.begin = int64(end) + 1
+	return logs, nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
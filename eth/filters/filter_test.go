@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
@@ -56,7 +57,7 @@ func BenchmarkFilters(b *testing.B) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, 0, 0}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
 		addr2      = common.BytesToAddress([]byte("jeff"))
@@ -121,7 +122,7 @@ func TestFilters(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, 0, 0}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr       = crypto.PubkeyToAddress(key1.PublicKey)
 
@@ -241,3 +242,80 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// Tests that the logs of the blocks covered by the address and topic log index
+// are looked up in there, and the rest found by iterating the blocks.
+func TestLogIndexFilters(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), 100, 2}
+		addr1   = common.BytesToAddress([]byte("addr1"))
+		addr2   = common.BytesToAddress([]byte("addr2"))
+		hash1   = common.BytesToHash([]byte("topic1"))
+		hash2   = common.BytesToHash([]byte("topic2"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr1, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 300, func(i int, gen *core.BlockGen) {
+		var log *types.Log
+		switch i {
+		case 10, 150, 250:
+			log = &types.Log{Address: addr1, Topics: []common.Hash{hash1}}
+		case 20, 260:
+			log = &types.Log{Address: addr1, Topics: []common.Hash{hash2}}
+		case 30:
+			log = &types.Log{Address: addr2}
+		default:
+			return
+		}
+		log.BlockNumber = gen.Number().Uint64()
+		receipt := types.NewReceipt(nil, false, new(big.Int))
+		receipt.Logs = []*types.Log{log}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		gen.AddUncheckedReceipt(receipt)
+	})
+	for i, block := range chain {
+		core.WriteBlock(db, block)
+		core.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		core.WriteHeadBlockHash(db, block.Hash())
+		core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+
+		// Index the first two sections, except for the log of block 151
+		if block.NumberU64() < 200 && block.NumberU64() != 151 {
+			for _, receipt := range receipts[i] {
+				for _, log := range receipt.Logs {
+					var topic common.Hash
+					if len(log.Topics) > 0 {
+						topic = log.Topics[0]
+					}
+					core.WriteLogIndexEntry(db, log.Address, topic, block.NumberU64())
+				}
+			}
+		}
+	}
+	tests := []struct {
+		begin, end int64
+		addresses  []common.Address
+		topics     [][]common.Hash
+		want       []uint64
+	}{
+		{0, -1, []common.Address{addr1}, nil, []uint64{11, 21, 251, 261}},
+		{0, -1, []common.Address{addr1}, [][]common.Hash{{hash1}}, []uint64{11, 251}},
+		{0, -1, []common.Address{addr1, addr2}, [][]common.Hash{{hash2}}, []uint64{21, 261}},
+		{0, 100, []common.Address{addr2}, nil, []uint64{31}},
+		{15, 255, []common.Address{addr1}, nil, []uint64{21, 251}},
+		{0, -1, nil, [][]common.Hash{{hash1}}, []uint64{11, 151, 251}},
+	}
+	for i, tt := range tests {
+		logs, err := New(backend, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs: %v", i, err)
+		}
+		var have []uint64
+		for _, log := range logs {
+			have = append(have, log.BlockNumber)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: log blocks mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
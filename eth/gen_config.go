@@ -11,6 +11,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/core"
 	"github.com/MeshBoxFoundation/meshbox/eth/downloader"
 	"github.com/MeshBoxFoundation/meshbox/eth/gasprice"
+	"github.com/MeshBoxFoundation/meshbox/params"
 )
 
 func (c Config) MarshalTOML() (interface{}, error) {
@@ -18,12 +19,23 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		MaxPeers                int  `toml:"-"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		FastSyncCheckpoint      *downloader.Checkpoint    `toml:",omitempty"`
+		LightServ               int                       `toml:",omitempty"`
+		LightPeers              int                       `toml:",omitempty"`
+		LightPriorityFile       string                    `toml:",omitempty"`
+		LightCheckpoint         *params.TrustedCheckpoint `toml:",omitempty"`
+		ULC                     *ULCConfig                `toml:",omitempty"`
+		MaxPeers                int                       `toml:"-"`
+		SkipBcVersionCheck      bool                      `toml:"-"`
+		DatabaseHandles         int                       `toml:"-"`
 		DatabaseCache           int
+		DatabaseFreezer         string
+		DatabaseFreezerDistance uint64
+		HistoryKeep             uint64         `toml:",omitempty"`
+		TxLookupLimit           uint64         `toml:",omitempty"`
+		TxLookupSkipChief       bool           `toml:",omitempty"`
+		Snapshot                bool           `toml:",omitempty"`
+		LogIndex                bool           `toml:",omitempty"`
 		Etherbase               common.Address `toml:",omitempty"`
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
@@ -44,11 +56,22 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.FastSyncCheckpoint = c.FastSyncCheckpoint
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
+	enc.LightPriorityFile = c.LightPriorityFile
+	enc.LightCheckpoint = c.LightCheckpoint
+	enc.ULC = c.ULC
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.DatabaseFreezerDistance = c.DatabaseFreezerDistance
+	enc.HistoryKeep = c.HistoryKeep
+	enc.TxLookupLimit = c.TxLookupLimit
+	enc.TxLookupSkipChief = c.TxLookupSkipChief
+	enc.Snapshot = c.Snapshot
+	enc.LogIndex = c.LogIndex
 	enc.Etherbase = c.Etherbase
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
@@ -72,12 +95,23 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		MaxPeers                *int  `toml:"-"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		FastSyncCheckpoint      *downloader.Checkpoint    `toml:",omitempty"`
+		LightServ               *int                      `toml:",omitempty"`
+		LightPeers              *int                      `toml:",omitempty"`
+		LightPriorityFile       *string                   `toml:",omitempty"`
+		LightCheckpoint         *params.TrustedCheckpoint `toml:",omitempty"`
+		ULC                     *ULCConfig                `toml:",omitempty"`
+		MaxPeers                *int                      `toml:"-"`
+		SkipBcVersionCheck      *bool                     `toml:"-"`
+		DatabaseHandles         *int                      `toml:"-"`
 		DatabaseCache           *int
+		DatabaseFreezer         *string
+		DatabaseFreezerDistance *uint64
+		HistoryKeep             *uint64         `toml:",omitempty"`
+		TxLookupLimit           *uint64         `toml:",omitempty"`
+		TxLookupSkipChief       *bool           `toml:",omitempty"`
+		Snapshot                *bool           `toml:",omitempty"`
+		LogIndex                *bool           `toml:",omitempty"`
 		Etherbase               *common.Address `toml:",omitempty"`
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes   `toml:",omitempty"`
@@ -107,12 +141,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.FastSyncCheckpoint != nil {
+		c.FastSyncCheckpoint = dec.FastSyncCheckpoint
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.LightPriorityFile != nil {
+		c.LightPriorityFile = *dec.LightPriorityFile
+	}
+	if dec.LightCheckpoint != nil {
+		c.LightCheckpoint = dec.LightCheckpoint
+	}
+	if dec.ULC != nil {
+		c.ULC = dec.ULC
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -122,6 +168,27 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
+	if dec.DatabaseFreezerDistance != nil {
+		c.DatabaseFreezerDistance = *dec.DatabaseFreezerDistance
+	}
+	if dec.HistoryKeep != nil {
+		c.HistoryKeep = *dec.HistoryKeep
+	}
+	if dec.TxLookupLimit != nil {
+		c.TxLookupLimit = *dec.TxLookupLimit
+	}
+	if dec.TxLookupSkipChief != nil {
+		c.TxLookupSkipChief = *dec.TxLookupSkipChief
+	}
+	if dec.Snapshot != nil {
+		c.Snapshot = *dec.Snapshot
+	}
+	if dec.LogIndex != nil {
+		c.LogIndex = *dec.LogIndex
+	}
 	if dec.Etherbase != nil {
 		c.Etherbase = *dec.Etherbase
 	}
@@ -29,7 +29,9 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/consensus"
 	"github.com/MeshBoxFoundation/meshbox/consensus/misc"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
 	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/core/forkid"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/eth/downloader"
@@ -39,6 +41,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/p2p"
 	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
 	"github.com/MeshBoxFoundation/meshbox/params"
 	"github.com/MeshBoxFoundation/meshbox/rlp"
 )
@@ -74,11 +77,13 @@ type ProtocolManager struct {
 	blockchain  *core.BlockChain
 	chaindb     ethdb.Database
 	chainconfig *params.ChainConfig
+	forkFilter  forkid.Filter // Fork ID filter, constant across the lifetime of the node
 	maxPeers    int
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
+	peering    *tribePeering // Signer-to-signer priority peering, nil if not running tribe
 
 	SubProtocols []p2p.Protocol
 
@@ -109,6 +114,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		blockchain:  blockchain,
 		chaindb:     chaindb,
 		chainconfig: config,
+		forkFilter:  forkid.NewFilter(config, blockchain.Genesis().Hash(), func() uint64 { return blockchain.CurrentHeader().Number.Uint64() }),
 		peers:       newPeerSet(),
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
@@ -123,6 +129,9 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	if mode == downloader.FastSync {
 		manager.fastSync = uint32(1)
 	}
+	if t, ok := engine.(*tribe.Tribe); ok {
+		manager.peering = newTribePeering(manager, t)
+	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
@@ -157,6 +166,15 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 				}
 				return nil
 			},
+			Attributes: func() []enr.Entry {
+				entries := []enr.Entry{manager.currentENREntry()}
+				if manager.peering != nil && version >= eth64 {
+					if entry := manager.peering.entry(); entry != nil {
+						entries = append(entries, entry)
+					}
+				}
+				return entries
+			},
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
@@ -164,6 +182,10 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	}
 	// Construct the different synchronisation mechanisms
 	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	if t, ok := engine.(*tribe.Tribe); ok {
+		// The chief state of the blocks below the pivot is never downloaded
+		manager.downloader.SetPivotHook(t.SetSyncPivot)
+	}
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -223,6 +245,14 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	go pm.txsyncLoop()
 }
 
+// StartPeering starts keeping priority connections to the other tribe signers
+// and leaders on the given server. It's a noop for other consensus engines.
+func (pm *ProtocolManager) StartPeering(srv *p2p.Server) {
+	if pm.peering != nil {
+		pm.peering.start(srv)
+	}
+}
+
 func (pm *ProtocolManager) Stop() {
 	log.Info("Stopping Ethereum protocol")
 
@@ -255,14 +285,17 @@ func (pm *ProtocolManager) newPeer(pv int, p *p2p.Peer, rw p2p.MsgReadWriter) *p
 // handle is the callback invoked to manage the life cycle of an eth peer. When
 // this function terminates, the peer is disconnected.
 func (pm *ProtocolManager) handle(p *peer) error {
-	if pm.peers.Len() >= pm.maxPeers {
+	if pm.peers.Len() >= pm.maxPeers && !p.Peer.Priority() {
 		return p2p.DiscTooManyPeers
 	}
 	p.Log().Debug("Ethereum peer connected", "name", p.Name())
 
 	// Execute the Ethereum handshake
-	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	var (
+		td, head, genesis = pm.blockchain.Status()
+		forkID            = forkid.NewID(pm.chainconfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
+	)
+	if err := p.Handshake(pm.networkId, td, head, genesis, forkID, pm.forkFilter); err != nil {
 		p.Log().Debug("Ethereum handshake failed", "peer", p.RemoteAddr(), "err", err)
 		return err
 	}
@@ -285,6 +318,13 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	// after this will be sent via broadcasts.
 	pm.syncTransactions(p)
 
+	// Tell the peer where to find the signers we know about.
+	if pm.peering != nil && p.version >= eth64 {
+		if err := pm.peering.sendRecords(p); err != nil {
+			return err
+		}
+	}
+
 	// If we're DAO hard-fork aware, validate any remote peer with regard to the hard-fork
 	if daoBlock := pm.chainconfig.DAOForkBlock; daoBlock != nil {
 		// Request the peer's DAO fork header for extra-data validation
@@ -487,6 +527,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if data := pm.blockchain.GetBodyRLP(hash); len(data) != 0 {
 				bodies = append(bodies, data)
 				bytes += len(data)
+			} else if pm.historyPruned(hash) {
+				// Bodies are matched by position, answer the ones before the expired one only
+				break
 			}
 		}
 		return p.SendBlockBodiesRLP(bodies)
@@ -577,6 +620,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			// Retrieve the requested block's receipts, skipping if unknown to us
 			results := core.GetBlockReceipts(pm.chaindb, hash, core.GetBlockNumber(pm.chaindb, hash))
 			if results == nil {
+				if pm.historyPruned(hash) {
+					// Receipts are matched by position, answer the ones before the expired one only
+					break
+				}
 				if header := pm.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
 					continue
 				}
@@ -602,6 +649,19 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
 
+	case p.version >= eth64 && msg.Code == NodeRecordsMsg:
+		// A batch of signer node records arrived
+		var records []*enr.Record
+		if err := msg.Decode(&records); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(records) > maxNodeRecords {
+			return errResp(ErrDecode, "too many node records: %d", len(records))
+		}
+		if pm.peering != nil {
+			pm.peering.handleRecords(p, records)
+		}
+
 	case msg.Code == NewBlockHashesMsg:
 		var announces newBlockHashesData
 		if err := msg.Decode(&announces); err != nil {
@@ -779,6 +839,7 @@ type NodeInfo struct {
 	Genesis    common.Hash         `json:"genesis"`    // SHA3 hash of the host's genesis block
 	Config     *params.ChainConfig `json:"config"`     // Chain configuration for the fork rules
 	Head       common.Hash         `json:"head"`       // SHA3 hash of the host's best owned block
+	History    uint64              `json:"history"`    // Oldest block whose body and receipts are served, 0 if all
 }
 
 // NodeInfo retrieves some protocol metadata about the running host node.
@@ -790,5 +851,12 @@ func (self *ProtocolManager) NodeInfo() *NodeInfo {
 		Genesis:    self.blockchain.Genesis().Hash(),
 		Config:     self.blockchain.Config(),
 		Head:       currentBlock.Hash(),
+		History:    core.GetHistoryTail(self.chaindb),
 	}
 }
+
+// historyPruned returns whether the body and receipts of the block with the
+// given hash were deleted by history expiry.
+func (pm *ProtocolManager) historyPruned(hash common.Hash) bool {
+	return core.IsHistoryPruned(pm.chaindb, core.GetBlockNumber(pm.chaindb, hash))
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/params"
+)
+
+// LogIndexer implements a core.ChainIndexer, indexing the canonical blocks by
+// the contract address and first topic of the logs they emitted. The chief,
+// POC and anmap contracts record most of their state without events, so the
+// successful calls into them are indexed by method selector as well.
+type LogIndexer struct {
+	db    ethdb.Database // database instance to write index data into
+	batch ethdb.Batch    // batch collecting the entries of the current section
+}
+
+// NewLogIndexer returns a chain indexer that generates the log and system call
+// index for the canonical chain.
+func NewLogIndexer(db ethdb.Database, size uint64) *core.ChainIndexer {
+	backend := &LogIndexer{
+		db: db,
+	}
+	table := ethdb.NewTable(db, string(core.LogIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, bloomConfirms, bloomThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+// Entries left over from reorged blocks are not deleted, the index is only used
+// to find candidate blocks whose receipts are checked anyway.
+func (b *LogIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	b.batch = b.db.NewBatch()
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs and system calls
+// of a new header into the index. Blocks whose receipts were expired are
+// skipped.
+func (b *LogIndexer) Process(header *types.Header) {
+	var (
+		hash     = header.Hash()
+		number   = header.Number.Uint64()
+		receipts = core.GetBlockReceipts(b.db, hash, number)
+	)
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			var topic common.Hash
+			if len(log.Topics) > 0 {
+				topic = log.Topics[0]
+			}
+			core.WriteLogIndexEntry(b.batch, log.Address, topic, number)
+		}
+	}
+	body := core.GetBody(b.db, hash, number)
+	if body == nil || len(body.Transactions) != len(receipts) {
+		return
+	}
+	for i, tx := range body.Transactions {
+		if tx.To() == nil || len(tx.Data()) < 4 || receipts[i].Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		if isSystemContract(*tx.To(), header.Number) {
+			core.WriteCallIndexEntry(b.batch, *tx.To(), tx.Data()[:4], number)
+		}
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, writing the entries of the section
+// out into the database.
+func (b *LogIndexer) Commit() error {
+	return b.batch.Write()
+}
+
+// isSystemContract returns whether addr is one of the tribe system contracts
+// whose calls are indexed at the given block.
+func isSystemContract(addr common.Address, number *big.Int) bool {
+	if params.IsChiefAddress(addr) {
+		return true
+	}
+	if poc := params.POCInfo(); poc != (common.Address{}) && addr == poc {
+		return true
+	}
+	_, anmap := params.AnmapInfo(number, "0.0.1")
+	return anmap != (common.Address{}) && addr == anmap
+}
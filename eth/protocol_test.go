@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core/forkid"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/eth/downloader"
@@ -88,6 +89,61 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+func TestStatusMsgErrors64(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	var (
+		protocol          = eth64
+		td, head, genesis = pm.blockchain.Status()
+		forkID            = forkid.NewID(pm.chainconfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
+	)
+	defer pm.Stop()
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: TxMsg, data: []interface{}{},
+			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
+		},
+		{
+			code: StatusMsg, data: statusData64{10, DefaultConfig.NetworkId, td, head, genesis, forkID},
+			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", protocol),
+		},
+		{
+			code: StatusMsg, data: statusData64{uint32(protocol), 999, td, head, genesis, forkID},
+			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
+		},
+		{
+			code: StatusMsg, data: statusData64{uint32(protocol), DefaultConfig.NetworkId, td, head, common.Hash{3}, forkID},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData64{uint32(protocol), DefaultConfig.NetworkId, td, head, genesis, forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
+			wantError: errResp(ErrForkIDRejected, "%v (remote %v)", forkid.ErrLocalIncompatibleOrStale, forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}),
+		},
+	}
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", protocol, pm, false)
+		// The send call might hang until reset because
+		// the protocol might not read the payload.
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down within 2 seconds")
+		}
+		p.close()
+	}
+}
+
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
@@ -0,0 +1,367 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
+	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/MeshBoxFoundation/meshbox/p2p"
+	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	maxNodeRecords    = 64  // Maximum number of node records accepted in a single message
+	nodeRecordCache   = 256 // Number of signer node records to keep around
+	chainHeadChanSize = 10  // Size of channel listening to ChainHeadEvent
+)
+
+var (
+	errNoTribeEntry    = errors.New("missing tribe entry")
+	errTribeEntryOwner = errors.New("tribe entry not owned by node key")
+)
+
+// tribeEntry is the "tribe" ENR entry which announces the signer address of
+// a node. In tribe the signer address is derived from the node key, so the
+// record signature proves the entry.
+type tribeEntry struct {
+	Signer common.Address
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e tribeEntry) ENRKey() string {
+	return "tribe"
+}
+
+// tribePeering keeps priority connections between the current signers and
+// leaders. Signers exchange their node records over eth/64, the member set is
+// reloaded on every new chain head.
+type tribePeering struct {
+	pm    *ProtocolManager
+	tribe *tribe.Tribe
+
+	lock     sync.Mutex
+	srv      *p2p.Server
+	self     common.Address
+	records  *lru.ARCCache                     // signer address -> latest *enr.Record
+	members  map[common.Address]bool           // current signers and leaders
+	priority map[common.Address]*discover.Node // nodes promoted to priority peers
+	ownSeq   uint64                            // sequence number of the own record last sent out
+	update   chan struct{}
+}
+
+func newTribePeering(pm *ProtocolManager, t *tribe.Tribe) *tribePeering {
+	records, _ := lru.NewARC(nodeRecordCache)
+	return &tribePeering{
+		pm:       pm,
+		tribe:    t,
+		records:  records,
+		members:  make(map[common.Address]bool),
+		priority: make(map[common.Address]*discover.Node),
+		update:   make(chan struct{}, 1),
+	}
+}
+
+// start begins maintaining the priority peers on the given server.
+func (tp *tribePeering) start(srv *p2p.Server) {
+	tp.lock.Lock()
+	tp.srv = srv
+	tp.self = crypto.PubkeyToAddress(srv.PrivateKey.PublicKey)
+	tp.lock.Unlock()
+
+	tp.pm.wg.Add(1)
+	go tp.loop()
+}
+
+// entry returns the tribe ENR entry of the local node, nil before start.
+func (tp *tribePeering) entry() enr.Entry {
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+
+	if tp.srv == nil {
+		return nil
+	}
+	return &tribeEntry{Signer: tp.self}
+}
+
+func (tp *tribePeering) loop() {
+	defer tp.pm.wg.Done()
+
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := tp.pm.blockchain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	tp.refresh(true)
+	for {
+		select {
+		case <-headCh:
+			tp.refresh(true)
+		case <-tp.update:
+			tp.refresh(false)
+		case <-sub.Err():
+			return
+		case <-tp.pm.quitSync:
+			return
+		}
+	}
+}
+
+// refresh reloads the member set if requested and brings the priority peers
+// in line with it. If the own record changed, it is announced to all peers.
+func (tp *tribePeering) refresh(reload bool) {
+	tp.lock.Lock()
+	if reload {
+		members := make(map[common.Address]bool)
+		for _, s := range tp.tribe.Status.Signers {
+			members[s.Address] = true
+		}
+		for _, l := range tp.tribe.Status.Leaders {
+			members[l] = true
+		}
+		tp.setMembers(members)
+	}
+	var add, remove []*discover.Node
+	for addr, node := range tp.priority {
+		if !tp.members[addr] {
+			remove = append(remove, node)
+			delete(tp.priority, addr)
+		}
+	}
+	for addr := range tp.members {
+		if addr == tp.self {
+			continue
+		}
+		cached, ok := tp.records.Get(addr)
+		if !ok {
+			continue
+		}
+		node := recordNode(cached.(*enr.Record))
+		if old := tp.priority[addr]; old != nil {
+			if old.ID == node.ID && old.IP.Equal(node.IP) && old.TCP == node.TCP {
+				continue
+			}
+			remove = append(remove, old)
+		}
+		tp.priority[addr] = node
+		add = append(add, node)
+	}
+	member := tp.members[tp.self]
+	srv := tp.srv
+	tp.lock.Unlock()
+
+	for _, node := range remove {
+		log.Debug("Removing tribe priority peer", "node", node)
+		srv.RemovePriorityPeer(node)
+	}
+	for _, node := range add {
+		log.Debug("Adding tribe priority peer", "node", node)
+		srv.AddPriorityPeer(node)
+	}
+	if !member {
+		// Announce the own record again once the node becomes a member
+		tp.lock.Lock()
+		tp.ownSeq = 0
+		tp.lock.Unlock()
+		return
+	}
+	own, err := srv.NodeRecord()
+	if err != nil {
+		log.Warn("Failed to sign node record", "err", err)
+		return
+	}
+	tp.lock.Lock()
+	changed := own.Seq() != tp.ownSeq
+	tp.ownSeq = own.Seq()
+	tp.lock.Unlock()
+
+	if changed {
+		tp.broadcast([]*enr.Record{own}, nil)
+	}
+}
+
+// setMembers replaces the member set and drops the cached records of former
+// members, only members are cached. The lock must be held.
+func (tp *tribePeering) setMembers(members map[common.Address]bool) {
+	tp.members = members
+	for _, addr := range tp.records.Keys() {
+		if !members[addr.(common.Address)] {
+			tp.records.Remove(addr)
+		}
+	}
+}
+
+// memberRecords returns the known records of the current members, including
+// the own one if the local node is a member itself.
+func (tp *tribePeering) memberRecords() []*enr.Record {
+	tp.lock.Lock()
+	srv, member := tp.srv, tp.members[tp.self]
+	tp.lock.Unlock()
+
+	var records []*enr.Record
+	if member {
+		// Signed outside the lock, the record attributes include tp.entry.
+		if own, err := srv.NodeRecord(); err == nil {
+			records = append(records, own)
+		}
+	}
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+
+	for addr := range tp.members {
+		if addr == tp.self {
+			continue
+		}
+		if cached, ok := tp.records.Get(addr); ok {
+			records = append(records, cached.(*enr.Record))
+		}
+	}
+	return records
+}
+
+// sendRecords hands the member records to a freshly connected peer.
+func (tp *tribePeering) sendRecords(p *peer) error {
+	records := tp.memberRecords()
+	if len(records) == 0 {
+		return nil
+	}
+	return p.SendNodeRecords(records)
+}
+
+// handleRecords stores the valid records of current members received from a
+// peer and relays the new ones. Records of non-members are dropped, so
+// peers cannot flood the cache.
+func (tp *tribePeering) handleRecords(p *peer, records []*enr.Record) {
+	var relay []*enr.Record
+	for _, r := range records {
+		signer, err := recordSigner(r)
+		if err != nil {
+			p.Log().Debug("Discarded node record", "err", err)
+			continue
+		}
+		tp.lock.Lock()
+		if !tp.members[signer] || signer == tp.self {
+			tp.lock.Unlock()
+			continue
+		}
+		if cached, ok := tp.records.Get(signer); ok && !supersedes(r, cached.(*enr.Record)) {
+			tp.lock.Unlock()
+			continue
+		}
+		tp.records.Add(signer, r)
+		tp.lock.Unlock()
+
+		relay = append(relay, r)
+	}
+	if len(relay) == 0 {
+		return
+	}
+	tp.broadcast(relay, p)
+	select {
+	case tp.update <- struct{}{}:
+	default:
+	}
+}
+
+// broadcast sends the records to all eth/64 peers except the origin.
+func (tp *tribePeering) broadcast(records []*enr.Record, origin *peer) {
+	for _, p := range tp.pm.peers.Peers() {
+		if p == origin || p.version < eth64 {
+			continue
+		}
+		if err := p.SendNodeRecords(records); err != nil {
+			p.Log().Debug("Failed to send node records", "err", err)
+		}
+	}
+}
+
+// supersedes reports whether the record r replaces the cached record of the
+// same signer. A record with the same sequence number but a different content
+// was signed by a node which lost its stored sequence number, the tie is broken
+// by the encodings so all peers settle on the same record instead of relaying
+// both of them forever.
+func supersedes(r, cached *enr.Record) bool {
+	if r.Seq() != cached.Seq() {
+		return r.Seq() > cached.Seq()
+	}
+	enc, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		return false
+	}
+	cachedEnc, err := rlp.EncodeToBytes(cached)
+	if err != nil {
+		return false
+	}
+	return bytes.Compare(enc, cachedEnc) > 0
+}
+
+// recordSigner returns the signer address announced by the record after
+// checking that it matches the key which signed the record.
+func recordSigner(r *enr.Record) (common.Address, error) {
+	var (
+		entry tribeEntry
+		pub   enr.Secp256k1
+	)
+	if err := r.Load(&entry); err != nil {
+		return common.Address{}, errNoTribeEntry
+	}
+	if err := r.Load(&pub); err != nil {
+		return common.Address{}, err
+	}
+	if crypto.PubkeyToAddress(ecdsa.PublicKey(pub)) != entry.Signer {
+		return common.Address{}, errTribeEntryOwner
+	}
+	return entry.Signer, nil
+}
+
+// recordNode assembles the dial destination out of a node record. A record
+// without an endpoint results in an incomplete node which the server resolves
+// through discovery.
+func recordNode(r *enr.Record) *discover.Node {
+	var (
+		pub enr.Secp256k1
+		ip  net.IP
+		ip4 enr.IP4
+		ip6 enr.IP6
+		tcp enr.TCP
+		udp enr.UDP
+	)
+	r.Load(&pub)
+	if r.Load(&ip4) == nil {
+		ip = net.IP(ip4)
+	} else if r.Load(&ip6) == nil {
+		ip = net.IP(ip6)
+	}
+	r.Load(&tcp)
+	r.Load(&udp)
+
+	key := ecdsa.PublicKey(pub)
+	return discover.NewNode(discover.PubkeyID(&key), ip, uint16(udp), uint16(tcp))
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/eth/downloader"
+	"github.com/MeshBoxFoundation/meshbox/p2p"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
+)
+
+// newTestRecord signs a node record of the given sequence number announcing
+// the signer address of the key.
+func newTestRecord(t *testing.T, key *ecdsa.PrivateKey, seq uint64, port uint16) *enr.Record {
+	r := new(enr.Record)
+	r.SetSeq(seq - 1)
+	r.Set(tribeEntry{Signer: crypto.PubkeyToAddress(key.PublicKey)})
+	r.Set(enr.TCP(port))
+	if err := r.Sign(key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	return r
+}
+
+// Tests that a node record replaces the cached one of its signer when it has
+// a higher sequence number, or the same one with a different content.
+func TestRecordSupersedes(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sign := func(seq uint64, port uint16) *enr.Record {
+		return newTestRecord(t, key, seq, port)
+	}
+	var (
+		old   = sign(5, 30303)
+		newer = sign(6, 30303)
+		moved = sign(5, 30304)
+	)
+	if !supersedes(newer, old) || supersedes(old, newer) {
+		t.Errorf("higher sequence number must win")
+	}
+	if supersedes(old, sign(5, 30303)) {
+		t.Errorf("identical record replaced the cached one")
+	}
+	if supersedes(moved, old) == supersedes(old, moved) {
+		t.Errorf("records with the same sequence number must settle on one of them")
+	}
+}
+
+// Tests that node records exchanged over eth/64 are relayed to the other peers
+// if they are newer than the cached ones, that stale records and the records
+// of non-members are ignored, and that the records of former members are
+// dropped instead of being handed to new peers.
+func TestNodeRecordsRelay(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	var (
+		signer, _   = crypto.GenerateKey()
+		leader, _   = crypto.GenerateKey()
+		outsider, _ = crypto.GenerateKey()
+	)
+	pm.peering = newTribePeering(pm, nil)
+	pm.peering.lock.Lock()
+	pm.peering.setMembers(map[common.Address]bool{
+		crypto.PubkeyToAddress(signer.PublicKey): true,
+		crypto.PubkeyToAddress(leader.PublicKey): true,
+	})
+	pm.peering.lock.Unlock()
+
+	src, _ := newTestPeer("source", eth64, pm, true)
+	defer src.close()
+	dst, _ := newTestPeer("sink", eth64, pm, true)
+	defer dst.close()
+
+	for i := 0; pm.peers.Len() < 2; i++ {
+		if i == 100 {
+			t.Fatalf("peers not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// A new record of a member is relayed to the other peer
+	fresh := newTestRecord(t, signer, 2, 30303)
+	if err := p2p.Send(src.app, NodeRecordsMsg, []*enr.Record{fresh}); err != nil {
+		t.Fatalf("failed to send records: %v", err)
+	}
+	if err := p2p.ExpectMsg(dst.app, NodeRecordsMsg, []*enr.Record{fresh}); err != nil {
+		t.Fatalf("fresh record not relayed: %v", err)
+	}
+	// Stale records and the ones of non-members are not, the next relayed
+	// message carries the record of the leader only
+	stale := newTestRecord(t, signer, 1, 30304)
+	foreign := newTestRecord(t, outsider, 1, 30305)
+	if err := p2p.Send(src.app, NodeRecordsMsg, []*enr.Record{stale, foreign}); err != nil {
+		t.Fatalf("failed to send records: %v", err)
+	}
+	other := newTestRecord(t, leader, 1, 30306)
+	if err := p2p.Send(src.app, NodeRecordsMsg, []*enr.Record{stale, other}); err != nil {
+		t.Fatalf("failed to send records: %v", err)
+	}
+	if err := p2p.ExpectMsg(dst.app, NodeRecordsMsg, []*enr.Record{other}); err != nil {
+		t.Fatalf("stale or foreign record relayed: %v", err)
+	}
+	if cached, ok := pm.peering.records.Get(crypto.PubkeyToAddress(signer.PublicKey)); !ok || cached.(*enr.Record).Seq() != fresh.Seq() {
+		t.Errorf("stale record replaced the cached one")
+	}
+	// Once the signer leaves the member set its record expires, a new peer
+	// is only handed the record of the remaining leader
+	pm.peering.lock.Lock()
+	pm.peering.setMembers(map[common.Address]bool{
+		crypto.PubkeyToAddress(leader.PublicKey): true,
+	})
+	pm.peering.lock.Unlock()
+
+	late, _ := newTestPeer("late", eth64, pm, true)
+	defer late.close()
+	if err := p2p.ExpectMsg(late.app, NodeRecordsMsg, []*enr.Record{other}); err != nil {
+		t.Fatalf("expired record not dropped: %v", err)
+	}
+	if pm.peering.records.Contains(crypto.PubkeyToAddress(signer.PublicKey)) {
+		t.Errorf("record of former member still cached")
+	}
+}
@@ -17,6 +17,7 @@
 package ethdb
 
 import (
+	"bytes"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +30,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 
 	gometrics "github.com/rcrowley/go-metrics"
 )
@@ -147,8 +149,32 @@ func (db *LDBDatabase) Delete(key []byte) error {
 	return db.db.Delete(key, nil)
 }
 
-func (db *LDBDatabase) NewIterator() iterator.Iterator {
-	return db.db.NewIterator(nil, nil)
+// NewIterator creates an iterator over the keys with the given prefix, starting
+// at prefix+start.
+func (db *LDBDatabase) NewIterator(prefix []byte, start []byte) Iterator {
+	return db.NewLDBIterator(prefix, start)
+}
+
+// NewLDBIterator is NewIterator returning the seekable LevelDB iterator.
+func (db *LDBDatabase) NewLDBIterator(prefix []byte, start []byte) iterator.Iterator {
+	r := util.BytesPrefix(prefix)
+	r.Start = append(r.Start, start...)
+	return db.db.NewIterator(r, nil)
+}
+
+// Stat returns the given LevelDB property, e.g. "leveldb.stats".
+func (db *LDBDatabase) Stat(property string) (string, error) {
+	return db.db.GetProperty(property)
+}
+
+// Compact flattens the underlying LevelDB storage of the key range [start, limit).
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
+// DeleteRange deletes the keys in the range [start, limit) in batches.
+func (db *LDBDatabase) DeleteRange(start []byte, limit []byte) error {
+	return deleteRange(db, start, limit)
 }
 
 func (db *LDBDatabase) Close() {
@@ -292,6 +318,12 @@ func (b *ldbBatch) Put(key, value []byte) error {
 	return nil
 }
 
+func (b *ldbBatch) Delete(key []byte) error {
+	b.b.Delete(key)
+	b.size += len(key)
+	return nil
+}
+
 func (b *ldbBatch) Write() error {
 	return b.db.Write(b.b, nil)
 }
@@ -334,6 +366,58 @@ func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
 
+func (dt *table) NewIterator(prefix []byte, start []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIterator(append([]byte(dt.prefix), prefix...), start),
+		prefix: dt.prefix,
+	}
+}
+
+func (dt *table) Stat(property string) (string, error) {
+	return dt.db.Stat(property)
+}
+
+func (dt *table) Compact(start []byte, limit []byte) error {
+	return dt.db.Compact(dt.key(start), dt.limit(limit))
+}
+
+func (dt *table) DeleteRange(start []byte, limit []byte) error {
+	return dt.db.DeleteRange(dt.key(start), dt.limit(limit))
+}
+
+// key prefixes the given key with the table prefix.
+func (dt *table) key(key []byte) []byte {
+	return append([]byte(dt.prefix), key...)
+}
+
+// limit prefixes the given range limit with the table prefix, a nil limit is
+// turned into the end of the table.
+func (dt *table) limit(limit []byte) []byte {
+	if limit == nil {
+		return util.BytesPrefix([]byte(dt.prefix)).Limit
+	}
+	return dt.key(limit)
+}
+
+// tableIterator strips the table prefix from the keys of the underlying
+// database iterator.
+type tableIterator struct {
+	it     Iterator
+	prefix string
+}
+
+func (it *tableIterator) Next() bool    { return it.it.Next() }
+func (it *tableIterator) Error() error  { return it.it.Error() }
+func (it *tableIterator) Value() []byte { return it.it.Value() }
+func (it *tableIterator) Release()      { it.it.Release() }
+
+func (it *tableIterator) Key() []byte {
+	if key := it.it.Key(); key != nil {
+		return key[len(it.prefix):]
+	}
+	return nil
+}
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
@@ -352,6 +436,10 @@ func (tb *tableBatch) Put(key, value []byte) error {
 	return tb.batch.Put(append([]byte(tb.prefix), key...), value)
 }
 
+func (tb *tableBatch) Delete(key []byte) error {
+	return tb.batch.Delete(append([]byte(tb.prefix), key...))
+}
+
 func (tb *tableBatch) Write() error {
 	return tb.batch.Write()
 }
@@ -359,3 +447,30 @@ func (tb *tableBatch) Write() error {
 func (tb *tableBatch) ValueSize() int {
 	return tb.batch.ValueSize()
 }
+
+// deleteRange implements DeleteRange on top of the iteration and batch
+// support of a database.
+func deleteRange(db Database, start []byte, limit []byte) error {
+	it := db.NewIterator(nil, start)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if limit != nil && bytes.Compare(it.Key(), limit) >= 0 {
+			break
+		}
+		if err := batch.Delete(it.Key()); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch = db.NewBatch()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
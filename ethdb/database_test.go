@@ -190,3 +190,85 @@ func testParallelPutGet(db ethdb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_IterateDeleteRange(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIterateDeleteRange(db, t)
+}
+
+func TestMemoryDB_IterateDeleteRange(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	testIterateDeleteRange(db, t)
+}
+
+func TestTable_IterateDeleteRange(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	db.Put([]byte("a"), []byte("outside"))
+	db.Put([]byte("tz"), []byte("outside"))
+	testIterateDeleteRange(ethdb.NewTable(db, "t-"), t)
+
+	for _, key := range []string{"a", "tz"} {
+		if has, _ := db.Has([]byte(key)); !has {
+			t.Fatalf("key %q outside the table deleted", key)
+		}
+	}
+}
+
+// collectKeys returns the keys iterated over with the given prefix and start.
+func collectKeys(db ethdb.Database, prefix, start string) string {
+	it := db.NewIterator([]byte(prefix), []byte(start))
+	defer it.Release()
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	return fmt.Sprint(keys)
+}
+
+func testIterateDeleteRange(db ethdb.Database, t *testing.T) {
+	t.Parallel()
+
+	for _, k := range []string{"b2", "a1", "b1", "c1", "b3", "a2"} {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	tests := []struct {
+		prefix, start string
+		want          string
+	}{
+		{"", "", "[a1 a2 b1 b2 b3 c1]"},
+		{"b", "", "[b1 b2 b3]"},
+		{"b", "2", "[b2 b3]"},
+		{"", "b2", "[b2 b3 c1]"},
+		{"d", "", "[]"},
+	}
+	for i, tt := range tests {
+		if have := collectKeys(db, tt.prefix, tt.start); have != tt.want {
+			t.Errorf("test %d: iteration mismatch: have %s, want %s", i, have, tt.want)
+		}
+	}
+	it := db.NewIterator([]byte("c"), nil)
+	if !it.Next() || !bytes.Equal(it.Value(), []byte("vc1")) {
+		t.Errorf("value mismatch: have %q, want %q", it.Value(), "vc1")
+	}
+	it.Release()
+
+	if err := db.DeleteRange([]byte("a2"), []byte("b3")); err != nil {
+		t.Fatalf("delete range failed: %v", err)
+	}
+	if have, want := collectKeys(db, "", ""), "[a1 b3 c1]"; have != want {
+		t.Errorf("keys mismatch after delete range: have %s, want %s", have, want)
+	}
+	if err := db.DeleteRange([]byte("b"), nil); err != nil {
+		t.Fatalf("open delete range failed: %v", err)
+	}
+	if have, want := collectKeys(db, "", ""), "[a1]"; have != want {
+		t.Errorf("keys mismatch after open delete range: have %s, want %s", have, want)
+	}
+	if err := db.Compact(nil, nil); err != nil {
+		t.Errorf("compaction failed: %v", err)
+	}
+}
@@ -25,20 +25,96 @@ type Putter interface {
 	Put(key []byte, value []byte) error
 }
 
+// Deleter wraps the database delete operation supported by both batches and regular databases.
+type Deleter interface {
+	Delete(key []byte) error
+}
+
 // Database wraps all database operations. All methods are safe for concurrent use.
 type Database interface {
 	Putter
+	Deleter
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
-	Delete(key []byte) error
 	Close()
 	NewBatch() Batch
+
+	// NewIterator creates an iterator over the keys with the given prefix in
+	// ascending order, starting at prefix+start. The iterator works on a
+	// consistent view of the database, it must be released after use.
+	NewIterator(prefix []byte, start []byte) Iterator
+
+	// Stat returns the given database property, e.g. "leveldb.stats".
+	Stat(property string) (string, error)
+
+	// Compact flattens the underlying storage of the key range [start, limit).
+	// A nil start is treated as a key before all keys, a nil limit as a key
+	// after all keys.
+	Compact(start []byte, limit []byte) error
+
+	// DeleteRange deletes the keys in the range [start, limit) in batches of
+	// IdealBatchSize. A nil limit deletes up to the last key.
+	DeleteRange(start []byte, limit []byte) error
 }
 
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
 	Putter
+	Deleter
 	ValueSize() int // amount of data in the batch
 	Write() error
 }
+
+// Iterator iterates over the key/value pairs of a database in ascending key
+// order. Key and Value return slices which are only valid until the next call
+// to Next. Iterator cannot be used concurrently.
+type Iterator interface {
+	// Next moves the iterator to the next key/value pair, returning whether
+	// the iterator is exhausted.
+	Next() bool
+
+	// Error returns any accumulated error.
+	Error() error
+
+	Key() []byte
+	Value() []byte
+
+	// Release releases the resources associated with the iterator.
+	Release()
+}
+
+// AncientReader contains the methods required to read from the immutable chain
+// segments (the freezer) kept next to the key-value store.
+type AncientReader interface {
+	// HasAncient returns whether an item of the given kind exists at number.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an item of the given kind at number.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of items frozen so far.
+	Ancients() (uint64, error)
+
+	// AncientSize returns the total data size of the given kind.
+	AncientSize(kind string) (uint64, error)
+}
+
+// AncientWriter contains the methods required to append to and rewind the
+// immutable chain segments.
+type AncientWriter interface {
+	// AppendAncient injects all the data of a block at the head of the ancients.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n items.
+	TruncateAncients(n uint64) error
+
+	// Sync flushes all the appended items to disk.
+	Sync() error
+}
+
+// AncientStore contains all the methods of an immutable chain segment store.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
+}
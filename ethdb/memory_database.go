@@ -18,6 +18,8 @@ package ethdb
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
@@ -90,13 +92,56 @@ func (db *MemDatabase) Delete(key []byte) error {
 
 func (db *MemDatabase) Close() {}
 
+// NewIterator creates an iterator over a snapshot of the keys with the given
+// prefix, starting at prefix+start.
+func (db *MemDatabase) NewIterator(prefix []byte, start []byte) Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var (
+		pr   = string(prefix)
+		st   = string(append(common.CopyBytes(prefix), start...))
+		keys = make([]string, 0, len(db.db))
+		it   = &memIterator{index: -1}
+	)
+	for key := range db.db {
+		if strings.HasPrefix(key, pr) && key >= st {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		it.keys = append(it.keys, []byte(key))
+		it.values = append(it.values, db.db[key])
+	}
+	return it
+}
+
+// Stat is not supported by the memory database.
+func (db *MemDatabase) Stat(property string) (string, error) {
+	return "", errors.New("unknown property")
+}
+
+// Compact is a no-op for the memory database.
+func (db *MemDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// DeleteRange deletes the keys in the range [start, limit) in batches.
+func (db *MemDatabase) DeleteRange(start []byte, limit []byte) error {
+	return deleteRange(db, start, limit)
+}
+
 func (db *MemDatabase) NewBatch() Batch {
 	return &memBatch{db: db}
 }
 
 func (db *MemDatabase) Len() int { return len(db.db) }
 
-type kv struct{ k, v []byte }
+type kv struct {
+	k, v []byte
+	del  bool
+}
 
 type memBatch struct {
 	db     *MemDatabase
@@ -105,16 +150,26 @@ type memBatch struct {
 }
 
 func (b *memBatch) Put(key, value []byte) error {
-	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value)})
+	b.writes = append(b.writes, kv{k: common.CopyBytes(key), v: common.CopyBytes(value)})
 	b.size += len(value)
 	return nil
 }
 
+func (b *memBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{k: common.CopyBytes(key), del: true})
+	b.size += len(key)
+	return nil
+}
+
 func (b *memBatch) Write() error {
 	b.db.lock.Lock()
 	defer b.db.lock.Unlock()
 
 	for _, kv := range b.writes {
+		if kv.del {
+			delete(b.db.db, string(kv.k))
+			continue
+		}
 		b.db.db[string(kv.k)] = kv.v
 	}
 	return nil
@@ -123,3 +178,38 @@ func (b *memBatch) Write() error {
 func (b *memBatch) ValueSize() int {
 	return b.size
 }
+
+// memIterator iterates over a sorted snapshot of the memory database.
+type memIterator struct {
+	keys   [][]byte
+	values [][]byte
+	index  int
+}
+
+func (it *memIterator) Next() bool {
+	if it.index >= len(it.keys) {
+		return false
+	}
+	it.index++
+	return it.index < len(it.keys)
+}
+
+func (it *memIterator) Error() error { return nil }
+
+func (it *memIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.index]
+}
+
+func (it *memIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+func (it *memIterator) Release() {
+	it.keys, it.values = nil, nil
+}
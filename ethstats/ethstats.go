@@ -31,8 +31,10 @@ import (
 	"time"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/common/hexutil"
 	"github.com/MeshBoxFoundation/meshbox/common/mclock"
 	"github.com/MeshBoxFoundation/meshbox/consensus"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
 	"github.com/MeshBoxFoundation/meshbox/core"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/eth"
@@ -40,6 +42,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/les"
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/p2p"
+	"github.com/MeshBoxFoundation/meshbox/params"
 	"github.com/MeshBoxFoundation/meshbox/rpc"
 	"golang.org/x/net/websocket"
 )
@@ -73,6 +76,7 @@ type Service struct {
 	eth    *eth.Ethereum      // Full Ethereum service if monitoring a full node
 	les    *les.LightEthereum // Light Ethereum service if monitoring a light node
 	engine consensus.Engine   // Consensus engine to retrieve variadic block fields
+	tribe  *tribe.API         // Tribe API to retrieve the turn order and node level, nil unless a tribe full node
 
 	node string // Name of the node to display on the monitoring page
 	pass string // Password to authorize access to the monitoring page
@@ -97,7 +101,7 @@ func New(url string, ethServ *eth.Ethereum, lesServ *les.LightEthereum) (*Servic
 	} else {
 		engine = lesServ.Engine()
 	}
-	return &Service{
+	service := &Service{
 		eth:    ethServ,
 		les:    lesServ,
 		engine: engine,
@@ -106,7 +110,16 @@ func New(url string, ethServ *eth.Ethereum, lesServ *les.LightEthereum) (*Servic
 		host:   parts[4],
 		pongCh: make(chan struct{}),
 		histCh: make(chan []uint64, 1),
-	}, nil
+	}
+	// The tribe fields need the chief state, only available on full nodes
+	if t, ok := engine.(*tribe.Tribe); ok && ethServ != nil {
+		for _, api := range t.APIs(ethServ.BlockChain()) {
+			if api, ok := api.Service.(*tribe.API); ok {
+				service.tribe = api
+			}
+		}
+	}
+	return service, nil
 }
 
 // Protocols implements node.Service, returning the P2P network protocols used
@@ -481,6 +494,16 @@ type blockStats struct {
 	TxHash     common.Hash    `json:"transactionsRoot"`
 	Root       common.Hash    `json:"stateRoot"`
 	Uncles     uncleStats     `json:"uncles"`
+	Tribe      *tribeStats    `json:"tribe,omitempty"`
+}
+
+// tribeStats is the tribe specific information to report about individual
+// blocks. Under tribe the difficulty only encodes the turn order.
+type tribeStats struct {
+	Turn           string        `json:"turn"`           // in-turn, leader, other-leader or out-of-turn
+	Period         uint64        `json:"period"`         // Seconds actually passed since the parent
+	ExpectedPeriod uint64        `json:"expectedPeriod"` // Seconds the sealer had to wait, see GetPeriodChief100
+	Vrf            hexutil.Bytes `json:"vrf,omitempty"`  // VRF number of the sealer, since SIP100
 }
 
 // txStats is the information to report about individual transactions.
@@ -567,7 +590,61 @@ func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
 		TxHash:     header.TxHash,
 		Root:       header.Root,
 		Uncles:     uncles,
+		Tribe:      s.assembleTribeStats(header),
+	}
+}
+
+// assembleTribeStats retrieves the turn order and timing of a tribe block. Nil
+// is returned if not monitoring a tribe full node or the chief state of the
+// parent is not available.
+func (s *Service) assembleTribeStats(header *types.Header) *tribeStats {
+	t, ok := s.engine.(*tribe.Tribe)
+	if !ok || s.tribe == nil || t.Status.GetNodeKey() == nil || header.Number.Sign() == 0 {
+		return nil
+	}
+	parent := s.eth.BlockChain().GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return nil
+	}
+	// Like consensus, pick the chief version by the header's own number
+	status, err := params.TribeGetStatus(header.Number, header.ParentHash)
+	if err != nil || len(status.SignerList) == 0 {
+		return nil
+	}
+	signers := make([]*tribe.Signer, 0, len(status.SignerList))
+	for i, signer := range status.SignerList {
+		signers = append(signers, &tribe.Signer{Address: signer, Score: status.ScoreList[i].Int64()})
+	}
+	author, err := t.Author(header)
+	if err != nil {
+		return nil
+	}
+	stats := &tribeStats{
+		Turn:           tribeTurn(author, header.Number.Uint64(), signers, status.LeaderList),
+		Period:         new(big.Int).Sub(header.Time, parent.Time).Uint64(),
+		ExpectedPeriod: t.GetPeriod(header, signers),
 	}
+	if params.IsSIP100Block(header.Number) && len(header.Extra) >= 32 {
+		stats.Vrf = common.CopyBytes(header.Extra[:32])
+	}
+	return stats
+}
+
+// tribeTurn classifies the sealer of a block: the in-turn signer, the leader of
+// the round at signers[0] standing in, or another leader.
+func tribeTurn(author common.Address, number uint64, signers []*tribe.Signer, leaders []common.Address) string {
+	switch {
+	case author == signers[number%uint64(len(signers))].Address:
+		return "in-turn"
+	case author == signers[0].Address:
+		return "leader"
+	}
+	for _, leader := range leaders {
+		if author == leader {
+			return "other-leader"
+		}
+	}
+	return "out-of-turn"
 }
 
 // reportHistory retrieves the most recent batch of blocks and reports it to the
@@ -669,6 +746,16 @@ type nodeStats struct {
 	Peers    int  `json:"peers"`
 	GasPrice int  `json:"gasPrice"`
 	Uptime   int  `json:"uptime"`
+
+	Tribe *tribeNodeStats `json:"tribe,omitempty"`
+}
+
+// tribeNodeStats is the tribe specific information to report about the local
+// node.
+type tribeNodeStats struct {
+	Level      string   `json:"level"`      // None, Volunteer, Signer or Sinner
+	PocState   string   `json:"pocState"`   // none, active, stopped or blacklisted
+	PocDeposit *big.Int `json:"pocDeposit"` // Deposit in wei, nil if none
 }
 
 // reportPending retrieves various stats about the node at the networking and
@@ -707,6 +794,7 @@ func (s *Service) reportStats(conn *websocket.Conn) error {
 			GasPrice: gasprice,
 			Syncing:  syncing,
 			Uptime:   100,
+			Tribe:    s.assembleTribeNodeStats(),
 		},
 	}
 	report := map[string][]interface{}{
@@ -714,3 +802,43 @@ func (s *Service) reportStats(conn *websocket.Conn) error {
 	}
 	return websocket.JSON.Send(conn, report)
 }
+
+// assembleTribeNodeStats retrieves the tribe level and POC deposit state of the
+// local node, nil if not monitoring a tribe full node or the node key is not
+// loaded yet.
+func (s *Service) assembleTribeNodeStats() *tribeNodeStats {
+	t, ok := s.engine.(*tribe.Tribe)
+	if !ok || s.tribe == nil || t.Status.GetNodeKey() == nil {
+		return nil
+	}
+	my, err := s.tribe.GetMyStatus()
+	if err != nil {
+		log.Debug("Failed to retrieve tribe status", "err", err)
+		return nil
+	}
+	stats := &tribeNodeStats{
+		Level:    my.Level,
+		PocState: "none",
+	}
+	poc, err := s.tribe.PocGetStatus(nil)
+	if err != nil {
+		log.Debug("Failed to retrieve poc status", "err", err)
+		return stats
+	}
+	for i, miner := range poc.MinerList {
+		if miner != my.Address {
+			continue
+		}
+		stats.PocDeposit = poc.AmountList[i]
+		switch {
+		case poc.BlackStatusList[i].Sign() > 0:
+			stats.PocState = "blacklisted"
+		case poc.BlockList[i].Sign() > 0:
+			stats.PocState = "stopped"
+		default:
+			stats.PocState = "active"
+		}
+		break
+	}
+	return stats
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethstats
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus/tribe"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/eth"
+	"github.com/MeshBoxFoundation/meshbox/p2p"
+	"golang.org/x/net/websocket"
+)
+
+// statsServer is a local stand-in for the netstats server. It acknowledges the
+// logins carrying the right secret, answers the pings and collects everything
+// else the nodes report.
+type statsServer struct {
+	*httptest.Server
+
+	secret  string
+	reports chan []interface{}
+}
+
+func newStatsServer(secret string) *statsServer {
+	srv := &statsServer{
+		secret:  secret,
+		reports: make(chan []interface{}, 16),
+	}
+	srv.Server = httptest.NewServer(websocket.Handler(srv.handle))
+	return srv
+}
+
+func (srv *statsServer) handle(conn *websocket.Conn) {
+	defer conn.Close()
+
+	var (
+		hello map[string][]json.RawMessage
+		auth  authMsg
+	)
+	if err := websocket.JSON.Receive(conn, &hello); err != nil || len(hello["emit"]) != 2 {
+		return
+	}
+	if err := json.Unmarshal(hello["emit"][1], &auth); err != nil || auth.Secret != srv.secret {
+		websocket.JSON.Send(conn, map[string][]string{"emit": {"unauthorized"}})
+		return
+	}
+	if err := websocket.JSON.Send(conn, map[string][]string{"emit": {"ready"}}); err != nil {
+		return
+	}
+	for {
+		var msg map[string][]interface{}
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return
+		}
+		if len(msg["emit"]) == 2 && msg["emit"][0] == "node-ping" {
+			websocket.JSON.Send(conn, map[string][]interface{}{"emit": {"node-pong", msg["emit"][1]}})
+			continue
+		}
+		srv.reports <- msg["emit"]
+	}
+}
+
+// dial connects to the stand-in server as the given service would.
+func (srv *statsServer) dial(t *testing.T) *websocket.Conn {
+	url := "ws://" + strings.TrimPrefix(srv.URL, "http://") + "/api"
+	conn, err := websocket.Dial(url, "", "http://localhost/")
+	if err != nil {
+		t.Fatalf("failed to dial stats server: %v", err)
+	}
+	return conn
+}
+
+func newTestService(pass string) *Service {
+	key, _ := crypto.GenerateKey()
+	server := &p2p.Server{Config: p2p.Config{
+		PrivateKey: key,
+		Protocols: []p2p.Protocol{{
+			Name:     "eth",
+			Version:  eth.ProtocolVersions[0],
+			NodeInfo: func() interface{} { return &eth.NodeInfo{Network: 1} },
+		}},
+	}}
+	return &Service{
+		server: server,
+		node:   "test",
+		pass:   pass,
+		pongCh: make(chan struct{}),
+		histCh: make(chan []uint64, 1),
+	}
+}
+
+// Tests that a node logs in to the stats server and reports its latency.
+func TestLoginAndLatency(t *testing.T) {
+	srv := newStatsServer("secret")
+	defer srv.Close()
+
+	s := newTestService("secret")
+	conn := srv.dial(t)
+	defer conn.Close()
+
+	if err := s.login(conn); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	go s.readLoop(conn)
+
+	if err := s.reportLatency(conn); err != nil {
+		t.Fatalf("latency report failed: %v", err)
+	}
+	select {
+	case report := <-srv.reports:
+		if report[0] != "latency" {
+			t.Errorf("report mismatch: have %v, want latency", report[0])
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("latency report not received")
+	}
+}
+
+// Tests that a node with the wrong secret is turned down.
+func TestLoginUnauthorized(t *testing.T) {
+	srv := newStatsServer("secret")
+	defer srv.Close()
+
+	s := newTestService("wrong")
+	conn := srv.dial(t)
+	defer conn.Close()
+
+	if err := s.login(conn); err == nil {
+		t.Fatalf("login succeeded with the wrong secret")
+	}
+}
+
+func TestTribeTurn(t *testing.T) {
+	var (
+		leader  = common.HexToAddress("0x1")
+		other   = common.HexToAddress("0x2")
+		signer  = common.HexToAddress("0x3")
+		unknown = common.HexToAddress("0x4")
+		signers = []*tribe.Signer{{Address: leader}, {Address: signer}}
+		leaders = []common.Address{leader, other}
+	)
+	tests := []struct {
+		author common.Address
+		number uint64
+		want   string
+	}{
+		{signer, 11, "in-turn"},
+		{leader, 10, "in-turn"},
+		{leader, 11, "leader"},
+		{other, 11, "other-leader"},
+		{unknown, 11, "out-of-turn"},
+	}
+	for i, tt := range tests {
+		if have := tribeTurn(tt.author, tt.number, signers, leaders); have != tt.want {
+			t.Errorf("test %d: turn mismatch: have %s, want %s", i, have, tt.want)
+		}
+	}
+}
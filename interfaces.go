@@ -103,6 +103,8 @@ type SyncProgress struct {
 	HighestBlock  uint64 // Highest alleged block number in the chain
 	PulledStates  uint64 // Number of state trie entries already downloaded
 	KnownStates   uint64 // Total number of state trie entries known about
+	Mode          string // Synchronisation mode ("full", "fast" or "light")
+	PivotBlock    uint64 // Fast sync pivot, signers are only validated from there on
 }
 
 // ChainSyncReader wraps access to the node's current sync status. If there's no
@@ -41,8 +41,6 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/params"
 	"github.com/MeshBoxFoundation/meshbox/rlp"
 	"github.com/MeshBoxFoundation/meshbox/rpc"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const (
@@ -50,6 +48,17 @@ const (
 	defaultGasPrice = 50 * params.Shannon
 )
 
+// errHistoryPruned is returned for the blocks, transactions and receipts whose
+// data was deleted by history expiry (--history.keep).
+var errHistoryPruned = errors.New("pruned: block body and receipts are past the history window")
+
+// txHistoryPruned returns whether the transaction with the given hash is in a
+// block whose body and receipts were deleted by history expiry.
+func txHistoryPruned(db core.DatabaseReader, hash common.Hash) bool {
+	blockHash, number, _ := core.GetTxLookupEntry(db, hash)
+	return blockHash != (common.Hash{}) && core.IsHistoryPruned(db, number)
+}
+
 // PublicEthereumAPI provides an API to access Ethereum related information.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicEthereumAPI struct {
@@ -86,13 +95,19 @@ func (s *PublicEthereumAPI) Syncing() (interface{}, error) {
 		return false, nil
 	}
 	// Otherwise gather the block sync stats
-	return map[string]interface{}{
+	status := map[string]interface{}{
 		"startingBlock": hexutil.Uint64(progress.StartingBlock),
 		"currentBlock":  hexutil.Uint64(progress.CurrentBlock),
 		"highestBlock":  hexutil.Uint64(progress.HighestBlock),
 		"pulledStates":  hexutil.Uint64(progress.PulledStates),
 		"knownStates":   hexutil.Uint64(progress.KnownStates),
-	}, nil
+		"syncMode":      progress.Mode,
+	}
+	// Fast sync only checks the seals of the blocks below the pivot
+	if progress.Mode == "fast" {
+		status["pivotBlock"] = hexutil.Uint64(progress.PivotBlock)
+	}
+	return status, nil
 }
 
 // PublicTxPoolAPI offers and API for the transaction pool. It only operates on data that is non confidential.
@@ -487,6 +502,9 @@ func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.
 		}
 		return response, err
 	}
+	if err == nil && blockNr >= 0 && core.IsHistoryPruned(s.b.ChainDb(), uint64(blockNr)) {
+		return nil, errHistoryPruned
+	}
 	return nil, err
 }
 
@@ -497,6 +515,9 @@ func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, blockHash comm
 	if block != nil {
 		return s.rpcOutputBlock(block, true, fullTx)
 	}
+	if err == nil && core.IsHistoryPruned(s.b.ChainDb(), core.GetBlockNumber(s.b.ChainDb(), blockHash)) {
+		return nil, errHistoryPruned
+	}
 	return nil, err
 }
 
@@ -1032,17 +1053,20 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 }
 
 // GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return newRPCTransaction(tx, blockHash, blockNumber, index), nil
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return newRPCPendingTransaction(tx), nil
+	}
+	if txHistoryPruned(s.b.ChainDb(), hash) {
+		return nil, errHistoryPruned
 	}
 	// Transaction unknown, return as such
-	return nil
+	return nil, nil
 }
 
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
@@ -1052,6 +1076,9 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 	// Retrieve a finalized transaction, or a pooled otherwise
 	if tx, _, _, _ = core.GetTransaction(s.b.ChainDb(), hash); tx == nil {
 		if tx = s.b.GetPoolTransaction(hash); tx == nil {
+			if txHistoryPruned(s.b.ChainDb(), hash) {
+				return nil, errHistoryPruned
+			}
 			// Transaction not found anywhere, abort
 			return nil, nil
 		}
@@ -1064,6 +1091,9 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash)
 	if tx == nil {
+		if txHistoryPruned(s.b.ChainDb(), hash) {
+			return nil, errHistoryPruned
+		}
 		//return nil, errors.New("unknown transaction")
 		return nil, nil
 	}
@@ -1423,30 +1453,18 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 
 // ChaindbProperty returns leveldb properties of the chain database.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return "", fmt.Errorf("chaindbProperty does not work for memory databases")
-	}
 	if property == "" {
 		property = "leveldb.stats"
 	} else if !strings.HasPrefix(property, "leveldb.") {
 		property = "leveldb." + property
 	}
-	return ldb.LDB().GetProperty(property)
+	return api.b.ChainDb().Stat(property)
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return fmt.Errorf("chaindbCompact does not work for memory databases")
-	}
 	for b := byte(0); b < 255; b++ {
 		log.Info("Compacting chain database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", b, b+1))
-		err := ldb.LDB().CompactRange(util.Range{Start: []byte{b}, Limit: []byte{b + 1}})
+		err := api.b.ChainDb().Compact([]byte{b}, []byte{b + 1})
 		if err != nil {
 			log.Error("Database compaction failed", "err", err)
 			return err
@@ -24,6 +24,7 @@ var Modules = map[string]string{
 	"tribe":      Tribe_JS,
 	"debug":      Debug_JS,
 	"eth":        Eth_JS,
+	"les":        LES_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
@@ -138,12 +139,23 @@ web3._extend({
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'getEquivocations',
+			call: 'tribe_getEquivocations',
+			params: 1,
+			inputFormatter: [null]
+		}),
 		new web3._extend.Method({
 			name: 'getHistory',
 			call: 'tribe_getHistory',
 			params: 2,
 			inputFormatter: [null,null]
 		}),
+		new web3._extend.Method({
+			name: 'getBindHistory',
+			call: 'tribe_getBindHistory',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'getMiner',
 			call: 'tribe_getMiner',
@@ -210,8 +222,96 @@ web3._extend({
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'getMyStatus',
+			call: 'tribe_getMyStatus',
+			params: 0
+		}),
 	],
 });
+
+(function() {
+	// table prints rows of cells as left aligned columns under a header.
+	var table = function(header, rows) {
+		var widths = header.map(function(h) { return String(h).length; });
+		rows.forEach(function(row) {
+			row.forEach(function(cell, i) { widths[i] = Math.max(widths[i], String(cell).length); });
+		});
+		var line = function(row) {
+			return row.map(function(cell, i) {
+				cell = String(cell);
+				return cell + new Array(widths[i] - cell.length + 1).join(' ');
+			}).join('  ').replace(/\s+$/, '');
+		};
+		console.log(line(header));
+		console.log(line(widths.map(function(w) { return new Array(w + 1).join('-'); })));
+		rows.forEach(function(row) { console.log(line(row)); });
+	};
+
+	// signersTable lists the signers and leaders of the current round and
+	// marks the one expected to seal the next block.
+	web3.tribe.signersTable = function() {
+		var status = web3.tribe.getStatus(null);
+		var signers = status.signers || [], leaders = status.leaders || [];
+		var next = signers.length > 0 ? (status.number + 1) % signers.length : -1;
+		var rows = signers.map(function(s, i) {
+			var role = leaders.indexOf(s.address) >= 0 ? 'Leader' : 'Signer';
+			return [i, s.address, s.score, role, i == next ? '<- next' : ''];
+		});
+		leaders.forEach(function(l) {
+			if (!signers.some(function(s) { return s.address == l; })) {
+				rows.push(['-', l, '-', 'Leader', '']);
+			}
+		});
+		console.log('block ' + status.number + ', epoch ' + status.epoch + ', chief ' + status.version);
+		table(['#', 'Address', 'Score', 'Role', ''], rows);
+	};
+
+	// myStatus shows the level of this node and when it is due to seal.
+	web3.tribe.myStatus = function() {
+		var my = web3.tribe.getMyStatus();
+		var rows = [
+			['Address', my.address],
+			['Level', my.level],
+			['Leader', my.leader],
+			['Signer index', my.signerIndex < 0 ? '-' : my.signerIndex],
+			['Current block', my.number]
+		];
+		if (my.nextSlot > 0) {
+			var blocks = my.nextSlot - my.number;
+			rows.push(['Next slot', my.nextSlot + ' (in ' + blocks + ' blocks, ~' + blocks * my.period + 's)']);
+		} else {
+			rows.push(['Next slot', '-']);
+		}
+		rows.push(['Period', my.period + 's']);
+		table(['Field', 'Value'], rows);
+	};
+
+	// poc.summary lists the poc deposits, optionally only those of the
+	// given miner or owner address.
+	web3.poc = {
+		summary: function(addr) {
+			var status = web3.tribe.pocGetStatus(null);
+			var miners = status.MinerList || [];
+			var rows = [];
+			miners.forEach(function(miner, i) {
+				var owner = status.OwnerList[i];
+				if (addr && miner != addr.toLowerCase() && owner != addr.toLowerCase()) {
+					return;
+				}
+				var stop = status.BlockList[i];
+				rows.push([
+					miner,
+					owner,
+					web3.fromWei(status.AmountList[i], 'ether'),
+					stop > 0 ? 'stopped at ' + stop : 'active',
+					status.BlackStatusList[i] > 0 ? 'yes' : 'no'
+				]);
+			});
+			table(['Miner', 'Owner', 'Deposit', 'State', 'Blacklisted'], rows);
+		}
+	};
+})();
 `
 
 const Admin_JS = `
@@ -684,6 +784,11 @@ web3._extend({
 			call: 'swarmfs_mount',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'mountProtected',
+			call: 'swarmfs_mountProtected',
+			params: 3
+		}),
 		new web3._extend.Method({
 			name: 'unmount',
 			call: 'swarmfs_unmount',
@@ -724,3 +829,48 @@ web3._extend({
 	]
 });
 `
+
+const LES_JS = `
+web3._extend({
+	property: 'les',
+	methods: [
+		new web3._extend.Method({
+			name: 'addPriorityClient',
+			call: 'les_addPriorityClient',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'removePriorityClient',
+			call: 'les_removePriorityClient',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setClientCapacity',
+			call: 'les_setClientCapacity',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'tribeStatus',
+			call: 'les_tribeStatus',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'priorityClients',
+			getter: 'les_priorityClients'
+		}),
+		new web3._extend.Property({
+			name: 'totalCapacity',
+			getter: 'les_totalCapacity',
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Property({
+			name: 'freeClientCapacity',
+			getter: 'les_freeClientCapacity',
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+	]
+});
+`
@@ -0,0 +1,103 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+
+	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+	"github.com/MeshBoxFoundation/meshbox/params"
+	"github.com/MeshBoxFoundation/meshbox/rpc"
+)
+
+// APIs returns the management APIs of the light server.
+func (s *LesServer) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPrivateLightServerAPI(s),
+			Public:    false,
+		},
+	}
+}
+
+// PrivateLightServerAPI provides an API to manage the priority clients of the
+// light server.
+type PrivateLightServerAPI struct {
+	pool *clientPool
+}
+
+// NewPrivateLightServerAPI creates a new light server management API.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{pool: server.clientPool}
+}
+
+// TotalCapacity returns the capacity shared by all the light clients.
+func (api *PrivateLightServerAPI) TotalCapacity() uint64 {
+	return api.pool.total
+}
+
+// FreeClientCapacity returns the capacity each free client is served with.
+func (api *PrivateLightServerAPI) FreeClientCapacity() uint64 {
+	return api.pool.free.MinRecharge
+}
+
+// PriorityClients returns the priority clients and whether they're connected.
+func (api *PrivateLightServerAPI) PriorityClients() []PriorityClient {
+	return api.pool.priorityClients()
+}
+
+// AddPriorityClient serves the given node with priority, with the given
+// capacity. A connected client is disconnected to pick up its new tier.
+func (api *PrivateLightServerAPI) AddPriorityClient(id discover.NodeID, capacity uint64) error {
+	return api.pool.setPriority(id, capacity)
+}
+
+// SetClientCapacity changes the capacity of a priority client.
+func (api *PrivateLightServerAPI) SetClientCapacity(id discover.NodeID, capacity uint64) error {
+	if !api.pool.isPriority(id) {
+		return errNotPriority
+	}
+	return api.pool.setPriority(id, capacity)
+}
+
+// RemovePriorityClient moves the given node back to the free tier.
+func (api *PrivateLightServerAPI) RemovePriorityClient(id discover.NodeID) error {
+	return api.pool.removePriority(id)
+}
+
+// PublicLightTribeAPI provides an API to access the tribe consensus state from
+// a light client.
+type PublicLightTribeAPI struct {
+	eth *LightEthereum
+}
+
+// NewPublicLightTribeAPI creates a new light client tribe API.
+func NewPublicLightTribeAPI(eth *LightEthereum) *PublicLightTribeAPI {
+	return &PublicLightTribeAPI{eth: eth}
+}
+
+// TribeStatus returns the chief status of the given block, proven against its
+// state root by a les/3 server.
+func (api *PublicLightTribeAPI) TribeStatus(ctx context.Context, blockNr rpc.BlockNumber) (params.ChiefStatus, error) {
+	header, err := api.eth.ApiBackend.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return params.ChiefStatus{}, errHeaderUnavailable
+	}
+	return api.eth.TribeStatus(ctx, header.Hash(), header.Number.Uint64())
+}
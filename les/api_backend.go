@@ -191,6 +191,10 @@ func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	return light.BloomTrieFrequency, sections
 }
 
+func (b *LesApiBackend) LogIndexStatus() (uint64, uint64) {
+	return 0, 0
+}
+
 func (b *LesApiBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
@@ -111,7 +111,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	leth.serverPool = newServerPool(chainDb, quitSync, &leth.wg)
 	leth.retriever = newRetrieveManager(peers, leth.reqDist, leth.serverPool)
 	leth.odr = NewLesOdr(chainDb, leth.chtIndexer, leth.bloomTrieIndexer, leth.bloomIndexer, leth.retriever)
-	if leth.blockchain, err = light.NewLightChain(leth.odr, leth.chainConfig, leth.engine); err != nil {
+	if leth.blockchain, err = light.NewLightChain(leth.odr, leth.chainConfig, leth.engine, config.LightCheckpoint); err != nil {
 		return nil, err
 	}
 	leth.bloomIndexer.Start(leth.blockchain)
@@ -126,6 +126,12 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, true, ClientProtocolVersions, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, quitSync, &leth.wg); err != nil {
 		return nil, err
 	}
+	if config.ULC != nil {
+		if leth.protocolManager.ulc, err = newULC(config.ULC); err != nil {
+			return nil, err
+		}
+		log.Info("Ultra light client mode enabled", "trusted", len(leth.protocolManager.ulc.servers), "required", leth.protocolManager.ulc.minTrusted)
+	}
 	leth.ApiBackend = &LesApiBackend{leth, nil}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
@@ -142,6 +148,8 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 		name = "LES"
 	case lpv2:
 		name = "LES2"
+	case lpv3:
+		name = "LES3"
 	default:
 		panic(nil)
 	}
@@ -194,6 +202,11 @@ func (s *LightEthereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   s.netRPCService,
 			Public:    true,
+		}, {
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPublicLightTribeAPI(s),
+			Public:    true,
 		},
 	}...)
 }
@@ -225,6 +238,11 @@ func (s *LightEthereum) Start(srvr *p2p.Server) error {
 	// servers always advertise all supported protocols
 	protocolVersion := ClientProtocolVersions[len(ClientProtocolVersions)-1]
 	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion))
+	if ulc := s.protocolManager.ulc; ulc != nil {
+		for _, node := range ulc.servers {
+			srvr.AddPeer(node)
+		}
+	}
 	s.protocolManager.Start()
 	return nil
 }
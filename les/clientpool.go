@@ -0,0 +1,305 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/common/mclock"
+	"github.com/MeshBoxFoundation/meshbox/les/flowcontrol"
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+)
+
+var (
+	errZeroCapacity     = errors.New("capacity must be positive")
+	errCapacityTooLarge = errors.New("capacity exceeds the total server capacity")
+	errNotPriority      = errors.New("client is not a priority client")
+)
+
+// clientPool decides which light clients the server accepts and with which
+// flow control parameters. Priority clients, configured by node ID, get the
+// capacity (minimum recharge rate) assigned to them. The rest of the total
+// capacity is shared by free clients, each one getting the default
+// parameters. Free clients are kicked, newest first, when a priority client
+// needs their capacity.
+type clientPool struct {
+	lock      sync.Mutex
+	total     uint64                          // Capacity shared by all connected clients
+	free      flowcontrol.ServerParams        // Flow control parameters of a free client
+	priority  map[discover.NodeID]uint64      // Capacities of the priority clients
+	connected map[discover.NodeID]*poolClient // Clients currently being served
+	file      string                          // File persisting the priority clients, if any
+}
+
+// poolClient is a client connected to the server.
+type poolClient struct {
+	params     flowcontrol.ServerParams
+	priority   bool
+	connected  mclock.AbsTime
+	disconnect func()
+}
+
+// PriorityClient is the file and RPC representation of a priority light client.
+type PriorityClient struct {
+	ID        discover.NodeID `json:"id"`
+	Capacity  uint64          `json:"capacity"`
+	Connected bool            `json:"connected,omitempty"`
+}
+
+// newClientPool creates a client pool serving up to total capacity, loading
+// the priority clients from file if one is given.
+func newClientPool(total uint64, free flowcontrol.ServerParams, file string) (*clientPool, error) {
+	pool := &clientPool{
+		total:     total,
+		free:      free,
+		priority:  make(map[discover.NodeID]uint64),
+		connected: make(map[discover.NodeID]*poolClient),
+		file:      file,
+	}
+	if file == "" {
+		return pool, nil
+	}
+	blob, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return pool, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []PriorityClient
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Capacity == 0 {
+			return nil, errZeroCapacity
+		}
+		pool.priority[entry.ID] = entry.Capacity
+	}
+	log.Info("Loaded priority light clients", "count", len(entries))
+	return pool, nil
+}
+
+// connect registers a new client, returning its tier and flow control
+// parameters. The disconnect callback is invoked if the client has to be
+// kicked later on. The client is rejected if there's no capacity left for it.
+func (pool *clientPool) connect(id discover.NodeID, disconnect func()) (*poolClient, bool) {
+	pool.lock.Lock()
+
+	if _, ok := pool.connected[id]; ok {
+		pool.lock.Unlock()
+		return nil, false
+	}
+	client := &poolClient{
+		params:     pool.free,
+		connected:  mclock.Now(),
+		disconnect: disconnect,
+	}
+	if capacity, ok := pool.priority[id]; ok {
+		client.params, client.priority = pool.priorityParams(capacity), true
+	}
+	kicked, ok := pool.makeRoom(client)
+	if !ok {
+		pool.lock.Unlock()
+		if client.priority {
+			rejectedPriorityMeter.Mark(1)
+		} else {
+			rejectedFreeMeter.Mark(1)
+		}
+		return nil, false
+	}
+	pool.connected[id] = client
+	pool.lock.Unlock()
+
+	if client.priority {
+		priorityClientCounter.Inc(1)
+	} else {
+		freeClientCounter.Inc(1)
+	}
+	for _, kick := range kicked {
+		log.Debug("Kicking free light client for priority client", "id", id)
+		kick.disconnect()
+	}
+	return client, true
+}
+
+// disconnect unregisters a client once it's gone. Clients kicked by the pool
+// are already unregistered, and may have reconnected since.
+func (pool *clientPool) disconnect(id discover.NodeID, client *poolClient) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.connected[id] == client {
+		pool.remove(id, client)
+	}
+}
+
+// makeRoom ensures the client fits into the total capacity, unregistering
+// free clients, newest first, if a priority client needs their capacity. The
+// unregistered clients are returned to be disconnected outside of the lock.
+func (pool *clientPool) makeRoom(client *poolClient) ([]*poolClient, bool) {
+	used, priorityUsed := uint64(0), uint64(0)
+	for _, c := range pool.connected {
+		used += c.params.MinRecharge
+		if c.priority {
+			priorityUsed += c.params.MinRecharge
+		}
+	}
+	need := client.params.MinRecharge
+	if used+need <= pool.total {
+		return nil, true
+	}
+	if !client.priority || priorityUsed+need > pool.total {
+		return nil, false
+	}
+	var kicked []*poolClient
+	for used+need > pool.total {
+		var (
+			newestID discover.NodeID
+			newest   *poolClient
+		)
+		for id, c := range pool.connected {
+			if !c.priority && (newest == nil || c.connected > newest.connected) {
+				newestID, newest = id, c
+			}
+		}
+		pool.remove(newestID, newest)
+		kickedFreeMeter.Mark(1)
+		kicked = append(kicked, newest)
+		used -= newest.params.MinRecharge
+	}
+	return kicked, true
+}
+
+// remove unregisters a connected client.
+func (pool *clientPool) remove(id discover.NodeID, client *poolClient) {
+	delete(pool.connected, id)
+	if client.priority {
+		priorityClientCounter.Dec(1)
+	} else {
+		freeClientCounter.Dec(1)
+	}
+}
+
+// priorityParams returns the flow control parameters of a priority client,
+// keeping the buffer limit in proportion with the recharge rate. Without a free
+// recharge rate to scale from, the free buffer limit is kept.
+func (pool *clientPool) priorityParams(capacity uint64) flowcontrol.ServerParams {
+	bufLimit := pool.free.BufLimit
+	if pool.free.MinRecharge != 0 {
+		bufLimit = pool.free.BufLimit * capacity / pool.free.MinRecharge
+	}
+	return flowcontrol.ServerParams{
+		BufLimit:    bufLimit,
+		MinRecharge: capacity,
+	}
+}
+
+// setPriority adds a priority client or changes its capacity. A connected
+// client is disconnected, flow control parameters can't be changed during a
+// session, so it picks up its new tier when reconnecting.
+func (pool *clientPool) setPriority(id discover.NodeID, capacity uint64) error {
+	if capacity == 0 {
+		return errZeroCapacity
+	}
+	if capacity > pool.total {
+		return errCapacityTooLarge
+	}
+	pool.lock.Lock()
+	pool.priority[id] = capacity
+	client := pool.dropConnected(id)
+	err := pool.save()
+	pool.lock.Unlock()
+
+	if client != nil {
+		client.disconnect()
+	}
+	return err
+}
+
+// removePriority turns a priority client into a free one, disconnecting it if
+// connected.
+func (pool *clientPool) removePriority(id discover.NodeID) error {
+	pool.lock.Lock()
+	if _, ok := pool.priority[id]; !ok {
+		pool.lock.Unlock()
+		return errNotPriority
+	}
+	delete(pool.priority, id)
+	client := pool.dropConnected(id)
+	err := pool.save()
+	pool.lock.Unlock()
+
+	if client != nil {
+		client.disconnect()
+	}
+	return err
+}
+
+// dropConnected unregisters the client if it's connected, returning it to be
+// disconnected outside of the lock.
+func (pool *clientPool) dropConnected(id discover.NodeID) *poolClient {
+	client, ok := pool.connected[id]
+	if !ok {
+		return nil
+	}
+	pool.remove(id, client)
+	return client
+}
+
+// isPriority returns whether the node is configured as a priority client.
+func (pool *clientPool) isPriority(id discover.NodeID) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	_, ok := pool.priority[id]
+	return ok
+}
+
+// priorityClients returns the configured priority clients.
+func (pool *clientPool) priorityClients() []PriorityClient {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	entries := make([]PriorityClient, 0, len(pool.priority))
+	for id, capacity := range pool.priority {
+		client, ok := pool.connected[id]
+		entries = append(entries, PriorityClient{ID: id, Capacity: capacity, Connected: ok && client.priority})
+	}
+	return entries
+}
+
+// save writes the priority clients into the pool's file, if any.
+func (pool *clientPool) save() error {
+	if pool.file == "" {
+		return nil
+	}
+	entries := make([]PriorityClient, 0, len(pool.priority))
+	for id, capacity := range pool.priority {
+		entries = append(entries, PriorityClient{ID: id, Capacity: capacity})
+	}
+	blob, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pool.file, blob, 0600)
+}
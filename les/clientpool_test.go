@@ -0,0 +1,177 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/les/flowcontrol"
+	"github.com/MeshBoxFoundation/meshbox/p2p"
+	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+)
+
+// Tests that free clients share the capacity left by the priority ones, and
+// are kicked newest first when a priority client needs room.
+func TestClientPoolTiers(t *testing.T) {
+	var (
+		free   = flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 10}
+		pool   = newTestClientPool(t, 40, free, "")
+		kicked = make(map[discover.NodeID]bool)
+	)
+	connect := func(id discover.NodeID) (*poolClient, bool) {
+		return pool.connect(id, func() { kicked[id] = true })
+	}
+	prio := discover.NodeID{0xff}
+	if err := pool.setPriority(prio, 25); err != nil {
+		t.Fatalf("failed to add priority client: %v", err)
+	}
+	// Fill the pool with free clients
+	var clients []*poolClient
+	for i := byte(1); i <= 4; i++ {
+		client, ok := connect(discover.NodeID{i})
+		if !ok {
+			t.Fatalf("free client %d rejected", i)
+		}
+		if client.priority || client.params != free {
+			t.Errorf("free client %d: have params %v, priority %v", i, client.params, client.priority)
+		}
+		clients = append(clients, client)
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := connect(discover.NodeID{5}); ok {
+		t.Errorf("free client accepted above the total capacity")
+	}
+	// The priority client kicks the newest free clients it needs
+	client, ok := connect(prio)
+	if !ok {
+		t.Fatalf("priority client rejected")
+	}
+	if want := (flowcontrol.ServerParams{BufLimit: 2500, MinRecharge: 25}); !client.priority || client.params != want {
+		t.Errorf("priority client: have params %v, priority %v, want %v", client.params, client.priority, want)
+	}
+	if len(kicked) != 3 || kicked[discover.NodeID{1}] {
+		t.Errorf("kicked clients mismatch: have %v, want 2, 3 and 4", kicked)
+	}
+	// Kicked clients unregistering late must not affect reconnected ones
+	pool.disconnect(discover.NodeID{4}, clients[3])
+	if _, ok := connect(discover.NodeID{2}); ok {
+		t.Errorf("free client accepted above the remaining capacity")
+	}
+	// Demoting the priority client disconnects it and frees its capacity
+	if err := pool.removePriority(prio); err != nil {
+		t.Fatalf("failed to remove priority client: %v", err)
+	}
+	if !kicked[prio] {
+		t.Errorf("demoted priority client not disconnected")
+	}
+	if _, ok := connect(discover.NodeID{2}); !ok {
+		t.Errorf("free client rejected after the priority client left")
+	}
+	if err := pool.removePriority(prio); err != errNotPriority {
+		t.Errorf("removing unknown priority client: have %v, want %v", err, errNotPriority)
+	}
+	if err := pool.setPriority(prio, 41); err != errCapacityTooLarge {
+		t.Errorf("oversized capacity: have %v, want %v", err, errCapacityTooLarge)
+	}
+}
+
+// Tests that the buffer limit of priority clients scales with their capacity
+// without losing precision, and survives a free tier without recharge rate.
+func TestClientPoolPriorityParams(t *testing.T) {
+	tests := []struct {
+		free     flowcontrol.ServerParams
+		capacity uint64
+		want     flowcontrol.ServerParams
+	}{
+		{flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 10}, 25, flowcontrol.ServerParams{BufLimit: 2500, MinRecharge: 25}},
+		{flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 3}, 7, flowcontrol.ServerParams{BufLimit: 2333, MinRecharge: 7}},
+		{flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 0}, 7, flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 7}},
+	}
+	for i, tt := range tests {
+		pool := &clientPool{free: tt.free}
+		if have := pool.priorityParams(tt.capacity); have != tt.want {
+			t.Errorf("test %d: params mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the priority clients are persisted into and loaded from file.
+func TestClientPoolFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "les-clientpool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		file = filepath.Join(dir, "priority.json")
+		free = flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 10}
+		pool = newTestClientPool(t, 100, free, file)
+	)
+	pool.setPriority(discover.NodeID{1}, 20)
+	pool.setPriority(discover.NodeID{2}, 30)
+	pool.removePriority(discover.NodeID{1})
+
+	loaded := newTestClientPool(t, 100, free, file)
+	if len(loaded.priority) != 1 || loaded.priority[discover.NodeID{2}] != 30 {
+		t.Errorf("loaded priority clients mismatch: have %v", loaded.priority)
+	}
+}
+
+// Tests that the server admits light clients through its client pool, turning
+// away the ones above its capacity until a served one leaves.
+func TestClientPoolAdmission(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	pm := newTestProtocolManagerMust(t, false, 1, nil, nil, nil, db)
+	pm.server.clientPool = newTestClientPool(t, 1, *pm.server.defParams, "")
+
+	served, servedErr := newTestPeer(t, "served", lpv2, pm, true)
+	defer served.close()
+
+	rejected, rejectedErr := newTestPeer(t, "rejected", lpv2, pm, false)
+	defer rejected.close()
+	select {
+	case err := <-rejectedErr:
+		if err != p2p.DiscTooManyPeers {
+			t.Errorf("client above capacity: have %v, want %v", err, p2p.DiscTooManyPeers)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("client above capacity not rejected")
+	}
+	// Once the served client leaves, its capacity is handed out again
+	served.close()
+	select {
+	case <-servedErr:
+	case <-time.After(time.Second):
+		t.Fatalf("served client not disconnected")
+	}
+	accepted, _ := newTestPeer(t, "accepted", lpv2, pm, true)
+	defer accepted.close()
+}
+
+func newTestClientPool(t *testing.T, total uint64, free flowcontrol.ServerParams, file string) *clientPool {
+	pool, err := newClientPool(total, free, file)
+	if err != nil {
+		t.Fatalf("failed to create client pool: %v", err)
+	}
+	return pool
+}
@@ -54,6 +54,8 @@ type lightFetcher struct {
 	requestChn chan bool // true if initiated from outside
 	syncing    bool
 	syncDone   chan *peer
+
+	trustedHead common.Hash // last head requested on the word of the trusted servers
 }
 
 // fetcherPeerInfo holds fetcher-specific information about each active peer
@@ -82,6 +84,7 @@ type fetcherTreeNode struct {
 	number           uint64
 	td               *big.Int
 	known, requested bool
+	signed           bool // announced with a valid signature
 	parent           *fetcherTreeNode
 	children         []*fetcherTreeNode
 }
@@ -93,6 +96,7 @@ type fetchRequest struct {
 	peer    *peer
 	sent    mclock.AbsTime
 	timeout bool
+	td      *big.Int // announced total difficulty of a trusted head
 }
 
 // fetchResponse represents a header download response
@@ -239,8 +243,9 @@ func (f *lightFetcher) unregisterPeer(p *peer) {
 }
 
 // announce processes a new announcement message received from a peer, adding new
-// nodes to the peer's block tree and removing old nodes if necessary
-func (f *lightFetcher) announce(p *peer, head *announceData) {
+// nodes to the peer's block tree and removing old nodes if necessary. Signed
+// tells whether the announcement carried a valid signature of the peer.
+func (f *lightFetcher) announce(p *peer, head *announceData, signed bool) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	p.Log().Debug("Received new announcement", "number", head.Number, "hash", head.Hash, "reorg", head.ReorgDepth)
@@ -324,15 +329,84 @@ func (f *lightFetcher) announce(p *peer, head *announceData) {
 		fp.confirmedTd = nil
 	}
 
+	n.signed = signed
 	f.checkKnownNode(p, n)
 	p.lock.Lock()
 	p.headInfo = head
 	fp.lastAnnounced = n
 	p.lock.Unlock()
 	f.checkUpdateStats(p, nil)
+	if f.pm.ulc != nil {
+		// ultra light clients never download the announced headers
+		if signed && p.trusted {
+			f.checkTrustedHead(n)
+		}
+		return
+	}
 	f.requestChn <- true
 }
 
+// checkTrustedHead requests the header of a head announced by the trusted
+// servers of an ultra light client, once enough of them have signed it.
+func (f *lightFetcher) checkTrustedHead(n *fetcherTreeNode) {
+	if n.hash == f.trustedHead || f.checkKnownTd(n.td) {
+		return
+	}
+	var announced int
+	for p, fp := range f.peers {
+		if nn := fp.nodeByHash[n.hash]; p.trusted && nn != nil && nn.signed && nn.td.Cmp(n.td) == 0 {
+			announced++
+		}
+	}
+	if announced < f.pm.ulc.minTrusted {
+		return
+	}
+	log.Debug("Head announced by trusted servers", "number", n.number, "hash", n.hash, "servers", announced)
+	f.trustedHead = n.hash
+
+	var (
+		hash, td = n.hash, n.td
+		reqID    = genReqID()
+	)
+	rq := &distReq{
+		getCost: func(dp distPeer) uint64 {
+			p := dp.(*peer)
+			return p.GetRequestCost(GetBlockHeadersMsg, 1)
+		},
+		canSend: func(dp distPeer) bool {
+			p := dp.(*peer)
+			f.lock.Lock()
+			defer f.lock.Unlock()
+
+			fp := f.peers[p]
+			return p.trusted && fp != nil && fp.nodeByHash[hash] != nil
+		},
+		request: func(dp distPeer) func() {
+			p := dp.(*peer)
+			cost := p.GetRequestCost(GetBlockHeadersMsg, 1)
+			p.fcServer.QueueRequest(reqID, cost)
+			f.reqMu.Lock()
+			f.requested[reqID] = fetchRequest{hash: hash, amount: 1, peer: p, sent: mclock.Now(), td: td}
+			f.reqMu.Unlock()
+			go func() {
+				time.Sleep(hardRequestTimeout)
+				f.timeoutChn <- reqID
+			}()
+			return func() { p.RequestHeadersByHash(reqID, cost, hash, 1, 0, true) }
+		},
+	}
+	// queue outside of the fetcher lock, the distributor may be checking canSend
+	go f.pm.reqDist.queue(rq)
+}
+
+// checkKnownTd returns whether the local head is at least as heavy as the
+// given total difficulty.
+func (f *lightFetcher) checkKnownTd(td *big.Int) bool {
+	head := f.chain.CurrentHeader()
+	headTd := f.chain.GetTd(head.Hash(), head.Number.Uint64())
+	return headTd != nil && td.Cmp(headTd) <= 0
+}
+
 // peerHasBlock returns true if we can assume the peer knows the given block
 // based on its announcements
 func (f *lightFetcher) peerHasBlock(p *peer, hash common.Hash, number uint64) bool {
@@ -491,6 +565,9 @@ func (f *lightFetcher) deliverHeaders(peer *peer, reqID uint64, headers []*types
 
 // processResponse processes header download request responses, returns true if successful
 func (f *lightFetcher) processResponse(req fetchRequest, resp fetchResponse) bool {
+	if f.pm.ulc != nil {
+		return f.processTrustedResponse(req, resp)
+	}
 	if uint64(len(resp.headers)) != req.amount || resp.headers[0].Hash() != req.hash {
 		req.peer.Log().Debug("Response content mismatch", "requested", len(resp.headers), "reqfrom", resp.headers[0], "delivered", req.amount, "delfrom", req.hash)
 		return false
@@ -519,6 +596,22 @@ func (f *lightFetcher) processResponse(req fetchRequest, resp fetchResponse) boo
 	return true
 }
 
+// processTrustedResponse accepts the header of a head announced by the trusted
+// servers as the new head of the chain, without verifying it.
+func (f *lightFetcher) processTrustedResponse(req fetchRequest, resp fetchResponse) bool {
+	if len(resp.headers) != 1 || resp.headers[0].Hash() != req.hash {
+		req.peer.Log().Debug("Trusted head response mismatch", "hash", req.hash, "delivered", len(resp.headers))
+		return false
+	}
+	header := resp.headers[0]
+	if err := f.chain.InsertTrustedHeader(header, req.td); err != nil {
+		log.Debug("Failed to insert trusted header", "number", header.Number, "hash", req.hash, "err", err)
+		return true
+	}
+	f.newHeaders([]*types.Header{header}, []*big.Int{req.td})
+	return true
+}
+
 // newHeaders updates the block trees of all active peers according to a newly
 // downloaded and validated batch or headers
 func (f *lightFetcher) newHeaders(headers []*types.Header, tds []*big.Int) {
@@ -559,6 +652,10 @@ func (f *lightFetcher) checkAnnouncedHeaders(fp *fetcherPeerInfo, headers []*typ
 			// we ran out of recently delivered headers but have not reached a node known by this peer yet, continue matching
 			td = f.chain.GetTd(header.ParentHash, header.Number.Uint64()-1)
 			header = f.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+			if header == nil {
+				// the ancestors of trusted heads are not downloaded by ultra light clients
+				return true
+			}
 		} else {
 			header = headers[i]
 			td = tds[i]
@@ -59,6 +59,7 @@ const (
 	MaxHelperTrieProofsFetch = 64  // Amount of merkle proofs to be fetched per retrieval request
 	MaxTxSend                = 64  // Amount of transactions to be send per request
 	MaxTxStatus              = 256 // Amount of transactions to queried per request
+	MaxTribeStatusFetch      = 16  // Amount of chief status proofs to be fetched per request
 
 	disableClientRemovePeer = false
 )
@@ -107,6 +108,8 @@ type ProtocolManager struct {
 	lesTopic    discv5.Topic
 	reqDist     *requestDistributor
 	retriever   *retrieveManager
+	ulc         *ulc // nil unless in ultra light client mode
+	engine      consensus.Engine
 
 	downloader *downloader.Downloader
 	fetcher    *lightFetcher
@@ -136,6 +139,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protoco
 		blockchain:  blockchain,
 		chainConfig: chainConfig,
 		chainDb:     chainDb,
+		engine:      engine,
 		odr:         odr,
 		networkId:   networkId,
 		txpool:      txpool,
@@ -261,6 +265,20 @@ func (pm *ProtocolManager) newPeer(pv int, nv uint64, p *p2p.Peer, rw p2p.MsgRea
 func (pm *ProtocolManager) handle(p *peer) error {
 	p.Log().Debug("Light Ethereum peer connected", "name", p.Name())
 
+	// Admit the client into its tier of the client pool
+	if pm.server != nil {
+		id := p.ID()
+		client, ok := pm.server.clientPool.connect(id, func() { p.Peer.Disconnect(p2p.DiscTooManyPeers) })
+		if !ok {
+			p.Log().Debug("Light Ethereum client rejected, no capacity left")
+			return p2p.DiscTooManyPeers
+		}
+		defer pm.server.clientPool.disconnect(id, client)
+		p.fcParams, p.priority = &client.params, client.priority
+	}
+	if pm.ulc != nil {
+		p.trusted = pm.ulc.isTrusted(p.ID())
+	}
 	// Execute the LES handshake
 	td, head, genesis := pm.blockchain.Status()
 	headNum := core.GetBlockNumber(pm.chainDb, head)
@@ -288,7 +306,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		head := p.headInfo
 		p.lock.Unlock()
 		if pm.fetcher != nil {
-			pm.fetcher.announce(p, head)
+			pm.fetcher.announce(p, head, false)
 		}
 
 		if p.poolEntry != nil {
@@ -319,7 +337,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 }
 
-var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg}
+var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg, GetTribeStatusMsg}
 
 // handleMsg is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
@@ -338,14 +356,24 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		bufValue, _ := p.fcClient.AcceptRequest()
 		cost := costs.baseCost + reqCnt*costs.reqCost
-		if cost > pm.server.defParams.BufLimit {
-			cost = pm.server.defParams.BufLimit
+		if cost > p.fcParams.BufLimit {
+			cost = p.fcParams.BufLimit
 		}
 		if cost > bufValue {
-			recharge := time.Duration((cost - bufValue) * 1000000 / pm.server.defParams.MinRecharge)
+			recharge := time.Duration((cost - bufValue) * 1000000 / p.fcParams.MinRecharge)
 			p.Log().Error("Request came too early", "recharge", common.PrettyDuration(recharge))
+			if p.priority {
+				priorityThrottledMeter.Mark(1)
+			} else {
+				freeThrottledMeter.Mark(1)
+			}
 			return true
 		}
+		if p.priority {
+			priorityRequestMeter.Mark(1)
+		} else {
+			freeRequestMeter.Mark(1)
+		}
 		return false
 	}
 
@@ -385,7 +413,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		p.Log().Trace("Announce message content", "number", req.Number, "hash", req.Hash, "td", req.Td, "reorg", req.ReorgDepth)
 		if pm.fetcher != nil {
-			pm.fetcher.announce(p, &req)
+			pm.fetcher.announce(p, &req, p.requestAnnounceType == announceTypeSigned)
 		}
 
 	case GetBlockHeadersMsg:
@@ -1063,6 +1091,61 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		p.fcServer.GotReply(resp.ReqID, resp.BV)
 
+	case GetTribeStatusMsg:
+		p.Log().Trace("Received tribe status request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather the status proofs until the fetch or network limits is reached
+		var (
+			bytes  int
+			proofs []light.NodeList
+		)
+		reqCnt := len(req.Hashes)
+		if reject(uint64(reqCnt), MaxTribeStatusFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		for _, hash := range req.Hashes {
+			if bytes >= softResponseLimit {
+				break
+			}
+			// Retrieve the requested block's status, appending an empty one if unknown
+			var proof light.NodeList
+			if header := pm.blockchain.GetHeaderByHash(hash); header != nil {
+				proof = pm.getTribeStatus(header)
+			}
+			proofs = append(proofs, proof)
+			bytes += proof.DataSize()
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendTribeStatus(req.ReqID, bv, proofs)
+
+	case TribeStatusMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received tribe status response")
+		var resp struct {
+			ReqID, BV uint64
+			Data      []light.NodeList
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgTribeStatus,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Data,
+		}
+
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
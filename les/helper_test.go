@@ -55,7 +55,8 @@ var (
 	testContractCodeDeployed = testContractCode[16:]
 	testContractDeployed     = uint64(2)
 
-	testBufLimit = uint64(100)
+	testBufLimit   = uint64(100)
+	testLightPeers = uint64(10) // Free clients served by the test servers
 
 	bigTxGas = new(big.Int).SetUint64(params.TxGas)
 )
@@ -146,7 +147,7 @@ func newTestProtocolManager(lightSync bool, blocks int, generator func(int, *cor
 	}
 
 	if lightSync {
-		chain, _ = light.NewLightChain(odr, gspec.Config, engine)
+		chain, _ = light.NewLightChain(odr, gspec.Config, engine, nil)
 	} else {
 		blockchain, _ := core.NewBlockChain(db, gspec.Config, engine, vm.Config{})
 		gchain, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, blocks, generator)
@@ -175,6 +176,10 @@ func newTestProtocolManager(lightSync bool, blocks int, generator func(int, *cor
 			MinRecharge: 1,
 		}
 
+		srv.clientPool, err = newClientPool(srv.defParams.MinRecharge*testLightPeers, *srv.defParams, "")
+		if err != nil {
+			return nil, err
+		}
 		srv.fcManager = flowcontrol.NewClientManager(50, 10, 1000000000)
 		srv.fcCostStats = newCostStats(nil)
 	}
@@ -62,6 +62,16 @@ var (
 	miscInTrafficMeter  = metrics.NewMeter("les/misc/in/traffic")
 	miscOutPacketsMeter = metrics.NewMeter("les/misc/out/packets")
 	miscOutTrafficMeter = metrics.NewMeter("les/misc/out/traffic")
+
+	priorityClientCounter  = metrics.NewCounter("les/server/clients/priority")
+	freeClientCounter      = metrics.NewCounter("les/server/clients/free")
+	priorityRequestMeter   = metrics.NewMeter("les/server/requests/priority")
+	freeRequestMeter       = metrics.NewMeter("les/server/requests/free")
+	priorityThrottledMeter = metrics.NewMeter("les/server/throttled/priority")
+	freeThrottledMeter     = metrics.NewMeter("les/server/throttled/free")
+	rejectedPriorityMeter  = metrics.NewMeter("les/server/rejected/priority")
+	rejectedFreeMeter      = metrics.NewMeter("les/server/rejected/free")
+	kickedFreeMeter        = metrics.NewMeter("les/server/kicked/free")
 )
 
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
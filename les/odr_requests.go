@@ -68,6 +68,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*ChtRequest)(r)
 	case *light.BloomRequest:
 		return (*BloomRequest)(r)
+	case *TribeStatusRequest:
+		return r
 	default:
 		return nil
 	}
@@ -193,7 +195,7 @@ func (r *TrieRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetProofsV1Msg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetProofsV2Msg, 1)
 	default:
 		panic(nil)
@@ -353,7 +355,7 @@ func (r *ChtRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetHeaderProofsMsg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetHelperTrieProofsMsg, 1)
 	default:
 		panic(nil)
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/core"
 	"github.com/MeshBoxFoundation/meshbox/core/types"
 	"github.com/MeshBoxFoundation/meshbox/eth"
 	"github.com/MeshBoxFoundation/meshbox/les/flowcontrol"
@@ -72,8 +73,11 @@ type peer struct {
 	hasBlock       func(common.Hash, uint64) bool
 	responseErrors int
 
-	fcClient       *flowcontrol.ClientNode // nil if the peer is server only
-	fcServer       *flowcontrol.ServerNode // nil if the peer is client only
+	fcClient       *flowcontrol.ClientNode   // nil if the peer is server only
+	fcParams       *flowcontrol.ServerParams // flow control parameters of a client, set by the client pool
+	priority       bool                      // whether the client is served in the priority tier
+	trusted        bool                      // whether the server is trusted by an ultra light client
+	fcServer       *flowcontrol.ServerNode   // nil if the peer is client only
 	fcServerParams *flowcontrol.ServerParams
 	fcCosts        requestCostTable
 }
@@ -236,6 +240,11 @@ func (p *peer) SendTxStatus(reqID, bv uint64, stats []txStatus) error {
 	return sendResponse(p.rw, TxStatusMsg, reqID, bv, stats)
 }
 
+// SendTribeStatus sends a batch of chief status proofs, corresponding to the ones requested.
+func (p *peer) SendTribeStatus(reqID, bv uint64, proofs []light.NodeList) error {
+	return sendResponse(p.rw, TribeStatusMsg, reqID, bv, proofs)
+}
+
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
 func (p *peer) RequestHeadersByHash(reqID, cost uint64, origin common.Hash, amount int, skip int, reverse bool) error {
@@ -276,7 +285,7 @@ func (p *peer) RequestProofs(reqID, cost uint64, reqs []ProofReq) error {
 	switch p.version {
 	case lpv1:
 		return sendRequest(p.rw, GetProofsV1Msg, reqID, cost, reqs)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetProofsV2Msg, reqID, cost, reqs)
 	default:
 		panic(nil)
@@ -299,7 +308,7 @@ func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, reqs []HelperTrieReq)
 			reqsV1[i] = ChtReq{ChtNum: (req.TrieIdx+1)*(light.ChtFrequency/light.ChtV1Frequency) - 1, BlockNum: blockNum, FromLevel: req.FromLevel}
 		}
 		return sendRequest(p.rw, GetHeaderProofsMsg, reqID, cost, reqsV1)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetHelperTrieProofsMsg, reqID, cost, reqs)
 	default:
 		panic(nil)
@@ -312,13 +321,19 @@ func (p *peer) RequestTxStatus(reqID, cost uint64, txHashes []common.Hash) error
 	return sendRequest(p.rw, GetTxStatusMsg, reqID, cost, txHashes)
 }
 
+// RequestTribeStatus fetches a batch of chief status proofs from a remote node.
+func (p *peer) RequestTribeStatus(reqID, cost uint64, hashes []common.Hash) error {
+	p.Log().Debug("Requesting tribe status", "count", len(hashes))
+	return sendRequest(p.rw, GetTribeStatusMsg, reqID, cost, hashes)
+}
+
 // SendTxStatus sends a batch of transactions to be added to the remote transaction pool.
 func (p *peer) SendTxs(reqID, cost uint64, txs types.Transactions) error {
 	p.Log().Debug("Fetching batch of transactions", "count", len(txs))
 	switch p.version {
 	case lpv1:
 		return p2p.Send(p.rw, SendTxMsg, txs) // old message format does not include reqID
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, SendTxV2Msg, reqID, cost, txs)
 	default:
 		panic(nil)
@@ -407,16 +422,20 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 	send = send.add("genesisHash", genesis)
 	if server != nil {
 		send = send.add("serveHeaders", nil)
-		send = send.add("serveChainSince", uint64(0))
+		send = send.add("serveChainSince", core.GetHistoryTail(server.protocolManager.chainDb))
 		send = send.add("serveStateSince", uint64(0))
 		send = send.add("txRelay", nil)
-		send = send.add("flowControl/BL", server.defParams.BufLimit)
-		send = send.add("flowControl/MRR", server.defParams.MinRecharge)
+		send = send.add("flowControl/BL", p.fcParams.BufLimit)
+		send = send.add("flowControl/MRR", p.fcParams.MinRecharge)
 		list := server.fcCostStats.getCurrentList()
 		send = send.add("flowControl/MRC", list)
 		p.fcCosts = list.decode()
 	} else {
-		p.requestAnnounceType = announceTypeSimple // set to default until "very light" client mode is implemented
+		p.requestAnnounceType = announceTypeSimple
+		if p.trusted {
+			// ultra light clients accept heads on the word of their trusted servers
+			p.requestAnnounceType = announceTypeSigned
+		}
 		send = send.add("announceType", p.requestAnnounceType)
 	}
 	recvList, err := p.sendReceiveHandshake(send)
@@ -465,7 +484,7 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		if recv.get("announceType", &p.announceType) != nil {
 			p.announceType = announceTypeSimple
 		}
-		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
+		p.fcClient = flowcontrol.NewClientNode(server.fcManager, p.fcParams)
 	} else {
 		if recv.get("serveChainSince", nil) != nil {
 			return errResp(ErrUselessPeer, "peer cannot serve chain")
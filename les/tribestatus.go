@@ -0,0 +1,252 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	ethereum "github.com/MeshBoxFoundation/meshbox"
+	"github.com/MeshBoxFoundation/meshbox/accounts/abi/bind"
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus"
+	chieflib "github.com/MeshBoxFoundation/meshbox/contracts/chief/lib"
+	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/core/state"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/core/vm"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/light"
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/MeshBoxFoundation/meshbox/params"
+)
+
+// chiefCallGas is the gas allowance of a single chief getter call.
+const chiefCallGas = 50000000
+
+var (
+	errNoChiefContract = errors.New("no chief contract at block")
+	errNoTribeStatus   = errors.New("tribe status unavailable")
+	errIncompleteState = errors.New("incomplete chief state proof")
+	errReadOnlyTrace   = errors.New("write to a read only trace")
+)
+
+// getTribeStatus proves the chief status of the given block. The chief
+// getters are executed against the block's state, and every trie node and
+// contract code they read is returned, which is all a client needs to run the
+// same calls. An empty proof means the server couldn't produce the status.
+func (pm *ProtocolManager) getTribeStatus(header *types.Header) light.NodeList {
+	chief := params.GetChiefInfo(header.Number)
+	if chief == nil {
+		return nil
+	}
+	db := &traceDatabase{Database: pm.chainDb}
+	chain := &tribeChain{BlockChain: pm.blockchain, engine: pm.engine}
+	if _, err := chiefStatusAt(context.Background(), header, chief, db, chain, pm.chainConfig); err != nil {
+		log.Debug("Failed to prove tribe status", "number", header.Number, "err", err)
+		return nil
+	}
+	return db.nodeList()
+}
+
+// chiefStatusAt reads the chief status by executing the chief getters against
+// the state of the given block, backed by db. Any state missing from db fails
+// the read with errIncompleteState.
+func chiefStatusAt(ctx context.Context, header *types.Header, chief *params.ChiefInfo, db *traceDatabase, chain core.ChainContext, config *params.ChainConfig) (params.ChiefStatus, error) {
+	statedb, err := state.New(header.Root, state.NewDatabase(db))
+	if err != nil {
+		return params.ChiefStatus{}, errIncompleteState
+	}
+	hash := header.Hash()
+	opts := &bind.CallOptsWithNumber{CallOpts: bind.CallOpts{Context: ctx}, Number: header.Number, Hash: &hash}
+	status, err := chieflib.ReadStatus(opts, chief, &stateCaller{header: header, state: statedb, chain: chain, config: config})
+
+	// Storage read failures are only remembered by the state objects, the
+	// trace catches those too
+	if db.missed || statedb.Error() != nil {
+		return params.ChiefStatus{}, errIncompleteState
+	}
+	return status, err
+}
+
+// TribeStatusRequest is the ODR request type for the chief status of a block,
+// see LesOdrRequest interface. The status is served by les/3 peers as the
+// state read by the chief getters, the client runs the getters itself on that
+// state and so derives the status from the header's state root alone.
+type TribeStatusRequest struct {
+	light.OdrRequest
+	Hash   common.Hash
+	Number uint64
+	Status params.ChiefStatus
+	Proof  *light.NodeSet
+
+	chain  core.ChainContext
+	config *params.ChainConfig
+}
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *TribeStatusRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetTribeStatusMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *TribeStatusRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv3 && peer.HasBlock(r.Hash, r.Number)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *TribeStatusRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting tribe status", "hash", r.Hash)
+	return peer.RequestTribeStatus(reqID, r.GetCost(peer), []common.Hash{r.Hash})
+}
+
+// Validate processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *TribeStatusRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating tribe status", "hash", r.Hash)
+
+	// Ensure we have a correct message with a single status
+	if msg.MsgType != MsgTribeStatus {
+		return errInvalidMessageType
+	}
+	proofs := msg.Obj.([]light.NodeList)
+	if len(proofs) != 1 {
+		return errInvalidEntryCount
+	}
+	if len(proofs[0]) == 0 {
+		return errNoTribeStatus
+	}
+	// Retrieve our stored header and run the chief getters against the proof
+	header := core.GetHeader(db, r.Hash, r.Number)
+	if header == nil {
+		return errHeaderUnavailable
+	}
+	chief := params.GetChiefInfo(header.Number)
+	if chief == nil {
+		return errNoChiefContract
+	}
+	nodeSet := proofs[0].NodeSet()
+	proofdb, _ := ethdb.NewMemDatabase()
+	nodeSet.Store(proofdb)
+
+	reads := &traceDatabase{Database: proofdb}
+	status, err := chiefStatusAt(context.Background(), header, chief, reads, r.chain, r.config)
+	if err != nil {
+		return err
+	}
+	if len(reads.reads) != nodeSet.KeyCount() {
+		return errUselessNodes
+	}
+	r.Status, r.Proof = status, nodeSet
+	return nil
+}
+
+// StoreResult stores the proven chief contract state in the local database
+func (r *TribeStatusRequest) StoreResult(db ethdb.Database) {
+	r.Proof.Store(db)
+}
+
+// traceDatabase is a database recording every entry read from it, and whether
+// any read failed. The state opened on it is only ever read from.
+type traceDatabase struct {
+	ethdb.Database
+	reads  map[string][]byte
+	missed bool
+}
+
+// Get retrieves the given key and records it, or the miss if it's absent.
+func (db *traceDatabase) Get(key []byte) ([]byte, error) {
+	value, err := db.Database.Get(key)
+	if err != nil {
+		db.missed = true
+		return nil, err
+	}
+	if db.reads == nil {
+		db.reads = make(map[string][]byte)
+	}
+	db.reads[string(key)] = value
+	return value, nil
+}
+
+// Put implements ethdb.Putter, nothing is written through a trace.
+func (db *traceDatabase) Put(key, value []byte) error {
+	return errReadOnlyTrace
+}
+
+// nodeList returns the entries read so far.
+func (db *traceDatabase) nodeList() light.NodeList {
+	nodes := make(light.NodeList, 0, len(db.reads))
+	for _, value := range db.reads {
+		nodes = append(nodes, value)
+	}
+	return nodes
+}
+
+// tribeChain completes a light server's chain into an EVM chain context.
+type tribeChain struct {
+	BlockChain
+	engine consensus.Engine
+}
+
+// Engine retrieves the chain's consensus engine.
+func (c *tribeChain) Engine() consensus.Engine { return c.engine }
+
+// stateCaller executes contract calls against a fixed state, it implements
+// bind.ContractCaller ignoring the requested block.
+type stateCaller struct {
+	header *types.Header
+	state  *state.StateDB
+	chain  core.ChainContext
+	config *params.ChainConfig
+}
+
+// CodeAt returns the code of the given account.
+func (c *stateCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return c.state.GetCode(contract), c.state.Error()
+}
+
+// CallContract executes a read only call against the state.
+func (c *stateCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var (
+		gas   = big.NewInt(chiefCallGas)
+		value = new(big.Int)
+		msg   = types.NewMessage(call.From, call.To, 0, value, gas, new(big.Int), call.Data, false)
+		evm   = vm.NewEVM(core.NewEVMContext(msg, c.header, c.chain, nil), c.state, c.config, vm.Config{})
+	)
+	ret, _, err := evm.Call(vm.AccountRef(call.From), *call.To, call.Data, gas.Uint64(), value)
+	if err := c.state.Error(); err != nil {
+		return nil, err
+	}
+	return ret, err
+}
+
+// CallContractWithHash executes a read only call against the state.
+func (c *stateCaller) CallContractWithHash(ctx context.Context, call ethereum.CallMsg, blockHash common.Hash) ([]byte, error) {
+	return c.CallContract(ctx, call, nil)
+}
+
+// TribeStatus retrieves the chief status of the given block from the network.
+func (s *LightEthereum) TribeStatus(ctx context.Context, hash common.Hash, number uint64) (params.ChiefStatus, error) {
+	r := &TribeStatusRequest{Hash: hash, Number: number, chain: s.blockchain, config: s.chainConfig}
+	if err := s.odr.Retrieve(ctx, r); err != nil {
+		return params.ChiefStatus{}, err
+	}
+	return r.Status, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/accounts/abi"
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/consensus"
+	"github.com/MeshBoxFoundation/meshbox/consensus/ethash"
+	chieflib "github.com/MeshBoxFoundation/meshbox/contracts/chief/lib"
+	"github.com/MeshBoxFoundation/meshbox/core"
+	"github.com/MeshBoxFoundation/meshbox/core/state"
+	"github.com/MeshBoxFoundation/meshbox/core/types"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/light"
+	"github.com/MeshBoxFoundation/meshbox/params"
+	"github.com/MeshBoxFoundation/meshbox/trie"
+)
+
+// testChain is an EVM chain context without any history.
+type testChain struct{}
+
+func (testChain) Engine() consensus.Engine                    { return ethash.NewFaker() }
+func (testChain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+
+// deployReturner deploys a contract answering every call with the given data,
+// loaded word by word from its storage.
+func deployReturner(statedb *state.StateDB, addr common.Address, data []byte) {
+	var code []byte
+	for i := 0; i*32 < len(data); i++ {
+		statedb.SetState(addr, common.BigToHash(big.NewInt(int64(i))), common.BytesToHash(data[i*32:(i+1)*32]))
+		// PUSH2 i SLOAD PUSH2 i*32 MSTORE
+		code = append(code, 0x61, byte(i>>8), byte(i), 0x54, 0x61, byte(i*32>>8), byte(i*32), 0x52)
+	}
+	// PUSH2 len PUSH1 0 RETURN
+	code = append(code, 0x61, byte(len(data)>>8), byte(len(data)), 0x60, 0x00, 0xf3)
+	statedb.SetCode(addr, code)
+}
+
+// Tests that the chief status is derived by the client from the state proven
+// by the server, that only the state read by the chief getters is proven, and
+// that partial or padded proofs are rejected.
+func TestTribeStatusProof(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	var (
+		number  = new(big.Int).Add(params.MainnetChainConfig.Chief100Block, big.NewInt(1))
+		chief   = params.GetChiefInfo(number)
+		signers = []common.Address{common.HexToAddress("0x2"), common.HexToAddress("0x3")}
+		leaders = []common.Address{common.HexToAddress("0x4")}
+	)
+	chiefABI, _ := abi.JSON(strings.NewReader(chieflib.TribeChief_1_0_0ABI))
+	status, err := chiefABI.Methods["getStatus"].Outputs.Pack(signers, []common.Address{}, []*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(3), big.NewInt(4)}, big.NewInt(5), number)
+	if err != nil {
+		t.Fatalf("failed to pack status: %v", err)
+	}
+	baseABI, _ := abi.JSON(strings.NewReader(chieflib.ChiefBase_1_0_0ABI))
+	leaderList, err := baseABI.Methods["takeLeaderList"].Outputs.Pack(leaders)
+	if err != nil {
+		t.Fatalf("failed to pack leaders: %v", err)
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	deployReturner(statedb, chief.Addr, status)
+	deployReturner(statedb, chief.BaseAddr, leaderList)
+	// Fill the chief storage with slots no getter reads
+	for i := int64(1000); i < 1100; i++ {
+		statedb.SetState(chief.Addr, common.BigToHash(big.NewInt(i)), common.BigToHash(big.NewInt(i*i)))
+	}
+	statedb.SetBalance(common.HexToAddress("0x1"), big.NewInt(1))
+	root, err := statedb.CommitTo(db, true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	header := &types.Header{Number: number, Root: root, Time: new(big.Int), Difficulty: new(big.Int), GasLimit: new(big.Int)}
+	if err := core.WriteHeader(db, header); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	want, err := chiefStatusAt(context.Background(), header, chief, &traceDatabase{Database: db}, testChain{}, params.MainnetChainConfig)
+	if err != nil {
+		t.Fatalf("failed to read status: %v", err)
+	}
+	pm := &ProtocolManager{chainDb: db, chainConfig: params.MainnetChainConfig, engine: ethash.NewFaker()}
+	proof := pm.getTribeStatus(header)
+
+	validate := func(proof light.NodeList) (*TribeStatusRequest, error) {
+		req := &TribeStatusRequest{Hash: header.Hash(), Number: number.Uint64(), chain: testChain{}, config: params.MainnetChainConfig}
+		return req, req.Validate(db, &Msg{MsgType: MsgTribeStatus, Obj: []light.NodeList{proof}})
+	}
+	req, err := validate(proof)
+	if err != nil {
+		t.Fatalf("valid status rejected: %v", err)
+	}
+	if !reflect.DeepEqual(req.Status, want) {
+		t.Errorf("status mismatch: have %+v, want %+v", req.Status, want)
+	}
+	if !reflect.DeepEqual(req.Status.SignerList, signers) {
+		t.Errorf("signers mismatch: have %x, want %x", req.Status.SignerList, signers)
+	}
+	if !reflect.DeepEqual(req.Status.LeaderList, leaders) {
+		t.Errorf("leaders mismatch: have %x, want %x", req.Status.LeaderList, leaders)
+	}
+	// The unread slots must be left out of the proof
+	str, _ := trie.New(statedb.StorageTrie(chief.Addr).Hash(), db)
+	var storageNodes int
+	for it := str.NodeIterator(nil); it.Next(true); {
+		if it.Hash() != (common.Hash{}) {
+			storageNodes++
+		}
+	}
+	if len(proof) >= storageNodes {
+		t.Errorf("proof too large: %d nodes, chief storage has %d", len(proof), storageNodes)
+	}
+	// Storing the result must make the chief status readable locally
+	local, _ := ethdb.NewMemDatabase()
+	req.StoreResult(local)
+	if have, err := chiefStatusAt(context.Background(), header, chief, &traceDatabase{Database: local}, testChain{}, params.MainnetChainConfig); err != nil || !reflect.DeepEqual(have, want) {
+		t.Errorf("stored status mismatch: have %+v (%v), want %+v", have, err, want)
+	}
+	// Tamper with the response and ensure it's rejected
+	if _, err := validate(nil); err != errNoTribeStatus {
+		t.Errorf("empty status: have %v, want %v", err, errNoTribeStatus)
+	}
+	for i := range proof {
+		missing := append(append(light.NodeList{}, proof[:i]...), proof[i+1:]...)
+		if _, err := validate(missing); err != errIncompleteState {
+			t.Errorf("node %d dropped: have %v, want %v", i, err, errIncompleteState)
+		}
+	}
+	padded := append(append(light.NodeList{}, proof...), []byte{0xc2, 0x80, 0x80})
+	if _, err := validate(padded); err != errUselessNodes {
+		t.Errorf("padded proof: have %v, want %v", err, errUselessNodes)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MeshBoxFoundation/meshbox/eth"
+	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+)
+
+var (
+	errNoTrustedServers       = errors.New("no trusted servers configured")
+	errInvalidTrustedFraction = errors.New("trusted server fraction must be between 1 and 100")
+)
+
+// ulc holds the trusted servers of an ultra light client. Such a client
+// doesn't download or verify any header, it accepts a new head as soon as
+// enough of its trusted servers have announced it with signed announcements.
+type ulc struct {
+	servers    []*discover.Node
+	trusted    map[discover.NodeID]struct{}
+	minTrusted int // Number of trusted servers needed to accept a head
+}
+
+// newULC parses the trusted servers of the ultra light client mode.
+func newULC(config *eth.ULCConfig) (*ulc, error) {
+	if len(config.TrustedServers) == 0 {
+		return nil, errNoTrustedServers
+	}
+	if config.MinTrustedFraction <= 0 || config.MinTrustedFraction > 100 {
+		return nil, errInvalidTrustedFraction
+	}
+	u := &ulc{trusted: make(map[discover.NodeID]struct{})}
+	for _, url := range config.TrustedServers {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted server %q: %v", url, err)
+		}
+		if _, ok := u.trusted[node.ID]; ok {
+			continue
+		}
+		u.servers = append(u.servers, node)
+		u.trusted[node.ID] = struct{}{}
+	}
+	u.minTrusted = (len(u.trusted)*config.MinTrustedFraction + 99) / 100
+	return u, nil
+}
+
+// isTrusted returns whether the given server is a trusted one.
+func (u *ulc) isTrusted(id discover.NodeID) bool {
+	_, ok := u.trusted[id]
+	return ok
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/eth"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/light"
+	"github.com/MeshBoxFoundation/meshbox/p2p"
+	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+)
+
+// Tests that the trusted servers are parsed and the number of them needed to
+// accept a head is rounded up.
+func TestULCConfig(t *testing.T) {
+	var urls []string
+	var ids []discover.NodeID
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		id := discover.PubkeyID(&key.PublicKey)
+		ids = append(ids, id)
+		urls = append(urls, fmt.Sprintf("enode://%x@127.0.0.1:%d", id[:], 30303+i))
+	}
+	tests := []struct {
+		fraction, minTrusted int
+	}{
+		{100, 3}, {75, 3}, {50, 2}, {34, 2}, {33, 1}, {1, 1},
+	}
+	for _, tt := range tests {
+		u, err := newULC(&eth.ULCConfig{TrustedServers: append(urls, urls[0]), MinTrustedFraction: tt.fraction})
+		if err != nil {
+			t.Fatalf("fraction %d: failed to create ulc: %v", tt.fraction, err)
+		}
+		if len(u.servers) != 3 || u.minTrusted != tt.minTrusted {
+			t.Errorf("fraction %d: have %d servers, %d needed, want 3, %d", tt.fraction, len(u.servers), u.minTrusted, tt.minTrusted)
+		}
+		for _, id := range ids {
+			if !u.isTrusted(id) {
+				t.Errorf("fraction %d: server %x not trusted", tt.fraction, id[:8])
+			}
+		}
+		if u.isTrusted(discover.NodeID{}) {
+			t.Errorf("fraction %d: unknown server trusted", tt.fraction)
+		}
+	}
+	if _, err := newULC(&eth.ULCConfig{MinTrustedFraction: 50}); err != errNoTrustedServers {
+		t.Errorf("no servers: have %v, want %v", err, errNoTrustedServers)
+	}
+	if _, err := newULC(&eth.ULCConfig{TrustedServers: urls, MinTrustedFraction: 101}); err != errInvalidTrustedFraction {
+		t.Errorf("fraction too large: have %v, want %v", err, errInvalidTrustedFraction)
+	}
+	if _, err := newULC(&eth.ULCConfig{TrustedServers: []string{"enode://xyz"}, MinTrustedFraction: 50}); err == nil {
+		t.Errorf("invalid server url accepted")
+	}
+}
+
+// Tests that an ultra light client requests an announced head only once the
+// trusted fraction of its servers has announced it with a signature.
+func TestULCTrustedHead(t *testing.T) {
+	var (
+		peers = newPeerSet()
+		dist  = newRequestDistributor(peers, make(chan struct{}))
+		rm    = newRetrieveManager(peers, dist, nil)
+		db, _ = ethdb.NewMemDatabase()
+		odr   = NewLesOdr(db, light.NewChtIndexer(db, true), light.NewBloomTrieIndexer(db, true), eth.NewBloomIndexer(db, light.BloomTrieFrequency), rm)
+		pm    = newTestProtocolManagerMust(t, true, 0, nil, peers, odr, db)
+	)
+	defer pm.Stop()
+	pm.ulc = &ulc{minTrusted: 2}
+
+	newServer := func(trusted bool) *peer {
+		var id discover.NodeID
+		rand.Read(id[:])
+		p := pm.newPeer(lpv2, NetworkId, p2p.NewPeer(id, "server", nil), nil)
+		p.trusted = trusted
+		pm.fetcher.registerPeer(p)
+		return p
+	}
+	trustedHead := func() common.Hash {
+		pm.fetcher.lock.Lock()
+		defer pm.fetcher.lock.Unlock()
+		return pm.fetcher.trustedHead
+	}
+	genesis := pm.blockchain.CurrentHeader()
+	head := &announceData{
+		Hash:   common.Hash{0x01},
+		Number: genesis.Number.Uint64() + 1,
+		Td:     new(big.Int).Add(pm.blockchain.GetTdByHash(genesis.Hash()), big.NewInt(1)),
+	}
+	tests := []struct {
+		trusted, signed bool
+		accepted        bool
+	}{
+		{trusted: false, signed: true, accepted: false}, // untrusted servers don't count
+		{trusted: true, signed: true, accepted: false},  // a single trusted server isn't enough
+		{trusted: true, signed: false, accepted: false}, // unsigned announcements don't count
+		{trusted: true, signed: true, accepted: true},   // the second signature reaches the fraction
+	}
+	for i, tt := range tests {
+		pm.fetcher.announce(newServer(tt.trusted), head, tt.signed)
+		if accepted := trustedHead() == head.Hash; accepted != tt.accepted {
+			t.Errorf("announcement %d: head accepted %v, want %v", i, accepted, tt.accepted)
+		}
+	}
+}
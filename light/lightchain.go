@@ -71,8 +71,9 @@ type LightChain struct {
 
 // NewLightChain returns a fully initialised light chain using information
 // available in the database. It initialises the default Ethereum header
-// validator.
-func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.Engine) (*LightChain, error) {
+// validator. Light sync starts from the given trusted checkpoint, or from the
+// hardcoded one of the chain if nil.
+func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.Engine, checkpoint *params.TrustedCheckpoint) (*LightChain, error) {
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -95,8 +96,11 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	if bc.genesisBlock == nil {
 		return nil, core.ErrNoGenesis
 	}
-	if cp, ok := trustedCheckpoints[bc.genesisBlock.Hash()]; ok {
-		bc.addTrustedCheckpoint(cp)
+	if checkpoint == nil {
+		checkpoint = params.TrustedCheckpoints[bc.genesisBlock.Hash()]
+	}
+	if checkpoint != nil && !checkpoint.Empty() {
+		bc.addTrustedCheckpoint(checkpoint)
 	}
 
 	if err := bc.loadLastState(); err != nil {
@@ -114,19 +118,19 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 }
 
 // addTrustedCheckpoint adds a trusted checkpoint to the blockchain
-func (self *LightChain) addTrustedCheckpoint(cp trustedCheckpoint) {
+func (self *LightChain) addTrustedCheckpoint(cp *params.TrustedCheckpoint) {
 	if self.odr.ChtIndexer() != nil {
-		StoreChtRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.chtRoot)
-		self.odr.ChtIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreChtRoot(self.chainDb, cp.SectionIndex, cp.SectionHead, cp.CHTRoot)
+		self.odr.ChtIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
 	if self.odr.BloomTrieIndexer() != nil {
-		StoreBloomTrieRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.bloomTrieRoot)
-		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreBloomTrieRoot(self.chainDb, cp.SectionIndex, cp.SectionHead, cp.BloomTrieRoot)
+		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
 	if self.odr.BloomIndexer() != nil {
-		self.odr.BloomIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		self.odr.BloomIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
-	log.Info("Added trusted checkpoint", "chain name", cp.name)
+	log.Info("Added trusted checkpoint", "chain name", cp.Name, "section", cp.SectionIndex, "head", cp.SectionHead)
 }
 
 func (self *LightChain) getProcInterrupt() bool {
@@ -397,6 +401,44 @@ func (self *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int)
 	return i, err
 }
 
+// InsertTrustedHeader sets the given header as the new head of the chain
+// without verifying it or requiring its ancestors, with the total difficulty
+// announced for it. It is used by ultra light clients to accept the heads
+// announced by their trusted servers.
+func (self *LightChain) InsertTrustedHeader(header *types.Header, td *big.Int) error {
+	self.chainmu.Lock()
+	defer self.chainmu.Unlock()
+
+	self.wg.Add(1)
+	defer self.wg.Done()
+
+	self.mu.Lock()
+	hash, number := header.Hash(), header.Number.Uint64()
+	head := self.hc.CurrentHeader()
+	if headTd := self.hc.GetTd(head.Hash(), head.Number.Uint64()); headTd != nil && td.Cmp(headTd) <= 0 {
+		self.mu.Unlock()
+		return nil
+	}
+	if err := core.WriteTd(self.chainDb, hash, number, td); err != nil {
+		self.mu.Unlock()
+		return err
+	}
+	if err := core.WriteHeader(self.chainDb, header); err != nil {
+		self.mu.Unlock()
+		return err
+	}
+	if err := core.WriteCanonicalHash(self.chainDb, hash, number); err != nil {
+		self.mu.Unlock()
+		return err
+	}
+	self.hc.SetCurrentHeader(header)
+	self.mu.Unlock()
+
+	log.Debug("Inserted trusted header", "number", number, "hash", hash, "td", td)
+	go self.postChainEvents([]interface{}{core.ChainEvent{Block: types.NewBlockWithHeader(header), Hash: hash}})
+	return nil
+}
+
 // CurrentHeader retrieves the current head header of the canonical chain. The
 // header is retrieved from the HeaderChain's internal cache.
 func (self *LightChain) CurrentHeader() *types.Header {
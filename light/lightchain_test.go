@@ -54,7 +54,7 @@ func newCanonical(n int) (ethdb.Database, *LightChain, error) {
 	db, _ := ethdb.NewMemDatabase()
 	gspec := core.Genesis{Config: params.TestChainConfig}
 	genesis := gspec.MustCommit(db)
-	blockchain, _ := NewLightChain(&dummyOdr{db: db}, gspec.Config, ethash.NewFaker())
+	blockchain, _ := NewLightChain(&dummyOdr{db: db}, gspec.Config, ethash.NewFaker(), nil)
 
 	// Create and inject the requested chain
 	if n == 0 {
@@ -74,7 +74,7 @@ func newTestLightChain() *LightChain {
 		Config:     params.TestChainConfig,
 	}
 	gspec.MustCommit(db)
-	lc, err := NewLightChain(&dummyOdr{db: db}, gspec.Config, ethash.NewFullFaker())
+	lc, err := NewLightChain(&dummyOdr{db: db}, gspec.Config, ethash.NewFullFaker(), nil)
 	if err != nil {
 		panic(err)
 	}
@@ -338,7 +338,7 @@ func TestReorgBadHeaderHashes(t *testing.T) {
 	defer func() { delete(core.BadHashes, headers[3].Hash()) }()
 
 	// Create a new LightChain and check that it rolled back the state.
-	ncm, err := NewLightChain(&dummyOdr{db: bc.chainDb}, params.TestChainConfig, ethash.NewFaker())
+	ncm, err := NewLightChain(&dummyOdr{db: bc.chainDb}, params.TestChainConfig, ethash.NewFaker(), nil)
 	if err != nil {
 		t.Fatalf("failed to create new chain manager: %v", err)
 	}
@@ -346,3 +346,30 @@ func TestReorgBadHeaderHashes(t *testing.T) {
 		t.Errorf("last header hash mismatch: have: %x, want %x", ncm.CurrentHeader().Hash(), headers[2].Hash())
 	}
 }
+
+// Tests that trusted headers become the chain head without their ancestors,
+// unless they are lighter than the current head.
+func TestInsertTrustedHeader(t *testing.T) {
+	bc := newTestLightChain()
+
+	header := &types.Header{Number: big.NewInt(1000), ParentHash: common.Hash{0x01}, Difficulty: big.NewInt(1)}
+	if err := bc.InsertTrustedHeader(header, big.NewInt(5000)); err != nil {
+		t.Fatalf("failed to insert trusted header: %v", err)
+	}
+	if head := bc.CurrentHeader(); head.Hash() != header.Hash() {
+		t.Errorf("head mismatch: have #%d, want #%d", head.Number, header.Number)
+	}
+	if have := bc.GetHeaderByNumber(1000); have == nil || have.Hash() != header.Hash() {
+		t.Errorf("canonical header mismatch: have %v", have)
+	}
+	if td := bc.GetTdByHash(header.Hash()); td == nil || td.Cmp(big.NewInt(5000)) != 0 {
+		t.Errorf("td mismatch: have %v, want 5000", td)
+	}
+	lighter := &types.Header{Number: big.NewInt(1001), ParentHash: header.Hash(), Difficulty: big.NewInt(1)}
+	if err := bc.InsertTrustedHeader(lighter, big.NewInt(4000)); err != nil {
+		t.Fatalf("failed to insert lighter header: %v", err)
+	}
+	if head := bc.CurrentHeader(); head.Hash() != header.Hash() {
+		t.Errorf("lighter header became the head: have #%d, want #%d", head.Number, header.Number)
+	}
+}
@@ -41,47 +41,46 @@ const (
 	HelperTrieProcessConfirmations = 256  // number of confirmations before a HelperTrie is generated
 )
 
-// trustedCheckpoint represents a set of post-processed trie roots (CHT and BloomTrie) associated with
-// the appropriate section index and head hash. It is used to start light syncing from this checkpoint
-// and avoid downloading the entire header chain while still being able to securely access old headers/logs.
-type trustedCheckpoint struct {
-	name                                string
-	sectionIdx                          uint64
-	sectionHead, chtRoot, bloomTrieRoot common.Hash
-}
-
-var (
-	mainnetCheckpoint = trustedCheckpoint{
-		name:          "ETH mainnet",
-		sectionIdx:    129,
-		sectionHead:   common.HexToHash("64100587c8ec9a76870056d07cb0f58622552d16de6253a59cac4b580c899501"),
-		chtRoot:       common.HexToHash("bb4fb4076cbe6923c8a8ce8f158452bbe19564959313466989fda095a60884ca"),
-		bloomTrieRoot: common.HexToHash("0db524b2c4a2a9520a42fd842b02d2e8fb58ff37c75cf57bd0eb82daeace6716"),
-	}
-
-	ropstenCheckpoint = trustedCheckpoint{
-		name:          "Ropsten testnet",
-		sectionIdx:    50,
-		sectionHead:   common.HexToHash("00bd65923a1aa67f85e6b4ae67835784dd54be165c37f056691723c55bf016bd"),
-		chtRoot:       common.HexToHash("6f56dc61936752cc1f8c84b4addabdbe6a1c19693de3f21cb818362df2117f03"),
-		bloomTrieRoot: common.HexToHash("aca7d7c504d22737242effc3fdc604a762a0af9ced898036b5986c3a15220208"),
-	}
-)
-
-// trustedCheckpoints associates each known checkpoint with the genesis hash of the chain it belongs to
-var trustedCheckpoints = map[common.Hash]trustedCheckpoint{
-	params.MainnetGenesisHash: mainnetCheckpoint,
-	params.TestnetGenesisHash: ropstenCheckpoint,
-}
-
 var (
 	ErrNoTrustedCht       = errors.New("No trusted canonical hash trie")
 	ErrNoTrustedBloomTrie = errors.New("No trusted bloom trie")
 	ErrNoHeader           = errors.New("Header not found")
+	ErrNoCheckpoint       = errors.New("No section with both CHT and BloomTrie")
 	chtPrefix             = []byte("chtRoot-") // chtPrefix + chtNum (uint64 big endian) -> trie root hash
 	ChtTablePrefix        = "cht-"
 )
 
+// ServerCheckpoint returns the trusted checkpoint of the latest section for
+// which both the CHT and the BloomTrie have been generated by the server mode
+// indexers. The CHT indexer of a server uses the LES/1 section size.
+func ServerCheckpoint(db ethdb.Database, chtIndexer, bloomTrieIndexer *core.ChainIndexer) (*params.TrustedCheckpoint, error) {
+	chtV1Sections, _, _ := chtIndexer.Sections()
+	sections, _, _ := bloomTrieIndexer.Sections()
+	if chtSections := chtV1Sections / (ChtFrequency / ChtV1Frequency); chtSections < sections {
+		sections = chtSections
+	}
+	if sections == 0 {
+		return nil, ErrNoCheckpoint
+	}
+	var (
+		section = sections - 1
+		head    = chtIndexer.SectionHead((section+1)*(ChtFrequency/ChtV1Frequency) - 1)
+	)
+	if head != bloomTrieIndexer.SectionHead(section) {
+		return nil, fmt.Errorf("CHT and BloomTrie section %d heads mismatch", section)
+	}
+	cp := &params.TrustedCheckpoint{
+		SectionIndex:  section,
+		SectionHead:   head,
+		CHTRoot:       GetChtV2Root(db, section, head),
+		BloomTrieRoot: GetBloomTrieRoot(db, section, head),
+	}
+	if cp.Empty() {
+		return nil, ErrNoCheckpoint
+	}
+	return cp, nil
+}
+
 // ChtNode structures are stored in the Canonical Hash Trie in an RLP encoded format
 type ChtNode struct {
 	Hash common.Hash
@@ -0,0 +1,66 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/ethdb"
+	"github.com/MeshBoxFoundation/meshbox/params"
+)
+
+// Tests that the server checkpoint is taken at the latest LES/2 section
+// covered by both the CHT and the BloomTrie.
+func TestServerCheckpoint(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	chtIndexer := NewChtIndexer(db, false)
+	defer chtIndexer.Close()
+	bloomTrieIndexer := NewBloomTrieIndexer(db, false)
+	defer bloomTrieIndexer.Close()
+
+	if _, err := ServerCheckpoint(db, chtIndexer, bloomTrieIndexer); err != ErrNoCheckpoint {
+		t.Fatalf("empty indexers: have %v, want %v", err, ErrNoCheckpoint)
+	}
+	// Three LES/2 CHT sections, but only two BloomTrie ones
+	ratio := uint64(ChtFrequency / ChtV1Frequency)
+	for section := uint64(0); section < 3; section++ {
+		head := common.Hash{byte(section + 1)}
+		chtIndexer.AddKnownSectionHead((section+1)*ratio-1, head)
+		StoreChtRoot(db, (section+1)*ratio-1, head, common.Hash{0xc0, byte(section)})
+		if section < 2 {
+			bloomTrieIndexer.AddKnownSectionHead(section, head)
+			StoreBloomTrieRoot(db, section, head, common.Hash{0xb0, byte(section)})
+		}
+	}
+	cp, err := ServerCheckpoint(db, chtIndexer, bloomTrieIndexer)
+	if err != nil {
+		t.Fatalf("failed to compute checkpoint: %v", err)
+	}
+	want := params.TrustedCheckpoint{
+		SectionIndex:  1,
+		SectionHead:   common.Hash{0x02},
+		CHTRoot:       common.Hash{0xc0, 0x01},
+		BloomTrieRoot: common.Hash{0xb0, 0x01},
+	}
+	if *cp != want {
+		t.Errorf("checkpoint mismatch: have %+v, want %+v", cp, want)
+	}
+	if have, want := cp.String(), "1:"+common.Bytes2Hex(want.SectionHead[:])+":"+common.Bytes2Hex(want.CHTRoot[:])+":"+common.Bytes2Hex(want.BloomTrieRoot[:]); have != want {
+		t.Errorf("checkpoint string mismatch: have %s, want %s", have, want)
+	}
+}
@@ -46,6 +46,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirNodeRecordSeq   = "nodeseq"            // Path within the datadir to the sequence number of the node record
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -181,6 +182,14 @@ func (c *Config) NodeDB() string {
 	return c.resolvePath(datadirNodeDatabase)
 }
 
+// NodeRecordSeq returns the path to the sequence number of the node record.
+func (c *Config) NodeRecordSeq() string {
+	if c.DataDir == "" {
+		return "" // ephemeral
+	}
+	return c.resolvePath(datadirNodeRecordSeq)
+}
+
 // DefaultIPCEndpoint returns the IPC path used by default.
 func DefaultIPCEndpointWithDir(dir, clientIdentifier string) string {
 	if clientIdentifier == "" {
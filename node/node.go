@@ -165,6 +165,9 @@ func (n *Node) Start() error {
 	if n.serverConfig.NodeDatabase == "" {
 		n.serverConfig.NodeDatabase = n.config.NodeDB()
 	}
+	if n.serverConfig.NodeRecordSeq == "" {
+		n.serverConfig.NodeRecordSeq = n.config.NodeRecordSeq()
+	}
 	running := &p2p.Server{Config: n.serverConfig}
 	n.log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
 
@@ -35,6 +35,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
 )
 
 const (
@@ -84,6 +85,8 @@ type transport interface {
 	ping(NodeID, *net.UDPAddr) error
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestENR(toid NodeID, addr *net.UDPAddr) (*enr.Record, error)
+	setNodeRecord(fn func() (*enr.Record, error))
 	close()
 }
 
@@ -225,6 +228,18 @@ func (tab *Table) Resolve(targetID NodeID) *Node {
 	return nil
 }
 
+// SetNodeRecord sets the source of the local node record, which is served
+// to bonded nodes over discovery.
+func (tab *Table) SetNodeRecord(fn func() (*enr.Record, error)) {
+	tab.net.setNodeRecord(fn)
+}
+
+// RequestENR asks the given node for its node record. The returned record
+// is verified to be signed by that node.
+func (tab *Table) RequestENR(n *Node) (*enr.Record, error) {
+	return tab.net.requestENR(n.ID, n.addr())
+}
+
 // Lookup performs a network search for nodes close
 // to the given target. It approaches the target by querying
 // nodes that are closer to it on each iteration.
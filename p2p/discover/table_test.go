@@ -29,6 +29,7 @@ import (
 
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
 )
 
 func TestTable_pingReplace(t *testing.T) {
@@ -160,6 +161,10 @@ func newPingRecorder() *pingRecorder {
 func (t *pingRecorder) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node, error) {
 	panic("findnode called on pingRecorder")
 }
+func (t *pingRecorder) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	panic("requestENR called on pingRecorder")
+}
+func (t *pingRecorder) setNodeRecord(fn func() (*enr.Record, error)) {}
 func (t *pingRecorder) close() {}
 func (t *pingRecorder) waitping(from NodeID) error {
 	return nil // remote always pings
@@ -526,6 +531,10 @@ func (tn *preminedTestnet) findnode(toid NodeID, toaddr *net.UDPAddr, target Nod
 func (*preminedTestnet) close()                                      {}
 func (*preminedTestnet) waitping(from NodeID) error                  { return nil }
 func (*preminedTestnet) ping(toid NodeID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	return nil, errNoRecord
+}
+func (*preminedTestnet) setNodeRecord(fn func() (*enr.Record, error)) {}
 
 // mine generates a testnet struct literal with nodes at
 // various distances to the given target.
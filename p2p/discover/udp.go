@@ -23,10 +23,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
 	"github.com/MeshBoxFoundation/meshbox/p2p/nat"
 	"github.com/MeshBoxFoundation/meshbox/p2p/netutil"
 	"github.com/MeshBoxFoundation/meshbox/rlp"
@@ -44,6 +46,7 @@ var (
 	errTimeout          = errors.New("RPC timeout")
 	errClockWarp        = errors.New("reply deadline too far in the future")
 	errClosed           = errors.New("socket closed")
+	errNoRecord         = errors.New("no node record")
 )
 
 // Timeouts
@@ -64,6 +67,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
 )
 
 // RPC request structures
@@ -105,6 +110,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest is a query for the node record of the recipient.
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte // Hash of the enrRequest packet.
+		Record   enr.Record
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -171,6 +191,9 @@ type udp struct {
 	closing chan struct{}
 	nat     nat.Interface
 
+	recordMu   sync.RWMutex
+	nodeRecord func() (*enr.Record, error) // local node record served to enrRequest
+
 	*Table
 }
 
@@ -310,6 +333,62 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 	return nodes, err
 }
 
+// setNodeRecord sets the source of the local node record, which is served
+// to bonded nodes asking for it.
+func (t *udp) setNodeRecord(fn func() (*enr.Record, error)) {
+	t.recordMu.Lock()
+	t.nodeRecord = fn
+	t.recordMu.Unlock()
+}
+
+// localRecord returns the local node record, if any.
+func (t *udp) localRecord() (*enr.Record, error) {
+	t.recordMu.RLock()
+	fn := t.nodeRecord
+	t.recordMu.RUnlock()
+
+	if fn == nil {
+		return nil, errNoRecord
+	}
+	return fn()
+}
+
+// requestENR sends an enrRequest to the given node and waits for the record
+// signed by that node.
+func (t *udp) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	packet, err := encodePacket(t.priv, enrRequestPacket, &enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	hash := packet[:macSize]
+
+	var record *enr.Record
+	errc := t.pending(toid, enrResponsePacket, func(r interface{}) bool {
+		reply := r.(*enrResponse)
+		if !bytes.Equal(reply.ReplyTok, hash) {
+			return false
+		}
+		var pubkey enr.Secp256k1
+		if err := reply.Record.Load(&pubkey); err != nil {
+			return false
+		}
+		if PubkeyID((*ecdsa.PublicKey)(&pubkey)) != toid {
+			log.Trace("Node record signed by another node", "id", toid, "addr", toaddr)
+			return false
+		}
+		record = &reply.Record
+		return true
+	})
+	_, err = t.conn.WriteToUDP(packet, toaddr)
+	log.Trace(">> ENRREQUEST/v4", "addr", toaddr, "err", err)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id NodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -561,6 +640,10 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -651,3 +734,30 @@ func (req *neighbors) name() string { return "NEIGHBORS/v4" }
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
+
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if t.db.node(fromID) == nil {
+		// Only bonded nodes are answered, for the same reason as findnode.
+		return errUnknownNode
+	}
+	record, err := t.localRecord()
+	if err != nil {
+		return err
+	}
+	t.send(from, enrResponsePacket, &enrResponse{ReplyTok: mac, Record: *record})
+	return nil
+}
+
+func (req *enrRequest) name() string { return "ENRREQUEST/v4" }
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if !t.handleReply(fromID, enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *enrResponse) name() string { return "ENRRESPONSE/v4" }
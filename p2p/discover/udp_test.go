@@ -35,6 +35,7 @@ import (
 
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
 	"github.com/MeshBoxFoundation/meshbox/rlp"
 	"github.com/davecgh/go-spew/spew"
 )
@@ -328,6 +329,84 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+func TestUDP_enrRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	record := new(enr.Record)
+	record.Set(enr.TCP(30303))
+	if err := record.Sign(test.localkey); err != nil {
+		t.Fatal(err)
+	}
+	test.table.SetNodeRecord(func() (*enr.Record, error) { return record, nil })
+
+	// unbonded nodes don't get the record.
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	test.table.db.updateNode(NewNode(
+		PubkeyID(&test.remotekey.PublicKey),
+		test.remoteaddr.IP,
+		uint16(test.remoteaddr.Port),
+		99,
+	))
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponse) {
+		if !bytes.Equal(p.ReplyTok, test.sent[len(test.sent)-1][:macSize]) {
+			t.Errorf("wrong reply token: %x", p.ReplyTok)
+		}
+		var port enr.TCP
+		if err := p.Record.Load(&port); err != nil || port != 30303 || p.Record.Seq() != record.Seq() {
+			t.Errorf("record mismatch: tcp %d seq %d, err %v", port, p.Record.Seq(), err)
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	rid := PubkeyID(&test.remotekey.PublicKey)
+	resultc, errc := make(chan *enr.Record, 1), make(chan error, 1)
+	go func() {
+		r, err := test.udp.requestENR(rid, test.remoteaddr)
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- r
+		}
+	}()
+	_, _, hash, err := decodePacket(test.pipe.waitPacketOut())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(key *ecdsa.PrivateKey) enr.Record {
+		var r enr.Record
+		r.Set(enr.UDP(30304))
+		if err := r.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	// a record signed by another node is ignored.
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: sign(newkey())})
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: sign(test.remotekey)})
+
+	select {
+	case r := <-resultc:
+		var port enr.UDP
+		if err := r.Load(&port); err != nil || port != 30304 {
+			t.Errorf("record mismatch: udp %d, err %v", port, err)
+		}
+		if !bytes.Equal(r.NodeAddr(), crypto.Keccak256(crypto.CompressPubkey(&test.remotekey.PublicKey))) {
+			t.Error("record not signed by the remote node")
+		}
+	case err := <-errc:
+		t.Errorf("requestENR error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("requestENR did not return within 5 seconds")
+	}
+}
+
 func TestUDP_successfulPing(t *testing.T) {
 	test := newUDPTest(t)
 	added := make(chan *Node, 1)
@@ -130,6 +130,12 @@ func (p *Peer) Name() string {
 	return p.rw.name
 }
 
+// Priority returns whether the peer occupies a priority slot outside of the
+// MaxPeers limit.
+func (p *Peer) Priority() bool {
+	return p.rw.is(priorityConn)
+}
+
 // Caps returns the capabilities (supported subprotocols) of the remote peer.
 func (p *Peer) Caps() []Cap {
 	// TODO: maybe return copy
@@ -162,7 +168,7 @@ func (p *Peer) String() string {
 
 // Inbound returns true if the peer is an inbound connection
 func (p *Peer) Inbound() bool {
-	return p.rw.flags&inboundConn != 0
+	return p.rw.is(inboundConn)
 }
 
 func newPeer(conn *conn, protocols []Protocol) *Peer {
@@ -419,6 +425,7 @@ type PeerInfo struct {
 	Network struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
+		Priority      bool   `json:"priority"`      // Whether the peer sits in a priority slot outside of MaxPeers
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
@@ -439,6 +446,7 @@ func (p *Peer) Info() *PeerInfo {
 	}
 	info.Network.LocalAddress = p.LocalAddr().String()
 	info.Network.RemoteAddress = p.RemoteAddr().String()
+	info.Network.Priority = p.Priority()
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,11 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Attributes is an optional helper method to retrieve the protocol specific
+	// entries of the local node record. It is invoked every time the record is
+	// assembled, so the returned entries may change over time.
+	Attributes func() []enr.Entry
 }
 
 func (p Protocol) cap() Cap {
@@ -18,11 +18,17 @@
 package p2p
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
@@ -31,8 +37,10 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/p2p/discover"
 	"github.com/MeshBoxFoundation/meshbox/p2p/discv5"
+	"github.com/MeshBoxFoundation/meshbox/p2p/enr"
 	"github.com/MeshBoxFoundation/meshbox/p2p/nat"
 	"github.com/MeshBoxFoundation/meshbox/p2p/netutil"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
 )
 
 const (
@@ -111,6 +119,11 @@ type Config struct {
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
 
+	// NodeRecordSeq is the path to the file keeping the sequence number of the
+	// last signed node record, so records signed after a restart supersede the
+	// ones other nodes cached. If empty, the sequence starts over on restart.
+	NodeRecordSeq string `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -167,15 +180,21 @@ type Server struct {
 	peerOp     chan peerOpFunc
 	peerOpDone chan struct{}
 
-	quit          chan struct{}
-	addstatic     chan *discover.Node
-	removestatic  chan *discover.Node
-	posthandshake chan *conn
-	addpeer       chan *conn
-	delpeer       chan peerDrop
-	loopWG        sync.WaitGroup // loop, listenLoop
-	peerFeed      event.Feed
-	log           log.Logger
+	quit           chan struct{}
+	addstatic      chan *discover.Node
+	removestatic   chan *discover.Node
+	addpriority    chan *discover.Node
+	removepriority chan *discover.Node
+	posthandshake  chan *conn
+	addpeer        chan *conn
+	delpeer        chan peerDrop
+	loopWG         sync.WaitGroup // loop, listenLoop
+	peerFeed       event.Feed
+	log            log.Logger
+
+	recordLock    sync.Mutex
+	record        *enr.Record // last signed local node record
+	recordContent []byte      // encoded entries of record, re-signed only when they change
 }
 
 type peerOpFunc func(map[discover.NodeID]*Peer)
@@ -186,13 +205,14 @@ type peerDrop struct {
 	requested bool // true if signaled by the peer
 }
 
-type connFlag int
+type connFlag int32
 
 const (
 	dynDialedConn connFlag = 1 << iota
 	staticDialedConn
 	inboundConn
 	trustedConn
+	priorityConn
 )
 
 // conn wraps a network connection with information gathered
@@ -222,7 +242,7 @@ type transport interface {
 }
 
 func (c *conn) String() string {
-	s := c.flags.String()
+	s := connFlag(atomic.LoadInt32((*int32)(&c.flags))).String()
 	if (c.id != discover.NodeID{}) {
 		s += " " + c.id.String()
 	}
@@ -235,6 +255,9 @@ func (f connFlag) String() string {
 	if f&trustedConn != 0 {
 		s += "-trusted"
 	}
+	if f&priorityConn != 0 {
+		s += "-priority"
+	}
 	if f&dynDialedConn != 0 {
 		s += "-dyndial"
 	}
@@ -251,7 +274,25 @@ func (f connFlag) String() string {
 }
 
 func (c *conn) is(f connFlag) bool {
-	return c.flags&f != 0
+	flags := connFlag(atomic.LoadInt32((*int32)(&c.flags)))
+	return flags&f != 0
+}
+
+// set sets or clears the given flags. Flags may change after the connection
+// was handed to the peer, e.g. when a node is promoted to a priority peer.
+func (c *conn) set(f connFlag, val bool) {
+	for {
+		oldFlags := connFlag(atomic.LoadInt32((*int32)(&c.flags)))
+		flags := oldFlags
+		if val {
+			flags |= f
+		} else {
+			flags &= ^f
+		}
+		if atomic.CompareAndSwapInt32((*int32)(&c.flags), int32(oldFlags), int32(flags)) {
+			return
+		}
+	}
 }
 
 // Peers returns all connected peers.
@@ -301,6 +342,26 @@ func (srv *Server) RemovePeer(node *discover.Node) {
 	}
 }
 
+// AddPriorityPeer connects to the given node and keeps the connection alive like
+// AddPeer does. In addition the node is exempt from the MaxPeers limit, both when
+// dialed and when it connects to us, until it is removed with RemovePriorityPeer.
+func (srv *Server) AddPriorityPeer(node *discover.Node) {
+	select {
+	case srv.addpriority <- node:
+	case <-srv.quit:
+	}
+}
+
+// RemovePriorityPeer stops maintaining the connection to the given node and
+// revokes its exemption from the peer limit. An established connection is kept,
+// but counts against MaxPeers from then on.
+func (srv *Server) RemovePriorityPeer(node *discover.Node) {
+	select {
+	case srv.removepriority <- node:
+	case <-srv.quit:
+	}
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -385,6 +446,8 @@ func (srv *Server) Start() (err error) {
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
 	srv.removestatic = make(chan *discover.Node)
+	srv.addpriority = make(chan *discover.Node)
+	srv.removepriority = make(chan *discover.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
@@ -397,6 +460,7 @@ func (srv *Server) Start() (err error) {
 		if err := ntab.SetFallbackNodes(srv.BootstrapNodes); err != nil {
 			return err
 		}
+		ntab.SetNodeRecord(srv.NodeRecord)
 		srv.ntab = ntab
 	}
 
@@ -472,6 +536,8 @@ func (srv *Server) run(dialstate dialer) {
 	var (
 		peers        = make(map[discover.NodeID]*Peer)
 		trusted      = make(map[discover.NodeID]bool, len(srv.TrustedNodes))
+		priority     = make(map[discover.NodeID]bool)
+		static       = make(map[discover.NodeID]bool, len(srv.StaticNodes))
 		taskdone     = make(chan task, maxActiveDialTasks)
 		runningTasks []task
 		queuedTasks  []task // tasks that can't run yet
@@ -482,6 +548,9 @@ func (srv *Server) run(dialstate dialer) {
 	for _, n := range srv.TrustedNodes {
 		trusted[n.ID] = true
 	}
+	for _, n := range srv.StaticNodes {
+		static[n.ID] = true
+	}
 
 	// removes t from runningTasks
 	delTask := func(t task) {
@@ -536,6 +605,26 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case n := <-srv.addpriority:
+			// This channel is used by AddPriorityPeer. Priority nodes are
+			// dialed like static ones and bypass the peer limit.
+			srv.log.Debug("Adding priority node", "node", n)
+			priority[n.ID] = true
+			dialstate.addStatic(n)
+			if p, ok := peers[n.ID]; ok {
+				p.rw.set(priorityConn, true)
+			}
+		case n := <-srv.removepriority:
+			// This channel is used by RemovePriorityPeer. Configured static
+			// nodes keep being dialed.
+			srv.log.Debug("Removing priority node", "node", n)
+			delete(priority, n.ID)
+			if !static[n.ID] {
+				dialstate.removeStatic(n)
+			}
+			if p, ok := peers[n.ID]; ok {
+				p.rw.set(priorityConn, false)
+			}
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -552,7 +641,11 @@ running:
 			// the remote identity is known (but hasn't been verified yet).
 			if trusted[c.id] {
 				// Ensure that the trusted flag is set before checking against MaxPeers.
-				c.flags |= trustedConn
+				c.set(trustedConn, true)
+			}
+			if priority[c.id] {
+				// Priority peers live in their own slots, outside of MaxPeers.
+				c.set(priorityConn, true)
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
 			select {
@@ -628,7 +721,7 @@ func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn
 
 func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn) error {
 	switch {
-	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
+	case !c.is(trustedConn|staticDialedConn|priorityConn) && countRegularPeers(peers) >= srv.MaxPeers:
 		return DiscTooManyPeers
 	case peers[c.id] != nil:
 		return DiscAlreadyConnected
@@ -639,6 +732,18 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn)
 	}
 }
 
+// countRegularPeers returns the number of peers occupying one of the MaxPeers
+// slots, that is all of them except the priority ones.
+func countRegularPeers(peers map[discover.NodeID]*Peer) int {
+	n := 0
+	for _, p := range peers {
+		if !p.Priority() {
+			n++
+		}
+	}
+	return n
+}
+
 type tempError interface {
 	Temporary() bool
 }
@@ -829,6 +934,7 @@ type NodeInfo struct {
 		Discovery int `json:"discovery"` // UDP listening port for discovery protocol
 		Listener  int `json:"listener"`  // TCP listening port for RLPx
 	} `json:"ports"`
+	ENR        string                 `json:"enr"` // Signed node record advertising the protocol attributes
 	ListenAddr string                 `json:"listenAddr"`
 	Protocols  map[string]interface{} `json:"protocols"`
 }
@@ -848,6 +954,11 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	}
 	info.Ports.Discovery = int(node.UDP)
 	info.Ports.Listener = int(node.TCP)
+	if record, err := srv.NodeRecord(); err == nil {
+		if enc, err := rlp.EncodeToBytes(record); err == nil {
+			info.ENR = "enr:" + base64.RawURLEncoding.EncodeToString(enc)
+		}
+	}
 
 	// Gather all the running protocol infos (only once per protocol type)
 	for _, proto := range srv.Protocols {
@@ -862,6 +973,81 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// NodeRecord assembles and signs the local node record, containing the
+// endpoint of the node and the attributes of all running protocols. The
+// record is only re-signed, with an increased sequence number, when any of
+// its entries changed. The record is served to other nodes by discovery.
+func (srv *Server) NodeRecord() (*enr.Record, error) {
+	if srv.PrivateKey == nil {
+		return nil, errors.New("node key not set")
+	}
+	node := srv.Self()
+
+	entries := []enr.Entry{enr.TCP(node.TCP), enr.UDP(node.UDP)}
+	switch {
+	case node.IP == nil || node.IP.IsUnspecified():
+		// Listening on all interfaces without a known external address,
+		// leave it to the remote side to fill in the endpoint.
+	case node.IP.To4() != nil:
+		entries = append(entries, enr.IP4(node.IP.To4()))
+	default:
+		entries = append(entries, enr.IP6(node.IP))
+	}
+	for _, proto := range srv.Protocols {
+		if proto.Attributes != nil {
+			entries = append(entries, proto.Attributes()...)
+		}
+	}
+	content, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.recordLock.Lock()
+	defer srv.recordLock.Unlock()
+
+	if srv.record != nil && bytes.Equal(content, srv.recordContent) {
+		return srv.record, nil
+	}
+	r := new(enr.Record)
+	if srv.record != nil {
+		r.SetSeq(srv.record.Seq())
+	} else {
+		r.SetSeq(srv.loadRecordSeq())
+	}
+	for _, entry := range entries {
+		r.Set(entry)
+	}
+	if err := r.Sign(srv.PrivateKey); err != nil {
+		return nil, err
+	}
+	if srv.NodeRecordSeq != "" {
+		if err := ioutil.WriteFile(srv.NodeRecordSeq, []byte(strconv.FormatUint(r.Seq(), 10)), 0600); err != nil {
+			log.Warn("Failed to persist node record sequence", "err", err)
+		}
+	}
+	srv.record, srv.recordContent = r, content
+	return r, nil
+}
+
+// loadRecordSeq returns the sequence number of the last record signed by a
+// previous run, or zero if there is none.
+func (srv *Server) loadRecordSeq() uint64 {
+	if srv.NodeRecordSeq == "" {
+		return 0
+	}
+	data, err := ioutil.ReadFile(srv.NodeRecordSeq)
+	if err != nil {
+		return 0
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		log.Warn("Invalid node record sequence", "file", srv.NodeRecordSeq, "err", err)
+		return 0
+	}
+	return seq
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
@@ -19,8 +19,11 @@ package p2p
 import (
 	"crypto/ecdsa"
 	"errors"
+	"io/ioutil"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -366,6 +369,63 @@ func TestServerAtCap(t *testing.T) {
 
 }
 
+// This test checks that priority peers are accepted when the server is at
+// capacity and that they don't occupy any of the MaxPeers slots.
+func TestServerPriorityPeers(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id discover.NodeID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(id, fd)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, id: id, cont: make(chan error)}
+	}
+	// Add a priority node while there is still room, it must not count.
+	priorityID := randomID()
+	srv.AddPriorityPeer(&discover.Node{ID: priorityID})
+	c := newconn(priorityID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for priority conn @posthandshake:", err)
+	}
+	if !c.is(priorityConn) {
+		t.Fatal("Server did not set priority flag")
+	}
+	if err := srv.checkpoint(c, srv.addpeer); err != nil {
+		t.Fatal("could not add priority conn:", err)
+	}
+	// Fill up the regular slots.
+	for i := 0; i < 10; i++ {
+		if err := srv.checkpoint(newconn(randomID()), srv.addpeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Error("wrong error for insert:", err)
+	}
+	// A second priority node is still welcome.
+	otherID := randomID()
+	srv.AddPriorityPeer(&discover.Node{ID: otherID})
+	if err := srv.checkpoint(newconn(otherID), srv.posthandshake); err != nil {
+		t.Error("unexpected error for priority conn @posthandshake:", err)
+	}
+	// Revoking the priority demotes the live connection.
+	srv.RemovePriorityPeer(&discover.Node{ID: priorityID})
+	for _, p := range srv.Peers() {
+		if p.ID() == priorityID && p.Priority() {
+			t.Error("priority flag not cleared")
+		}
+	}
+}
+
 func TestServerSetupConn(t *testing.T) {
 	id := randomID()
 	srvkey := newkey()
@@ -488,6 +548,37 @@ func (c *setupTransport) ReadMsg() (Msg, error) {
 	panic("ReadMsg called on setupTransport")
 }
 
+// Tests that the node record sequence survives restarts, so records signed
+// by a restarted node supersede the ones cached by other nodes.
+func TestServerNodeRecordSeq(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-nodeseq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := Config{PrivateKey: newkey(), NodeRecordSeq: filepath.Join(dir, "nodeseq")}
+	for want := uint64(1); want <= 3; want++ {
+		srv := &Server{Config: config}
+		r, err := srv.NodeRecord()
+		if err != nil {
+			t.Fatalf("run %d: failed to sign record: %v", want, err)
+		}
+		if r.Seq() != want {
+			t.Fatalf("run %d: record seq mismatch: have %d, want %d", want, r.Seq(), want)
+		}
+		// unchanged records are not signed again
+		if r, _ := srv.NodeRecord(); r.Seq() != want {
+			t.Fatalf("run %d: unchanged record re-signed with seq %d", want, r.Seq())
+		}
+	}
+	// without a stored sequence the records start over
+	srv := &Server{Config: Config{PrivateKey: config.PrivateKey}}
+	if r, _ := srv.NodeRecord(); r.Seq() != 1 {
+		t.Fatalf("ephemeral record seq mismatch: have %d, want 1", r.Seq())
+	}
+}
+
 func newkey() *ecdsa.PrivateKey {
 	key, err := crypto.GenerateKey()
 	if err != nil {
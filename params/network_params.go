@@ -16,6 +16,12 @@
 
 package params
 
+import (
+	"fmt"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+)
+
 // These are network parameters that need to be constant between clients, but
 // aren't necesarilly consensus related.
 
@@ -24,3 +30,34 @@ const (
 	// contains.
 	BloomBitsBlocks uint64 = 4096
 )
+
+// TrustedCheckpoint represents a set of post-processed trie roots (CHT and
+// BloomTrie) associated with the appropriate section index and head hash. It is
+// used to start light syncing from this checkpoint and avoid downloading the
+// entire header chain while still being able to securely access old
+// headers/logs. The section index is the LES/2 one, of 32768 blocks.
+type TrustedCheckpoint struct {
+	Name          string
+	SectionIndex  uint64
+	SectionHead   common.Hash
+	CHTRoot       common.Hash
+	BloomTrieRoot common.Hash
+}
+
+// Empty returns whether the checkpoint is yet to be filled in.
+func (c *TrustedCheckpoint) Empty() bool {
+	return c.SectionHead == (common.Hash{}) || c.CHTRoot == (common.Hash{}) || c.BloomTrieRoot == (common.Hash{})
+}
+
+// String returns the checkpoint in the <index>:<head>:<cht>:<bloomtrie> form
+// accepted by the --les.checkpoint flag.
+func (c *TrustedCheckpoint) String() string {
+	return fmt.Sprintf("%d:%x:%x:%x", c.SectionIndex, c.SectionHead, c.CHTRoot, c.BloomTrieRoot)
+}
+
+// TrustedCheckpoints associates each known checkpoint with the genesis hash of
+// the chain it belongs to. No network ships one yet: the entries are printed
+// by `smc checkpoint` on a synced full node serving light clients, and none
+// has been run against mainnet, testnet or devnet at the time of writing.
+// Until then light clients sync from genesis unless --les.checkpoint is given.
+var TrustedCheckpoints = map[common.Hash]*TrustedCheckpoint{}
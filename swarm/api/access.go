@@ -0,0 +1,325 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/common/hexutil"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/swarm/storage"
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+Access control protects a manifest so that only the parties it is granted to
+can open it.
+
+The protected reference is wrapped in an access manifest, the root entry of
+which holds the reference encrypted with an access key, along with what is
+needed to recover the key:
+
+- "pass": the access key is derived from a passphrase with scrypt, a short
+  check of the key tells a wrong passphrase apart.
+
+- "pk": the access key is random and granted to a list of public keys. The
+  publisher key is an ephemeral key stored in the entry, for each grantee a
+  session key is derived from the ECDH shared secret of the publisher and the
+  grantee keys. The grant of a grantee is keccak256(session key || 0) to find
+  it, followed by the access key encrypted with keccak256(session key || 1).
+*/
+
+const (
+	AccessTypePass = "pass"
+	AccessTypePK   = "pk"
+
+	// AccessContentType is the content type of the root entry of access
+	// manifests
+	AccessContentType = "application/bzz-access+json"
+
+	accessKeyLength   = 32
+	accessCheckLength = 4
+
+	maxAccessKdfs      = 2   // passphrase keys derived at the same time, each one takes 32MB
+	accessKeyCacheSize = 256 // access keys of the right passphrases kept around
+)
+
+var (
+	ErrAccessPassword   = errors.New("access password missing or wrong")
+	ErrAccessNotGranted = errors.New("access not granted to the node key")
+)
+
+// KdfParams are the scrypt parameters deriving the access key of a passphrase.
+type KdfParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// DefaultKdfParams are cheaper than the keystore ones, the key is derived
+// again by each request for protected content.
+var DefaultKdfParams = &KdfParams{N: 1 << 15, R: 8, P: 1}
+
+// AccessEntry describes how to recover the access key of an access manifest.
+type AccessEntry struct {
+	Type      string          `json:"type"`
+	Salt      hexutil.Bytes   `json:"salt"`
+	KdfParams *KdfParams      `json:"kdf_params,omitempty"`
+	Check     hexutil.Bytes   `json:"check,omitempty"`
+	Publisher hexutil.Bytes   `json:"publisher,omitempty"`
+	Grants    []hexutil.Bytes `json:"grants,omitempty"`
+}
+
+// NewPassAccess creates the access entry and the access key of content
+// protected by the given passphrase.
+func NewPassAccess(password string) (*AccessEntry, []byte, error) {
+	if password == "" {
+		return nil, nil, errors.New("empty access password")
+	}
+	entry := &AccessEntry{
+		Type:      AccessTypePass,
+		Salt:      make([]byte, 32),
+		KdfParams: DefaultKdfParams,
+	}
+	if _, err := rand.Read(entry.Salt); err != nil {
+		return nil, nil, err
+	}
+	key, err := entry.passKey(password)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.Check = accessCheck(key)
+	return entry, key, nil
+}
+
+// NewPKAccess creates the access entry and the access key of content granted
+// to the given public keys.
+func NewPKAccess(grantees []*ecdsa.PublicKey) (*AccessEntry, []byte, error) {
+	if len(grantees) == 0 {
+		return nil, nil, errors.New("no access grantees")
+	}
+	publisher, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := &AccessEntry{
+		Type:      AccessTypePK,
+		Salt:      make([]byte, 32),
+		Publisher: crypto.CompressPubkey(&publisher.PublicKey),
+	}
+	key := make([]byte, accessKeyLength)
+	if _, err := rand.Read(entry.Salt); err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	for _, grantee := range grantees {
+		session := entry.sessionKey(publisher, grantee)
+		grant := append(crypto.Keccak256(session, []byte{0}), accessXOR(key, crypto.Keccak256(session, []byte{1}))...)
+		entry.Grants = append(entry.Grants, grant)
+	}
+	// the order of the grants doesn't tell the grantees apart
+	sort.Slice(entry.Grants, func(i, j int) bool {
+		return bytes.Compare(entry.Grants[i], entry.Grants[j]) < 0
+	})
+	return entry, key, nil
+}
+
+// AccessKey recovers the access key with the passphrase for "pass" entries or
+// with the private key of a grantee for "pk" entries.
+func (e *AccessEntry) AccessKey(password string, prvKey *ecdsa.PrivateKey) ([]byte, error) {
+	switch e.Type {
+	case AccessTypePass:
+		if password == "" {
+			return nil, ErrAccessPassword
+		}
+		key, err := e.passKey(password)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(accessCheck(key), e.Check) {
+			return nil, ErrAccessPassword
+		}
+		return key, nil
+
+	case AccessTypePK:
+		if prvKey == nil {
+			return nil, ErrAccessNotGranted
+		}
+		publisher, err := crypto.DecompressPubkey(e.Publisher)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access publisher key: %s", err)
+		}
+		session := e.sessionKey(prvKey, publisher)
+		lookup := crypto.Keccak256(session, []byte{0})
+		for _, grant := range e.Grants {
+			if len(grant) == 2*accessKeyLength && bytes.Equal(grant[:accessKeyLength], lookup) {
+				return accessXOR(grant[accessKeyLength:], crypto.Keccak256(session, []byte{1})), nil
+			}
+		}
+		return nil, ErrAccessNotGranted
+
+	default:
+		return nil, fmt.Errorf("unknown access type %q", e.Type)
+	}
+}
+
+func (e *AccessEntry) passKey(password string) ([]byte, error) {
+	params := e.KdfParams
+	if params == nil {
+		return nil, errors.New("missing access kdf params")
+	}
+	// the params come from the manifest, don't let it make the node derive
+	// keys costlier than the default ones
+	if params.N < 2 || params.N > DefaultKdfParams.N || params.R < 1 || params.R > DefaultKdfParams.R || params.P < 1 || params.P > DefaultKdfParams.P {
+		return nil, fmt.Errorf("access kdf params out of bounds: n=%d r=%d p=%d", params.N, params.R, params.P)
+	}
+	return scrypt.Key([]byte(password), e.Salt, params.N, params.R, params.P, accessKeyLength)
+}
+
+// sessionKey derives the key shared by the owners of prvKey and pubKey.
+func (e *AccessEntry) sessionKey(prvKey *ecdsa.PrivateKey, pubKey *ecdsa.PublicKey) []byte {
+	x, _ := crypto.S256().ScalarMult(pubKey.X, pubKey.Y, prvKey.D.Bytes())
+	return crypto.Keccak256(common.LeftPadBytes(x.Bytes(), 32), e.Salt)
+}
+
+func accessCheck(key []byte) []byte {
+	return crypto.Keccak256(key)[:accessCheckLength]
+}
+
+// accessXOR encrypts (or decrypts) data with a keccak256 keystream of the key.
+func accessXOR(data, key []byte) []byte {
+	out := make([]byte, len(data))
+	var stream []byte
+	for i := range data {
+		if i%32 == 0 {
+			var counter [4]byte
+			binary.BigEndian.PutUint32(counter[:], uint32(i/32))
+			stream = crypto.Keccak256(key, counter[:])
+		}
+		out[i] = data[i] ^ stream[i%32]
+	}
+	return out
+}
+
+// NewAccessManifest returns the access manifest wrapping the reference with
+// the given access entry and key.
+func NewAccessManifest(ref storage.Key, access *AccessEntry, key []byte) *Manifest {
+	return &Manifest{
+		Entries: []ManifestEntry{{
+			Hash:        common.Bytes2Hex(accessXOR(ref, key)),
+			ContentType: AccessContentType,
+			Access:      access,
+		}},
+	}
+}
+
+// ManifestRef is a reference resolved by ResolveManifestAccess along with the
+// manifest loaded from it, if any, so that serving it doesn't load it again
+type ManifestRef struct {
+	Key  storage.Key
+	trie *manifestTrie
+}
+
+// ResolveAccess returns the reference wrapped by the access manifest with
+// the given key, unlocked with the password or, if nodeKey is set, the node
+// key. Other keys are returned as is.
+func (self *Api) ResolveAccess(key storage.Key, password string, nodeKey bool) (storage.Key, error) {
+	ref, err := self.ResolveManifestAccess(key, password, nodeKey)
+	if err != nil {
+		return nil, err
+	}
+	return ref.Key, nil
+}
+
+// ResolveManifestAccess is ResolveAccess keeping the manifest it loads to
+// check for access control when the key isn't protected.
+func (self *Api) ResolveManifestAccess(key storage.Key, password string, nodeKey bool) (*ManifestRef, error) {
+	trie, err := loadManifest(self.dpa, key, nil)
+	if err != nil {
+		// not a manifest, reading it fails later if it was expected to be one
+		return &ManifestRef{Key: key}, nil
+	}
+	entry := trie.entries[256]
+	if entry == nil || entry.Access == nil {
+		return &ManifestRef{Key: key, trie: trie}, nil
+	}
+	var prvKey *ecdsa.PrivateKey
+	if nodeKey {
+		prvKey = self.prvKey
+	}
+	accessKey, err := self.accessKey(entry.Access, password, prvKey)
+	if err != nil {
+		return nil, err
+	}
+	return &ManifestRef{Key: accessXOR(common.Hex2Bytes(entry.Hash), accessKey)}, nil
+}
+
+// accessKey is AccessEntry.AccessKey bounding the cost of passphrases: the
+// keys of the right ones are cached and only a few are derived at a time, so
+// that requests with made up passwords can't exhaust the node.
+func (self *Api) accessKey(entry *AccessEntry, password string, prvKey *ecdsa.PrivateKey) ([]byte, error) {
+	if entry.Type != AccessTypePass || password == "" || entry.KdfParams == nil {
+		return entry.AccessKey(password, prvKey)
+	}
+	params := entry.KdfParams
+	id := crypto.Keccak256Hash([]byte(fmt.Sprintf("%d/%d/%d/%x/", params.N, params.R, params.P, entry.Salt)), []byte(password))
+	if key, ok := self.passKeys.Get(id); ok {
+		return key.([]byte), nil
+	}
+	self.kdfSlots <- struct{}{}
+	key, err := entry.AccessKey(password, prvKey)
+	<-self.kdfSlots
+	if err != nil {
+		return nil, err
+	}
+	self.passKeys.Add(id, key)
+	return key, nil
+}
+
+// ResolveAccessHash is ResolveAccess for a manifest hash or name, optionally
+// followed by a path as the swarm fuse mounts take it. The mounts are local,
+// so the node key is used.
+func (self *Api) ResolveAccessHash(mhash, password string) (string, error) {
+	uri, err := Parse("bzz:/" + mhash)
+	if err != nil {
+		return "", err
+	}
+	key, err := self.Resolve(uri)
+	if err != nil {
+		return "", err
+	}
+	ref, err := self.ResolveAccess(key, password, true)
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(ref, key) {
+		return mhash, nil
+	}
+	if uri.Path == "" {
+		return ref.Hex(), nil
+	}
+	return ref.Hex() + "/" + uri.Path, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/swarm/storage"
+)
+
+func storeAccessManifest(t *testing.T, api *Api, ref storage.Key, access *AccessEntry, key []byte) storage.Key {
+	data, err := json.Marshal(NewAccessManifest(ref, access, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := &sync.WaitGroup{}
+	manifest, err := api.Store(bytes.NewReader(data), int64(len(data)), wg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	return manifest
+}
+
+func TestAccessPass(t *testing.T) {
+	testApi(t, func(api *Api) {
+		// encrypted references are longer
+		ref := make(storage.Key, 64)
+		rand.Read(ref)
+		access, key, err := NewPassAccess("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest := storeAccessManifest(t, api, ref, access, key)
+
+		resolved, err := api.ResolveAccess(manifest, "secret", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(resolved, ref) {
+			t.Fatalf("expected reference %x, got %x", ref, resolved)
+		}
+		for _, password := range []string{"", "wrong"} {
+			if _, err := api.ResolveAccess(manifest, password, true); err != ErrAccessPassword {
+				t.Fatalf("password %q: expected %v, got %v", password, ErrAccessPassword, err)
+			}
+		}
+
+		// manifests asking for costlier keys than the default are rejected
+		for _, params := range []*KdfParams{
+			{N: 1 << 20, R: 8, P: 1},
+			{N: 1 << 15, R: 1 << 10, P: 1},
+			{N: 1 << 15, R: 8, P: 1 << 10},
+			{N: 1 << 15, R: 0, P: 0},
+		} {
+			costly := *access
+			costly.KdfParams = params
+			if _, err := api.ResolveAccess(storeAccessManifest(t, api, ref, &costly, key), "secret", true); err == nil {
+				t.Fatalf("kdf params %+v: expected an error", params)
+			}
+		}
+
+		// other manifests are not changed, and are not loaded again to serve them
+		plain := storeAccessManifest(t, api, ref, nil, key)
+		if resolved, err := api.ResolveAccess(plain, "", true); err != nil || !bytes.Equal(resolved, plain) {
+			t.Fatalf("unprotected manifest resolved to %x (%v)", resolved, err)
+		}
+		if resolved, err := api.ResolveManifestAccess(plain, "", true); err != nil || resolved.trie == nil {
+			t.Fatalf("unprotected manifest not kept (%v)", err)
+		}
+	})
+}
+
+// Tests that only the keys of right passphrases are cached, and that cached
+// keys are served while the key derivations are all taken.
+func TestAccessPassCache(t *testing.T) {
+	testApi(t, func(api *Api) {
+		ref := make(storage.Key, 64)
+		rand.Read(ref)
+		access, key, err := NewPassAccess("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest := storeAccessManifest(t, api, ref, access, key)
+
+		if _, err := api.ResolveAccess(manifest, "wrong", true); err != ErrAccessPassword {
+			t.Fatalf("expected %v, got %v", ErrAccessPassword, err)
+		}
+		if api.passKeys.Len() != 0 {
+			t.Fatalf("key of a wrong passphrase cached")
+		}
+		if _, err := api.ResolveAccess(manifest, "secret", true); err != nil {
+			t.Fatal(err)
+		}
+		if api.passKeys.Len() != 1 {
+			t.Fatalf("expected 1 cached key, got %d", api.passKeys.Len())
+		}
+
+		for i := 0; i < maxAccessKdfs; i++ {
+			api.kdfSlots <- struct{}{}
+		}
+		done := make(chan error, 1)
+		go func() {
+			resolved, err := api.ResolveAccess(manifest, "secret", true)
+			if err == nil && !bytes.Equal(resolved, ref) {
+				err = fmt.Errorf("expected reference %x, got %x", ref, resolved)
+			}
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("cached key waited for a key derivation")
+		}
+		for i := 0; i < maxAccessKdfs; i++ {
+			<-api.kdfSlots
+		}
+	})
+}
+
+func TestAccessPK(t *testing.T) {
+	testApi(t, func(api *Api) {
+		var keys []*ecdsa.PrivateKey
+		var grantees []*ecdsa.PublicKey
+		for i := 0; i < 3; i++ {
+			key, _ := crypto.GenerateKey()
+			keys = append(keys, key)
+			grantees = append(grantees, &key.PublicKey)
+		}
+		ref := make(storage.Key, 64)
+		rand.Read(ref)
+		access, key, err := NewPKAccess(grantees)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest := storeAccessManifest(t, api, ref, access, key)
+
+		for i, key := range keys {
+			api.prvKey = key
+			resolved, err := api.ResolveAccess(manifest, "", true)
+			if err != nil {
+				t.Fatalf("grantee %d: %v", i, err)
+			}
+			if !bytes.Equal(resolved, ref) {
+				t.Fatalf("grantee %d: expected reference %x, got %x", i, ref, resolved)
+			}
+		}
+		if _, err := api.ResolveAccess(manifest, "", false); err != ErrAccessNotGranted {
+			t.Fatalf("expected %v without the node key, got %v", ErrAccessNotGranted, err)
+		}
+
+		api.prvKey, _ = crypto.GenerateKey()
+		if _, err := api.ResolveAccess(manifest, "", true); err != ErrAccessNotGranted {
+			t.Fatalf("expected %v for another key, got %v", ErrAccessNotGranted, err)
+		}
+		api.prvKey = nil
+		if _, err := api.ResolveAccess(manifest, "", true); err != ErrAccessNotGranted {
+			t.Fatalf("expected %v without a node key, got %v", ErrAccessNotGranted, err)
+		}
+	})
+}
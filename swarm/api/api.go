@@ -17,6 +17,8 @@
 package api
 
 import (
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -32,6 +34,7 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/swarm/storage"
+	"github.com/hashicorp/golang-lru"
 )
 
 var hashMatcher = regexp.MustCompile("^[0-9A-Fa-f]{64}")
@@ -46,16 +49,25 @@ on top of the dpa
 it is the public interface of the dpa which is included in the ethereum stack
 */
 type Api struct {
-	dpa *storage.DPA
-	dns Resolver
+	dpa      *storage.DPA
+	dns      Resolver
+	resource *storage.ResourceHandler // mutable resources, nil if not supported
+	prvKey   *ecdsa.PrivateKey        // node key opening the content granted to it
+
+	passKeys *lru.Cache    // access keys of the passphrases seen, by hash of the kdf input
+	kdfSlots chan struct{} // bounds the access keys derived at the same time
 }
 
 //the api constructor initialises
-func NewApi(dpa *storage.DPA, dns Resolver) (self *Api) {
+func NewApi(dpa *storage.DPA, dns Resolver, resource *storage.ResourceHandler, prvKey *ecdsa.PrivateKey) (self *Api) {
 	self = &Api{
-		dpa: dpa,
-		dns: dns,
+		dpa:      dpa,
+		dns:      dns,
+		resource: resource,
+		prvKey:   prvKey,
+		kdfSlots: make(chan struct{}, maxAccessKdfs),
 	}
+	self.passKeys, _ = lru.New(accessKeyCacheSize)
 	return
 }
 
@@ -75,6 +87,47 @@ func (self *Api) Store(data io.Reader, size int64, wg *sync.WaitGroup) (key stor
 	return self.dpa.Store(data, size, wg, nil)
 }
 
+// StoreEncrypted stores the data encrypted with a new key, the returned key
+// embeds the encryption key.
+func (self *Api) StoreEncrypted(data io.Reader, size int64, wg *sync.WaitGroup) (key storage.Key, err error) {
+	return self.dpa.StoreEncrypted(data, size, wg, nil)
+}
+
+var (
+	errNoResourceHandler = errors.New("mutable resources not supported")
+	errResourceDepth     = errors.New("too many nested mutable resources")
+)
+
+// maxResourceDepth is the number of mutable resources a path may be resolved
+// through, resources referring to each other would otherwise never resolve
+const maxResourceDepth = 8
+
+// ResourceCreate creates a mutable resource owned by the node with data as its
+// first version and returns the resource address
+func (self *Api) ResourceCreate(name string, data []byte) (storage.Key, error) {
+	if self.resource == nil {
+		return nil, errNoResourceHandler
+	}
+	return self.resource.Create(name, data)
+}
+
+// ResourceUpdate publishes data as the next version of the resource and
+// returns the new version number
+func (self *Api) ResourceUpdate(addr storage.Key, data []byte) (uint64, error) {
+	if self.resource == nil {
+		return 0, errNoResourceHandler
+	}
+	return self.resource.Update(addr, data)
+}
+
+// ResourceLookup retrieves a version of the resource, version 0 is the latest
+func (self *Api) ResourceLookup(addr storage.Key, version uint64) (*storage.ResourceUpdate, error) {
+	if self.resource == nil {
+		return nil, errNoResourceHandler
+	}
+	return self.resource.Lookup(addr, version)
+}
+
 type ErrResolve error
 
 // DNS Resolver
@@ -130,16 +183,49 @@ func (self *Api) Put(content, contentType string) (storage.Key, error) {
 // to resolve basePath to content using dpa retrieve
 // it returns a section reader, mimeType, status and an error
 func (self *Api) Get(key storage.Key, path string) (reader storage.LazySectionReader, mimeType string, status int, err error) {
-	trie, err := loadManifest(self.dpa, key, nil)
-	if err != nil {
-		status = http.StatusNotFound
-		log.Warn(fmt.Sprintf("loadManifestTrie error: %v", err))
-		return
+	return self.get(key, nil, path, 0)
+}
+
+// GetManifest is Get for a manifest reference resolved by
+// ResolveManifestAccess, reusing the manifest loaded by it
+func (self *Api) GetManifest(ref *ManifestRef, path string) (reader storage.LazySectionReader, mimeType string, status int, err error) {
+	return self.get(ref.Key, ref.trie, path, 0)
+}
+
+// get resolves path under the manifest at key, loaded as trie unless nil,
+// depth being the number of mutable resources followed to reach that manifest
+func (self *Api) get(key storage.Key, trie *manifestTrie, path string, depth int) (reader storage.LazySectionReader, mimeType string, status int, err error) {
+	if trie == nil {
+		trie, err = loadManifest(self.dpa, key, nil)
+		if err != nil {
+			status = http.StatusNotFound
+			log.Warn(fmt.Sprintf("loadManifestTrie error: %v", err))
+			return
+		}
 	}
 
 	log.Trace(fmt.Sprintf("getEntry(%s)", path))
 
-	entry, _ := trie.getEntry(path)
+	entry, fullpath := trie.getEntry(path)
+
+	if entry != nil && entry.ContentType == ResourceContentType {
+		// the entry points to a mutable resource, the latest version of which
+		// holds the key of the manifest serving the rest of the path
+		if depth >= maxResourceDepth {
+			status = http.StatusLoopDetected
+			err = errResourceDepth
+			log.Warn(fmt.Sprintf("resource lookup error: %v", err))
+			return
+		}
+		var update *storage.ResourceUpdate
+		update, err = self.ResourceLookup(common.Hex2Bytes(entry.Hash), 0)
+		if err != nil {
+			status = http.StatusNotFound
+			log.Warn(fmt.Sprintf("resource lookup error: %v", err))
+			return
+		}
+		return self.get(update.Data, nil, RegularSlashes(path)[len(fullpath):], depth+1)
+	}
 
 	if entry != nil {
 		key = common.Hex2Bytes(entry.Hash)
@@ -21,10 +21,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/swarm/storage"
 )
@@ -40,7 +44,7 @@ func testApi(t *testing.T, f func(*Api)) {
 	if err != nil {
 		return
 	}
-	api := NewApi(dpa, nil)
+	api := NewApi(dpa, nil, nil, nil)
 	dpa.Start()
 	f(api)
 	dpa.Stop()
@@ -119,6 +123,37 @@ func TestApiPut(t *testing.T) {
 	})
 }
 
+// TestApiGetResourceLoop tests that a mutable resource whose latest version
+// is a manifest pointing back to the resource fails to resolve.
+func TestApiGetResourceLoop(t *testing.T) {
+	testApi(t, func(api *Api) {
+		prvKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		api.resource = storage.NewResourceHandler(api.dpa, api.dpa, prvKey)
+
+		addr, err := api.ResourceCreate("loop", []byte("first"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest := fmt.Sprintf(`{"entries":[{"hash":"%s","contentType":"%s"}]}`, addr, ResourceContentType)
+		wg := &sync.WaitGroup{}
+		key, err := api.Store(strings.NewReader(manifest), int64(len(manifest)), wg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if _, err := api.ResourceUpdate(addr, key); err != nil {
+			t.Fatal(err)
+		}
+		_, _, status, err := api.Get(key, "")
+		if err != errResourceDepth || status != http.StatusLoopDetected {
+			t.Fatalf("expected status %d and error %q, got %d and %v", http.StatusLoopDetected, errResourceDepth, status, err)
+		}
+	})
+}
+
 // testResolver implements the Resolver interface and either returns the given
 // hash if it is set, or returns a "name not found" error
 type testResolver struct {
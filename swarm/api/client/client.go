@@ -19,6 +19,8 @@ package client
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -50,14 +52,41 @@ func NewClient(gateway string) *Client {
 // Client wraps interaction with a swarm HTTP gateway.
 type Client struct {
 	Gateway string
+
+	// Password is sent with the download requests to open manifests
+	// protected by a passphrase
+	Password string
 }
 
-// UploadRaw uploads raw data to swarm and returns the resulting hash
-func (c *Client) UploadRaw(r io.Reader, size int64) (string, error) {
+// get sends a GET request to the given URI
+func (c *Client) get(uri string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setPassword(req)
+	return http.DefaultClient.Do(req)
+}
+
+// setPassword adds the access password to the request if there is one
+func (c *Client) setPassword(req *http.Request) {
+	if c.Password != "" {
+		req.SetBasicAuth("", c.Password)
+	}
+}
+
+// UploadRaw uploads raw data to swarm and returns the resulting hash. If
+// toEncrypt is set the data is stored encrypted and the returned hash
+// includes the decryption key
+func (c *Client) UploadRaw(r io.Reader, size int64, toEncrypt bool) (string, error) {
 	if size <= 0 {
 		return "", errors.New("data size must be greater than zero")
 	}
-	req, err := http.NewRequest("POST", c.Gateway+"/bzz-raw:/", r)
+	addr := ""
+	if toEncrypt {
+		addr = "encrypt"
+	}
+	req, err := http.NewRequest("POST", c.Gateway+"/bzz-raw:/"+addr, r)
 	if err != nil {
 		return "", err
 	}
@@ -136,7 +165,7 @@ func (c *Client) Upload(file *File, manifest string) (string, error) {
 // the given hash (i.e. it gets bzz:/<hash>/<path>)
 func (c *Client) Download(hash, path string) (*File, error) {
 	uri := c.Gateway + "/bzz:/" + hash + "/" + path
-	res, err := http.DefaultClient.Get(uri)
+	res, err := c.get(uri)
 	if err != nil {
 		return nil, err
 	}
@@ -185,6 +214,7 @@ func (c *Client) DownloadDirectory(hash, path, destDir string) error {
 		return err
 	}
 	req.Header.Set("Accept", "application/x-tar")
+	c.setPassword(req)
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
@@ -228,13 +258,90 @@ func (c *Client) DownloadDirectory(hash, path, destDir string) error {
 	}
 }
 
+// DownloadArchive downloads the files contained in a swarm manifest under the
+// given path as a single archive stream in the given format, either "tar" or
+// "zip". The files keep their path in the manifest, the caller must close the
+// returned reader
+func (c *Client) DownloadArchive(hash, path, format string) (io.ReadCloser, error) {
+	uri := c.Gateway + "/bzz:/" + hash + "/" + path + "?archive=" + format
+	res, err := c.get(uri)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return res.Body, nil
+}
+
+// CreatePassAccess uploads an access manifest protecting the manifest with
+// the given hash with a passphrase and returns the access manifest hash
+func (c *Client) CreatePassAccess(hash, password string) (string, error) {
+	access, key, err := api.NewPassAccess(password)
+	if err != nil {
+		return "", err
+	}
+	return c.uploadAccess(hash, access, key)
+}
+
+// CreatePKAccess uploads an access manifest granting the manifest with the
+// given hash to the given public keys and returns the access manifest hash
+func (c *Client) CreatePKAccess(hash string, grantees []*ecdsa.PublicKey) (string, error) {
+	access, key, err := api.NewPKAccess(grantees)
+	if err != nil {
+		return "", err
+	}
+	return c.uploadAccess(hash, access, key)
+}
+
+func (c *Client) uploadAccess(hash string, access *api.AccessEntry, key []byte) (string, error) {
+	ref, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", fmt.Errorf("invalid manifest hash: %q", hash)
+	}
+	// the chunks of unencrypted content can be read by any node storing
+	// them, the reference must include the decryption key
+	if len(ref) != 64 {
+		return "", fmt.Errorf("manifest %q is not encrypted, upload it with --encrypt", hash)
+	}
+	return c.UploadManifest(api.NewAccessManifest(ref, access, key))
+}
+
+// AddResource adds an entry at the given path to the manifest (or to a new
+// manifest if the manifest argument is empty) pointing to the mutable resource
+// at addr, returning the resulting manifest hash (the latest version of the
+// resource then serves bzz:/<hash>/<path>)
+func (c *Client) AddResource(manifest, path, addr string) (string, error) {
+	if manifest == "" {
+		var err error
+		if manifest, err = c.UploadManifest(&api.Manifest{}); err != nil {
+			return "", err
+		}
+	}
+	uri := c.Gateway + "/bzz:/" + manifest + "/" + path + "?resource=" + addr
+	res, err := http.Post(uri, "", nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // UploadManifest uploads the given manifest to swarm
 func (c *Client) UploadManifest(m *api.Manifest) (string, error) {
 	data, err := json.Marshal(m)
 	if err != nil {
 		return "", err
 	}
-	return c.UploadRaw(bytes.NewReader(data), int64(len(data)))
+	return c.UploadRaw(bytes.NewReader(data), int64(len(data)), false)
 }
 
 // DownloadManifest downloads a swarm manifest
@@ -269,7 +376,7 @@ func (c *Client) DownloadManifest(hash string) (*api.Manifest, error) {
 //
 // where entries ending with "/" are common prefixes.
 func (c *Client) List(hash, prefix string) (*api.ManifestList, error) {
-	res, err := http.DefaultClient.Get(c.Gateway + "/bzz-list:/" + hash + "/" + prefix)
+	res, err := c.get(c.Gateway + "/bzz-list:/" + hash + "/" + prefix)
 	if err != nil {
 		return nil, err
 	}
@@ -284,6 +391,56 @@ func (c *Client) List(hash, prefix string) (*api.ManifestList, error) {
 	return &list, nil
 }
 
+// Pin pins the content with the given hash in the local store of the node so
+// that it is not garbage collected. Unless raw is set the content is a
+// manifest and everything it references is pinned as well
+func (c *Client) Pin(hash string, raw bool) error {
+	return c.pinRequest("POST", hash, raw)
+}
+
+// Unpin releases a pin of the content with the given hash, along with the
+// content pinned with it
+func (c *Client) Unpin(hash string) error {
+	return c.pinRequest("DELETE", hash, false)
+}
+
+func (c *Client) pinRequest(method, hash string, raw bool) error {
+	uri := c.Gateway + "/bzz-pin:/" + hash
+	if raw {
+		uri += "?raw=true"
+	}
+	req, err := http.NewRequest(method, uri, nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return nil
+}
+
+// ListPins lists the content pinned in the local store of the node
+func (c *Client) ListPins() (*api.PinList, error) {
+	res, err := http.DefaultClient.Get(c.Gateway + "/bzz-pin:/")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	var list api.PinList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
 // Uploader uploads files to swarm using a provided UploadFn
 type Uploader interface {
 	Upload(UploadFn) error
@@ -17,20 +17,36 @@
 package client
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/swarm/api"
 	"github.com/MeshBoxFoundation/meshbox/swarm/testutil"
 )
 
 // TestClientUploadDownloadRaw test uploading and downloading raw data to swarm
 func TestClientUploadDownloadRaw(t *testing.T) {
+	testClientUploadDownloadRaw(false, t)
+}
+
+// TestClientUploadDownloadRawEncrypted test uploading and downloading
+// encrypted raw data to swarm
+func TestClientUploadDownloadRawEncrypted(t *testing.T) {
+	testClientUploadDownloadRaw(true, t)
+}
+
+func testClientUploadDownloadRaw(toEncrypt bool, t *testing.T) {
 	srv := testutil.NewTestSwarmServer(t)
 	defer srv.Close()
 
@@ -38,11 +54,20 @@ func TestClientUploadDownloadRaw(t *testing.T) {
 
 	// upload some raw data
 	data := []byte("foo123")
-	hash, err := client.UploadRaw(bytes.NewReader(data), int64(len(data)))
+	hash, err := client.UploadRaw(bytes.NewReader(data), int64(len(data)), toEncrypt)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	// check the hash includes the decryption key of encrypted data
+	hashLen := 64
+	if toEncrypt {
+		hashLen = 128
+	}
+	if len(hash) != hashLen {
+		t.Fatalf("expected hash of length %d, got %q", hashLen, hash)
+	}
+
 	// check we can download the same data
 	res, err := client.DownloadRaw(hash)
 	if err != nil {
@@ -122,6 +147,17 @@ func TestClientUploadDownloadFiles(t *testing.T) {
 	// check both files have the other data
 	checkDownload(newHash, "", otherData)
 	checkDownload(newHash, "some/other/path", otherData)
+
+	// upload a file to a new encrypted manifest and add another one to it
+	encryptedHash := upload("encrypt", "", rootData)
+	if len(encryptedHash) != 128 {
+		t.Fatalf("expected encrypted manifest hash to include the key, got %q", encryptedHash)
+	}
+	encryptedHash = upload(encryptedHash, "some/other/path", otherData)
+
+	// check we can download both files from the encrypted manifest
+	checkDownload(encryptedHash, "", rootData)
+	checkDownload(encryptedHash, "some/other/path", otherData)
 }
 
 var testDirFiles = []string{
@@ -215,6 +251,245 @@ func TestClientUploadDownloadDirectory(t *testing.T) {
 	}
 }
 
+// TestClientDownloadArchive tests downloading the files under a path of a
+// swarm manifest as tar and zip archives
+func TestClientDownloadArchive(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	// zip stores the modification times with a 2 second precision
+	modTime := time.Date(2018, 5, 4, 3, 2, 0, 0, time.UTC)
+	for _, file := range testDirFiles {
+		if err := os.Chtimes(filepath.Join(dir, file), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := NewClient(srv.URL)
+	hash, err := client.UploadDirectory(dir, "", "")
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	expected := []string{
+		"dir2/dir3/file6.txt",
+		"dir2/dir4/file7.txt",
+		"dir2/dir4/file8.txt",
+		"dir2/file5.txt",
+	}
+
+	// checkFile checks the content, the content type and the modification time
+	// of an archived file, readTar and readZip return the paths of the files in
+	// the archive
+	checkFile := func(name, contentType string, mtime time.Time, r io.Reader) {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, []byte(name)) {
+			t.Fatalf("expected %s to contain %q, got %q", name, name, data)
+		}
+		if contentType != "text/plain; charset=utf-8" {
+			t.Fatalf("unexpected content type of %s: %q", name, contentType)
+		}
+		if !mtime.Equal(modTime) {
+			t.Fatalf("unexpected modification time of %s: %v", name, mtime)
+		}
+	}
+	readTar := func(r io.Reader) (paths []string) {
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return paths
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			checkFile(hdr.Name, hdr.Xattrs["user.swarm.content-type"], hdr.ModTime, tr)
+			paths = append(paths, hdr.Name)
+		}
+	}
+	readZip := func(r io.Reader) (paths []string) {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range zr.File {
+			fr, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFile(f.Name, f.Comment, f.ModTime(), fr)
+			fr.Close()
+			paths = append(paths, f.Name)
+		}
+		return paths
+	}
+
+	for _, x := range []struct {
+		format string
+		path   string
+		read   func(io.Reader) []string
+	}{
+		{"tar", "dir2/", readTar},
+		{"zip", "dir2", readZip},
+	} {
+		archive, err := client.DownloadArchive(hash, x.path, x.format)
+		if err != nil {
+			t.Fatalf("error downloading %s archive: %s", x.format, err)
+		}
+		paths := x.read(archive)
+		archive.Close()
+		sort.Strings(paths)
+		if !reflect.DeepEqual(paths, expected) {
+			t.Fatalf("expected %s archive to contain %v, got %v", x.format, expected, paths)
+		}
+	}
+
+	// a path without files is not found
+	if _, err := client.DownloadArchive(hash, "dir5/", "zip"); err == nil {
+		t.Fatal("expected an error downloading an archive of a missing path")
+	}
+}
+
+// TestClientPassAccess tests protecting a manifest with a passphrase and
+// downloading from it
+func TestClientPassAccess(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	plain, err := client.UploadDirectory(dir, "", "")
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	if _, err := client.CreatePassAccess(plain, "secret"); err == nil {
+		t.Fatal("expected protecting an unencrypted manifest to fail")
+	}
+	hash, err := client.UploadDirectory(dir, "", "encrypt")
+	if err != nil {
+		t.Fatalf("error uploading encrypted directory: %s", err)
+	}
+	access, err := client.CreatePassAccess(hash, "secret")
+	if err != nil {
+		t.Fatalf("error creating access manifest: %s", err)
+	}
+
+	// browsers are asked for the password
+	res, err := http.Get(srv.URL + "/bzz:/" + access + "/file1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized || res.Header.Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected a password prompt, got %s", res.Status)
+	}
+
+	for _, password := range []string{"", "wrong"} {
+		client.Password = password
+		if _, err := client.Download(access, "file1.txt"); err == nil {
+			t.Fatalf("password %q: expected download to fail", password)
+		}
+	}
+
+	client.Password = "secret"
+	file, err := client.Download(access, "dir1/file3.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "dir1/file3.txt" {
+		t.Fatalf("unexpected file content: %q", data)
+	}
+	list, err := client.List(access, "dir2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Entries) != 1 || list.Entries[0].Path != "dir2/file5.txt" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+}
+
+// TestClientAddResource tests adding a mutable resource to a manifest and
+// downloading through it as the resource is updated
+func TestClientAddResource(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	client := NewClient(srv.URL)
+	site, err := client.UploadDirectory(dir, "", "")
+	if err != nil {
+		t.Fatalf("error uploading directory: %s", err)
+	}
+	post := func(uri, data string) string {
+		res, err := http.Post(uri, "application/octet-stream", bytes.NewReader(common.Hex2Bytes(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s: unexpected HTTP status: %s", uri, res.Status)
+		}
+		return string(body)
+	}
+	addr := post(srv.URL+"/bzz-resource:/site", site)
+
+	if _, err := client.AddResource("", "site", "nonsense"); err == nil {
+		t.Fatal("expected adding an invalid resource address to fail")
+	}
+	hash, err := client.AddResource("", "site", addr)
+	if err != nil {
+		t.Fatalf("error adding resource: %s", err)
+	}
+	checkDownload := func(path, expected string) {
+		file, err := client.Download(hash, path)
+		if err != nil {
+			t.Fatalf("error downloading %s: %s", path, err)
+		}
+		data, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Fatalf("%s: expected %q, got %q", path, expected, data)
+		}
+	}
+	checkDownload("site/dir1/file3.txt", "dir1/file3.txt")
+
+	// the manifest follows the updates of the resource
+	updated, err := client.Upload(&File{
+		ReadCloser:    ioutil.NopCloser(bytes.NewReader([]byte("updated"))),
+		ManifestEntry: api.ManifestEntry{Path: "dir1/file3.txt", Size: 7},
+	}, site)
+	if err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	post(srv.URL+"/bzz-resource:/"+addr, updated)
+	checkDownload("site/dir1/file3.txt", "updated")
+	checkDownload("site/file1.txt", "file1.txt")
+}
+
 // TestClientFileList tests listing files in a swarm manifest
 func TestClientFileList(t *testing.T) {
 	srv := testutil.NewTestSwarmServer(t)
@@ -323,3 +598,78 @@ func TestClientMultipartUpload(t *testing.T) {
 		checkDownloadFile(file)
 	}
 }
+
+// TestClientPin tests pinning and unpinning content
+func TestClientPin(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+	hash, err := client.UploadDirectory(dir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pin the manifest twice
+	for i := 0; i < 2; i++ {
+		if err := client.Pin(hash, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := client.ListPins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the manifest chunks and a chunk per file are pinned
+	if len(list.Pins) != 1 || list.Pins[0].Key.String() != hash || list.Pins[0].Count != 2 || list.Pins[0].Chunks <= uint64(len(testDirFiles)) {
+		t.Fatalf("unexpected pins: %+v", list.Pins)
+	}
+	if list.Stats.PinnedChunks != list.Pins[0].Chunks || list.Stats.PinnedSize != list.Pins[0].Size {
+		t.Fatalf("unexpected pin stats: %+v", list.Stats)
+	}
+	chunks := list.Pins[0].Chunks
+
+	// unpin it twice
+	for i := 0; i < 2; i++ {
+		if err := client.Unpin(hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Unpin(hash); err == nil {
+		t.Fatal("expected an error unpinning content which is not pinned")
+	}
+	list, err = client.ListPins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Pins) != 0 || list.Stats.PinnedChunks != 0 {
+		t.Fatalf("unexpected pins after unpinning: %+v %+v", list.Pins, list.Stats)
+	}
+
+	// a raw pin only covers the manifest, and is released the same way
+	if err := client.Pin(hash, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Pin(hash, false); err == nil {
+		t.Fatal("expected an error pinning raw pinned content as a manifest")
+	}
+	list, err = client.ListPins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Pins) != 1 || !list.Pins[0].Raw || list.Pins[0].Count != 1 || list.Pins[0].Chunks >= chunks {
+		t.Fatalf("unexpected raw pins: %+v", list.Pins)
+	}
+	if err := client.Unpin(hash); err != nil {
+		t.Fatal(err)
+	}
+	list, err = client.ListPins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Pins) != 0 || list.Stats.PinnedChunks != 0 {
+		t.Fatalf("unexpected pins after raw unpinning: %+v %+v", list.Pins, list.Stats)
+	}
+}
@@ -21,6 +21,7 @@ package http
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,9 +29,11 @@ import (
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -86,8 +89,14 @@ type Request struct {
 	uri *api.URI
 }
 
+// encryptAddr is the address of POST requests storing the content encrypted,
+// i.e. bzz-raw:/encrypt and bzz:/encrypt
+const encryptAddr = "encrypt"
+
 // HandlePostRaw handles a POST request to a raw bzz-raw:/ URI, stores the request
-// body in swarm and returns the resulting storage key as a text/plain response
+// body in swarm and returns the resulting storage key as a text/plain response.
+// The body is stored encrypted when posted to bzz-raw:/encrypt, the returned key
+// then includes the encryption key
 func (s *Server) HandlePostRaw(w http.ResponseWriter, r *Request) {
 	if r.uri.Path != "" {
 		s.BadRequest(w, r, "raw POST request cannot contain a path")
@@ -99,7 +108,15 @@ func (s *Server) HandlePostRaw(w http.ResponseWriter, r *Request) {
 		return
 	}
 
-	key, err := s.api.Store(r.Body, r.ContentLength, nil)
+	var (
+		key storage.Key
+		err error
+	)
+	if r.uri.Addr == encryptAddr {
+		key, err = s.api.StoreEncrypted(r.Body, r.ContentLength, nil)
+	} else {
+		key, err = s.api.Store(r.Body, r.ContentLength, nil)
+	}
 	if err != nil {
 		s.Error(w, r, err)
 		return
@@ -115,16 +132,36 @@ func (s *Server) HandlePostRaw(w http.ResponseWriter, r *Request) {
 // bzz:/<hash>/<path> which contains either a single file or multiple files
 // (either a tar archive or multipart form), adds those files either to an
 // existing manifest or to a new manifest under <path> and returns the
-// resulting manifest hash as a text/plain response
+// resulting manifest hash as a text/plain response. Files posted to bzz:/encrypt
+// are added encrypted to a new encrypted manifest. With the resource=<addr>
+// query parameter no files are posted, an entry pointing to the mutable
+// resource at <addr> is added under <path> instead
 func (s *Server) HandlePostFiles(w http.ResponseWriter, r *Request) {
-	contentType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
-	if err != nil {
-		s.BadRequest(w, r, err.Error())
+	var (
+		contentType string
+		params      map[string]string
+		err         error
+	)
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		contentType, params, err = mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			s.BadRequest(w, r, err.Error())
+			return
+		}
+	} else if !resourceAddrMatcher.MatchString(resource) {
+		s.BadRequest(w, r, fmt.Sprintf("invalid resource address %q", resource))
 		return
 	}
 
 	var key storage.Key
-	if r.uri.Addr != "" {
+	if r.uri.Addr == encryptAddr {
+		key, err = s.api.NewEncryptedManifest()
+		if err != nil {
+			s.Error(w, r, err)
+			return
+		}
+	} else if r.uri.Addr != "" {
 		key, err = s.api.Resolve(r.uri)
 		if err != nil {
 			s.Error(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
@@ -139,6 +176,10 @@ func (s *Server) HandlePostFiles(w http.ResponseWriter, r *Request) {
 	}
 
 	newKey, err := s.updateManifest(key, func(mw *api.ManifestWriter) error {
+		if resource != "" {
+			s.logDebug("adding resource %s to manifest %s at %q", resource, key.Log(), r.uri.Path)
+			return mw.AddResource(r.uri.Path, common.Hex2Bytes(resource))
+		}
 		switch contentType {
 
 		case "application/x-tar":
@@ -266,6 +307,203 @@ func (s *Server) handleDirectUpload(req *Request, mw *api.ManifestWriter) error
 	return nil
 }
 
+// resourceAddrMatcher matches the addresses of mutable resources, other
+// addresses in POST requests to bzz-resource:/ are names of new resources
+var resourceAddrMatcher = regexp.MustCompile("^[0-9A-Fa-f]{64}$")
+
+// HandlePostResource handles a POST request to bzz-resource:/<name>, which
+// creates a resource owned by the node with the request body as its first
+// version and returns the resource address, or to bzz-resource:/<addr>, which
+// publishes the request body as the next version of the resource and returns
+// the new version number, both as text/plain responses. The updates are signed
+// with the node key, so only requests from the local host are allowed
+func (s *Server) HandlePostResource(w http.ResponseWriter, r *Request) {
+	if !isLoopback(r.RemoteAddr) {
+		ShowError(w, &r.Request, fmt.Sprintf("Publishing %s is only allowed from the local host", r.uri), http.StatusForbidden)
+		return
+	}
+	if r.uri.Addr == "" || r.uri.Path != "" {
+		s.BadRequest(w, r, "resource POST request must be to bzz-resource:/<name> or bzz-resource:/<addr>")
+		return
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(storage.MaxResourceDataLength)+1))
+	if err != nil {
+		s.Error(w, r, err)
+		return
+	}
+
+	var result string
+	if resourceAddrMatcher.MatchString(r.uri.Addr) {
+		version, err := s.api.ResourceUpdate(common.Hex2Bytes(r.uri.Addr), data)
+		if err != nil {
+			s.resourceError(w, r, err)
+			return
+		}
+		s.logDebug("resource %s updated to version %d", r.uri.Addr, version)
+		result = strconv.FormatUint(version, 10)
+	} else {
+		key, err := s.api.ResourceCreate(r.uri.Addr, data)
+		if err != nil {
+			s.resourceError(w, r, err)
+			return
+		}
+		s.logDebug("resource %q created at %s", r.uri.Addr, key)
+		result = key.String()
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, result)
+}
+
+// HandleGetResource handles a GET request to bzz-resource:/<addr>/<version>
+// and responds with the data of that version of the resource, or of its
+// latest version if the version is omitted
+func (s *Server) HandleGetResource(w http.ResponseWriter, r *Request) {
+	key, err := s.api.Resolve(r.uri)
+	if err != nil {
+		s.Error(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
+		return
+	}
+	var version uint64
+	if r.uri.Path != "" {
+		version, err = strconv.ParseUint(r.uri.Path, 10, 64)
+		if err != nil || version == 0 {
+			s.BadRequest(w, r, fmt.Sprintf("invalid resource version %q", r.uri.Path))
+			return
+		}
+	}
+	update, err := s.api.ResourceLookup(key, version)
+	if err != nil {
+		s.resourceError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Swarm-Resource-Version", strconv.FormatUint(update.Version, 10))
+	w.WriteHeader(http.StatusOK)
+	w.Write(update.Data)
+}
+
+func (s *Server) resourceError(w http.ResponseWriter, r *Request, err error) {
+	switch err {
+	case storage.ErrResourceNotFound:
+		s.NotFound(w, r, err)
+	case storage.ErrResourceExists, storage.ErrResourceNotOwner:
+		ShowError(w, &r.Request, fmt.Sprintf("Error serving %s %s: %s", r.Method, r.uri, err), http.StatusConflict)
+	default:
+		s.Error(w, r, err)
+	}
+}
+
+// resolveAccess unwraps the reference protected by the access manifest with
+// the given key, using the password of the request or the node key. The node
+// key only opens content for requests from the local host. If access is denied
+// the request is answered and false returned.
+func (s *Server) resolveAccess(w http.ResponseWriter, r *Request, key storage.Key) (*api.ManifestRef, bool) {
+	_, password, _ := r.BasicAuth()
+	ref, err := s.api.ResolveManifestAccess(key, password, isLoopback(r.RemoteAddr))
+	switch err {
+	case nil:
+		return ref, true
+	case api.ErrAccessPassword:
+		// let browsers prompt for the password
+		w.Header().Set("WWW-Authenticate", `Basic realm="swarm access"`)
+		ShowError(w, &r.Request, fmt.Sprintf("Access denied to %s: %s", r.uri, err), http.StatusUnauthorized)
+	case api.ErrAccessNotGranted:
+		ShowError(w, &r.Request, fmt.Sprintf("Access denied to %s: %s", r.uri, err), http.StatusForbidden)
+	default:
+		s.Error(w, r, err)
+	}
+	return nil, false
+}
+
+// HandlePin handles a POST request to bzz-pin:/<key>, which pins the content
+// in the local store, or a DELETE request to bzz-pin:/<key>, which releases
+// the pin. The content is pinned as a manifest along with all the content it
+// references, unless the raw=true query parameter is set, and unpinned the
+// same way as it was pinned. The key is returned
+// as a text/plain response. Pinned content is never garbage collected, so
+// only requests from the local host are allowed to pin
+func (s *Server) HandlePin(w http.ResponseWriter, r *Request) {
+	if !isLoopback(r.RemoteAddr) {
+		ShowError(w, &r.Request, fmt.Sprintf("Pinning %s is only allowed from the local host", r.uri), http.StatusForbidden)
+		return
+	}
+	key, err := s.api.Resolve(r.uri)
+	if err != nil {
+		s.Error(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
+		return
+	}
+	if r.Method == "DELETE" {
+		err = s.api.Unpin(key)
+	} else {
+		err = s.api.Pin(key, r.URL.Query().Get("raw") == "true")
+	}
+	if err == storage.ErrNotPinned {
+		s.NotFound(w, r, err)
+		return
+	} else if err == storage.ErrPinRawMismatch {
+		ShowError(w, &r.Request, fmt.Sprintf("Error pinning %s: %s", r.uri, err), http.StatusConflict)
+		return
+	} else if err != nil {
+		s.Error(w, r, err)
+		return
+	}
+	s.logDebug("%s %s done", r.Method, r.uri)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, key)
+}
+
+// isLoopback returns whether the given request address is a loopback one
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// HandleGetPins handles a GET request to bzz-pin:/, which returns the pinned
+// content and the pinned size against the capacity of the local store, or to
+// bzz-pin:/<key>, which returns the pin of that content, both as JSON
+// responses. They reveal what the node stores, so only requests from the local
+// host are allowed
+func (s *Server) HandleGetPins(w http.ResponseWriter, r *Request) {
+	if !isLoopback(r.RemoteAddr) {
+		ShowError(w, &r.Request, fmt.Sprintf("Listing %s is only allowed from the local host", r.uri), http.StatusForbidden)
+		return
+	}
+	var result interface{}
+	if r.uri.Addr == "" {
+		list, err := s.api.Pins()
+		if err != nil {
+			s.Error(w, r, err)
+			return
+		}
+		result = list
+	} else {
+		key, err := s.api.Resolve(r.uri)
+		if err != nil {
+			s.Error(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
+			return
+		}
+		pin, err := s.api.Pinned(key)
+		if err == storage.ErrNotPinned {
+			s.NotFound(w, r, err)
+			return
+		} else if err != nil {
+			s.Error(w, r, err)
+			return
+		}
+		result = pin
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // HandleDelete handles a DELETE request to bzz:/<manifest>/<path>, removes
 // <path> from <manifest> and returns the resulting manifest hash as a
 // text/plain response
@@ -366,35 +604,58 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *Request) {
 	}
 }
 
-// HandleGetFiles handles a GET request to bzz:/<manifest> with an Accept
-// header of "application/x-tar" and returns a tar stream of all files
-// contained in the manifest
-func (s *Server) HandleGetFiles(w http.ResponseWriter, r *Request) {
-	if r.uri.Path != "" {
-		s.BadRequest(w, r, "files request cannot contain a path")
-		return
-	}
+// archive formats of the files of a manifest supported by HandleGetFiles
+const (
+	archiveTar = "tar"
+	archiveZip = "zip"
+)
 
+var archiveContentTypes = map[string]string{
+	archiveTar: "application/x-tar",
+	archiveZip: "application/zip",
+}
+
+// HandleGetFiles handles a GET request to bzz:/<manifest>/<path> with an
+// Accept header of "application/x-tar" or an archive query parameter of
+// "tar" or "zip" and returns an archive stream of all files contained in the
+// manifest under <path>
+func (s *Server) HandleGetFiles(w http.ResponseWriter, r *Request, format string) {
 	key, err := s.api.Resolve(r.uri)
 	if err != nil {
 		s.Error(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
 		return
 	}
+	ref, ok := s.resolveAccess(w, r, key)
+	if !ok {
+		return
+	}
 
-	walker, err := s.api.NewManifestWalker(key, nil)
+	walker, err := s.api.NewManifestRefWalker(ref, nil)
 	if err != nil {
 		s.Error(w, r, err)
 		return
 	}
 
-	tw := tar.NewWriter(w)
-	defer tw.Close()
-	w.Header().Set("Content-Type", "application/x-tar")
-	w.WriteHeader(http.StatusOK)
-
+	// the archive is only started once the first file is found, so that
+	// a path without any file can be reported as not found
+	var archive archiveWriter
+	prefix := r.uri.Path
 	err = walker.Walk(func(entry *api.ManifestEntry) error {
-		// ignore manifests (walk will recurse into them)
+		// skip the manifests not leading to the path, walk will recurse
+		// into the others
 		if entry.ContentType == api.ManifestType {
+			if !strings.HasPrefix(entry.Path, prefix) && !strings.HasPrefix(prefix, entry.Path) {
+				return api.SkipManifest
+			}
+			return nil
+		}
+
+		// ignore resources and the files outside the path
+		if entry.ContentType == api.ResourceContentType || !inPath(entry.Path, prefix) {
+			return nil
+		}
+		// zip entries need a name, so the default entry is left out
+		if format == archiveZip && entry.Path == "" {
 			return nil
 		}
 
@@ -405,22 +666,19 @@ func (s *Server) HandleGetFiles(w http.ResponseWriter, r *Request) {
 			return err
 		}
 
-		// write a tar header for the entry
-		hdr := &tar.Header{
-			Name:    entry.Path,
-			Mode:    entry.Mode,
-			Size:    size,
-			ModTime: entry.ModTime,
-			Xattrs: map[string]string{
-				"user.swarm.content-type": entry.ContentType,
-			},
+		if archive == nil {
+			archive = newArchiveWriter(w, format)
+			w.Header().Set("Content-Type", archiveContentTypes[format])
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", r.uri.Addr+"."+format))
+			w.WriteHeader(http.StatusOK)
 		}
-		if err := tw.WriteHeader(hdr); err != nil {
+
+		// copy the file into the archive stream
+		fw, err := archive.WriteFile(entry, size)
+		if err != nil {
 			return err
 		}
-
-		// copy the file into the tar stream
-		n, err := io.Copy(tw, io.LimitReader(reader, hdr.Size))
+		n, err := io.Copy(fw, io.LimitReader(reader, size))
 		if err != nil {
 			return err
 		} else if n != size {
@@ -429,9 +687,84 @@ func (s *Server) HandleGetFiles(w http.ResponseWriter, r *Request) {
 
 		return nil
 	})
+	if archive == nil {
+		if err == nil {
+			err = fmt.Errorf("no files under %q", prefix)
+		}
+		s.NotFound(w, r, err)
+		return
+	}
 	if err != nil {
-		s.logError("error generating tar stream: %s", err)
+		s.logError("error generating %s stream: %s", format, err)
+		return
 	}
+	if err := archive.Close(); err != nil {
+		s.logError("error closing %s stream: %s", format, err)
+	}
+}
+
+// inPath returns whether the manifest path is the given path or is contained
+// in the directory it names
+func inPath(entryPath, path string) bool {
+	if path == "" || entryPath == path {
+		return true
+	}
+	return strings.HasPrefix(entryPath, strings.TrimSuffix(path, "/")+"/")
+}
+
+// archiveWriter writes the files of a manifest into an archive stream
+type archiveWriter interface {
+	// WriteFile starts a new file in the archive for the manifest entry,
+	// its content has to be written to the returned writer
+	WriteFile(entry *api.ManifestEntry, size int64) (io.Writer, error)
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format string) archiveWriter {
+	if format == archiveZip {
+		return &zipArchiveWriter{zip.NewWriter(w)}
+	}
+	return &tarArchiveWriter{tar.NewWriter(w)}
+}
+
+// tarArchiveWriter stores the content type of the files in the
+// "user.swarm.content-type" extended attribute, as expected by tar uploads
+type tarArchiveWriter struct {
+	*tar.Writer
+}
+
+func (a *tarArchiveWriter) WriteFile(entry *api.ManifestEntry, size int64) (io.Writer, error) {
+	hdr := &tar.Header{
+		Name:    entry.Path,
+		Mode:    entry.Mode,
+		Size:    size,
+		ModTime: entry.ModTime,
+		Xattrs: map[string]string{
+			"user.swarm.content-type": entry.ContentType,
+		},
+	}
+	if err := a.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	return a.Writer, nil
+}
+
+// zipArchiveWriter stores the content type of the files in their comment
+type zipArchiveWriter struct {
+	*zip.Writer
+}
+
+func (a *zipArchiveWriter) WriteFile(entry *api.ManifestEntry, size int64) (io.Writer, error) {
+	hdr := &zip.FileHeader{
+		Name:    entry.Path,
+		Method:  zip.Deflate,
+		Comment: entry.ContentType,
+	}
+	hdr.SetModTime(entry.ModTime)
+	if entry.Mode > 0 {
+		hdr.SetMode(os.FileMode(entry.Mode))
+	}
+	return a.CreateHeader(hdr)
 }
 
 // HandleGetList handles a GET request to bzz-list:/<manifest>/<path> and returns
@@ -449,8 +782,12 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *Request) {
 		s.Error(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
 		return
 	}
+	ref, ok := s.resolveAccess(w, r, key)
+	if !ok {
+		return
+	}
 
-	list, err := s.getManifestList(key, r.uri.Path)
+	list, err := s.getManifestList(ref, r.uri.Path)
 
 	if err != nil {
 		s.Error(w, r, err)
@@ -479,8 +816,8 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *Request) {
 	json.NewEncoder(w).Encode(&list)
 }
 
-func (s *Server) getManifestList(key storage.Key, prefix string) (list api.ManifestList, err error) {
-	walker, err := s.api.NewManifestWalker(key, nil)
+func (s *Server) getManifestList(ref *api.ManifestRef, prefix string) (list api.ManifestList, err error) {
+	walker, err := s.api.NewManifestRefWalker(ref, nil)
 	if err != nil {
 		return
 	}
@@ -549,8 +886,12 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *Request) {
 		s.Error(w, r, fmt.Errorf("error resolving %s: %s", r.uri.Addr, err))
 		return
 	}
+	ref, ok := s.resolveAccess(w, r, key)
+	if !ok {
+		return
+	}
 
-	reader, contentType, status, err := s.api.Get(key, r.uri.Path)
+	reader, contentType, status, err := s.api.GetManifest(ref, r.uri.Path)
 	if err != nil {
 		switch status {
 		case http.StatusNotFound:
@@ -564,7 +905,7 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *Request) {
 	//the request results in ambiguous files
 	//e.g. /read with readme.md and readinglist.txt available in manifest
 	if status == http.StatusMultipleChoices {
-		list, err := s.getManifestList(key, r.uri.Path)
+		list, err := s.getManifestList(ref, r.uri.Path)
 
 		if err != nil {
 			s.Error(w, r, err)
@@ -602,7 +943,11 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "POST":
-		if uri.Raw() || uri.DeprecatedRaw() {
+		if uri.Resource() {
+			s.HandlePostResource(w, req)
+		} else if uri.Pin() {
+			s.HandlePin(w, req)
+		} else if uri.Raw() || uri.DeprecatedRaw() {
 			s.HandlePostRaw(w, req)
 		} else {
 			s.HandlePostFiles(w, req)
@@ -614,7 +959,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		//   new manifest leaving the existing one intact, so it isn't
 		//   strictly a traditional PUT request which replaces content
 		//   at a URI, and POST is more ubiquitous)
-		if uri.Raw() || uri.DeprecatedRaw() {
+		if uri.Raw() || uri.DeprecatedRaw() || uri.Resource() || uri.Pin() {
 			ShowError(w, r, fmt.Sprintf("No PUT to %s allowed.", uri), http.StatusBadRequest)
 			return
 		} else {
@@ -622,10 +967,14 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 	case "DELETE":
-		if uri.Raw() || uri.DeprecatedRaw() {
+		if uri.Raw() || uri.DeprecatedRaw() || uri.Resource() {
 			ShowError(w, r, fmt.Sprintf("No DELETE to %s allowed.", uri), http.StatusBadRequest)
 			return
 		}
+		if uri.Pin() {
+			s.HandlePin(w, req)
+			return
+		}
 		s.HandleDelete(w, req)
 
 	case "GET":
@@ -639,8 +988,27 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if uri.Resource() {
+			s.HandleGetResource(w, req)
+			return
+		}
+
+		if uri.Pin() {
+			s.HandleGetPins(w, req)
+			return
+		}
+
+		switch format := r.URL.Query().Get("archive"); format {
+		case archiveTar, archiveZip:
+			s.HandleGetFiles(w, req, format)
+			return
+		case "":
+		default:
+			s.BadRequest(w, req, fmt.Sprintf("unsupported archive format %q", format))
+			return
+		}
 		if r.Header.Get("Accept") == "application/x-tar" {
-			s.HandleGetFiles(w, req)
+			s.HandleGetFiles(w, req, archiveTar)
 			return
 		}
 
@@ -18,10 +18,13 @@ package http_test
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
@@ -318,3 +321,220 @@ func TestBzzRootRedirect(t *testing.T) {
 		t.Fatalf("expected response to equal %q, got %q", data, gotData)
 	}
 }
+
+// TestBzzResource tests creating, updating and reading a mutable resource,
+// and serving the manifest referenced by its latest version through a
+// manifest entry pointing to the resource.
+func TestBzzResource(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	post := func(url string, data []byte, expectStatus int) string {
+		res, err := http.Post(url, "application/octet-stream", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != expectStatus {
+			t.Fatalf("POST %s: expected status %d, got %s", url, expectStatus, res.Status)
+		}
+		return string(body)
+	}
+	get := func(url string, expect []byte) {
+		res, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: unexpected status %s", url, res.Status)
+		}
+		if !bytes.Equal(body, expect) {
+			t.Fatalf("GET %s: expected %q, got %q", url, expect, body)
+		}
+	}
+
+	// create the resource and read its first version
+	addr := post(srv.URL+"/bzz-resource:/firmware", []byte("first"), http.StatusOK)
+	if len(addr) != 64 {
+		t.Fatalf("unexpected resource address %q", addr)
+	}
+	get(srv.URL+"/bzz-resource:/"+addr, []byte("first"))
+	post(srv.URL+"/bzz-resource:/firmware", []byte("again"), http.StatusConflict)
+
+	// publish the key of a manifest as the second version
+	client := swarm.NewClient(srv.URL)
+	data := []byte("release data")
+	hash, err := client.Upload(&swarm.File{
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{
+			Path:        "release.bin",
+			ContentType: "application/octet-stream",
+			Size:        int64(len(data)),
+		},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version := post(srv.URL+"/bzz-resource:/"+addr, common.Hex2Bytes(hash), http.StatusOK); version != "2" {
+		t.Fatalf("expected version 2, got %q", version)
+	}
+	get(srv.URL+"/bzz-resource:/"+addr, common.Hex2Bytes(hash))
+	get(srv.URL+"/bzz-resource:/"+addr+"/1", []byte("first"))
+
+	// a manifest entry pointing to the resource serves its latest manifest
+	manifest := fmt.Sprintf(`{"entries":[{"hash":"%s","contentType":"%s"}]}`, addr, api.ResourceContentType)
+	wg := &sync.WaitGroup{}
+	key, err := srv.Dpa.Store(strings.NewReader(manifest), int64(len(manifest)), wg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	get(srv.URL+"/bzz:/"+key.String()+"/release.bin", data)
+}
+
+// Tests that content can only be pinned by requests from the local host
+func TestBzzPinLocalOnly(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	data := []byte("pinned data")
+	key, err := swarm.NewClient(srv.URL).UploadRaw(bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"POST", "GET", "DELETE"} {
+		for addr, code := range map[string]int{
+			"10.0.0.1:30399":  http.StatusForbidden,
+			"[fe80::1]:30399": http.StatusForbidden,
+			"127.0.0.1:30399": http.StatusOK,
+			"[::1]:30399":     http.StatusOK,
+		} {
+			req := httptest.NewRequest(method, "/bzz-pin:/"+key+"?raw=true", nil)
+			req.RemoteAddr = addr
+			w := httptest.NewRecorder()
+			srv.Config.Handler.ServeHTTP(w, req)
+			if w.Code != code {
+				t.Errorf("%s from %s: expected status %d, got %d", method, addr, code, w.Code)
+			}
+		}
+	}
+	for addr, code := range map[string]int{
+		"10.0.0.1:30399":  http.StatusForbidden,
+		"127.0.0.1:30399": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/bzz-pin:/", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		srv.Config.Handler.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("GET list from %s: expected status %d, got %d", addr, code, w.Code)
+		}
+	}
+}
+
+// Tests that GET bzz-pin:/<key> returns the pin of that content only
+func TestBzzPinGet(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	data := []byte("pinned data")
+	key, err := swarm.NewClient(srv.URL).UploadRaw(bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, uri string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, uri, nil)
+		req.RemoteAddr = "127.0.0.1:30399"
+		w := httptest.NewRecorder()
+		srv.Config.Handler.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("GET", "/bzz-pin:/"+key); w.Code != http.StatusNotFound {
+		t.Fatalf("unpinned content: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := do("POST", "/bzz-pin:/"+key+"?raw=true"); w.Code != http.StatusOK {
+		t.Fatalf("pinning failed with status %d", w.Code)
+	}
+	w := do("GET", "/bzz-pin:/"+key)
+	if w.Code != http.StatusOK {
+		t.Fatalf("pinned content: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var pin storage.PinInfo
+	if err := json.NewDecoder(w.Body).Decode(&pin); err != nil {
+		t.Fatal(err)
+	}
+	if pin.Key.String() != key || pin.Count != 1 || !pin.Raw {
+		t.Fatalf("unexpected pin: %+v", pin)
+	}
+}
+
+// Tests that resources can only be published by requests from the local host
+func TestBzzResourceLocalOnly(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	for i, test := range []struct {
+		addr string
+		code int
+	}{
+		{"10.0.0.1:30399", http.StatusForbidden},
+		{"[fe80::1]:30399", http.StatusForbidden},
+		{"127.0.0.1:30399", http.StatusOK},
+		{"[::1]:30399", http.StatusOK},
+	} {
+		req := httptest.NewRequest("POST", fmt.Sprintf("/bzz-resource:/resource%d", i), strings.NewReader("first"))
+		req.RemoteAddr = test.addr
+		w := httptest.NewRecorder()
+		srv.Config.Handler.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Errorf("POST from %s: expected status %d, got %d", test.addr, test.code, w.Code)
+		}
+	}
+}
+
+// Tests that the node key only opens the content granted to it for requests
+// from the local host
+func TestBzzPKAccessLocalOnly(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	data := "granted data"
+	client := swarm.NewClient(srv.URL)
+	hash, err := client.Upload(&swarm.File{
+		ReadCloser:    ioutil.NopCloser(strings.NewReader(data)),
+		ManifestEntry: api.ManifestEntry{Path: "file.txt", ContentType: "text/plain", Size: int64(len(data))},
+	}, "encrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := client.CreatePKAccess(hash, []*ecdsa.PublicKey{&srv.PrvKey.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, code := range map[string]int{
+		"10.0.0.1:30399":  http.StatusForbidden,
+		"[fe80::1]:30399": http.StatusForbidden,
+		"127.0.0.1:30399": http.StatusOK,
+		"[::1]:30399":     http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/bzz:/"+access+"/file.txt", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		srv.Config.Handler.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("GET from %s: expected status %d, got %d", addr, code, w.Code)
+		}
+		if code == http.StatusOK && w.Body.String() != data {
+			t.Errorf("GET from %s: unexpected content %q", addr, w.Body.String())
+		}
+	}
+}
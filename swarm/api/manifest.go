@@ -33,7 +33,8 @@ import (
 )
 
 const (
-	ManifestType = "application/bzz-manifest+json"
+	ManifestType        = "application/bzz-manifest+json"
+	ResourceContentType = "application/bzz-resource"
 )
 
 // Manifest represents a swarm manifest
@@ -43,13 +44,14 @@ type Manifest struct {
 
 // ManifestEntry represents an entry in a swarm manifest
 type ManifestEntry struct {
-	Hash        string    `json:"hash,omitempty"`
-	Path        string    `json:"path,omitempty"`
-	ContentType string    `json:"contentType,omitempty"`
-	Mode        int64     `json:"mode,omitempty"`
-	Size        int64     `json:"size,omitempty"`
-	ModTime     time.Time `json:"mod_time,omitempty"`
-	Status      int       `json:"status,omitempty"`
+	Hash        string       `json:"hash,omitempty"`
+	Path        string       `json:"path,omitempty"`
+	ContentType string       `json:"contentType,omitempty"`
+	Mode        int64        `json:"mode,omitempty"`
+	Size        int64        `json:"size,omitempty"`
+	ModTime     time.Time    `json:"mod_time,omitempty"`
+	Status      int          `json:"status,omitempty"`
+	Access      *AccessEntry `json:"access,omitempty"`
 }
 
 // ManifestList represents the result of listing files in a manifest
@@ -68,6 +70,17 @@ func (a *Api) NewManifest() (storage.Key, error) {
 	return a.Store(bytes.NewReader(data), int64(len(data)), &sync.WaitGroup{})
 }
 
+// NewEncryptedManifest creates and stores a new, empty encrypted manifest.
+// The content added to an encrypted manifest is encrypted as well.
+func (a *Api) NewEncryptedManifest() (storage.Key, error) {
+	var manifest Manifest
+	data, err := json.Marshal(&manifest)
+	if err != nil {
+		return nil, err
+	}
+	return a.StoreEncrypted(bytes.NewReader(data), int64(len(data)), &sync.WaitGroup{})
+}
+
 // ManifestWriter is used to add and remove entries from an underlying manifest
 type ManifestWriter struct {
 	api   *Api
@@ -85,7 +98,15 @@ func (a *Api) NewManifestWriter(key storage.Key, quitC chan bool) (*ManifestWrit
 
 // AddEntry stores the given data and adds the resulting key to the manifest
 func (m *ManifestWriter) AddEntry(data io.Reader, e *ManifestEntry) (storage.Key, error) {
-	key, err := m.api.Store(data, e.Size, nil)
+	var (
+		key storage.Key
+		err error
+	)
+	if m.trie.encrypted {
+		key, err = m.api.StoreEncrypted(data, e.Size, nil)
+	} else {
+		key, err = m.api.Store(data, e.Size, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -95,6 +116,18 @@ func (m *ManifestWriter) AddEntry(data io.Reader, e *ManifestEntry) (storage.Key
 	return key, nil
 }
 
+// AddResource adds an entry at the given path pointing to the mutable resource
+// at addr, the latest version of which serves the content under the path
+func (m *ManifestWriter) AddResource(path string, addr storage.Key) error {
+	entry := newManifestTrieEntry(&ManifestEntry{
+		Path:        path,
+		ContentType: ResourceContentType,
+	}, nil)
+	entry.Hash = addr.String()
+	m.trie.addEntry(entry, m.quitC)
+	return nil
+}
+
 // RemoveEntry removes the given path from the manifest
 func (m *ManifestWriter) RemoveEntry(path string) error {
 	m.trie.deleteEntry(path, m.quitC)
@@ -122,6 +155,15 @@ func (a *Api) NewManifestWalker(key storage.Key, quitC chan bool) (*ManifestWalk
 	return &ManifestWalker{a, trie, quitC}, nil
 }
 
+// NewManifestRefWalker is NewManifestWalker for a manifest reference resolved
+// by ResolveManifestAccess, reusing the manifest loaded by it
+func (a *Api) NewManifestRefWalker(ref *ManifestRef, quitC chan bool) (*ManifestWalker, error) {
+	if ref.trie == nil {
+		return a.NewManifestWalker(ref.Key, quitC)
+	}
+	return &ManifestWalker{a, ref.trie, quitC}, nil
+}
+
 // SkipManifest is used as a return value from WalkFn to indicate that the
 // manifest should be skipped
 var SkipManifest = errors.New("skip this manifest")
@@ -166,6 +208,8 @@ type manifestTrie struct {
 	dpa     *storage.DPA
 	entries [257]*manifestTrieEntry // indexed by first character of basePath, entries[256] is the empty basePath entry
 	hash    storage.Key             // if hash != nil, it is stored
+
+	encrypted bool // the trie and its new subtries are stored encrypted
 }
 
 func newManifestTrieEntry(entry *ManifestEntry, subtrie *manifestTrie) *manifestTrieEntry {
@@ -222,7 +266,8 @@ func readManifest(manifestReader storage.LazySectionReader, hash storage.Key, dp
 	log.Trace(fmt.Sprintf("Manifest %v has %d entries.", hash.Log(), len(man.Entries)))
 
 	trie = &manifestTrie{
-		dpa: dpa,
+		dpa:       dpa,
+		encrypted: hash.Encrypted(),
 	}
 	for _, entry := range man.Entries {
 		trie.addEntry(entry, quitC)
@@ -263,7 +308,8 @@ func (self *manifestTrie) addEntry(entry *manifestTrieEntry, quitC chan bool) {
 	commonPrefix := entry.Path[:cpl]
 
 	subtrie := &manifestTrie{
-		dpa: self.dpa,
+		dpa:       self.dpa,
+		encrypted: self.encrypted,
 	}
 	entry.Path = entry.Path[cpl:]
 	oldentry.Path = oldentry.Path[cpl:]
@@ -352,7 +398,15 @@ func (self *manifestTrie) recalcAndStore() error {
 
 	sr := bytes.NewReader(manifest)
 	wg := &sync.WaitGroup{}
-	key, err2 := self.dpa.Store(sr, int64(len(manifest)), wg, nil)
+	var (
+		key  storage.Key
+		err2 error
+	)
+	if self.encrypted {
+		key, err2 = self.dpa.StoreEncrypted(sr, int64(len(manifest)), wg, nil)
+	} else {
+		key, err2 = self.dpa.Store(sr, int64(len(manifest)), wg, nil)
+	}
 	wg.Wait()
 	self.hash = key
 	return err2
@@ -471,8 +525,9 @@ func (self *manifestTrie) findPrefixOf(path string, quitC chan bool) (entry *man
 			}
 
 		} else {
-			//entry is not a manifest, return it
-			if path != entry.Path {
+			//entry is not a manifest, return it, a resource serves the
+			//paths below it as well
+			if path != entry.Path && !(entry.ContentType == ResourceContentType && path[epl] == '/') {
 				return nil, 0
 			}
 			pos = epl
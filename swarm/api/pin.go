@@ -0,0 +1,105 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/swarm/storage"
+)
+
+// PinList represents the content pinned in the local store
+type PinList struct {
+	Pins  []*storage.PinInfo `json:"pins"`
+	Stats *storage.PinStats  `json:"stats"`
+}
+
+// Pin pins the content with the given key in the local store so that its
+// chunks are not garbage collected. Unless raw is set, the content is a
+// manifest and everything it references is pinned as well.
+func (self *Api) Pin(key storage.Key, raw bool) error {
+	chunks, err := self.contentChunks(key, raw)
+	if err != nil {
+		return err
+	}
+	return self.dpa.Pin(key, chunks, raw)
+}
+
+// Unpin releases a pin of the content with the given key, the chunks pinned
+// along with it are released as when it was pinned.
+func (self *Api) Unpin(key storage.Key) error {
+	pin, err := self.dpa.Pinned(key)
+	if err != nil {
+		return err
+	}
+	chunks, err := self.contentChunks(key, pin.Raw)
+	if err != nil {
+		return err
+	}
+	return self.dpa.Unpin(key, chunks)
+}
+
+// Pinned returns the pin of the content with the given key
+func (self *Api) Pinned(key storage.Key) (*storage.PinInfo, error) {
+	return self.dpa.Pinned(key)
+}
+
+// Pins lists the pinned content and the size it takes in the local store
+func (self *Api) Pins() (*PinList, error) {
+	pins, err := self.dpa.Pins()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := self.dpa.PinStats()
+	if err != nil {
+		return nil, err
+	}
+	return &PinList{Pins: pins, Stats: stats}, nil
+}
+
+// contentChunks retrieves all chunks of the content and returns their sizes
+// by key. Unless raw is set, the chunks of the content referenced by the
+// manifest and its submanifests are included.
+func (self *Api) contentChunks(key storage.Key, raw bool) (map[string]uint64, error) {
+	chunks := make(map[string]uint64)
+	collect := func(key storage.Key) error {
+		return self.dpa.Walk(key, func(chunk *storage.Chunk) error {
+			chunks[string(chunk.Key)] = uint64(len(chunk.SData))
+			return nil
+		})
+	}
+	if err := collect(key); err != nil {
+		return nil, err
+	}
+	if raw {
+		return chunks, nil
+	}
+	walker, err := self.NewManifestWalker(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = walker.Walk(func(entry *ManifestEntry) error {
+		// resources are not content, their updates can't be pinned
+		if entry.Hash == "" || entry.ContentType == ResourceContentType {
+			return nil
+		}
+		return collect(common.Hex2Bytes(entry.Hash))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
@@ -31,6 +31,8 @@ type URI struct {
 	// * bzz-immutable - immutable URI of an entry in a swarm manifest
 	//                   (address is not resolved)
 	// * bzz-list      -  list of all files contained in a swarm manifest
+	// * bzz-resource  - a version of a mutable resource
+	// * bzz-pin       - content pinned in the local store
 	//
 	// Deprecated Schemes:
 	// * bzzr - raw swarm content
@@ -58,7 +60,7 @@ type URI struct {
 // * <scheme>://<addr>
 // * <scheme>://<addr>/<path>
 //
-// with scheme one of bzz, bzz-raw, bzz-immutable, bzz-list or bzz-hash
+// with scheme one of bzz, bzz-raw, bzz-immutable, bzz-list, bzz-hash, bzz-resource or bzz-pin
 // or deprecated ones bzzr and bzzi
 func Parse(rawuri string) (*URI, error) {
 	u, err := url.Parse(rawuri)
@@ -69,7 +71,7 @@ func Parse(rawuri string) (*URI, error) {
 
 	// check the scheme is valid
 	switch uri.Scheme {
-	case "bzz", "bzz-raw", "bzz-immutable", "bzz-list", "bzz-hash", "bzzr", "bzzi":
+	case "bzz", "bzz-raw", "bzz-immutable", "bzz-list", "bzz-hash", "bzz-resource", "bzz-pin", "bzzr", "bzzi":
 	default:
 		return nil, fmt.Errorf("unknown scheme %q", u.Scheme)
 	}
@@ -104,6 +106,14 @@ func (u *URI) List() bool {
 	return u.Scheme == "bzz-list"
 }
 
+func (u *URI) Resource() bool {
+	return u.Scheme == "bzz-resource"
+}
+
+func (u *URI) Pin() bool {
+	return u.Scheme == "bzz-pin"
+}
+
 func (u *URI) DeprecatedRaw() bool {
 	return u.Scheme == "bzzr"
 }
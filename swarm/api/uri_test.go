@@ -30,6 +30,8 @@ func TestParseURI(t *testing.T) {
 		expectImmutable           bool
 		expectList                bool
 		expectHash                bool
+		expectResource            bool
+		expectPin                 bool
 		expectDeprecatedRaw       bool
 		expectDeprecatedImmutable bool
 	}
@@ -119,6 +121,26 @@ func TestParseURI(t *testing.T) {
 			expectURI:  &URI{Scheme: "bzz-list"},
 			expectList: true,
 		},
+		{
+			uri:            "bzz-resource:/abc123",
+			expectURI:      &URI{Scheme: "bzz-resource", Addr: "abc123"},
+			expectResource: true,
+		},
+		{
+			uri:            "bzz-resource://abc123/2",
+			expectURI:      &URI{Scheme: "bzz-resource", Addr: "abc123", Path: "2"},
+			expectResource: true,
+		},
+		{
+			uri:       "bzz-pin:/",
+			expectURI: &URI{Scheme: "bzz-pin"},
+			expectPin: true,
+		},
+		{
+			uri:       "bzz-pin:/abc123",
+			expectURI: &URI{Scheme: "bzz-pin", Addr: "abc123"},
+			expectPin: true,
+		},
 		{
 			uri:                 "bzzr:",
 			expectURI:           &URI{Scheme: "bzzr"},
@@ -166,6 +188,12 @@ func TestParseURI(t *testing.T) {
 		if actual.Hash() != x.expectHash {
 			t.Fatalf("expected %s hash to be %t, got %t", x.uri, x.expectHash, actual.Hash())
 		}
+		if actual.Resource() != x.expectResource {
+			t.Fatalf("expected %s resource to be %t, got %t", x.uri, x.expectResource, actual.Resource())
+		}
+		if actual.Pin() != x.expectPin {
+			t.Fatalf("expected %s pin to be %t, got %t", x.uri, x.expectPin, actual.Pin())
+		}
 		if actual.DeprecatedRaw() != x.expectDeprecatedRaw {
 			t.Fatalf("expected %s deprecated raw to be %t, got %t", x.uri, x.expectDeprecatedRaw, actual.DeprecatedRaw())
 		}
@@ -32,12 +32,17 @@ type MountInfo struct {
 	MountPoint     string
 	StartManifest  string
 	LatestManifest string
+	Protected      bool
 }
 
 func (self *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 	return nil, errNoFUSE
 }
 
+func (self *SwarmFS) MountProtected(mhash, mountpoint, password string) (*MountInfo, error) {
+	return nil, errNoFUSE
+}
+
 func (self *SwarmFS) Unmount(mountpoint string) (bool, error) {
 	return false, errNoFUSE
 }
@@ -21,12 +21,15 @@ package fuse
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/swarm/api"
 	"github.com/MeshBoxFoundation/meshbox/swarm/storage"
 )
@@ -230,6 +233,63 @@ func (ta *testAPI) mountListAndUnmount(t *testing.T) {
 
 }
 
+func (ta *testAPI) mountProtected(t *testing.T) {
+	files := make(map[string]fileInfo)
+	testUploadDir, _ := ioutil.TempDir(os.TempDir(), "fuse-source")
+	testMountDir, _ := ioutil.TempDir(os.TempDir(), "fuse-dest")
+
+	files["1.txt"] = fileInfo{0700, 333, 444, getRandomBtes(10)}
+	files["dir/2.txt"] = fileInfo{0711, 333, 444, getRandomBtes(100)}
+	bzzHash := createTestFilesAndUploadToSwarm(t, ta.api, files, testUploadDir)
+
+	access, key, err := api.NewPassAccess("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(api.NewAccessManifest(common.Hex2Bytes(bzzHash), access, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := &sync.WaitGroup{}
+	accessKey, err := ta.api.Store(bytes.NewReader(data), int64(len(data)), wg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	accessHash := accessKey.String()
+
+	os.RemoveAll(testMountDir)
+	os.MkdirAll(testMountDir, 0777)
+	swarmfs := NewSwarmFS(ta.api)
+	defer swarmfs.Stop()
+
+	for _, password := range []string{"", "wrong"} {
+		if _, err := swarmfs.MountProtected(accessHash, testMountDir, password); err != api.ErrAccessPassword {
+			t.Fatalf("password %q: expected %v, got %v", password, api.ErrAccessPassword, err)
+		}
+	}
+	_, err = swarmfs.MountProtected(accessHash, testMountDir, "secret")
+	if isFUSEUnsupportedError(err) {
+		t.Skip("FUSE not supported:", err)
+	} else if err != nil {
+		t.Fatalf("Error mounting hash %v: %v", accessHash, err)
+	}
+	compareGeneratedFileWithFileInMount(t, files, testMountDir)
+
+	// the protected manifest isn't shown, not even after changes
+	actualPath := filepath.Join(testMountDir, "3.txt")
+	if err := ioutil.WriteFile(actualPath, getRandomBtes(10), 0700); err != nil {
+		t.Fatalf("Could not create file %s : %v", actualPath, err)
+	}
+	mi, err := swarmfs.Unmount(testMountDir)
+	if err != nil {
+		t.Fatalf("could not unmount  %v", accessHash)
+	}
+	if !mi.Protected || mi.StartManifest != accessHash || mi.LatestManifest != accessHash {
+		t.Fatalf("protected manifest exposed: %+v", mi)
+	}
+}
+
 func (ta *testAPI) maxMounts(t *testing.T) {
 	files := make(map[string]fileInfo)
 	files["1.txt"] = fileInfo{0700, 333, 444, getRandomBtes(10)}
@@ -812,11 +872,12 @@ func TestFUSE(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	ta := &testAPI{api: api.NewApi(dpa, nil)}
+	ta := &testAPI{api: api.NewApi(dpa, nil, nil, nil)}
 	dpa.Start()
 	defer dpa.Stop()
 
 	t.Run("mountListAndUmount", ta.mountListAndUnmount)
+	t.Run("mountProtected", ta.mountProtected)
 	t.Run("maxMounts", ta.maxMounts)
 	t.Run("remount", ta.remount)
 	t.Run("unmount", ta.unmount)
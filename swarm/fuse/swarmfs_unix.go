@@ -51,8 +51,10 @@ func isFUSEUnsupportedError(err error) bool {
 // information about every active mount
 type MountInfo struct {
 	MountPoint     string
-	StartManifest  string
-	LatestManifest string
+	StartManifest  string // the access manifest of a protected mount
+	LatestManifest string // not updated by the changes to a protected mount
+	Protected      bool
+	manifest       string // manifest served and changed, unwrapped if protected
 	rootDir        *SwarmDir
 	fuseConnection *fuse.Conn
 	swarmApi       *api.Api
@@ -64,6 +66,7 @@ func NewMountInfo(mhash, mpoint string, sapi *api.Api) *MountInfo {
 		MountPoint:     mpoint,
 		StartManifest:  mhash,
 		LatestManifest: mhash,
+		manifest:       mhash,
 		rootDir:        nil,
 		fuseConnection: nil,
 		swarmApi:       sapi,
@@ -72,7 +75,25 @@ func NewMountInfo(mhash, mpoint string, sapi *api.Api) *MountInfo {
 	return newMountInfo
 }
 
+// setManifest records the manifest holding the changes made to the mount,
+// the caller holds the lock
+func (self *MountInfo) setManifest(mhash string) {
+	self.manifest = mhash
+	if !self.Protected {
+		self.LatestManifest = mhash
+	}
+}
+
 func (self *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
+	return self.MountProtected(mhash, mountpoint, "")
+}
+
+// MountProtected mounts a manifest, which may be protected by access control
+// and then is opened with the password or the node key. The manifest it
+// protects is mounted, so changes are committed to unprotected manifests. Their
+// hashes hold the key of the protected content, the mount info only shows the
+// access manifest of a protected mount.
+func (self *SwarmFS) MountProtected(mhash, mountpoint, password string) (*MountInfo, error) {
 
 	if mountpoint == "" {
 		return nil, errEmptyMountPoint
@@ -95,12 +116,20 @@ func (self *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 	}
 
 	log.Info(fmt.Sprintf("Attempting to mount %s ", cleanedMountPoint))
+	accessHash := mhash
+	mhash, err = self.swarmApi.ResolveAccessHash(mhash, password)
+	if err != nil {
+		return nil, err
+	}
 	_, manifestEntryMap, err := self.swarmApi.BuildDirectoryTree(mhash, true)
 	if err != nil {
 		return nil, err
 	}
 
 	mi := NewMountInfo(mhash, cleanedMountPoint, self.swarmApi)
+	if mhash != accessHash {
+		mi.StartManifest, mi.LatestManifest, mi.Protected = accessHash, accessHash, true
+	}
 
 	dirTree := map[string]*SwarmDir{}
 	rootDir := NewSwarmDir("/", mi)
@@ -137,7 +166,7 @@ func (self *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 		parentDir.files = append(parentDir.files, thisFile)
 	}
 
-	fconn, err := fuse.Mount(cleanedMountPoint, fuse.FSName("swarmfs"), fuse.VolumeName(mhash))
+	fconn, err := fuse.Mount(cleanedMountPoint, fuse.FSName("swarmfs"), fuse.VolumeName(mi.StartManifest))
 	if isFUSEUnsupportedError(err) {
 		log.Warn("Fuse not installed", "mountpoint", cleanedMountPoint, "err", err)
 		return nil, err
@@ -150,7 +179,7 @@ func (self *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 
 	serverr := make(chan error, 1)
 	go func() {
-		log.Info(fmt.Sprintf("Serving %s at %s", mhash, cleanedMountPoint))
+		log.Info(fmt.Sprintf("Serving %s at %s", mi.StartManifest, cleanedMountPoint))
 		filesys := &SwarmRoot{root: rootDir}
 		if err := fs.Serve(fconn, filesys); err != nil {
 			log.Warn(fmt.Sprintf("Could not Serve SwarmFileSystem error: %v", err))
@@ -171,7 +200,7 @@ func (self *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 		return nil, err
 
 	case <-fconn.Ready:
-		log.Info("Now serving swarm FUSE FS", "manifest", mhash, "mountpoint", cleanedMountPoint)
+		log.Info("Now serving swarm FUSE FS", "manifest", mi.StartManifest, "mountpoint", cleanedMountPoint)
 	}
 
 	self.activeMounts[cleanedMountPoint] = mi
@@ -48,7 +48,7 @@ func externalUnmount(mountPoint string) error {
 
 func addFileToSwarm(sf *SwarmFile, content []byte, size int) error {
 
-	fkey, mhash, err := sf.mountInfo.swarmApi.AddFile(sf.mountInfo.LatestManifest, sf.path, sf.name, content, true)
+	fkey, mhash, err := sf.mountInfo.swarmApi.AddFile(sf.mountInfo.manifest, sf.path, sf.name, content, true)
 	if err != nil {
 		return err
 	}
@@ -60,7 +60,7 @@ func addFileToSwarm(sf *SwarmFile, content []byte, size int) error {
 
 	sf.mountInfo.lock.Lock()
 	defer sf.mountInfo.lock.Unlock()
-	sf.mountInfo.LatestManifest = mhash
+	sf.mountInfo.setManifest(mhash)
 
 	log.Info("Added new file:", "fname", sf.name, "New Manifest hash", mhash)
 	return nil
@@ -69,14 +69,14 @@ func addFileToSwarm(sf *SwarmFile, content []byte, size int) error {
 
 func removeFileFromSwarm(sf *SwarmFile) error {
 
-	mkey, err := sf.mountInfo.swarmApi.RemoveFile(sf.mountInfo.LatestManifest, sf.path, sf.name, true)
+	mkey, err := sf.mountInfo.swarmApi.RemoveFile(sf.mountInfo.manifest, sf.path, sf.name, true)
 	if err != nil {
 		return err
 	}
 
 	sf.mountInfo.lock.Lock()
 	defer sf.mountInfo.lock.Unlock()
-	sf.mountInfo.LatestManifest = mkey
+	sf.mountInfo.setManifest(mkey)
 
 	log.Info("Removed file:", "fname", sf.name, "New Manifest hash", mkey)
 	return nil
@@ -108,7 +108,7 @@ func removeDirectoryFromSwarm(sd *SwarmDir) error {
 
 func appendToExistingFileInSwarm(sf *SwarmFile, content []byte, offset int64, length int64) error {
 
-	fkey, mhash, err := sf.mountInfo.swarmApi.AppendFile(sf.mountInfo.LatestManifest, sf.path, sf.name, sf.fileSize, content, sf.key, offset, length, true)
+	fkey, mhash, err := sf.mountInfo.swarmApi.AppendFile(sf.mountInfo.manifest, sf.path, sf.name, sf.fileSize, content, sf.key, offset, length, true)
 	if err != nil {
 		return err
 	}
@@ -120,7 +120,7 @@ func appendToExistingFileInSwarm(sf *SwarmFile, content []byte, offset int64, le
 
 	sf.mountInfo.lock.Lock()
 	defer sf.mountInfo.lock.Unlock()
-	sf.mountInfo.LatestManifest = mhash
+	sf.mountInfo.setManifest(mhash)
 
 	log.Info("Appended file:", "fname", sf.name, "New Manifest hash", mhash)
 	return nil
@@ -123,7 +123,7 @@ func (self *Depo) HandleStoreRequestMsg(req *storeRequestMsgData, p *peer) {
 
 	hasher := self.hashfunc()
 	hasher.Write(req.SData)
-	if !bytes.Equal(hasher.Sum(nil), req.Key) {
+	if !bytes.Equal(hasher.Sum(nil), req.Key) && !storage.IsResourceChunk(req.Key, req.SData) {
 		// data does not validate, ignore
 		// TODO: peer should be penalised/dropped?
 		log.Warn(fmt.Sprintf("Depo.HandleStoreRequest: chunk invalid. store request ignored: %v", req))
@@ -119,6 +119,24 @@ func (self *TreeChunker) decrementWorkerCount() {
 }
 
 func (self *TreeChunker) Split(data io.Reader, size int64, chunkC chan *Chunk, swg, wwg *sync.WaitGroup) (Key, error) {
+	return self.doSplit(data, size, chunkC, swg, wwg, nil)
+}
+
+// SplitEncrypted splits the data like Split, encrypting the chunks with a new
+// key. The returned key is the root hash followed by the encryption key.
+func (self *TreeChunker) SplitEncrypted(data io.Reader, size int64, chunkC chan *Chunk, swg, wwg *sync.WaitGroup) (Key, error) {
+	cipher, err := newChunkCipher()
+	if err != nil {
+		return nil, err
+	}
+	key, err := self.doSplit(data, size, chunkC, swg, wwg, cipher)
+	if err != nil {
+		return nil, err
+	}
+	return append(key, cipher.key...), nil
+}
+
+func (self *TreeChunker) doSplit(data io.Reader, size int64, chunkC chan *Chunk, swg, wwg *sync.WaitGroup, cipher *chunkCipher) (Key, error) {
 	if self.chunkSize <= 0 {
 		panic("chunker must be initialised")
 	}
@@ -149,7 +167,7 @@ func (self *TreeChunker) Split(data io.Reader, size int64, chunkC chan *Chunk, s
 	// this waitgroup member is released after the root hash is calculated
 	wg.Add(1)
 	//launch actual recursive function passing the waitgroups
-	go self.split(depth, treeSize/self.branches, key, data, size, jobC, chunkC, errC, quitC, wg, swg, wwg)
+	go self.split(depth, treeSize/self.branches, key, data, 0, size, cipher, jobC, chunkC, errC, quitC, wg, swg, wwg)
 
 	// closes internal error channel if all subprocesses in the workgroup finished
 	go func() {
@@ -175,7 +193,7 @@ func (self *TreeChunker) Split(data io.Reader, size int64, chunkC chan *Chunk, s
 	return key, nil
 }
 
-func (self *TreeChunker) split(depth int, treeSize int64, key Key, data io.Reader, size int64, jobC chan *hashJob, chunkC chan *Chunk, errC chan error, quitC chan bool, parentWg, swg, wwg *sync.WaitGroup) {
+func (self *TreeChunker) split(depth int, treeSize int64, key Key, data io.Reader, offset int64, size int64, cipher *chunkCipher, jobC chan *hashJob, chunkC chan *Chunk, errC chan error, quitC chan bool, parentWg, swg, wwg *sync.WaitGroup) {
 
 	//
 
@@ -197,6 +215,9 @@ func (self *TreeChunker) split(depth int, treeSize int64, key Key, data io.Reade
 				return
 			}
 		}
+		if cipher != nil {
+			copy(chunkData[8:], cipher.transform(chunkData[8:], depth, offset))
+		}
 		select {
 		case jobC <- &hashJob{key, chunkData, size, parentWg}:
 		case <-quitC:
@@ -225,7 +246,7 @@ func (self *TreeChunker) split(depth int, treeSize int64, key Key, data io.Reade
 		subTreeKey := chunk[8+i*self.hashSize : 8+(i+1)*self.hashSize]
 
 		childrenWg.Add(1)
-		self.split(depth-1, treeSize/self.branches, subTreeKey, data, secSize, jobC, chunkC, errC, quitC, childrenWg, swg, wwg)
+		self.split(depth-1, treeSize/self.branches, subTreeKey, data, offset+pos, secSize, cipher, jobC, chunkC, errC, quitC, childrenWg, swg, wwg)
 
 		i++
 		pos += treeSize
@@ -234,6 +255,9 @@ func (self *TreeChunker) split(depth int, treeSize int64, key Key, data io.Reade
 	// parentWg.Add(1)
 	// go func() {
 	childrenWg.Wait()
+	if cipher != nil {
+		copy(chunk[8:], cipher.transform(chunk[8:], depth, offset))
+	}
 
 	worker := self.getWorkerCount()
 	if int64(len(jobC)) > worker && worker < ChunkProcessors {
@@ -308,24 +332,30 @@ func (self *TreeChunker) Append(key Key, data io.Reader, chunkC chan *Chunk, swg
 
 // LazyChunkReader implements LazySectionReader
 type LazyChunkReader struct {
-	key       Key         // root key
-	chunkC    chan *Chunk // chunk channel to send retrieve requests on
-	chunk     *Chunk      // size of the entire subtree
-	off       int64       // offset
-	chunkSize int64       // inherit from chunker
-	branches  int64       // inherit from chunker
-	hashSize  int64       // inherit from chunker
+	key       Key          // root key
+	chunkC    chan *Chunk  // chunk channel to send retrieve requests on
+	chunk     *Chunk       // size of the entire subtree
+	off       int64        // offset
+	chunkSize int64        // inherit from chunker
+	branches  int64        // inherit from chunker
+	hashSize  int64        // inherit from chunker
+	cipher    *chunkCipher // decrypts the chunks of encrypted content
 }
 
 // implements the Joiner interface
 func (self *TreeChunker) Join(key Key, chunkC chan *Chunk) LazySectionReader {
-	return &LazyChunkReader{
+	reader := &LazyChunkReader{
 		key:       key,
 		chunkC:    chunkC,
 		chunkSize: self.chunkSize,
 		branches:  self.branches,
 		hashSize:  self.hashSize,
 	}
+	if key.Encrypted() {
+		reader.key = key[:self.hashSize]
+		reader.cipher = &chunkCipher{key: key[self.hashSize:]}
+	}
+	return reader
 }
 
 // Size is meant to be called on the LazySectionReader
@@ -372,7 +402,7 @@ func (self *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 	}
 	wg := sync.WaitGroup{}
 	wg.Add(1)
-	go self.join(b, off, off+int64(len(b)), depth, treeSize/self.branches, self.chunk, &wg, errC, quitC)
+	go self.join(b, off, off+int64(len(b)), 0, depth, treeSize/self.branches, self.chunk, &wg, errC, quitC)
 	go func() {
 		wg.Wait()
 		close(errC)
@@ -390,7 +420,7 @@ func (self *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 	return len(b), nil
 }
 
-func (self *LazyChunkReader) join(b []byte, off int64, eoff int64, depth int, treeSize int64, chunk *Chunk, parentWg *sync.WaitGroup, errC chan error, quitC chan bool) {
+func (self *LazyChunkReader) join(b []byte, off int64, eoff int64, base int64, depth int, treeSize int64, chunk *Chunk, parentWg *sync.WaitGroup, errC chan error, quitC chan bool) {
 	defer parentWg.Done()
 	// return NewDPA(&LocalStore{})
 
@@ -402,13 +432,19 @@ func (self *LazyChunkReader) join(b []byte, off int64, eoff int64, depth int, tr
 		depth--
 	}
 
+	// chunks are shared with the caches, so encrypted ones are decrypted to a copy
+	data := chunk.SData[8:]
+	if self.cipher != nil {
+		data = self.cipher.transform(data, depth, base)
+	}
+
 	// leaf chunk found
 	if depth == 0 {
 		extra := 8 + eoff - int64(len(chunk.SData))
 		if extra > 0 {
 			eoff -= extra
 		}
-		copy(b, chunk.SData[8+off:8+eoff])
+		copy(b, data[off:eoff])
 		return // simply give back the chunks reader for content chunks
 	}
 
@@ -435,7 +471,7 @@ func (self *LazyChunkReader) join(b []byte, off int64, eoff int64, depth int, tr
 		}
 		wg.Add(1)
 		go func(j int64) {
-			childKey := chunk.SData[8+j*self.hashSize : 8+(j+1)*self.hashSize]
+			childKey := data[j*self.hashSize : (j+1)*self.hashSize]
 			chunk := retrieve(childKey, self.chunkC, quitC)
 			if chunk == nil {
 				select {
@@ -447,11 +483,56 @@ func (self *LazyChunkReader) join(b []byte, off int64, eoff int64, depth int, tr
 			if soff < off {
 				soff = off
 			}
-			self.join(b[soff-off:seoff-off], soff-roff, seoff-roff, depth-1, treeSize/self.branches, chunk, wg, errC, quitC)
+			self.join(b[soff-off:seoff-off], soff-roff, seoff-roff, base+roff, depth-1, treeSize/self.branches, chunk, wg, errC, quitC)
 		}(i)
 	} //for
 }
 
+// Walk calls walkFn for every chunk of the content, starting with the root
+// chunk and visiting the subtrees in order
+func (self *LazyChunkReader) Walk(walkFn func(*Chunk) error) error {
+	quitC := make(chan bool)
+	defer close(quitC)
+	size, err := self.Size(quitC)
+	if err != nil {
+		return err
+	}
+	var depth int
+	treeSize := self.chunkSize
+	for ; treeSize < size; treeSize *= self.branches {
+		depth++
+	}
+	return self.walk(self.chunk, 0, depth, treeSize/self.branches, walkFn, quitC)
+}
+
+func (self *LazyChunkReader) walk(chunk *Chunk, base int64, depth int, treeSize int64, walkFn func(*Chunk) error, quitC chan bool) error {
+	if err := walkFn(chunk); err != nil {
+		return err
+	}
+	for chunk.Size < treeSize && depth > 0 {
+		treeSize /= self.branches
+		depth--
+	}
+	if depth == 0 {
+		return nil
+	}
+	data := chunk.SData[8:]
+	if self.cipher != nil {
+		data = self.cipher.transform(data, depth, base)
+	}
+	for i := int64(0); (i+1)*self.hashSize <= int64(len(data)); i++ {
+		childKey := Key(data[i*self.hashSize : (i+1)*self.hashSize])
+		child := retrieve(childKey, self.chunkC, quitC)
+		if child == nil {
+			return fmt.Errorf("chunk %v not found", childKey.Log())
+		}
+		if err := self.walk(child, base+i*treeSize, depth-1, treeSize/self.branches, walkFn, quitC); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // the helper method submits chunks for a key to a oueue (DPA) and
 // block until they time out or arrive
 // abort if quitC is readable
@@ -372,6 +372,66 @@ func TestRandomBrokenData(t *testing.T) {
 	}
 }
 
+// encryptingSplitter splits through SplitEncrypted so the tester can be reused
+type encryptingSplitter struct {
+	*TreeChunker
+}
+
+func (self encryptingSplitter) Split(data io.Reader, size int64, chunkC chan *Chunk, swg, wwg *sync.WaitGroup) (Key, error) {
+	return self.SplitEncrypted(data, size, chunkC, swg, wwg)
+}
+
+func TestEncryptedData(t *testing.T) {
+	sizes := []int{60, 83, 179, 253, 1024, 4095, 4096, 4097, 8191, 8192, 8193, 12287, 12288, 12289, 123456, 2345678}
+	tester := &chunkerTester{t: t}
+	chunker := NewTreeChunker(NewChunkerParams())
+	for _, s := range sizes {
+		data, input := testDataReaderAndSlice(s)
+		key, err := tester.Split(encryptingSplitter{chunker}, data, int64(s), make(chan *Chunk, 1000), &sync.WaitGroup{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key) != 2*EncryptionKeyLength || !key.Encrypted() {
+			t.Fatalf("size %v: invalid key length %v", s, len(key))
+		}
+		for _, chunk := range tester.chunks {
+			if bytes.Contains(chunk.SData, input[:32]) {
+				t.Fatalf("size %v: chunk %v contains plaintext", s, chunk.Key.Log())
+			}
+		}
+
+		chunkC := make(chan *Chunk, 1000)
+		quitC := make(chan bool)
+		reader := tester.Join(chunker, key, 0, chunkC, quitC)
+		output := make([]byte, s)
+		if n, err := reader.Read(output); n != s || err != io.EOF {
+			t.Fatalf("size %v: read error  read: %v  err = %v", s, n, err)
+		}
+		if !bytes.Equal(output, input) {
+			t.Fatalf("size %v: input and output mismatch", s)
+		}
+		// read a section not aligned with the chunks
+		off := s / 3
+		section := make([]byte, s/2)
+		if _, err := reader.ReadAt(section, int64(off)); err != nil && err != io.EOF {
+			t.Fatalf("size %v: read error %v", s, err)
+		}
+		if !bytes.Equal(section, input[off:off+len(section)]) {
+			t.Fatalf("size %v: section mismatch at offset %v", s, off)
+		}
+		// walking the content decrypts the references to visit all chunks
+		walked := 0
+		if err := reader.(*LazyChunkReader).Walk(func(*Chunk) error { walked++; return nil }); err != nil {
+			t.Fatalf("size %v: walk error %v", s, err)
+		}
+		if walked != len(tester.chunks) {
+			t.Fatalf("size %v: walked %d chunks, stored %d", s, walked, len(tester.chunks))
+		}
+		close(chunkC)
+		<-quitC
+	}
+}
+
 func benchReadAll(reader LazySectionReader) {
 	size, _ := reader.Size(nil)
 	output := make([]byte, 1000)
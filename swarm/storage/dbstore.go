@@ -46,8 +46,10 @@ const (
 	gcArrayFreeRatio = 0.1
 
 	// key prefixes for leveldb storage
-	kpIndex = 0
-	kpData  = 1
+	kpIndex   = 0
+	kpData    = 1
+	kpPin     = 6
+	kpPinRoot = 7
 )
 
 var (
@@ -204,7 +206,9 @@ func gcListSelect(list []*gcItem, left int, right int, n int) int {
 	}
 }
 
-func (s *DbStore) collectGarbage(ratio float32) {
+// collectGarbage deletes the least accessed unpinned chunks and returns the
+// number of chunks deleted
+func (s *DbStore) collectGarbage(ratio float32) int {
 	it := s.db.NewIterator()
 	it.Seek(s.gcPos)
 	if it.Valid() {
@@ -214,7 +218,9 @@ func (s *DbStore) collectGarbage(ratio float32) {
 	}
 	gcnt := 0
 
-	for (gcnt < gcArraySize) && (uint64(gcnt) < s.entryCnt) {
+	// pinned chunks are skipped, so stop once every entry has been visited
+	var visited uint64
+	for (gcnt < gcArraySize) && (visited < s.entryCnt) {
 
 		if (s.gcPos == nil) || (s.gcPos[0] != kpIndex) {
 			it.Seek(s.gcStartPos)
@@ -229,15 +235,19 @@ func (s *DbStore) collectGarbage(ratio float32) {
 			break
 		}
 
-		gci := new(gcItem)
-		gci.idxKey = s.gcPos
-		var index dpaDBIndex
-		decodeIndex(it.Value(), &index)
-		gci.idx = index.Idx
-		// the smaller, the more likely to be gc'd
-		gci.value = getIndexGCValue(&index)
-		s.gcArray[gcnt] = gci
-		gcnt++
+		visited++
+		if !s.isPinned(Key(s.gcPos[1:])) {
+			gci := new(gcItem)
+			// the iterator reuses the key buffer, keep a copy
+			gci.idxKey = append([]byte(nil), s.gcPos...)
+			var index dpaDBIndex
+			decodeIndex(it.Value(), &index)
+			gci.idx = index.Idx
+			// the smaller, the more likely to be gc'd
+			gci.value = getIndexGCValue(&index)
+			s.gcArray[gcnt] = gci
+			gcnt++
+		}
 		it.Next()
 		if it.Valid() {
 			s.gcPos = it.Key()
@@ -247,21 +257,30 @@ func (s *DbStore) collectGarbage(ratio float32) {
 	}
 	it.Release()
 
+	if gcnt == 0 {
+		log.Warn("DbStore: no garbage to collect, all chunks are pinned")
+		s.db.Put(keyGCPos, s.gcPos)
+		return 0
+	}
+
 	cutidx := gcListSelect(s.gcArray, 0, gcnt-1, int(float32(gcnt)*ratio))
 	cutval := s.gcArray[cutidx].value
 
 	// fmt.Print(gcnt, " ", s.entryCnt, " ")
 
 	// actual gc
+	var deleted int
 	for i := 0; i < gcnt; i++ {
 		if s.gcArray[i].value <= cutval {
 			s.delete(s.gcArray[i].idx, s.gcArray[i].idxKey)
+			deleted++
 		}
 	}
 
 	// fmt.Println(s.entryCnt)
 
 	s.db.Put(keyGCPos, s.gcPos)
+	return deleted
 }
 
 // Export writes all chunks from the store to a tar archive, returning the
@@ -367,7 +386,7 @@ func (s *DbStore) Cleanup() {
 			hasher := s.hashfunc()
 			hasher.Write(data)
 			hash := hasher.Sum(nil)
-			if !bytes.Equal(hash, key[1:]) {
+			if !bytes.Equal(hash, key[1:]) && !IsResourceChunk(key[1:], data) {
 				log.Warn(fmt.Sprintf("Found invalid chunk. Hash mismatch. hash=%x, key=%x", hash, key[:]))
 				s.delete(index.Idx, getIndexKey(key[1:]))
 				errorsFound++
@@ -479,7 +498,7 @@ func (s *DbStore) Get(key Key) (chunk *Chunk, err error) {
 		hasher := s.hashfunc()
 		hasher.Write(data)
 		hash := hasher.Sum(nil)
-		if !bytes.Equal(hash, key) {
+		if !bytes.Equal(hash, key) && !IsResourceChunk(key, data) {
 			s.delete(index.Idx, getIndexKey(key))
 			log.Warn("Invalid Chunk in Database. Please repair with command: 'swarm cleandb'")
 		}
@@ -522,7 +541,9 @@ func (s *DbStore) setCapacity(c uint64) {
 			ratio = 1
 		}
 		for s.entryCnt > c {
-			s.collectGarbage(ratio)
+			if s.collectGarbage(ratio) == 0 {
+				break
+			}
 		}
 	}
 }
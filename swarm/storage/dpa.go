@@ -48,7 +48,8 @@ const (
 )
 
 var (
-	notFound = errors.New("not found")
+	notFound            = errors.New("not found")
+	errWalkNotSupported = errors.New("chunker does not support walking the chunks")
 )
 
 type DPA struct {
@@ -94,12 +95,34 @@ func (self *DPA) Retrieve(key Key) LazySectionReader {
 	return self.Chunker.Join(key, self.retrieveC)
 }
 
+// Walk calls walkFn for every chunk of the document with the given key,
+// retrieving the chunks like Retrieve
+func (self *DPA) Walk(key Key, walkFn func(*Chunk) error) error {
+	walker, ok := self.Chunker.Join(key, self.retrieveC).(interface {
+		Walk(func(*Chunk) error) error
+	})
+	if !ok {
+		return errWalkNotSupported
+	}
+	return walker.Walk(walkFn)
+}
+
 // Public API. Main entry point for document storage directly. Used by the
 // FS-aware API and httpaccess
 func (self *DPA) Store(data io.Reader, size int64, swg *sync.WaitGroup, wwg *sync.WaitGroup) (key Key, err error) {
 	return self.Chunker.Split(data, size, self.storeC, swg, wwg)
 }
 
+// StoreEncrypted stores the data like Store, but encrypted with a new key.
+// The returned key embeds the encryption key needed to retrieve the data.
+func (self *DPA) StoreEncrypted(data io.Reader, size int64, swg *sync.WaitGroup, wwg *sync.WaitGroup) (key Key, err error) {
+	splitter, ok := self.Chunker.(EncryptedSplitter)
+	if !ok {
+		return nil, errEncryptionNotSupported
+	}
+	return splitter.SplitEncrypted(data, size, self.storeC, swg, wwg)
+}
+
 func (self *DPA) Start() {
 	self.lock.Lock()
 	defer self.lock.Unlock()
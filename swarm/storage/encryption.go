@@ -0,0 +1,99 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/crypto/sha3"
+)
+
+/*
+Encrypted content is split by the TreeChunker like plaintext content, but the
+payload of every chunk, i.e. the data of leaf chunks and the child references
+of intermediate chunks, is encrypted with a symmetric key generated for the
+upload. The 8 byte span of the chunks stays readable, it is needed by the
+storage layers.
+
+The payload is XORed with a keccak256 keystream derived from the upload key
+and the position of the chunk in the tree (its depth and the offset of the
+data it covers), which is unique within an upload, so no nonce needs to be
+stored. Chunks are addressed by the hash of their encrypted content as usual.
+
+The key of encrypted content is the root chunk hash followed by the upload key,
+so references to encrypted content are twice as long: bzz://<hash><key>.
+*/
+
+// EncryptionKeyLength is the length of the symmetric key of encrypted content.
+const EncryptionKeyLength = 32
+
+var errEncryptionNotSupported = errors.New("chunker does not support encryption")
+
+// EncryptedSplitter is implemented by the chunkers able to encrypt content.
+type EncryptedSplitter interface {
+	// SplitEncrypted behaves like Split, but encrypts the chunks with a new
+	// key, returning the longer key of the encrypted content.
+	SplitEncrypted(io.Reader, int64, chan *Chunk, *sync.WaitGroup, *sync.WaitGroup) (Key, error)
+}
+
+// Encrypted returns whether the key references encrypted content.
+func (key Key) Encrypted() bool {
+	return len(key) > EncryptionKeyLength && len(key)%EncryptionKeyLength == 0
+}
+
+// chunkCipher encrypts and decrypts the chunks of a single upload.
+type chunkCipher struct {
+	key []byte
+}
+
+// newChunkCipher creates a cipher with a new random key.
+func newChunkCipher() (*chunkCipher, error) {
+	key := make([]byte, EncryptionKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &chunkCipher{key: key}, nil
+}
+
+// transform returns the encrypted (or decrypted) copy of the payload of the
+// chunk at the given depth and offset.
+func (c *chunkCipher) transform(data []byte, depth int, offset int64) []byte {
+	var (
+		out    = make([]byte, len(data))
+		seed   = make([]byte, len(c.key)+20)
+		hasher = sha3.NewKeccak256()
+		stream []byte
+	)
+	copy(seed, c.key)
+	binary.BigEndian.PutUint64(seed[len(c.key):], uint64(depth))
+	binary.BigEndian.PutUint64(seed[len(c.key)+8:], uint64(offset))
+
+	for i := range data {
+		if i%hasher.Size() == 0 {
+			binary.BigEndian.PutUint32(seed[len(c.key)+16:], uint32(i/hasher.Size()))
+			hasher.Reset()
+			hasher.Write(seed)
+			stream = hasher.Sum(stream[:0])
+		}
+		out[i] = data[i] ^ stream[i%hasher.Size()]
+	}
+	return out
+}
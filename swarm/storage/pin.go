@@ -0,0 +1,309 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MeshBoxFoundation/meshbox/log"
+	"github.com/MeshBoxFoundation/meshbox/rlp"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+/*
+Pinned chunks are exempt from garbage collection in the DbStore.
+
+Content is pinned by its root key. All chunks of the content are given a
+reference count, so chunks shared by several pinned roots stay pinned until
+all of them are unpinned. Pinning the same root again increments the counts
+again, it then has to be unpinned as many times. The pin records whether only
+the root content or also a manifest's references were pinned, so the same
+chunks are released when unpinning.
+
+The pins are kept in the chunk database next to the index:
+
+	kpPin     || chunk key -> rlp(pinCount)
+	kpPinRoot || root key  -> rlp(PinInfo)
+*/
+
+var (
+	ErrNotPinned          = errors.New("content is not pinned")
+	ErrPinRawMismatch     = errors.New("content is already pinned with another raw setting")
+	errPinningUnsupported = errors.New("chunk store does not support pinning")
+)
+
+// Pinner is implemented by the chunk stores able to exempt the chunks of
+// pinned content from garbage collection.
+type Pinner interface {
+	// Pin pins the chunks of the content with the given root, chunks maps
+	// the chunk keys to the chunk sizes and raw tells whether they are the
+	// chunks of the root content only
+	Pin(root Key, chunks map[string]uint64, raw bool) error
+	// Unpin releases one pin of the content with the given root
+	Unpin(root Key, chunks map[string]uint64) error
+	// Pinned returns the pin of the content with the given root
+	Pinned(root Key) (*PinInfo, error)
+	// Pins lists the pinned contents
+	Pins() []*PinInfo
+	// PinStats reports the size of the pinned content
+	PinStats() *PinStats
+}
+
+// PinInfo describes pinned content.
+type PinInfo struct {
+	Key    Key    `json:"key"`
+	Count  uint64 `json:"count"`  // number of times the content is pinned
+	Chunks uint64 `json:"chunks"` // number of chunks of the content
+	Size   uint64 `json:"size"`   // total size of the chunks
+	Raw    bool   `json:"raw"`    // whether the content referenced by a manifest is left out
+}
+
+// PinStats reports the pinned size against the capacity of the store.
+type PinStats struct {
+	PinnedChunks uint64 `json:"pinnedChunks"`
+	PinnedSize   uint64 `json:"pinnedSize"`
+	Entries      uint64 `json:"entries"`
+	Capacity     uint64 `json:"capacity"`
+}
+
+// pinCount is the pin reference count of a chunk.
+type pinCount struct {
+	Count uint64
+	Size  uint64
+}
+
+func getPinKey(key Key) []byte {
+	return append([]byte{kpPin}, key...)
+}
+
+func getPinRootKey(key Key) []byte {
+	return append([]byte{kpPinRoot}, key...)
+}
+
+// isPinned returns whether the chunk with the given key is pinned.
+func (s *DbStore) isPinned(key Key) bool {
+	_, err := s.db.Get(getPinKey(key))
+	return err == nil
+}
+
+func (s *DbStore) Pin(root Key, chunks map[string]uint64, raw bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	info := &PinInfo{Key: root, Raw: raw}
+	if data, err := s.db.Get(getPinRootKey(root)); err == nil {
+		if err := rlp.DecodeBytes(data, info); err != nil {
+			return err
+		}
+		if info.Raw != raw {
+			return ErrPinRawMismatch
+		}
+	}
+	info.Count++
+	info.Chunks, info.Size = 0, 0
+	for key, size := range chunks {
+		var count pinCount
+		if data, err := s.db.Get(getPinKey(Key(key))); err == nil {
+			if err := rlp.DecodeBytes(data, &count); err != nil {
+				return err
+			}
+		}
+		count.Count++
+		count.Size = size
+		data, _ := rlp.EncodeToBytes(&count)
+		batch.Put(getPinKey(Key(key)), data)
+		info.Chunks++
+		info.Size += size
+	}
+	data, _ := rlp.EncodeToBytes(info)
+	batch.Put(getPinRootKey(root), data)
+	if err := s.db.Write(batch); err != nil {
+		return err
+	}
+	log.Debug(fmt.Sprintf("DbStore: pinned %v (%d chunks, %d bytes)", root.Log(), info.Chunks, info.Size))
+	return nil
+}
+
+func (s *DbStore) Unpin(root Key, chunks map[string]uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, err := s.db.Get(getPinRootKey(root))
+	if err != nil {
+		return ErrNotPinned
+	}
+	info := new(PinInfo)
+	if err := rlp.DecodeBytes(data, info); err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	for key := range chunks {
+		var count pinCount
+		data, err := s.db.Get(getPinKey(Key(key)))
+		if err != nil {
+			continue
+		}
+		if err := rlp.DecodeBytes(data, &count); err != nil {
+			return err
+		}
+		if count.Count <= 1 {
+			batch.Delete(getPinKey(Key(key)))
+			continue
+		}
+		count.Count--
+		data, _ = rlp.EncodeToBytes(&count)
+		batch.Put(getPinKey(Key(key)), data)
+	}
+	if info.Count <= 1 {
+		batch.Delete(getPinRootKey(root))
+	} else {
+		info.Count--
+		data, _ := rlp.EncodeToBytes(info)
+		batch.Put(getPinRootKey(root), data)
+	}
+	if err := s.db.Write(batch); err != nil {
+		return err
+	}
+	log.Debug(fmt.Sprintf("DbStore: unpinned %v", root.Log()))
+	return nil
+}
+
+func (s *DbStore) Pinned(root Key) (*PinInfo, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, err := s.db.Get(getPinRootKey(root))
+	if err != nil {
+		return nil, ErrNotPinned
+	}
+	info := new(PinInfo)
+	if err := rlp.DecodeBytes(data, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *DbStore) Pins() []*PinInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var pins []*PinInfo
+	it := s.db.NewIterator()
+	defer it.Release()
+	for ok := it.Seek([]byte{kpPinRoot}); ok; ok = it.Next() {
+		key := it.Key()
+		if key[0] != kpPinRoot {
+			break
+		}
+		info := new(PinInfo)
+		if err := rlp.DecodeBytes(it.Value(), info); err != nil {
+			log.Warn(fmt.Sprintf("DbStore: invalid pin %x: %v", key[1:], err))
+			continue
+		}
+		pins = append(pins, info)
+	}
+	return pins
+}
+
+func (s *DbStore) PinStats() *PinStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := &PinStats{
+		Entries:  s.entryCnt,
+		Capacity: s.capacity,
+	}
+	it := s.db.NewIterator()
+	defer it.Release()
+	for ok := it.Seek([]byte{kpPin}); ok; ok = it.Next() {
+		if it.Key()[0] != kpPin {
+			break
+		}
+		var count pinCount
+		if err := rlp.DecodeBytes(it.Value(), &count); err != nil {
+			continue
+		}
+		stats.PinnedChunks++
+		stats.PinnedSize += count.Size
+	}
+	return stats
+}
+
+// pinner returns the persistent local chunk store of the DPA if it supports
+// pinning.
+func (self *DPA) pinner() (Pinner, error) {
+	store := self.ChunkStore
+	if dpaStore, ok := store.(*dpaChunkStore); ok {
+		store = dpaStore.localStore
+	}
+	if localStore, ok := store.(*LocalStore); ok {
+		store = localStore.DbStore
+	}
+	if pinner, ok := store.(Pinner); ok {
+		return pinner, nil
+	}
+	return nil, errPinningUnsupported
+}
+
+// Pin pins the given chunks of the content with the given root in the local
+// store, see Pinner
+func (self *DPA) Pin(root Key, chunks map[string]uint64, raw bool) error {
+	pinner, err := self.pinner()
+	if err != nil {
+		return err
+	}
+	return pinner.Pin(root, chunks, raw)
+}
+
+// Unpin releases a pin of the content with the given root, see Pinner
+func (self *DPA) Unpin(root Key, chunks map[string]uint64) error {
+	pinner, err := self.pinner()
+	if err != nil {
+		return err
+	}
+	return pinner.Unpin(root, chunks)
+}
+
+// Pinned returns the pin of the content with the given root
+func (self *DPA) Pinned(root Key) (*PinInfo, error) {
+	pinner, err := self.pinner()
+	if err != nil {
+		return nil, err
+	}
+	return pinner.Pinned(root)
+}
+
+// Pins lists the content pinned in the local store
+func (self *DPA) Pins() ([]*PinInfo, error) {
+	pinner, err := self.pinner()
+	if err != nil {
+		return nil, err
+	}
+	return pinner.Pins(), nil
+}
+
+// PinStats reports the size of the content pinned in the local store
+func (self *DPA) PinStats() (*PinStats, error) {
+	pinner, err := self.pinner()
+	if err != nil {
+		return nil, err
+	}
+	return pinner.PinStats(), nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+)
+
+func newTestDbChunk(s *DbStore) *Chunk {
+	data := make([]byte, 8+64)
+	binary.LittleEndian.PutUint64(data, 64)
+	rand.Read(data[8:])
+	hasher := s.hashfunc()
+	hasher.Write(data)
+	return &Chunk{Key: hasher.Sum(nil), SData: data, Size: 64}
+}
+
+func TestDbStorePinnedChunksNotCollected(t *testing.T) {
+	s := initDbStore(t)
+	defer s.Close()
+	s.setCapacity(100)
+
+	pinned := make(map[string]uint64)
+	var root Key
+	for i := 0; i < 50; i++ {
+		chunk := newTestDbChunk(s)
+		s.Put(chunk)
+		if root == nil {
+			root = chunk.Key
+		}
+		pinned[string(chunk.Key)] = uint64(len(chunk.SData))
+	}
+	if err := s.Pin(root, pinned, false); err != nil {
+		t.Fatal(err)
+	}
+	// pinning twice needs two unpins
+	if err := s.Pin(root, pinned, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// fill the store way beyond its capacity
+	for i := 0; i < 1000; i++ {
+		s.Put(newTestDbChunk(s))
+	}
+	if s.entryCnt > 100 {
+		t.Fatalf("garbage not collected: %d entries stored, capacity 100", s.entryCnt)
+	}
+	for key := range pinned {
+		if _, err := s.Get(Key(key)); err != nil {
+			t.Fatalf("pinned chunk %x collected", key)
+		}
+	}
+
+	if err := s.Pin(root, pinned, true); err != ErrPinRawMismatch {
+		t.Fatalf("expected %v pinning with another raw setting, got %v", ErrPinRawMismatch, err)
+	}
+	if pin, err := s.Pinned(root); err != nil || pin.Count != 2 || pin.Raw {
+		t.Fatalf("unexpected pin: %+v %v", pin, err)
+	}
+	pins := s.Pins()
+	if len(pins) != 1 || pins[0].Count != 2 || pins[0].Chunks != 50 || pins[0].Size != 50*72 {
+		t.Fatalf("unexpected pins: %+v", pins)
+	}
+	stats := s.PinStats()
+	if stats.PinnedChunks != 50 || stats.PinnedSize != 50*72 || stats.Capacity != 100 {
+		t.Fatalf("unexpected pin stats: %+v", stats)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Unpin(root, pinned); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Unpin(root, pinned); err != ErrNotPinned {
+		t.Fatalf("expected %v, got %v", ErrNotPinned, err)
+	}
+	if _, err := s.Pinned(root); err != ErrNotPinned {
+		t.Fatalf("expected %v, got %v", ErrNotPinned, err)
+	}
+	if pins := s.Pins(); len(pins) != 0 {
+		t.Fatalf("unexpected pins after unpinning: %+v", pins)
+	}
+	if stats := s.PinStats(); stats.PinnedChunks != 0 {
+		t.Fatalf("unexpected pin stats after unpinning: %+v", stats)
+	}
+}
+
+func TestDbStoreAllPinned(t *testing.T) {
+	s := initDbStore(t)
+	defer s.Close()
+	s.setCapacity(10)
+
+	pinned := make(map[string]uint64)
+	for i := 0; i < 10; i++ {
+		chunk := newTestDbChunk(s)
+		s.Put(chunk)
+		pinned[string(chunk.Key)] = uint64(len(chunk.SData))
+	}
+	if err := s.Pin(Key("root"), pinned, false); err != nil {
+		t.Fatal(err)
+	}
+	// storing beyond capacity must not loop forever with nothing to collect
+	s.Put(newTestDbChunk(s))
+	if s.entryCnt != 11 {
+		t.Fatalf("expected 11 entries, got %d", s.entryCnt)
+	}
+}
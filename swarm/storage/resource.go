@@ -0,0 +1,317 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/MeshBoxFoundation/meshbox/common"
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+	"github.com/MeshBoxFoundation/meshbox/log"
+)
+
+/*
+Mutable resources give a fixed address to content that changes over time.
+
+A resource is identified by its name and the address of its owner, the
+resource address is keccak256(name || owner). The owner publishes numbered
+versions of the resource, starting at 1, each stored as a single chunk under
+the key keccak256(resource address || version).
+
+The key of an update chunk is not the hash of its data. Instead the update is
+signed by the owner and the chunk is valid if the key derived from the name,
+the version and the recovered signer matches, so update chunks can be checked
+by any node without looking up anything else.
+
+Layout of the chunk data:
+
+	span (8, little endian length of the rest, as for content chunks)
+	version (8, big endian)
+	name length (2, big endian)
+	name
+	data
+	signature (65) of keccak256(version || name length || name || data)
+*/
+
+const (
+	resourceHeaderLength = 8 + 2
+	resourceSigLength    = 65
+
+	// MaxResourceDataLength is the maximum size of the data of a resource
+	// update with an empty name, longer names reduce the available space.
+	MaxResourceDataLength = int(DefaultBranches)*32 - resourceHeaderLength - resourceSigLength
+
+	maxResourceNameLength = 256
+)
+
+var (
+	ErrResourceNotFound   = errors.New("resource not found")
+	ErrResourceExists     = errors.New("resource already exists")
+	ErrResourceNotOwner   = errors.New("not the owner of the resource")
+	ErrResourceNoKey      = errors.New("no key to sign resource updates")
+	errInvalidResourceLen = errors.New("invalid resource update length")
+)
+
+// ResourceUpdate is a version of a mutable resource.
+type ResourceUpdate struct {
+	Name    string
+	Owner   common.Address
+	Version uint64
+	Data    []byte
+}
+
+// ResourceAddr returns the address of the resource with the given name and owner.
+func ResourceAddr(name string, owner common.Address) Key {
+	return crypto.Keccak256([]byte(name), owner[:])
+}
+
+// resourceUpdateKey returns the key of the chunk holding a version of a resource.
+func resourceUpdateKey(addr Key, version uint64) Key {
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], version)
+	return crypto.Keccak256(addr, v[:])
+}
+
+// newResourceChunk signs the update and returns the chunk holding it.
+func newResourceChunk(update *ResourceUpdate, prvKey *ecdsa.PrivateKey) (*Chunk, error) {
+	if len(update.Name) > maxResourceNameLength {
+		return nil, fmt.Errorf("resource name too long (%d > %d)", len(update.Name), maxResourceNameLength)
+	}
+	if len(update.Name)+len(update.Data) > MaxResourceDataLength {
+		return nil, fmt.Errorf("resource update too big (%d > %d)", len(update.Name)+len(update.Data), MaxResourceDataLength)
+	}
+	size := resourceHeaderLength + len(update.Name) + len(update.Data)
+	sdata := make([]byte, 8+size+resourceSigLength)
+	binary.LittleEndian.PutUint64(sdata, uint64(size+resourceSigLength))
+	binary.BigEndian.PutUint64(sdata[8:], update.Version)
+	binary.BigEndian.PutUint16(sdata[16:], uint16(len(update.Name)))
+	copy(sdata[18:], update.Name)
+	copy(sdata[18+len(update.Name):], update.Data)
+
+	sig, err := crypto.Sign(crypto.Keccak256(sdata[8:8+size]), prvKey)
+	if err != nil {
+		return nil, err
+	}
+	copy(sdata[8+size:], sig)
+
+	owner := crypto.PubkeyToAddress(prvKey.PublicKey)
+	return &Chunk{
+		Key:   resourceUpdateKey(ResourceAddr(update.Name, owner), update.Version),
+		SData: sdata,
+		Size:  int64(size + resourceSigLength),
+	}, nil
+}
+
+// parseResourceChunk decodes the update held by the chunk data and checks it
+// is signed by the owner of the resource the key belongs to.
+func parseResourceChunk(key Key, sdata []byte) (*ResourceUpdate, error) {
+	if len(sdata) < 8+resourceHeaderLength+resourceSigLength {
+		return nil, errInvalidResourceLen
+	}
+	payload := sdata[8 : len(sdata)-resourceSigLength]
+	nameLen := int(binary.BigEndian.Uint16(payload[8:]))
+	if resourceHeaderLength+nameLen > len(payload) {
+		return nil, errInvalidResourceLen
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(payload), sdata[len(sdata)-resourceSigLength:])
+	if err != nil {
+		return nil, err
+	}
+	update := &ResourceUpdate{
+		Name:    string(payload[resourceHeaderLength : resourceHeaderLength+nameLen]),
+		Owner:   crypto.PubkeyToAddress(*pub),
+		Version: binary.BigEndian.Uint64(payload),
+		Data:    common.CopyBytes(payload[resourceHeaderLength+nameLen:]),
+	}
+	if !bytes.Equal(resourceUpdateKey(ResourceAddr(update.Name, update.Owner), update.Version), key) {
+		return nil, fmt.Errorf("resource update %v does not match its key", key.Log())
+	}
+	return update, nil
+}
+
+// IsResourceChunk returns whether the data is a valid resource update stored
+// under key. Such chunks don't hash to their key but are accepted by the stores.
+func IsResourceChunk(key Key, sdata []byte) bool {
+	_, err := parseResourceChunk(key, sdata)
+	return err == nil
+}
+
+// ResourceHandler publishes and looks up the versions of mutable resources.
+type ResourceHandler struct {
+	store      ChunkStore
+	localStore ChunkStore        // answers without asking the network
+	prvKey     *ecdsa.PrivateKey // signs the updates of the resources owned by the node
+	lock       sync.Mutex        // serialises updates so versions are not reused
+
+	versions     map[string]uint64 // last known version per resource address
+	versionsLock sync.Mutex
+}
+
+// NewResourceHandler creates a handler storing the updates in store, and
+// checking localStore alone where a missing update is expected. Updates are
+// signed with prvKey, without a key resources can only be looked up.
+func NewResourceHandler(store, localStore ChunkStore, prvKey *ecdsa.PrivateKey) *ResourceHandler {
+	return &ResourceHandler{
+		store:      store,
+		localStore: localStore,
+		prvKey:     prvKey,
+		versions:   make(map[string]uint64),
+	}
+}
+
+// Create publishes the first version of a new resource owned by the handler
+// key and returns the address of the resource. Only the local store is checked
+// for an existing resource, the network is not asked for a chunk that usually
+// isn't there.
+func (self *ResourceHandler) Create(name string, data []byte) (Key, error) {
+	if self.prvKey == nil {
+		return nil, ErrResourceNoKey
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	addr := ResourceAddr(name, crypto.PubkeyToAddress(self.prvKey.PublicKey))
+	if _, err := self.getFrom(self.localStore, addr, 1); err == nil {
+		return nil, ErrResourceExists
+	}
+	if err := self.put(&ResourceUpdate{Name: name, Version: 1, Data: data}); err != nil {
+		return nil, err
+	}
+	self.setVersion(addr, 1)
+	log.Debug(fmt.Sprintf("resource %q created at %v", name, addr.Log()))
+	return addr, nil
+}
+
+// Update publishes the next version of the resource and returns its number.
+func (self *ResourceHandler) Update(addr Key, data []byte) (uint64, error) {
+	if self.prvKey == nil {
+		return 0, ErrResourceNoKey
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	latest, err := self.latest(addr)
+	if err != nil {
+		return 0, err
+	}
+	if latest.Owner != crypto.PubkeyToAddress(self.prvKey.PublicKey) {
+		return 0, ErrResourceNotOwner
+	}
+	version := latest.Version + 1
+	if err := self.put(&ResourceUpdate{Name: latest.Name, Version: version, Data: data}); err != nil {
+		return 0, err
+	}
+	self.setVersion(addr, version)
+	log.Debug(fmt.Sprintf("resource %v updated to version %d", addr.Log(), version))
+	return version, nil
+}
+
+// Lookup returns the given version of the resource, or the latest one if
+// version is 0.
+func (self *ResourceHandler) Lookup(addr Key, version uint64) (*ResourceUpdate, error) {
+	if version == 0 {
+		return self.latest(addr)
+	}
+	return self.get(addr, version)
+}
+
+// latest finds the highest version of the resource. Versions are published
+// in sequence, so starting from the last known version it steps up until the
+// next version is missing. The first time a resource is looked up it finds the
+// first missing version by doubling the version and then bisecting.
+func (self *ResourceHandler) latest(addr Key) (*ResourceUpdate, error) {
+	if known := self.version(addr); known > 0 {
+		if update, err := self.get(addr, known); err == nil {
+			for {
+				next, err := self.get(addr, update.Version+1)
+				if err != nil {
+					break
+				}
+				update = next
+			}
+			self.setVersion(addr, update.Version)
+			return update, nil
+		}
+	}
+	update, err := self.get(addr, 1)
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := uint64(1), uint64(2)
+	for {
+		next, err := self.get(addr, hi)
+		if err != nil {
+			break
+		}
+		update, lo, hi = next, hi, hi*2
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if next, err := self.get(addr, mid); err == nil {
+			update, lo = next, mid
+		} else {
+			hi = mid
+		}
+	}
+	self.setVersion(addr, update.Version)
+	return update, nil
+}
+
+// version returns the last known version of the resource, 0 if unknown.
+func (self *ResourceHandler) version(addr Key) uint64 {
+	self.versionsLock.Lock()
+	defer self.versionsLock.Unlock()
+
+	return self.versions[string(addr)]
+}
+
+// setVersion records a version of the resource seen, unless a later one is known.
+func (self *ResourceHandler) setVersion(addr Key, version uint64) {
+	self.versionsLock.Lock()
+	defer self.versionsLock.Unlock()
+
+	if version > self.versions[string(addr)] {
+		self.versions[string(addr)] = version
+	}
+}
+
+func (self *ResourceHandler) get(addr Key, version uint64) (*ResourceUpdate, error) {
+	return self.getFrom(self.store, addr, version)
+}
+
+func (self *ResourceHandler) getFrom(store ChunkStore, addr Key, version uint64) (*ResourceUpdate, error) {
+	key := resourceUpdateKey(addr, version)
+	chunk, err := store.Get(key)
+	if err != nil || chunk == nil || len(chunk.SData) == 0 {
+		return nil, ErrResourceNotFound
+	}
+	return parseResourceChunk(key, chunk.SData)
+}
+
+func (self *ResourceHandler) put(update *ResourceUpdate) error {
+	chunk, err := newResourceChunk(update, self.prvKey)
+	if err != nil {
+		return err
+	}
+	self.store.Put(chunk)
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 The Spectrum Authors
+// This file is part of the Spectrum library.
+//
+// The Spectrum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Spectrum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Spectrum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/MeshBoxFoundation/meshbox/crypto"
+)
+
+func TestResourceUpdates(t *testing.T) {
+	// the db store checks the chunks hash to their key when they are read
+	store := initDbStore(t)
+	defer store.Close()
+
+	prvKey, _ := crypto.GenerateKey()
+	owner := crypto.PubkeyToAddress(prvKey.PublicKey)
+	rh := NewResourceHandler(store, store, prvKey)
+
+	addr, err := rh.Create("firmware", []byte("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(addr, ResourceAddr("firmware", owner)) {
+		t.Fatalf("resource address mismatch: have %x, want %x", addr, ResourceAddr("firmware", owner))
+	}
+	if _, err := rh.Create("firmware", []byte("v1")); err != ErrResourceExists {
+		t.Fatalf("expected %v creating the resource twice, got %v", ErrResourceExists, err)
+	}
+
+	for i := 2; i <= 11; i++ {
+		version, err := rh.Update(addr, []byte(fmt.Sprintf("v%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != uint64(i) {
+			t.Fatalf("update %d published as version %d", i, version)
+		}
+	}
+
+	latest, err := rh.Lookup(addr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != 11 || string(latest.Data) != "v11" || latest.Name != "firmware" || latest.Owner != owner {
+		t.Fatalf("unexpected latest version: %+v", latest)
+	}
+	for i := 1; i <= 11; i++ {
+		update, err := rh.Lookup(addr, uint64(i))
+		if err != nil {
+			t.Fatalf("version %d: %v", i, err)
+		}
+		if string(update.Data) != fmt.Sprintf("v%d", i) {
+			t.Fatalf("version %d: unexpected data %q", i, update.Data)
+		}
+	}
+	if _, err := rh.Lookup(addr, 12); err != ErrResourceNotFound {
+		t.Fatalf("expected %v for a missing version, got %v", ErrResourceNotFound, err)
+	}
+
+	// only the owner can publish updates, but anybody can read them
+	otherKey, _ := crypto.GenerateKey()
+	other := NewResourceHandler(store, store, otherKey)
+	if _, err := other.Update(addr, []byte("evil")); err != ErrResourceNotOwner {
+		t.Fatalf("expected %v updating someone else's resource, got %v", ErrResourceNotOwner, err)
+	}
+	if update, err := NewResourceHandler(store, store, nil).Lookup(addr, 0); err != nil || update.Version != 11 {
+		t.Fatalf("lookup without key failed: %v %+v", err, update)
+	}
+}
+
+// countingStore counts the chunks requested from the store it wraps.
+type countingStore struct {
+	ChunkStore
+	gets int
+}
+
+func (s *countingStore) Get(key Key) (*Chunk, error) {
+	s.gets++
+	return s.ChunkStore.Get(key)
+}
+
+func TestResourceVersionCache(t *testing.T) {
+	store := initDbStore(t)
+	defer store.Close()
+	net := &countingStore{ChunkStore: store}
+
+	prvKey, _ := crypto.GenerateKey()
+	rh := NewResourceHandler(net, store, prvKey)
+
+	// the existence check of a new resource doesn't go to the network
+	addr, err := rh.Create("firmware", []byte("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.gets != 0 {
+		t.Fatalf("resource creation requested %d chunks from the network", net.gets)
+	}
+	for i := 2; i <= 11; i++ {
+		if _, err := rh.Update(addr, []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the latest version is found from the last known one
+	lookup := func(rh *ResourceHandler, version uint64, gets int) {
+		net.gets = 0
+		update, err := rh.Lookup(addr, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if update.Version != version {
+			t.Fatalf("expected version %d, got %d", version, update.Version)
+		}
+		if gets > 0 && net.gets != gets {
+			t.Fatalf("version %d: expected %d chunk requests, got %d", version, gets, net.gets)
+		}
+	}
+	lookup(rh, 11, 2)
+
+	other := NewResourceHandler(net, store, nil)
+	lookup(other, 11, 0)
+	lookup(other, 11, 2)
+	if _, err := rh.Update(addr, []byte("v12")); err != nil {
+		t.Fatal(err)
+	}
+	lookup(other, 12, 3)
+}
+
+func TestResourceChunkValidation(t *testing.T) {
+	prvKey, _ := crypto.GenerateKey()
+	chunk, err := newResourceChunk(&ResourceUpdate{Name: "name", Version: 3, Data: []byte("data")}, prvKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsResourceChunk(chunk.Key, chunk.SData) {
+		t.Fatal("valid resource chunk rejected")
+	}
+
+	// tampered data or a different key must be rejected
+	tampered := make([]byte, len(chunk.SData))
+	copy(tampered, chunk.SData)
+	tampered[20]++
+	if IsResourceChunk(chunk.Key, tampered) {
+		t.Fatal("tampered resource chunk accepted")
+	}
+	if IsResourceChunk(resourceUpdateKey(ResourceAddr("name", crypto.PubkeyToAddress(prvKey.PublicKey)), 4), chunk.SData) {
+		t.Fatal("resource chunk accepted under another version")
+	}
+	if IsResourceChunk(chunk.Key, chunk.SData[:20]) {
+		t.Fatal("truncated resource chunk accepted")
+	}
+
+	if _, err := newResourceChunk(&ResourceUpdate{Data: make([]byte, MaxResourceDataLength+1)}, prvKey); err == nil {
+		t.Fatal("oversized resource update accepted")
+	}
+}
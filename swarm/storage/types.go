@@ -116,8 +116,13 @@ func (key Key) MarshalJSON() (out []byte, err error) {
 
 func (key *Key) UnmarshalJSON(value []byte) error {
 	s := string(value)
-	*key = make([]byte, 32)
 	h := common.Hex2Bytes(s[1 : len(s)-1])
+	// keys of encrypted content are longer than a hash
+	if len(h) <= 32 {
+		*key = make([]byte, 32)
+	} else {
+		*key = make([]byte, len(h))
+	}
 	copy(*key, h)
 	return nil
 }
@@ -146,7 +146,11 @@ func NewSwarm(ctx *node.ServiceContext, backend chequebook.Backend, ensClient *e
 	}
 	log.Debug(fmt.Sprintf("-> Swarm Domain Name Registrar @ address %v", config.EnsRoot.Hex()))
 
-	self.api = api.NewApi(self.dpa, self.dns)
+	// mutable resources are published with the swarm account key
+	resource := storage.NewResourceHandler(self.dpa, self.lstore, self.privateKey)
+	log.Debug(fmt.Sprintf("-> Mutable resource handler"))
+
+	self.api = api.NewApi(self.dpa, self.dns, resource, self.privateKey)
 	// Manifests for Smart Hosting
 	log.Debug(fmt.Sprintf("-> Web3 virtual server API"))
 
@@ -324,7 +328,7 @@ func NewLocalSwarm(datadir, port string) (self *Swarm, err error) {
 	}
 
 	self = &Swarm{
-		api:    api.NewApi(dpa, nil),
+		api:    api.NewApi(dpa, nil, storage.NewResourceHandler(dpa, dpa, prvKey), prvKey),
 		config: config,
 	}
 
@@ -17,11 +17,13 @@
 package testutil
 
 import (
+	"crypto/ecdsa"
 	"io/ioutil"
 	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/swarm/api"
 	httpapi "github.com/MeshBoxFoundation/meshbox/swarm/api/http"
 	"github.com/MeshBoxFoundation/meshbox/swarm/storage"
@@ -49,11 +51,17 @@ func NewTestSwarmServer(t *testing.T) *TestSwarmServer {
 		ChunkStore: localStore,
 	}
 	dpa.Start()
-	a := api.NewApi(dpa, nil)
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	a := api.NewApi(dpa, nil, storage.NewResourceHandler(dpa, localStore, prvKey), prvKey)
 	srv := httptest.NewServer(httpapi.NewServer(a))
 	return &TestSwarmServer{
 		Server: srv,
 		Dpa:    dpa,
+		PrvKey: prvKey,
 		dir:    dir,
 	}
 }
@@ -61,8 +69,9 @@ func NewTestSwarmServer(t *testing.T) *TestSwarmServer {
 type TestSwarmServer struct {
 	*httptest.Server
 
-	Dpa *storage.DPA
-	dir string
+	Dpa    *storage.DPA
+	PrvKey *ecdsa.PrivateKey
+	dir    string
 }
 
 func (t *TestSwarmServer) Close() {
@@ -18,7 +18,9 @@ package mailserver
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"github.com/MeshBoxFoundation/meshbox/cmd/utils"
@@ -26,16 +28,19 @@ import (
 	"github.com/MeshBoxFoundation/meshbox/crypto"
 	"github.com/MeshBoxFoundation/meshbox/log"
 	"github.com/MeshBoxFoundation/meshbox/rlp"
-	whisper "github.com/MeshBoxFoundation/meshbox/whisper/whisperv5"
+	whisper "github.com/MeshBoxFoundation/meshbox/whisper/whisperv6"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 type WMailServer struct {
-	db  *leveldb.DB
-	w   *whisper.Whisper
-	pow float64
-	key []byte
+	db     *leveldb.DB
+	w      *whisper.Whisper
+	pow    float64
+	key    []byte
+	prvKey *ecdsa.PrivateKey // signs the responses
+
+	pageSize uint32 // maximum number of envelopes delivered per request
 }
 
 type DBKey struct {
@@ -44,18 +49,98 @@ type DBKey struct {
 	raw       []byte
 }
 
+// DBKeyLength is the length of the archive keys, which also serve as cursors
+const DBKeyLength = common.HashLength + 4
+
+// MaxPageSize is the maximum number of envelopes delivered per request, larger
+// or unlimited requests get their envelopes over several pages
+const MaxPageSize = 1000
+
 func NewDbKey(t uint32, h common.Hash) *DBKey {
-	const sz = common.HashLength + 4
 	var k DBKey
 	k.timestamp = t
 	k.hash = h
-	k.raw = make([]byte, sz)
+	k.raw = make([]byte, DBKeyLength)
 	binary.BigEndian.PutUint32(k.raw, k.timestamp)
 	copy(k.raw[4:], k.hash[:])
 	return &k
 }
 
-func (s *WMailServer) Init(shh *whisper.Whisper, path string, password string, pow float64) {
+/*
+A mail request is a p2p message encrypted with the symmetric key of the mail
+server and signed by the requesting peer. Its payload is:
+
+	lower (4, big endian): lower bound of the time range
+	upper (4, big endian): upper bound of the time range, excluded
+	bloom (64): bloom filter of the topics to deliver
+	limit (4, big endian): maximum number of envelopes delivered, 0 for the
+	                       server maximum
+	cursor (0 or 36): archive key to resume a previous request from
+
+Older requests carry a single topic or no topic at all instead of the bloom
+filter and no limit, they get the first page only.
+
+The envelopes of the page are delivered as p2p messages, followed by a
+response encrypted with the same key, under the topic of the request and
+signed by the server. Its payload is the hash of the request envelope, the
+hash of the last delivered envelope and the cursor of the next page, which is
+empty once all the envelopes have been delivered.
+*/
+
+// MailRequest is a request for archived envelopes.
+type MailRequest struct {
+	Lower  uint32
+	Upper  uint32
+	Bloom  []byte // nil to match all topics
+	Limit  uint32
+	Cursor []byte
+}
+
+// Payload returns the payload of the request envelope.
+func (r *MailRequest) Payload() []byte {
+	bloom := r.Bloom
+	if bloom == nil {
+		bloom = bytes.Repeat([]byte{0xff}, whisper.BloomFilterSize)
+	}
+	data := make([]byte, 8+whisper.BloomFilterSize+4, 8+whisper.BloomFilterSize+4+len(r.Cursor))
+	binary.BigEndian.PutUint32(data, r.Lower)
+	binary.BigEndian.PutUint32(data[4:], r.Upper)
+	copy(data[8:], bloom)
+	binary.BigEndian.PutUint32(data[8+whisper.BloomFilterSize:], r.Limit)
+	return append(data, r.Cursor...)
+}
+
+// MailResponse is sent after the envelopes delivered for a request.
+type MailResponse struct {
+	RequestHash      common.Hash
+	LastEnvelopeHash common.Hash // zero if no envelope was delivered
+	Cursor           []byte      // empty if there are no more envelopes
+}
+
+// Payload returns the payload of the response envelope.
+func (r *MailResponse) Payload() []byte {
+	data := make([]byte, 0, 2*common.HashLength+len(r.Cursor))
+	data = append(data, r.RequestHash[:]...)
+	data = append(data, r.LastEnvelopeHash[:]...)
+	return append(data, r.Cursor...)
+}
+
+// ParseMailResponse decodes the payload of a response envelope.
+func ParseMailResponse(payload []byte) (*MailResponse, error) {
+	if len(payload) != 2*common.HashLength && len(payload) != 2*common.HashLength+DBKeyLength {
+		return nil, errors.New("invalid mail server response length")
+	}
+	return &MailResponse{
+		RequestHash:      common.BytesToHash(payload[:common.HashLength]),
+		LastEnvelopeHash: common.BytesToHash(payload[common.HashLength : 2*common.HashLength]),
+		Cursor:           common.CopyBytes(payload[2*common.HashLength:]),
+	}, nil
+}
+
+// Init opens the archive. The requests are encrypted with a key derived from
+// password, the responses are signed with prvKey, the node key so that peers
+// can check them against the enode of the server.
+func (s *WMailServer) Init(shh *whisper.Whisper, path string, password string, pow float64, prvKey *ecdsa.PrivateKey) {
 	var err error
 	if len(path) == 0 {
 		utils.Fatalf("DB file is not specified")
@@ -72,6 +157,8 @@ func (s *WMailServer) Init(shh *whisper.Whisper, path string, password string, p
 
 	s.w = shh
 	s.pow = pow
+	s.prvKey = prvKey
+	s.pageSize = MaxPageSize
 
 	MailServerKeyID, err := s.w.AddSymKeyFromPassword(password)
 	if err != nil {
@@ -108,67 +195,115 @@ func (s *WMailServer) DeliverMail(peer *whisper.Peer, request *whisper.Envelope)
 		return
 	}
 
-	ok, lower, upper, topic := s.validateRequest(peer.ID(), request)
-	if ok {
-		s.processRequest(peer, lower, upper, topic)
+	ok, req := s.validateRequest(peer.ID(), request)
+	if !ok {
+		return
+	}
+	_, last, cursor, err := s.processRequest(peer, req)
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to deliver mail: %s", err))
+		return
 	}
+	s.sendResponse(peer, request, &MailResponse{
+		RequestHash:      request.Hash(),
+		LastEnvelopeHash: last,
+		Cursor:           cursor,
+	})
 }
 
-func (s *WMailServer) processRequest(peer *whisper.Peer, lower, upper uint32, topic whisper.TopicType) []*whisper.Envelope {
-	ret := make([]*whisper.Envelope, 0)
-	var err error
+// processRequest delivers a page of the envelopes matching the request and
+// returns the hash of the last one and the cursor of the next page. Without
+// a peer the envelopes are returned instead, for test purposes.
+func (s *WMailServer) processRequest(peer *whisper.Peer, req *MailRequest) (ret []*whisper.Envelope, last common.Hash, cursor []byte, err error) {
 	var zero common.Hash
-	var empty whisper.TopicType
-	kl := NewDbKey(lower, zero)
-	ku := NewDbKey(upper, zero)
-	i := s.db.NewIterator(&util.Range{Start: kl.raw, Limit: ku.raw}, nil)
+	start := NewDbKey(req.Lower, zero).raw
+	if len(req.Cursor) == DBKeyLength && bytes.Compare(req.Cursor, start) > 0 {
+		start = req.Cursor
+	}
+	ku := NewDbKey(req.Upper, zero)
+	i := s.db.NewIterator(&util.Range{Start: start, Limit: ku.raw}, nil)
 	defer i.Release()
 
+	limit := req.Limit
+	if limit == 0 || limit > s.pageSize {
+		limit = s.pageSize
+	}
+
+	var delivered uint32
 	for i.Next() {
 		var envelope whisper.Envelope
-		err = rlp.DecodeBytes(i.Value(), &envelope)
-		if err != nil {
+		if err := rlp.DecodeBytes(i.Value(), &envelope); err != nil {
 			log.Error(fmt.Sprintf("RLP decoding failed: %s", err))
+			continue
+		}
+		if !whisper.BloomFilterMatch(req.Bloom, envelope.Bloom()) {
+			continue
+		}
+		if limit > 0 && delivered == limit {
+			// the page is full, the next one starts at this envelope
+			cursor = common.CopyBytes(i.Key())
+			break
 		}
 
-		if topic == empty || envelope.Topic == topic {
-			if peer == nil {
-				// used for test purposes
-				ret = append(ret, &envelope)
-			} else {
-				err = s.w.SendP2PDirect(peer, &envelope)
-				if err != nil {
-					log.Error(fmt.Sprintf("Failed to send direct message to peer: %s", err))
-					return nil
-				}
-			}
+		if peer == nil {
+			// used for test purposes
+			ret = append(ret, &envelope)
+		} else if err := s.w.SendP2PDirect(peer, &envelope); err != nil {
+			return nil, zero, nil, fmt.Errorf("failed to send direct message to peer: %s", err)
 		}
+		delivered++
+		last = envelope.Hash()
 	}
 
-	err = i.Error()
-	if err != nil {
+	if err := i.Error(); err != nil {
 		log.Error(fmt.Sprintf("Level DB iterator error: %s", err))
 	}
 
-	return ret
+	return ret, last, cursor, nil
+}
+
+// sendResponse sends the signed response to a request to the peer.
+func (s *WMailServer) sendResponse(peer *whisper.Peer, request *whisper.Envelope, response *MailResponse) {
+	env, err := s.createResponse(request, response)
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to create mail server response: %s", err))
+		return
+	}
+	if err := s.w.SendP2PDirect(peer, env); err != nil {
+		log.Error(fmt.Sprintf("Failed to send mail server response to peer: %s", err))
+	}
+}
+
+func (s *WMailServer) createResponse(request *whisper.Envelope, response *MailResponse) (*whisper.Envelope, error) {
+	// p2p messages are not checked for PoW, no work needs to be done
+	params := &whisper.MessageParams{
+		KeySym:  s.key,
+		Topic:   request.Topic,
+		Payload: response.Payload(),
+		Src:     s.prvKey,
+	}
+	msg, err := whisper.NewSentMessage(params)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Wrap(params)
 }
 
-func (s *WMailServer) validateRequest(peerID []byte, request *whisper.Envelope) (bool, uint32, uint32, whisper.TopicType) {
-	var topic whisper.TopicType
+func (s *WMailServer) validateRequest(peerID []byte, request *whisper.Envelope) (bool, *MailRequest) {
 	if s.pow > 0.0 && request.PoW() < s.pow {
-		return false, 0, 0, topic
+		return false, nil
 	}
 
 	f := whisper.Filter{KeySym: s.key}
 	decrypted := request.Open(&f)
 	if decrypted == nil {
 		log.Warn(fmt.Sprintf("Failed to decrypt p2p request"))
-		return false, 0, 0, topic
+		return false, nil
 	}
 
 	if len(decrypted.Payload) < 8 {
 		log.Warn(fmt.Sprintf("Undersized p2p request"))
-		return false, 0, 0, topic
+		return false, nil
 	}
 
 	src := crypto.FromECDSAPub(decrypted.Src)
@@ -177,15 +312,30 @@ func (s *WMailServer) validateRequest(peerID []byte, request *whisper.Envelope)
 	}
 	if !bytes.Equal(peerID, src) {
 		log.Warn(fmt.Sprintf("Wrong signature of p2p request"))
-		return false, 0, 0, topic
+		return false, nil
 	}
 
-	lower := binary.BigEndian.Uint32(decrypted.Payload[:4])
-	upper := binary.BigEndian.Uint32(decrypted.Payload[4:8])
-
-	if len(decrypted.Payload) >= 8+whisper.TopicLength {
-		topic = whisper.BytesToTopic(decrypted.Payload[8:])
+	payload := decrypted.Payload
+	req := &MailRequest{
+		Lower: binary.BigEndian.Uint32(payload[:4]),
+		Upper: binary.BigEndian.Uint32(payload[4:8]),
+	}
+	switch {
+	case len(payload) == 8:
+		// all topics
+	case len(payload) == 8+whisper.TopicLength:
+		// the empty topic matches all topics
+		if topic := whisper.BytesToTopic(payload[8:]); topic != (whisper.TopicType{}) {
+			req.Bloom = whisper.TopicToBloom(topic)
+		}
+	case len(payload) == 8+whisper.BloomFilterSize+4 || len(payload) == 8+whisper.BloomFilterSize+4+DBKeyLength:
+		req.Bloom = common.CopyBytes(payload[8 : 8+whisper.BloomFilterSize])
+		req.Limit = binary.BigEndian.Uint32(payload[8+whisper.BloomFilterSize:])
+		req.Cursor = common.CopyBytes(payload[8+whisper.BloomFilterSize+4:])
+	default:
+		log.Warn(fmt.Sprintf("Invalid p2p request length %d", len(payload)))
+		return false, nil
 	}
 
-	return true, lower, upper, topic
+	return true, req
 }
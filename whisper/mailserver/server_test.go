@@ -17,16 +17,18 @@
 package mailserver
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"encoding/binary"
 	"io/ioutil"
 	"math/rand"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/MeshBoxFoundation/meshbox/common"
 	"github.com/MeshBoxFoundation/meshbox/crypto"
-	whisper "github.com/MeshBoxFoundation/meshbox/whisper/whisperv5"
+	whisper "github.com/MeshBoxFoundation/meshbox/whisper/whisperv6"
 )
 
 const powRequirement = 0.00001
@@ -57,11 +59,11 @@ func TestDBKey(t *testing.T) {
 	assert(byte(i/0x1000000) == k.raw[0], "big endian expected", t)
 }
 
-func generateEnvelope(t *testing.T) *whisper.Envelope {
+func generateEnvelope(t *testing.T, topic whisper.TopicType) *whisper.Envelope {
 	h := crypto.Keccak256Hash([]byte("test sample data"))
 	params := &whisper.MessageParams{
 		KeySym:   h[:],
-		Topic:    whisper.TopicType{},
+		Topic:    topic,
 		Payload:  []byte("test payload"),
 		PoW:      powRequirement,
 		WorkTime: 2,
@@ -78,7 +80,7 @@ func generateEnvelope(t *testing.T) *whisper.Envelope {
 	return env
 }
 
-func TestMailServer(t *testing.T) {
+func newTestServer(t *testing.T) (*WMailServer, func()) {
 	const password = "password_for_this_test"
 	const dbPath = "whisper-server-test"
 
@@ -86,23 +88,139 @@ func TestMailServer(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	serverKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	var server WMailServer
+	server := new(WMailServer)
 	shh = whisper.New(&whisper.DefaultConfig)
-	shh.RegisterServer(&server)
+	shh.RegisterServer(server)
 
-	server.Init(shh, dir, password, powRequirement)
-	defer server.Close()
+	server.Init(shh, dir, password, powRequirement, serverKey)
 
 	keyID, err = shh.AddSymKeyFromPassword(password)
 	if err != nil {
 		t.Fatalf("Failed to create symmetric key for mail request: %s", err)
 	}
+	return server, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestMailServer(t *testing.T) {
+	server, teardown := newTestServer(t)
+	defer teardown()
 
 	rand.Seed(seed)
-	env := generateEnvelope(t)
+	env := generateEnvelope(t, whisper.TopicType{})
 	server.Archive(env)
-	deliverTest(t, &server, env)
+	deliverTest(t, server, env)
+}
+
+func TestMailServerPagination(t *testing.T) {
+	server, teardown := newTestServer(t)
+	defer teardown()
+
+	// the bloom filter of the first two topics doesn't match the third
+	topics := []whisper.TopicType{{0x01}, {0x02}, {0x03}}
+	expected := make(map[common.Hash]bool)
+	for i := 0; i < 12; i++ {
+		env := generateEnvelope(t, topics[i%len(topics)])
+		server.Archive(env)
+		if i%len(topics) != 2 {
+			expected[env.Hash()] = true
+		}
+	}
+	bloom := whisper.TopicToBloom(topics[0])
+	for i, b := range whisper.TopicToBloom(topics[1]) {
+		bloom[i] |= b
+	}
+
+	req := &MailRequest{Lower: 0, Upper: 0xffffffff, Bloom: bloom, Limit: 3}
+	delivered := make(map[common.Hash]bool)
+	for pages := 1; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("too many pages, delivered %d of %d envelopes", len(delivered), len(expected))
+		}
+		p := &ServerTestParams{key: newPeerKey(t)}
+		request := createRequestWithPayload(t, p, req.Payload())
+		ok, parsed := server.validateRequest(crypto.FromECDSAPub(&p.key.PublicKey), request)
+		if !ok || parsed.Limit != req.Limit || !bytes.Equal(parsed.Bloom, req.Bloom) || !bytes.Equal(parsed.Cursor, req.Cursor) {
+			t.Fatalf("page %d: request validation failed: %+v", pages, parsed)
+		}
+
+		mail, last, cursor, err := server.processRequest(nil, parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only the last page is not full
+		if len(mail) > int(req.Limit) || (len(cursor) > 0 && len(mail) != int(req.Limit)) || len(mail) == 0 {
+			t.Fatalf("page %d: unexpected page size %d (cursor %x)", pages, len(mail), cursor)
+		}
+		for _, env := range mail {
+			if !expected[env.Hash()] || delivered[env.Hash()] {
+				t.Fatalf("page %d: unexpected envelope %x", pages, env.Hash())
+			}
+			delivered[env.Hash()] = true
+		}
+		if last != mail[len(mail)-1].Hash() {
+			t.Fatalf("page %d: wrong last envelope hash", pages)
+		}
+
+		// the response carries the cursor and is signed by the server
+		responseEnv, err := server.createResponse(request, &MailResponse{RequestHash: request.Hash(), LastEnvelopeHash: last, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := responseEnv.Open(&whisper.Filter{KeySym: server.key})
+		if msg == nil || !whisper.IsPubKeyEqual(msg.Src, &server.prvKey.PublicKey) {
+			t.Fatalf("page %d: response not signed by the server", pages)
+		}
+		response, err := ParseMailResponse(msg.Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.RequestHash != request.Hash() || response.LastEnvelopeHash != last || !bytes.Equal(response.Cursor, cursor) {
+			t.Fatalf("page %d: unexpected response %+v", pages, response)
+		}
+
+		if len(response.Cursor) == 0 {
+			break
+		}
+		req.Cursor = response.Cursor
+	}
+	if len(delivered) != len(expected) {
+		t.Fatalf("delivered %d of %d envelopes", len(delivered), len(expected))
+	}
+}
+
+func TestMailServerMaxPageSize(t *testing.T) {
+	server, teardown := newTestServer(t)
+	defer teardown()
+	server.pageSize = 4
+
+	for i := 0; i < 10; i++ {
+		server.Archive(generateEnvelope(t, whisper.TopicType{}))
+	}
+	for limit, size := range map[uint32]int{0: 4, 2: 2, 4: 4, 100: 4} {
+		mail, _, cursor, err := server.processRequest(nil, &MailRequest{Lower: 0, Upper: 0xffffffff, Limit: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mail) != size || len(cursor) != DBKeyLength {
+			t.Errorf("limit %d: unexpected page size %d (cursor %x), want %d", limit, len(mail), cursor, size)
+		}
+	}
+}
+
+func newPeerKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
 }
 
 func deliverTest(t *testing.T, server *WMailServer, env *whisper.Envelope) {
@@ -131,29 +249,37 @@ func deliverTest(t *testing.T, server *WMailServer, env *whisper.Envelope) {
 
 	p.low = birth - 1
 	p.upp = birth + 1
-	p.topic[0]++
+	// a topic the bloom filter of which doesn't match the envelope's
+	p.topic = whisper.TopicType{0x01, 0x01, 0x01, 0x00}
 	singleRequest(t, server, env, p, false)
 }
 
 func singleRequest(t *testing.T, server *WMailServer, env *whisper.Envelope, p *ServerTestParams, expect bool) {
 	request := createRequest(t, p)
 	src := crypto.FromECDSAPub(&p.key.PublicKey)
-	ok, lower, upper, topic := server.validateRequest(src, request)
+	ok, req := server.validateRequest(src, request)
 	if !ok {
 		t.Fatalf("request validation failed, seed: %d.", seed)
 	}
-	if lower != p.low {
+	if req.Lower != p.low {
 		t.Fatalf("request validation failed (lower bound), seed: %d.", seed)
 	}
-	if upper != p.upp {
+	if req.Upper != p.upp {
 		t.Fatalf("request validation failed (upper bound), seed: %d.", seed)
 	}
-	if topic != p.topic {
+	var bloom []byte
+	if p.topic != (whisper.TopicType{}) {
+		bloom = whisper.TopicToBloom(p.topic)
+	}
+	if !bytes.Equal(req.Bloom, bloom) {
 		t.Fatalf("request validation failed (topic), seed: %d.", seed)
 	}
 
 	var exist bool
-	mail := server.processRequest(nil, p.low, p.upp, p.topic)
+	mail, _, _, err := server.processRequest(nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for _, msg := range mail {
 		if msg.Hash() == env.Hash() {
 			exist = true
@@ -166,18 +292,23 @@ func singleRequest(t *testing.T, server *WMailServer, env *whisper.Envelope, p *
 	}
 
 	src[0]++
-	ok, lower, upper, topic = server.validateRequest(src, request)
+	ok, req = server.validateRequest(src, request)
 	if ok {
-		t.Fatalf("request validation false positive, seed: %d (lower: %d, upper: %d).", seed, lower, upper)
+		t.Fatalf("request validation false positive, seed: %d (lower: %d, upper: %d).", seed, req.Lower, req.Upper)
 	}
 }
 
+// createRequest creates a request with a single topic, as sent by the older
+// clients
 func createRequest(t *testing.T, p *ServerTestParams) *whisper.Envelope {
 	data := make([]byte, 8+whisper.TopicLength)
 	binary.BigEndian.PutUint32(data, p.low)
 	binary.BigEndian.PutUint32(data[4:], p.upp)
 	copy(data[8:], p.topic[:])
+	return createRequestWithPayload(t, p, data)
+}
 
+func createRequestWithPayload(t *testing.T, p *ServerTestParams, data []byte) *whisper.Envelope {
 	key, err := shh.GetSymKey(keyID)
 	if err != nil {
 		t.Fatalf("failed to retrieve sym key with seed %d: %s.", seed, err)
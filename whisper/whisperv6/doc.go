@@ -53,6 +53,7 @@ const (
 	signatureFlag = byte(4)
 
 	TopicLength     = 4
+	BloomFilterSize = 64
 	signatureLength = 65
 	aesKeyLength    = 32
 	AESNonceLength  = 12
@@ -52,6 +52,11 @@ func (e *Envelope) size() int {
 	return EnvelopeHeaderLength + len(e.Data)
 }
 
+// Bloom returns the bloom filter matching the topic of the envelope.
+func (e *Envelope) Bloom() []byte {
+	return TopicToBloom(e.Topic)
+}
+
 // rlpWithoutNonce returns the RLP encoded envelope contents, except the nonce.
 func (e *Envelope) rlpWithoutNonce() []byte {
 	res, _ := rlp.EncodeToBytes([]interface{}{e.Expiry, e.TTL, e.Topic, e.Data})
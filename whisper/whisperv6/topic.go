@@ -53,3 +53,41 @@ func (t TopicType) MarshalText() ([]byte, error) {
 func (t *TopicType) UnmarshalText(input []byte) error {
 	return hexutil.UnmarshalFixedText("Topic", input, t[:])
 }
+
+// TopicToBloom converts the topic (4 bytes) to the bloom filter (64 bytes)
+// matching it. The first three bytes of the topic, extended to 9 bits by the
+// low bits of the last byte, select the three bits set in the filter.
+func TopicToBloom(topic TopicType) []byte {
+	b := make([]byte, BloomFilterSize)
+	var index [3]int
+	for j := 0; j < 3; j++ {
+		index[j] = int(topic[j])
+		if (topic[3] & (1 << uint(j))) != 0 {
+			index[j] += 256
+		}
+	}
+
+	for j := 0; j < 3; j++ {
+		byteIndex := index[j] / 8
+		bitIndex := index[j] % 8
+		b[byteIndex] |= (1 << uint(bitIndex))
+	}
+	return b
+}
+
+// BloomFilterMatch returns whether all the bits set in the sample are set in
+// the filter, a nil filter matches everything.
+func BloomFilterMatch(filter, sample []byte) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter) != BloomFilterSize || len(sample) != BloomFilterSize {
+		return false
+	}
+	for i := 0; i < BloomFilterSize; i++ {
+		if filter[i]|sample[i] != filter[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -132,3 +132,42 @@ func TestUnmarshalTestsUgly(t *testing.T) {
 		}
 	}
 }
+
+func TestTopicToBloom(t *testing.T) {
+	var filter []byte
+	topics := []TopicType{{0x00, 0x00, 0x00, 0x00}, {0x01, 0x02, 0x03, 0x07}, {0xff, 0x80, 0x7f, 0x05}}
+	for i, topic := range topics {
+		b := TopicToBloom(topic)
+		bits := 0
+		for _, x := range b {
+			for ; x != 0; x &= x - 1 {
+				bits++
+			}
+		}
+		// the three bit indices may coincide
+		if bits == 0 || bits > 3 {
+			t.Fatalf("topic %d: %d bits set in the bloom filter", i, bits)
+		}
+		if !BloomFilterMatch(b, b) {
+			t.Fatalf("topic %d: bloom filter does not match itself", i)
+		}
+		if filter == nil {
+			filter = b
+		} else {
+			for j := range filter {
+				filter[j] |= b[j]
+			}
+		}
+	}
+	for i, topic := range topics {
+		if !BloomFilterMatch(filter, TopicToBloom(topic)) {
+			t.Fatalf("topic %d not matched by the combined bloom filter", i)
+		}
+	}
+	if BloomFilterMatch(TopicToBloom(topics[0]), TopicToBloom(topics[1])) {
+		t.Fatal("bloom filter matched a different topic")
+	}
+	if !BloomFilterMatch(nil, TopicToBloom(topics[2])) {
+		t.Fatal("nil bloom filter must match everything")
+	}
+}